@@ -0,0 +1,235 @@
+package backtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"crypto_bot/pkg/backtest"
+	"crypto_bot/pkg/exchange/dbased"
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage"
+	"crypto_bot/pkg/storage/backend"
+	"crypto_bot/pkg/storage/memory"
+	"crypto_bot/pkg/storage/pgdb"
+	"crypto_bot/pkg/strategies"
+)
+
+const timeLayout = "2006-01-02_15:04:05"
+
+var (
+	Flags = struct {
+		ConnStr    string
+		Symbols    []string
+		Intervals  []string
+		From       string
+		To         string
+		Strategy   string
+		Params     map[string]string
+		Balances   map[string]string
+		QuoteAsset string
+		MakerFee   float64
+		TakerFee   float64
+		User       string
+		Report     string
+	}{}
+
+	RootCmd = &cobra.Command{
+		Use:   "backtest",
+		Short: "Run a strategy over stored klines and print its performance",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			from, err := time.Parse(timeLayout, Flags.From)
+			if err != nil {
+				return fmt.Errorf("parse from: %s", err)
+			}
+			to := time.Now()
+			if Flags.To != "" {
+				if to, err = time.Parse(timeLayout, Flags.To); err != nil {
+					return fmt.Errorf("parse to: %s", err)
+				}
+			}
+
+			strategy, err := strategies.New(Flags.Strategy, Flags.Params)
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+
+			interrupt := make(chan os.Signal, 1)
+			signal.Notify(interrupt, os.Interrupt)
+			signal.Notify(interrupt, os.Kill)
+			go func() { <-interrupt; log.Println("keyboard interruption"); cancel() }()
+
+			db, err := backend.Open(ctx, Flags.ConnStr, 0)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			// Users are kept in memory for the run without Postgres.
+			var users userStorage = memory.NewAccounts()
+			if db.Postgres != nil {
+				users = db.Postgres
+			} else if Flags.User != "" {
+				return fmt.Errorf("user requires a pg db")
+			}
+
+			login := Flags.User
+			if login == "" {
+				if login, err = createUser(ctx, users); err != nil {
+					return err
+				}
+				defer deleteUser(users, login)
+			}
+
+			s := struct {
+				dbased.Accounts
+				storage.KlineRepository
+			}{users, db.Klines}
+			ex, err := dbased.NewClient(ctx, s, login, from.UnixMilli())
+			if err != nil {
+				return fmt.Errorf("create exchange: %w", err)
+			}
+			ex.SetCommissionRates(Flags.MakerFee, Flags.TakerFee)
+
+			var streams []models.WsKlineRequest
+			for _, s := range Flags.Symbols {
+				symbol, err := models.ParseSymbol(s)
+				if err != nil {
+					return err
+				}
+				for _, i := range Flags.Intervals {
+					interval, err := models.ParseInterval(i)
+					if err != nil {
+						return err
+					}
+					streams = append(streams, models.WsKlineRequest{Symbol: string(symbol), Interval: string(interval)})
+				}
+			}
+
+			log.Printf("Running %s over %v from %s to %s", Flags.Strategy, streams, from, to)
+			res, err := backtest.
+				NewEngine(ex, strategy).
+				SetStreams(streams...).
+				SetPeriod(from.UnixMilli(), to.UnixMilli()).
+				SetQuoteAsset(Flags.QuoteAsset).
+				Run(ctx)
+			if err != nil {
+				return err
+			}
+
+			summary := res.Summary()
+			printSummary(summary, res.QuoteAsset)
+			if Flags.Report != "" {
+				return writeReport(Flags.Report, summary, res)
+			}
+			return nil
+		},
+	}
+)
+
+func init() {
+	flags := RootCmd.Flags()
+	flags.StringVar(&Flags.ConnStr, "conn-str", "", "pg db connection string, sqlite:<path> or memory:")
+	flags.StringSliceVar(&Flags.Symbols, "symbol", []string{"BTCUSDT"}, "symbols to replay")
+	flags.StringSliceVar(&Flags.Intervals, "interval", []string{"1m"}, "intervals to replay")
+	flags.StringVar(&Flags.From, "from", "2017-08-17_4:00:00", "to replay klines from date")
+	flags.StringVar(&Flags.To, "to", "", "to replay klines to date")
+	flags.StringVar(&Flags.Strategy, "strategy", "buy-and-hold",
+		fmt.Sprintf("strategy to run, one of %s", strings.Join(strategies.Names(), ", ")))
+	flags.StringToStringVar(&Flags.Params, "param", nil, "strategy params, e.g. --param fast=10,slow=30")
+	flags.StringToStringVar(&Flags.Balances, "balance", map[string]string{"USDT": "10000"},
+		"initial balances of a new user, e.g. --balance USDT=10000,BTC=1")
+	flags.StringVar(&Flags.QuoteAsset, "quote-asset", "USDT", "asset to measure equity and fees in")
+	flags.Float64Var(&Flags.MakerFee, "maker-fee", dbased.DefaultCommissionRate, "maker commission rate")
+	flags.Float64Var(&Flags.TakerFee, "taker-fee", dbased.DefaultCommissionRate, "taker commission rate")
+	flags.StringVar(&Flags.User, "user", "", "existing user to trade as, a temporary one is created if empty")
+	flags.StringVar(&Flags.Report, "report", "", "path to write a json report to")
+
+	if err := cobra.MarkFlagRequired(flags, "conn-str"); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// userStorage keeps the users of backtests with their orders and balances.
+type userStorage interface {
+	dbased.Accounts
+	CreateUser(context.Context, pgdb.CreateUserRequest) (*pgdb.User, error)
+	CreateBalance(context.Context, pgdb.CreateBalanceRequest) (*pgdb.Balance, error)
+	DeleteUser(context.Context, pgdb.DeleteUserRequest) error
+}
+
+func createUser(ctx context.Context, db userStorage) (string, error) {
+	login := fmt.Sprintf("backtest_%d", time.Now().UnixNano())
+	user, err := db.CreateUser(ctx, pgdb.CreateUserRequest{Login: login})
+	if err != nil {
+		return "", fmt.Errorf("create user: %w", err)
+	}
+	for asset, amount := range Flags.Balances {
+		free, err := strconv.ParseFloat(amount, 64)
+		if err != nil {
+			return "", fmt.Errorf("parse %s balance: %w", asset, err)
+		}
+		_, err = db.CreateBalance(ctx, pgdb.CreateBalanceRequest{UserUID: user.UID, Asset: strings.ToUpper(asset), Free: free})
+		if err != nil {
+			return "", fmt.Errorf("create %s balance: %w", asset, err)
+		}
+	}
+	return login, nil
+}
+
+// deleteUser removes the temporary user with its orders and balances.
+func deleteUser(db userStorage, login string) {
+	ctx := context.Background()
+	user, err := db.ReadUser(ctx, pgdb.ReadUserRequest{Login: login})
+	if err == nil {
+		err = db.DeleteUser(ctx, pgdb.DeleteUserRequest{UID: user.UID})
+	}
+	if err != nil {
+		log.Printf("ERROR: delete user %s: %s", login, err)
+	}
+}
+
+func printSummary(s backtest.Summary, quoteAsset string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Start equity\t%.2f %s\n", s.StartEquity, quoteAsset)
+	fmt.Fprintf(w, "End equity\t%.2f %s\n", s.EndEquity, quoteAsset)
+	fmt.Fprintf(w, "Total return\t%.2f%%\n", s.TotalReturn*100)
+	fmt.Fprintf(w, "CAGR\t%.2f%%\n", s.CAGR*100)
+	fmt.Fprintf(w, "Sharpe\t%.3f\n", s.Sharpe)
+	fmt.Fprintf(w, "Sortino\t%.3f\n", s.Sortino)
+	fmt.Fprintf(w, "Max drawdown\t%.2f%%\n", s.MaxDrawdown*100)
+	fmt.Fprintf(w, "Win rate\t%.2f%%\n", s.WinRate*100)
+	fmt.Fprintf(w, "Trades\t%d\n", s.Trades)
+	fmt.Fprintf(w, "Fees\t%.2f %s\n", s.Fees, quoteAsset)
+	w.Flush()
+}
+
+func writeReport(path string, s backtest.Summary, res *backtest.Result) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create report: %w", err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(struct {
+		Summary backtest.Summary `json:"summary"`
+		*backtest.Result
+	}{Summary: s, Result: res})
+	if err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+	return nil
+}
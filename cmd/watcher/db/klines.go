@@ -0,0 +1,37 @@
+package db
+
+import (
+	"context"
+	"log"
+
+	"github.com/spf13/cobra"
+
+	"crypto_bot/pkg/storage/pgdb"
+)
+
+var (
+	MoveKlinesFlags = struct {
+		Drop bool
+	}{}
+
+	MoveKlinesCmd = &cobra.Command{
+		Use:   "move-klines",
+		Short: "Move klines of kline_<symbol>_<interval> tables into the klines table",
+		Long: "Move klines of kline_<symbol>_<interval> tables into the klines table of the hypertable layout. " +
+			"Connect with kline_layout=hypertable in the connection string afterwards to use it.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withClient(func(ctx context.Context, db *pgdb.Client) error {
+				moved, err := db.MoveKlineTables(ctx, pgdb.MoveKlineTablesRequest{Drop: MoveKlinesFlags.Drop})
+				for _, m := range moved {
+					log.Printf("moved %s: %d klines of %s %s", m.Table, m.Klines, m.Symbol, m.Interval)
+				}
+				return err
+			})
+		},
+	}
+)
+
+func init() {
+	MoveKlinesCmd.Flags().BoolVar(&MoveKlinesFlags.Drop, "drop", false, "drop every table once its klines are moved")
+}
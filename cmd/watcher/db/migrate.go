@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"crypto_bot/pkg/storage/pgdb"
+)
+
+var (
+	MigrateFlags = struct {
+		Version int
+		Steps   int
+	}{}
+
+	MigrateCmd = &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply, revert and list schema migrations",
+	}
+
+	MigrateUpCmd = &cobra.Command{
+		Use:   "up",
+		Short: "Apply migrations that are not applied yet",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withClient(func(ctx context.Context, db *pgdb.Client) error {
+				applied, err := db.MigrateUp(ctx, pgdb.MigrateUpRequest{Version: MigrateFlags.Version})
+				for _, m := range applied {
+					log.Printf("applied %d %s", m.Version, m.Description)
+				}
+				if err == nil && len(applied) == 0 {
+					log.Println("schema is up to date")
+				}
+				return err
+			})
+		},
+	}
+
+	MigrateDownCmd = &cobra.Command{
+		Use:   "down",
+		Short: "Revert the latest applied migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withClient(func(ctx context.Context, db *pgdb.Client) error {
+				reverted, err := db.MigrateDown(ctx, pgdb.MigrateDownRequest{Steps: MigrateFlags.Steps})
+				for _, m := range reverted {
+					log.Printf("reverted %d %s", m.Version, m.Description)
+				}
+				return err
+			})
+		},
+	}
+
+	MigrateStatusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "List migrations and when they were applied",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withClient(func(ctx context.Context, db *pgdb.Client) error {
+				statuses, err := db.MigrationsStatus(ctx)
+				if err != nil {
+					return err
+				}
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "VERSION\tDESCRIPTION\tAPPLIED AT")
+				for _, s := range statuses {
+					appliedAt := "pending"
+					if !s.AppliedAt.IsZero() {
+						appliedAt = s.AppliedAt.Format(time.RFC3339)
+					}
+					fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Description, appliedAt)
+				}
+				return w.Flush()
+			})
+		},
+	}
+
+	MigrateBaselineCmd = &cobra.Command{
+		Use:   "baseline <version>",
+		Short: "Mark migrations up to the version applied without running them",
+		Long: "Mark migrations up to the version applied without running them. " +
+			"Use it once for a database whose schema was created before migrations were tracked.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("parse version: %w", err)
+			}
+			return withClient(func(ctx context.Context, db *pgdb.Client) error {
+				return db.Baseline(ctx, pgdb.BaselineRequest{Version: version})
+			})
+		},
+	}
+)
+
+func init() {
+	MigrateUpCmd.Flags().IntVar(&MigrateFlags.Version, "version", 0, "version to migrate up to, 0 applies all migrations")
+	MigrateDownCmd.Flags().IntVar(&MigrateFlags.Steps, "steps", 1, "number of migrations to revert")
+
+	MigrateCmd.AddCommand(MigrateUpCmd)
+	MigrateCmd.AddCommand(MigrateDownCmd)
+	MigrateCmd.AddCommand(MigrateStatusCmd)
+	MigrateCmd.AddCommand(MigrateBaselineCmd)
+}
+
+func withClient(f func(context.Context, *pgdb.Client) error) error {
+	ctx := context.Background()
+	db, err := pgdb.Connect(ctx, Flags.ConnStr, 1)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return f(ctx, db)
+}
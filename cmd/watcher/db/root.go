@@ -0,0 +1,29 @@
+package db
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	Flags = struct {
+		ConnStr string
+	}{}
+
+	RootCmd = &cobra.Command{
+		Use:   "db",
+		Short: "Commands for managing the database",
+	}
+)
+
+func init() {
+	flags := RootCmd.PersistentFlags()
+	flags.StringVar(&Flags.ConnStr, "conn-str", "", "pg db connection string")
+	if err := cobra.MarkFlagRequired(flags, "conn-str"); err != nil {
+		log.Fatal(err)
+	}
+
+	RootCmd.AddCommand(MigrateCmd)
+	RootCmd.AddCommand(MoveKlinesCmd)
+}
@@ -0,0 +1,128 @@
+package kline
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"crypto_bot/pkg/helpers/gapfixer"
+	"crypto_bot/pkg/storage/backend"
+)
+
+const (
+	auditTable = "table"
+	auditCSV   = "csv"
+	auditJSON  = "json"
+)
+
+var (
+	AuditFlags = struct {
+		ConnStr   string
+		Symbols   []string
+		Intervals []string
+		Pairs     []string
+		From      string
+		To        string
+		ChunkSize int
+		Format    string
+	}{}
+
+	AuditCmd = &cobra.Command{
+		Use:   "audit",
+		Short: "Report missing klines in db without asking the exchange",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch AuditFlags.Format {
+			case auditTable, auditCSV, auditJSON:
+			default:
+				return fmt.Errorf("unknown format %q, expected table, csv or json", AuditFlags.Format)
+			}
+			pairs, err := klinePairs(cmd, AuditFlags.Symbols, AuditFlags.Intervals, AuditFlags.Pairs)
+			if err != nil {
+				return err
+			}
+			from, to, err := timeRange(AuditFlags.From, AuditFlags.To)
+			if err != nil {
+				return err
+			}
+
+			ctx := context.Background()
+			db, err := backend.Open(ctx, AuditFlags.ConnStr, 0)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			audits, err := gapfixer.Audit(ctx, db.Klines, emptyRanges(db), gapfixer.AuditRequest{
+				Pairs:     pairs,
+				From:      from,
+				To:        to,
+				ChunkSize: AuditFlags.ChunkSize,
+			})
+			if err != nil {
+				return err
+			}
+			return writeAudits(os.Stdout, AuditFlags.Format, audits)
+		},
+	}
+)
+
+func init() {
+	flags := AuditCmd.Flags()
+	flags.StringVar(&AuditFlags.ConnStr, "conn-str", "", "pg db connection string, sqlite:<path> or memory:")
+	flags.StringSliceVar(&AuditFlags.Symbols, "symbol", []string{"BTCUSDT"}, "symbols to audit with every interval")
+	flags.StringSliceVar(&AuditFlags.Intervals, "interval", []string{"1m"}, "intervals to audit every symbol with")
+	flags.StringSliceVar(&AuditFlags.Pairs, "pair", nil, "symbol:interval pairs to audit, replace the default symbol and interval")
+	flags.StringVar(&AuditFlags.From, "from", "2017-08-17_4:00:00", "to audit from date")
+	flags.StringVar(&AuditFlags.To, "to", "", "to audit to date")
+	flags.IntVar(&AuditFlags.ChunkSize, "chunk-size", 1000, "klines to read from db at once")
+	flags.StringVar(&AuditFlags.Format, "format", auditTable, "output format: table, csv or json")
+}
+
+// writeAudits writes a coverage summary and the missing ranges of every pair
+// as a table, missing ranges alone as csv or everything as json.
+func writeAudits(out io.Writer, format string, audits []*gapfixer.PairAudit) error {
+	switch format {
+	case auditCSV:
+		w := csv.NewWriter(out)
+		_ = w.Write([]string{"symbol", "interval", "start", "end", "klines", "exchange_empty"})
+		for _, a := range audits {
+			for _, r := range a.Ranges {
+				_ = w.Write([]string{a.Symbol, a.Interval, formatMs(r.Start, time.RFC3339), formatMs(r.End, time.RFC3339),
+					strconv.FormatInt(r.Klines, 10), strconv.FormatBool(r.ExchangeEmpty)})
+			}
+		}
+		w.Flush()
+		return w.Error()
+	case auditJSON:
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(audits)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SYMBOL\tINTERVAL\tEXPECTED\tMISSING\tEXCHANGE EMPTY\tCOVERAGE")
+	for _, a := range audits {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%.2f%%\n", a.Symbol, a.Interval, a.Expected, a.Missing, a.ExchangeEmpty, a.Coverage())
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "SYMBOL\tINTERVAL\tSTART\tEND\tKLINES\tEXCHANGE EMPTY")
+	for _, a := range audits {
+		for _, r := range a.Ranges {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%t\n", a.Symbol, a.Interval,
+				formatMs(r.Start, timeLayout), formatMs(r.End, timeLayout), r.Klines, r.ExchangeEmpty)
+		}
+	}
+	return w.Flush()
+}
+
+func formatMs(ms int64, layout string) string {
+	return time.UnixMilli(ms).UTC().Format(layout)
+}
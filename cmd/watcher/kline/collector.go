@@ -2,30 +2,63 @@ package kline
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"time"
 
-	"github.com/jackc/pgx/v5"
 	"github.com/spf13/cobra"
 
 	"crypto_bot/pkg/exchange/binance"
-	"crypto_bot/pkg/storage/pgdb"
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/helpers/resampler"
+	"crypto_bot/pkg/storage/backend"
 	"crypto_bot/pkg/watcher/kline"
 )
 
 var (
 	CollectorFlags = struct {
-		ConnStr   string
-		Symbol    string
-		Interval  string
-		ChunkSize int
-		Debug     bool
+		ConnStr      string
+		MaxConns     int32
+		CheckSymbols bool
+		Symbols      []string
+		Intervals    []string
+		Pairs        []string
+		ChunkSize    int
+		Debug        bool
+		Resample     []string
+
+		FlushInterval time.Duration
+		MetricsAddr   string
+
+		ReconnectMin time.Duration
+		ReconnectMax time.Duration
 	}{}
 	CollectCmd = &cobra.Command{
 		Use:   "collect",
 		Short: "Save new klines from exchange to db",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			pairs, err := parsePairs(CollectorFlags.Pairs)
+			if err != nil {
+				return err
+			}
+			symbols, err := parseSymbols(CollectorFlags.Symbols)
+			if err != nil {
+				return err
+			}
+			intervals := CollectorFlags.Intervals
+			for _, interval := range intervals {
+				if _, err = models.ParseInterval(interval); err != nil {
+					return err
+				}
+			}
+			if len(pairs) > 0 && !cmd.Flags().Changed("symbol") && !cmd.Flags().Changed("interval") {
+				symbols, intervals = nil, nil
+			}
+
 			ctx, cancel := context.WithCancel(context.Background())
 
 			interrupt := make(chan os.Signal, 1)
@@ -35,25 +68,58 @@ var (
 
 			c := binance.NewClient("", "")
 
-			conn, err := pgx.Connect(ctx, CollectorFlags.ConnStr)
+			db, err := backend.Open(ctx, CollectorFlags.ConnStr, CollectorFlags.MaxConns)
 			if err != nil {
 				return err
 			}
-			db := pgdb.NewClient(conn)
+			defer db.Close()
+
+			var storage kline.Storage = db.Klines
+			if len(CollectorFlags.Resample) > 0 {
+				targets, err := parseIntervals(CollectorFlags.Resample)
+				if err != nil {
+					return err
+				}
+				if err = db.CheckWritable(intervalStrings(targets)...); err != nil {
+					return fmt.Errorf("resample target %w", err)
+				}
+				r, err := resampler.NewResampler(db.Klines, models.Interval1m, targets...)
+				if err != nil {
+					return err
+				}
+				storage = resampler.NewResamplingStorage(db.Klines, r).SetErrorHandler(func(err error) {
+					log.Printf("ERROR: %s", err)
+				})
+			}
 
-			log.Printf("Starting watcher %s interval=%s chunk-size=%d",
-				CollectorFlags.Symbol, CollectorFlags.Interval, CollectorFlags.ChunkSize)
 			watcher := kline.
-				NewWatcher(c, db).
-				SetSymbols(CollectorFlags.Symbol).
-				SetInterval(CollectorFlags.Interval).
+				NewWatcher(c, storage).
+				SetSymbols(symbols...).
+				SetInterval(intervals...).
+				SetPairs(pairs...).
 				SetChunkSize(CollectorFlags.ChunkSize).
+				SetFlushInterval(CollectorFlags.FlushInterval).
+				SetReconnectBackoff(CollectorFlags.ReconnectMin, CollectorFlags.ReconnectMax).
 				SetErrorHandler(func(err error) {
 					log.Printf("ERROR: %s", err)
 				}).
 				SetDebug(CollectorFlags.Debug)
+			if err = checkWritable(db, watcher.Pairs()); err != nil {
+				return err
+			}
+			if CollectorFlags.CheckSymbols {
+				if err = checkSymbols(ctx, c, watcher.Pairs()); err != nil {
+					return err
+				}
+			}
+			log.Printf("Starting watcher pairs=%v chunk-size=%d", watcher.Pairs(), CollectorFlags.ChunkSize)
+
+			if CollectorFlags.MetricsAddr != "" {
+				// The watcher publishes its metrics with expvar at /debug/vars.
+				go func() { log.Printf("metrics server: %s", http.ListenAndServe(CollectorFlags.MetricsAddr, nil)) }()
+			}
 
-			if err = watcher.Start(ctx); err != nil {
+			if err = watcher.Start(ctx); err != nil && err != context.Canceled {
 				return err
 			}
 			return nil
@@ -63,13 +129,68 @@ var (
 
 func init() {
 	flags := CollectCmd.Flags()
-	flags.StringVar(&CollectorFlags.ConnStr, "conn-str", "", "connection string")
-	flags.StringVar(&CollectorFlags.Symbol, "symbol", "BTCUSDT", "symbol to watch")
-	flags.StringVar(&CollectorFlags.Interval, "interval", "1m", "interval of symbol to watch")
+	flags.StringVar(&CollectorFlags.ConnStr, "conn-str", "", "pg db connection string, sqlite:<path> or memory:")
+	flags.Int32Var(&CollectorFlags.MaxConns, "max-conns", 0, "max db connections, 0 uses pool_max_conns of conn-str or the default")
+	flags.StringSliceVar(&CollectorFlags.Symbols, "symbol", []string{"BTCUSDT"}, "symbols to watch with every interval")
+	flags.StringSliceVar(&CollectorFlags.Intervals, "interval", []string{"1m"}, "intervals to watch every symbol with")
+	flags.BoolVar(&CollectorFlags.CheckSymbols, "check-symbols", false, "check symbols are trading on the exchange before watching")
+	flags.StringSliceVar(&CollectorFlags.Pairs, "pair", nil, "symbol:interval pairs to watch, replace the default symbol and interval")
 	flags.IntVar(&CollectorFlags.ChunkSize, "chunk-size", 50, "chunk size to write to db")
-	flags.BoolVarP(&CollectorFlags.Debug, "debug", "v", false, "chunk size to write to db")
+	flags.DurationVar(&CollectorFlags.FlushInterval, "flush-interval", time.Minute, "max time klines stay buffered, 0 waits for a full chunk")
+	flags.StringVar(&CollectorFlags.MetricsAddr, "metrics-addr", "", "address to serve metrics at /debug/vars, disabled if empty")
+	flags.DurationVar(&CollectorFlags.ReconnectMin, "reconnect-min", time.Second, "delay before the first reconnect")
+	flags.DurationVar(&CollectorFlags.ReconnectMax, "reconnect-max", time.Minute, "max delay between reconnects")
+	flags.StringSliceVar(&CollectorFlags.Resample, "resample", nil, "intervals to build from 1m klines as they are written, like 5m,15m,1h,4h,1d")
+	flags.BoolVarP(&CollectorFlags.Debug, "debug", "v", false, "log written chunks and backfills")
 
 	if err := cobra.MarkFlagRequired(flags, "conn-str"); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// parsePairs parses pairs in the symbol:interval form.
+func parsePairs(pairs []string) ([]models.WsKlineRequest, error) {
+	res := make([]models.WsKlineRequest, 0, len(pairs))
+	for _, p := range pairs {
+		s, i, ok := strings.Cut(p, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid pair %q, expected symbol:interval", p)
+		}
+		symbol, err := models.ParseSymbol(s)
+		if err != nil {
+			return nil, fmt.Errorf("pair %q: %w", p, err)
+		}
+		interval, err := models.ParseInterval(i)
+		if err != nil {
+			return nil, fmt.Errorf("pair %q: %w", p, err)
+		}
+		res = append(res, models.WsKlineRequest{Symbol: string(symbol), Interval: string(interval)})
+	}
+	return res, nil
+}
+
+func parseSymbols(symbols []string) ([]string, error) {
+	res := make([]string, len(symbols))
+	for i, s := range symbols {
+		symbol, err := models.ParseSymbol(s)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = string(symbol)
+	}
+	return res, nil
+}
+
+// checkSymbols checks the symbols of pairs are trading on the exchange.
+func checkSymbols(ctx context.Context, c *binance.Client, pairs []models.WsKlineRequest) error {
+	known, err := c.Symbols(ctx)
+	if err != nil {
+		return fmt.Errorf("read exchange symbols: %w", err)
+	}
+	for _, p := range pairs {
+		if err = models.Symbol(p.Symbol).ValidateIn(known); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package kline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/helpers/exporter"
+	"crypto_bot/pkg/storage/backend"
+)
+
+var (
+	ExportFlags = struct {
+		ConnStr     string
+		Symbol      string
+		Interval    string
+		From        string
+		To          string
+		Format      string
+		Compression string
+		Out         string
+		PageSize    int
+	}{}
+
+	ExportCmd = &cobra.Command{
+		Use:   "export",
+		Short: "Export klines from db to csv, ndjson or parquet",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			symbol, err := models.ParseSymbol(ExportFlags.Symbol)
+			if err != nil {
+				return err
+			}
+			interval, err := models.ParseInterval(ExportFlags.Interval)
+			if err != nil {
+				return err
+			}
+			format, err := exporter.ParseFormat(ExportFlags.Format)
+			if err != nil {
+				return err
+			}
+			compression, err := exporter.ParseCompression(ExportFlags.Compression)
+			if err != nil {
+				return err
+			}
+			var from, to time.Time
+			if ExportFlags.From != "" {
+				if from, err = time.Parse(timeLayout, ExportFlags.From); err != nil {
+					return fmt.Errorf("parse from: %s", err)
+				}
+			}
+			if ExportFlags.To != "" {
+				if to, err = time.Parse(timeLayout, ExportFlags.To); err != nil {
+					return fmt.Errorf("parse to: %s", err)
+				}
+			}
+
+			ctx := context.Background()
+			db, err := backend.Open(ctx, ExportFlags.ConnStr, 0)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			var (
+				out      io.Writer = os.Stdout
+				closeOut           = func() error { return nil }
+			)
+			if ExportFlags.Out != "-" {
+				path := ExportFlags.Out
+				if path == "" {
+					path = fmt.Sprintf("%s-%s%s", symbol, interval, exporter.Ext(format, compression))
+				}
+				f, err := os.Create(path)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				out, closeOut = f, f.Close
+				log.Printf("Exporting to %s", path)
+			}
+
+			n, err := exporter.NewExporter(db.Klines).
+				SetPageSize(ExportFlags.PageSize).
+				Export(ctx, out, exporter.ExportRequest{
+					Symbol:      string(symbol),
+					Interval:    string(interval),
+					From:        from,
+					To:          to,
+					Format:      format,
+					Compression: compression,
+				})
+			if err != nil {
+				return err
+			}
+			if err = closeOut(); err != nil {
+				return err
+			}
+			log.Printf("Exported %d klines", n)
+			return nil
+		},
+	}
+)
+
+func init() {
+	flags := ExportCmd.Flags()
+	flags.StringVar(&ExportFlags.ConnStr, "conn-str", "", "pg db connection string, sqlite:<path> or memory:")
+	flags.StringVar(&ExportFlags.Symbol, "symbol", "BTCUSDT", "symbol to export")
+	flags.StringVar(&ExportFlags.Interval, "interval", "1m", "interval to export")
+	flags.StringVar(&ExportFlags.From, "from", "", "to export from date, from the first kline if empty")
+	flags.StringVar(&ExportFlags.To, "to", "", "to export to date, to the last kline if empty")
+	flags.StringVar(&ExportFlags.Format, "format", string(exporter.FormatCSV), "file format: csv, ndjson or parquet")
+	flags.StringVar(&ExportFlags.Compression, "compression", string(exporter.CompressionNone), "compression: none, gzip or zstd")
+	flags.StringVar(&ExportFlags.Out, "out", "", "file to write, - for stdout, SYMBOL-INTERVAL.FORMAT if empty")
+	flags.IntVar(&ExportFlags.PageSize, "page-size", 10_000, "klines to read from db at once")
+}
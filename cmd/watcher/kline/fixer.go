@@ -8,40 +8,43 @@ import (
 	"os/signal"
 	"time"
 
-	"github.com/jackc/pgx/v5"
 	"github.com/spf13/cobra"
 
 	"crypto_bot/pkg/exchange/binance"
+	"crypto_bot/pkg/exchange/models"
 	"crypto_bot/pkg/helpers/gapfixer"
-	"crypto_bot/pkg/storage/pgdb"
+	"crypto_bot/pkg/storage/backend"
 )
 
 const timeLayout = "2006-01-02_15:04:05"
 
 var (
 	FixGapsFlags = struct {
-		ConnStr   string
-		Symbol    string
-		Interval  string
-		From      string
-		To        string
-		ChunkSize int
+		ConnStr          string
+		Symbols          []string
+		Intervals        []string
+		Pairs            []string
+		From             string
+		To               string
+		ChunkSize        int
+		Concurrency      int
+		WeightLimit      int
+		Resume           bool
+		ProgressInterval time.Duration
+		DryRun           bool
 	}{}
 
 	FixGapsCmd = &cobra.Command{
 		Use:   "fix-gaps",
 		Short: "Fix gaps in klines",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			from, err := time.Parse(timeLayout, FixGapsFlags.From)
+			pairs, err := klinePairs(cmd, FixGapsFlags.Symbols, FixGapsFlags.Intervals, FixGapsFlags.Pairs)
 			if err != nil {
-				return fmt.Errorf("parse from: %s", err)
+				return err
 			}
-
-			to := time.Now()
-			if FixGapsFlags.To != "" {
-				if to, err = time.Parse(timeLayout, FixGapsFlags.To); err != nil {
-					return fmt.Errorf("parse to: %s", err)
-				}
+			from, to, err := timeRange(FixGapsFlags.From, FixGapsFlags.To)
+			if err != nil {
+				return err
 			}
 
 			ctx, cancel := context.WithCancel(context.Background())
@@ -51,29 +54,136 @@ var (
 			signal.Notify(interrupt, os.Kill)
 			go func() { <-interrupt; log.Println("keyboard interruption"); cancel() }()
 
-			c := binance.NewClient("", "")
-
-			conn, err := pgx.Connect(ctx, FixGapsFlags.ConnStr)
+			db, err := backend.Open(ctx, FixGapsFlags.ConnStr, int32(FixGapsFlags.Concurrency+1))
 			if err != nil {
 				return err
 			}
-			db := pgdb.NewClient(conn)
+			defer db.Close()
 
-			err = gapfixer.FixGaps(ctx, c, db, FixGapsFlags.Symbol, FixGapsFlags.Interval, from, to, FixGapsFlags.ChunkSize)
-			if err != nil {
+			if FixGapsFlags.DryRun {
+				audits, err := gapfixer.Audit(ctx, db.Klines, emptyRanges(db), gapfixer.AuditRequest{
+					Pairs:     pairs,
+					From:      from,
+					To:        to,
+					ChunkSize: FixGapsFlags.ChunkSize,
+				})
+				if err != nil {
+					return err
+				}
+				return writeAudits(os.Stdout, auditTable, audits)
+			}
+
+			if err = checkWritable(db, pairs); err != nil {
 				return err
 			}
-			return nil
+			c := binance.NewClient("", "")
+
+			log.Printf("Fixing gaps pairs=%v from=%s to=%s", pairs, from.Format(timeLayout), to.Format(timeLayout))
+			// Checkpoints and empty ranges are only kept in Postgres.
+			var checkpoints gapfixer.Checkpoints
+			if db.Postgres != nil {
+				checkpoints = db.Postgres
+			}
+			return gapfixer.NewRunner(c, db.Klines, checkpoints).
+				SetEmptyRanges(emptyRanges(db)).
+				SetConcurrency(FixGapsFlags.Concurrency).
+				SetChunkSize(FixGapsFlags.ChunkSize).
+				SetLimiter(gapfixer.NewWeightLimiter(FixGapsFlags.WeightLimit)).
+				SetResume(FixGapsFlags.Resume).
+				SetProgress(log.Writer(), FixGapsFlags.ProgressInterval).
+				Run(ctx, gapfixer.RunRequest{Pairs: pairs, From: from, To: to})
 		},
 	}
 )
 
 func init() {
 	flags := FixGapsCmd.Flags()
-	flags.StringVar(&FixGapsFlags.ConnStr, "conn-str", "", "pg db connection string")
-	flags.StringVar(&FixGapsFlags.Symbol, "symbol", "BTCUSDT", "symbol to fix gaps for")
-	flags.StringVar(&FixGapsFlags.Interval, "interval", "1m", "interval to fix gaps")
+	flags.StringVar(&FixGapsFlags.ConnStr, "conn-str", "", "pg db connection string, sqlite:<path> or memory:")
+	flags.StringSliceVar(&FixGapsFlags.Symbols, "symbol", []string{"BTCUSDT"}, "symbols to fix gaps for with every interval")
+	flags.StringSliceVar(&FixGapsFlags.Intervals, "interval", []string{"1m"}, "intervals to fix gaps for every symbol")
+	flags.StringSliceVar(&FixGapsFlags.Pairs, "pair", nil, "symbol:interval pairs to fix gaps for, replace the default symbol and interval")
 	flags.StringVar(&FixGapsFlags.From, "from", "2017-08-17_4:00:00", "to fix gaps from date")
 	flags.StringVar(&FixGapsFlags.To, "to", "", "to fix gaps to date")
 	flags.IntVar(&FixGapsFlags.ChunkSize, "chunk-size", 100, "chunk size")
+	flags.IntVar(&FixGapsFlags.Concurrency, "concurrency", 4, "pairs to fix at once")
+	flags.IntVar(&FixGapsFlags.WeightLimit, "weight-limit", 3000, "exchange request weight to spend per minute, binance allows 6000")
+	flags.BoolVar(&FixGapsFlags.Resume, "resume", true, "resume pairs from their checkpoints")
+	flags.DurationVar(&FixGapsFlags.ProgressInterval, "progress-interval", 10*time.Second, "how often progress is printed")
+	flags.BoolVar(&FixGapsFlags.DryRun, "dry-run", false, "print the gaps as kline audit does instead of fixing them")
+}
+
+// klinePairs returns the pairs of the symbol and interval flags followed by the
+// ones of the pair flag. The pair flag alone replaces the symbol and interval defaults.
+func klinePairs(cmd *cobra.Command, symbols, intervals, pairs []string) ([]models.WsKlineRequest, error) {
+	parsed, err := parsePairs(pairs)
+	if err != nil {
+		return nil, err
+	}
+	if symbols, err = parseSymbols(symbols); err != nil {
+		return nil, err
+	}
+	for _, interval := range intervals {
+		if _, err = models.ParseInterval(interval); err != nil {
+			return nil, err
+		}
+	}
+	if len(parsed) > 0 && !cmd.Flags().Changed("symbol") && !cmd.Flags().Changed("interval") {
+		symbols, intervals = nil, nil
+	}
+	return crossPairs(symbols, intervals, parsed), nil
+}
+
+// checkWritable rejects pairs of intervals the storage builds from 1m klines itself.
+func checkWritable(db *backend.Backend, pairs []models.WsKlineRequest) error {
+	for _, p := range pairs {
+		if err := db.CheckWritable(p.Interval); err != nil {
+			return fmt.Errorf("%s %w", p.Symbol, err)
+		}
+	}
+	return nil
+}
+
+// emptyRanges returns where ranges the exchange has no data for are recorded,
+// nil if the backend does not keep them.
+func emptyRanges(db *backend.Backend) gapfixer.EmptyRanges {
+	if db.Postgres == nil {
+		return nil
+	}
+	return db.Postgres
+}
+
+// timeRange parses the from and to flags, an empty to is now.
+func timeRange(from, to string) (time.Time, time.Time, error) {
+	f, err := time.Parse(timeLayout, from)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("parse from: %s", err)
+	}
+	t := time.Now()
+	if to != "" {
+		if t, err = time.Parse(timeLayout, to); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("parse to: %s", err)
+		}
+	}
+	return f, t, nil
+}
+
+// crossPairs returns every symbol with every interval followed by pairs, without duplicates.
+func crossPairs(symbols, intervals []string, pairs []models.WsKlineRequest) []models.WsKlineRequest {
+	res := make([]models.WsKlineRequest, 0, len(symbols)*len(intervals)+len(pairs))
+	seen := make(map[models.WsKlineRequest]bool)
+	add := func(p models.WsKlineRequest) {
+		if !seen[p] {
+			seen[p] = true
+			res = append(res, p)
+		}
+	}
+	for _, symbol := range symbols {
+		for _, interval := range intervals {
+			add(models.WsKlineRequest{Symbol: symbol, Interval: interval})
+		}
+	}
+	for _, p := range pairs {
+		add(p)
+	}
+	return res
 }
@@ -0,0 +1,67 @@
+package kline
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	"crypto_bot/pkg/helpers/archive"
+	"crypto_bot/pkg/storage/backend"
+)
+
+var (
+	ImportFlags = struct {
+		ConnStr      string
+		Dir          string
+		Pairs        []string
+		SkipChecksum bool
+	}{}
+
+	ImportCmd = &cobra.Command{
+		Use:   "import",
+		Short: "Import kline archives of data.binance.vision from a directory to db",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pairs, err := parsePairs(ImportFlags.Pairs)
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+
+			interrupt := make(chan os.Signal, 1)
+			signal.Notify(interrupt, os.Interrupt)
+			signal.Notify(interrupt, os.Kill)
+			go func() { <-interrupt; log.Println("keyboard interruption"); cancel() }()
+
+			db, err := backend.Open(ctx, ImportFlags.ConnStr, 0)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			res, err := archive.NewImporter(db.Klines).
+				SetVerifyChecksum(!ImportFlags.SkipChecksum).
+				SetProgress(log.Writer()).
+				Import(ctx, archive.ImportRequest{Dir: ImportFlags.Dir, Pairs: pairs})
+			if res != nil {
+				log.Printf("Imported %d klines from %d archives, skipped %d zip files", res.Klines, res.Files, len(res.Skipped))
+				for p, closeTime := range res.LastCloseTime {
+					log.Printf("%s %s imported up to %s, fix gaps from %s", p.Symbol, p.Interval,
+						formatMs(closeTime, timeLayout), formatMs(closeTime+1, timeLayout))
+				}
+			}
+			return err
+		},
+	}
+)
+
+func init() {
+	flags := ImportCmd.Flags()
+	flags.StringVar(&ImportFlags.ConnStr, "conn-str", "", "pg db connection string, sqlite:<path> or memory:")
+	flags.StringVar(&ImportFlags.Dir, "dir", ".", "directory to look for SYMBOL-INTERVAL-PERIOD.zip archives in")
+	flags.StringSliceVar(&ImportFlags.Pairs, "pair", nil, "symbol:interval pairs to import, all found are imported if empty")
+	flags.BoolVar(&ImportFlags.SkipChecksum, "skip-checksum", false, "import archives without checking their .CHECKSUM files")
+}
@@ -0,0 +1,111 @@
+package kline
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/helpers/resampler"
+	"crypto_bot/pkg/storage/backend"
+)
+
+var (
+	ResampleFlags = struct {
+		ConnStr   string
+		Symbols   []string
+		Base      string
+		Targets   []string
+		From      string
+		To        string
+		ChunkSize int
+	}{}
+
+	ResampleCmd = &cobra.Command{
+		Use:   "resample",
+		Short: "Build klines of higher intervals from stored ones",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			symbols, err := parseSymbols(ResampleFlags.Symbols)
+			if err != nil {
+				return err
+			}
+			base, err := models.ParseInterval(ResampleFlags.Base)
+			if err != nil {
+				return err
+			}
+			targets, err := parseIntervals(ResampleFlags.Targets)
+			if err != nil {
+				return err
+			}
+			var from, to time.Time
+			if ResampleFlags.From != "" {
+				if from, err = time.Parse(timeLayout, ResampleFlags.From); err != nil {
+					return fmt.Errorf("parse from: %s", err)
+				}
+			}
+			if ResampleFlags.To != "" {
+				if to, err = time.Parse(timeLayout, ResampleFlags.To); err != nil {
+					return fmt.Errorf("parse to: %s", err)
+				}
+			}
+
+			ctx := context.Background()
+			db, err := backend.Open(ctx, ResampleFlags.ConnStr, 0)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			if err = db.CheckWritable(intervalStrings(targets)...); err != nil {
+				return fmt.Errorf("target %w", err)
+			}
+			r, err := resampler.NewResampler(db.Klines, base, targets...)
+			if err != nil {
+				return err
+			}
+			r.SetChunkSize(ResampleFlags.ChunkSize)
+			for _, symbol := range symbols {
+				n, err := r.Resample(ctx, resampler.ResampleRequest{Symbol: symbol, From: from, To: to})
+				if err != nil {
+					return fmt.Errorf("%s: %w", symbol, err)
+				}
+				log.Printf("Resampled %s %s into %v: %d klines", symbol, base, targets, n)
+			}
+			return nil
+		},
+	}
+)
+
+func init() {
+	flags := ResampleCmd.Flags()
+	flags.StringVar(&ResampleFlags.ConnStr, "conn-str", "", "pg db connection string, sqlite:<path> or memory:")
+	flags.StringSliceVar(&ResampleFlags.Symbols, "symbol", []string{"BTCUSDT"}, "symbols to resample")
+	flags.StringVar(&ResampleFlags.Base, "base", "1m", "interval to resample from")
+	flags.StringSliceVar(&ResampleFlags.Targets, "target", intervalStrings(resampler.DefaultTargets), "intervals to build")
+	flags.StringVar(&ResampleFlags.From, "from", "", "to resample from date, from the first kline if empty")
+	flags.StringVar(&ResampleFlags.To, "to", "", "to resample to date, to the last kline if empty")
+	flags.IntVar(&ResampleFlags.ChunkSize, "chunk-size", 1000, "klines to read and write at once")
+}
+
+func parseIntervals(intervals []string) ([]models.Interval, error) {
+	res := make([]models.Interval, len(intervals))
+	for i, s := range intervals {
+		interval, err := models.ParseInterval(s)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = interval
+	}
+	return res, nil
+}
+
+func intervalStrings(intervals []models.Interval) []string {
+	res := make([]string, len(intervals))
+	for i, interval := range intervals {
+		res[i] = string(interval)
+	}
+	return res
+}
@@ -10,4 +10,9 @@ var RootCmd = &cobra.Command{
 func init() {
 	RootCmd.AddCommand(CollectCmd)
 	RootCmd.AddCommand(FixGapsCmd)
+	RootCmd.AddCommand(AuditCmd)
+	RootCmd.AddCommand(VerifyCmd)
+	RootCmd.AddCommand(ImportCmd)
+	RootCmd.AddCommand(ExportCmd)
+	RootCmd.AddCommand(ResampleCmd)
 }
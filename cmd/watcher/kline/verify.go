@@ -0,0 +1,102 @@
+package kline
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"crypto_bot/pkg/exchange/binance"
+	"crypto_bot/pkg/helpers/gapfixer"
+	"crypto_bot/pkg/helpers/verifier"
+	"crypto_bot/pkg/storage/backend"
+)
+
+var (
+	VerifyFlags = struct {
+		ConnStr     string
+		Symbols     []string
+		Intervals   []string
+		Pairs       []string
+		From        string
+		To          string
+		ChunkSize   int
+		Repair      bool
+		WeightLimit int
+	}{}
+
+	VerifyCmd = &cobra.Command{
+		Use:   "verify",
+		Short: "Check stored klines for wrong rows and optionally repair them from exchange",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pairs, err := klinePairs(cmd, VerifyFlags.Symbols, VerifyFlags.Intervals, VerifyFlags.Pairs)
+			if err != nil {
+				return err
+			}
+			from, to, err := timeRange(VerifyFlags.From, VerifyFlags.To)
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+
+			interrupt := make(chan os.Signal, 1)
+			signal.Notify(interrupt, os.Interrupt)
+			signal.Notify(interrupt, os.Kill)
+			go func() { <-interrupt; log.Println("keyboard interruption"); cancel() }()
+
+			db, err := backend.Open(ctx, VerifyFlags.ConnStr, 0)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			v := verifier.NewVerifier(db.Klines).SetChunkSize(VerifyFlags.ChunkSize)
+			if VerifyFlags.Repair {
+				if err = checkWritable(db, pairs); err != nil {
+					return err
+				}
+				v.SetRepair(binance.NewClient("", "")).
+					SetLimiter(gapfixer.NewWeightLimiter(VerifyFlags.WeightLimit))
+			}
+			issues, err := v.Verify(ctx, verifier.VerifyRequest{Pairs: pairs, From: from, To: to})
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "SYMBOL\tINTERVAL\tOPEN TIME\tPROBLEMS\tREPAIRED")
+			for _, issue := range issues {
+				problems := make([]string, len(issue.Problems))
+				for i, p := range issue.Problems {
+					problems[i] = string(p)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\n", issue.Symbol, issue.Interval,
+					formatMs(issue.Kline.OpenTime, timeLayout), strings.Join(problems, "; "), issue.Repaired)
+			}
+			if err = w.Flush(); err != nil {
+				return err
+			}
+			log.Printf("Found %d klines with issues", len(issues))
+			return nil
+		},
+	}
+)
+
+func init() {
+	flags := VerifyCmd.Flags()
+	flags.StringVar(&VerifyFlags.ConnStr, "conn-str", "", "pg db connection string, sqlite:<path> or memory:")
+	flags.StringSliceVar(&VerifyFlags.Symbols, "symbol", []string{"BTCUSDT"}, "symbols to verify with every interval")
+	flags.StringSliceVar(&VerifyFlags.Intervals, "interval", []string{"1m"}, "intervals to verify every symbol with")
+	flags.StringSliceVar(&VerifyFlags.Pairs, "pair", nil, "symbol:interval pairs to verify, replace the default symbol and interval")
+	flags.StringVar(&VerifyFlags.From, "from", "2017-08-17_4:00:00", "to verify from date")
+	flags.StringVar(&VerifyFlags.To, "to", "", "to verify to date")
+	flags.IntVar(&VerifyFlags.ChunkSize, "chunk-size", 1000, "klines to read from db and exchange at once")
+	flags.BoolVar(&VerifyFlags.Repair, "repair", false, "replace wrong klines with the ones of the exchange")
+	flags.IntVar(&VerifyFlags.WeightLimit, "weight-limit", 3000, "exchange request weight to spend per minute when repairing")
+}
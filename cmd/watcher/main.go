@@ -5,7 +5,10 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"crypto_bot/cmd/watcher/backtest"
+	"crypto_bot/cmd/watcher/db"
 	"crypto_bot/cmd/watcher/kline"
+	"crypto_bot/cmd/watcher/trade"
 )
 
 var RootCmd = &cobra.Command{
@@ -15,6 +18,9 @@ var RootCmd = &cobra.Command{
 
 func init() {
 	RootCmd.AddCommand(kline.RootCmd)
+	RootCmd.AddCommand(trade.RootCmd)
+	RootCmd.AddCommand(backtest.RootCmd)
+	RootCmd.AddCommand(db.RootCmd)
 }
 
 func main() {
@@ -0,0 +1,117 @@
+package trade
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"crypto_bot/pkg/exchange/binance"
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/helpers/gapfixer"
+	"crypto_bot/pkg/storage/pgdb"
+	"crypto_bot/pkg/watcher/trade"
+)
+
+var (
+	CollectorFlags = struct {
+		ConnStr     string
+		MaxConns    int32
+		Symbols     []string
+		Agg         bool
+		ChunkSize   int
+		WeightLimit int
+		Debug       bool
+
+		FlushInterval time.Duration
+		MetricsAddr   string
+
+		ReconnectMin time.Duration
+		ReconnectMax time.Duration
+	}{}
+	CollectCmd = &cobra.Command{
+		Use:   "collect",
+		Short: "Save new trades from exchange to db",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			symbols, err := parseSymbols(CollectorFlags.Symbols)
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+
+			interrupt := make(chan os.Signal, 1)
+			signal.Notify(interrupt, os.Interrupt)
+			signal.Notify(interrupt, os.Kill)
+			go func() { <-interrupt; log.Println("keyboard interruption"); cancel() }()
+
+			c := binance.NewClient("", "")
+
+			// Trades are only kept in Postgres.
+			db, err := pgdb.Connect(ctx, CollectorFlags.ConnStr, CollectorFlags.MaxConns)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			watcher := trade.
+				NewWatcher(c, db).
+				SetSymbols(symbols...).
+				SetAgg(CollectorFlags.Agg).
+				SetChunkSize(CollectorFlags.ChunkSize).
+				SetFlushInterval(CollectorFlags.FlushInterval).
+				SetReconnectBackoff(CollectorFlags.ReconnectMin, CollectorFlags.ReconnectMax).
+				SetLimiter(gapfixer.NewWeightLimiter(CollectorFlags.WeightLimit)).
+				SetErrorHandler(func(err error) {
+					log.Printf("ERROR: %s", err)
+				}).
+				SetDebug(CollectorFlags.Debug)
+			log.Printf("Starting watcher symbols=%v agg=%t chunk-size=%d", symbols, CollectorFlags.Agg, CollectorFlags.ChunkSize)
+
+			if CollectorFlags.MetricsAddr != "" {
+				// The watcher publishes its metrics with expvar at /debug/vars.
+				go func() { log.Printf("metrics server: %s", http.ListenAndServe(CollectorFlags.MetricsAddr, nil)) }()
+			}
+
+			if err = watcher.Start(ctx); err != nil && err != context.Canceled {
+				return err
+			}
+			return nil
+		},
+	}
+)
+
+func init() {
+	flags := CollectCmd.Flags()
+	flags.StringVar(&CollectorFlags.ConnStr, "conn-str", "", "pg db connection string")
+	flags.Int32Var(&CollectorFlags.MaxConns, "max-conns", 0, "max db connections, 0 uses pool_max_conns of conn-str or the default")
+	flags.StringSliceVar(&CollectorFlags.Symbols, "symbol", []string{"BTCUSDT"}, "symbols to watch")
+	flags.BoolVar(&CollectorFlags.Agg, "agg", false, "collect aggregate trades instead of trades")
+	flags.IntVar(&CollectorFlags.ChunkSize, "chunk-size", 1000, "chunk size to write to db")
+	flags.DurationVar(&CollectorFlags.FlushInterval, "flush-interval", 10*time.Second, "max time trades stay buffered, 0 waits for a full chunk")
+	flags.StringVar(&CollectorFlags.MetricsAddr, "metrics-addr", "", "address to serve metrics at /debug/vars, disabled if empty")
+	flags.DurationVar(&CollectorFlags.ReconnectMin, "reconnect-min", time.Second, "delay before the first reconnect")
+	flags.DurationVar(&CollectorFlags.ReconnectMax, "reconnect-max", time.Minute, "max delay between reconnects")
+	flags.IntVar(&CollectorFlags.WeightLimit, "weight-limit", 3000, "exchange request weight backfills spend per minute, binance allows 6000")
+	flags.BoolVarP(&CollectorFlags.Debug, "debug", "v", false, "log written chunks and backfills")
+
+	if err := cobra.MarkFlagRequired(flags, "conn-str"); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func parseSymbols(symbols []string) ([]string, error) {
+	res := make([]string, len(symbols))
+	for i, s := range symbols {
+		symbol, err := models.ParseSymbol(s)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = string(symbol)
+	}
+	return res, nil
+}
@@ -0,0 +1,154 @@
+package trade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/spf13/cobra"
+
+	"crypto_bot/pkg/exchange/binance"
+	"crypto_bot/pkg/helpers/gapfixer"
+	"crypto_bot/pkg/storage"
+	"crypto_bot/pkg/storage/pgdb"
+)
+
+var (
+	FixGapsFlags = struct {
+		ConnStr     string
+		Symbols     []string
+		Agg         bool
+		FromID      int64
+		ToID        int64
+		ChunkSize   int
+		WeightLimit int
+	}{}
+
+	FixGapsCmd = &cobra.Command{
+		Use:   "fix-gaps",
+		Short: "Fix gaps in trades by their ids",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			symbols, err := parseSymbols(FixGapsFlags.Symbols)
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+
+			interrupt := make(chan os.Signal, 1)
+			signal.Notify(interrupt, os.Interrupt)
+			signal.Notify(interrupt, os.Kill)
+			go func() { <-interrupt; log.Println("keyboard interruption"); cancel() }()
+
+			db, err := pgdb.Connect(ctx, FixGapsFlags.ConnStr, 0)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			c := binance.NewClient("", "")
+			limiter := gapfixer.NewWeightLimiter(FixGapsFlags.WeightLimit)
+			for _, symbol := range symbols {
+				from, to, err := idRange(ctx, db, symbol)
+				if errors.Is(err, pgx.ErrNoRows) {
+					log.Printf("No trades of %s stored, skipping", symbol)
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("%s: %w", symbol, err)
+				}
+				log.Printf("Fixing gaps symbol=%s agg=%t from-id=%d to-id=%d", symbol, FixGapsFlags.Agg, from, to)
+				n, err := gapfixer.FixTradeGaps(ctx, c, db, limiter, gapfixer.FixTradeGapsRequest{
+					Symbol:    symbol,
+					FromID:    from,
+					ToID:      to,
+					Agg:       FixGapsFlags.Agg,
+					ChunkSize: FixGapsFlags.ChunkSize,
+				})
+				if err != nil {
+					return fmt.Errorf("%s: %w", symbol, err)
+				}
+				log.Printf("Fixed %d trades of %s", n, symbol)
+			}
+			return nil
+		},
+	}
+)
+
+func init() {
+	flags := FixGapsCmd.Flags()
+	flags.StringVar(&FixGapsFlags.ConnStr, "conn-str", "", "pg db connection string")
+	flags.StringSliceVar(&FixGapsFlags.Symbols, "symbol", []string{"BTCUSDT"}, "symbols to fix gaps for")
+	flags.BoolVar(&FixGapsFlags.Agg, "agg", false, "fix aggregate trades instead of trades")
+	flags.Int64Var(&FixGapsFlags.FromID, "from-id", -1, "to fix gaps from trade id, the first stored one if negative")
+	flags.Int64Var(&FixGapsFlags.ToID, "to-id", -1, "to fix gaps to trade id, the last stored one if negative")
+	flags.IntVar(&FixGapsFlags.ChunkSize, "chunk-size", binance.MaxTradesLimit, "trades to request at once")
+	flags.IntVar(&FixGapsFlags.WeightLimit, "weight-limit", 3000, "exchange request weight to spend per minute, binance allows 6000")
+
+	if err := cobra.MarkFlagRequired(flags, "conn-str"); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// idRange returns the ids of the flags, replacing negative ones with the first
+// and the last stored ids of symbol.
+func idRange(ctx context.Context, db *pgdb.Client, symbol string) (int64, int64, error) {
+	from, to := FixGapsFlags.FromID, FixGapsFlags.ToID
+	if from < 0 {
+		first, err := firstID(ctx, db, symbol)
+		if err != nil {
+			return 0, 0, err
+		}
+		from = first
+	}
+	if to < 0 {
+		last, err := lastID(ctx, db, symbol)
+		if err != nil {
+			return 0, 0, err
+		}
+		to = last
+	}
+	return from, to, nil
+}
+
+func firstID(ctx context.Context, db *pgdb.Client, symbol string) (int64, error) {
+	req := storage.ReadTradesRequest{Symbol: symbol, Limit: 1}
+	if FixGapsFlags.Agg {
+		trades, err := db.ReadAggTrades(ctx, req)
+		if err != nil {
+			return 0, err
+		}
+		if len(trades) == 0 {
+			return 0, pgx.ErrNoRows
+		}
+		return trades[0].ID, nil
+	}
+	trades, err := db.ReadTrades(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	if len(trades) == 0 {
+		return 0, pgx.ErrNoRows
+	}
+	return trades[0].ID, nil
+}
+
+func lastID(ctx context.Context, db *pgdb.Client, symbol string) (int64, error) {
+	req := storage.ReadLastTradeRequest{Symbol: symbol}
+	if FixGapsFlags.Agg {
+		t, err := db.ReadLastAggTrade(ctx, req)
+		if err != nil {
+			return 0, err
+		}
+		return t.ID, nil
+	}
+	t, err := db.ReadLastTrade(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	return t.ID, nil
+}
@@ -0,0 +1,13 @@
+package trade
+
+import "github.com/spf13/cobra"
+
+var RootCmd = &cobra.Command{
+	Use:   "trade",
+	Short: "Commands for watching for exchange's trades",
+}
+
+func init() {
+	RootCmd.AddCommand(CollectCmd)
+	RootCmd.AddCommand(FixGapsCmd)
+}
@@ -0,0 +1,209 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+
+	"crypto_bot/pkg/exchange"
+	"crypto_bot/pkg/exchange/dbased"
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/exchange/utils"
+)
+
+// Strategy reacts to replayed klines and to the orders changed by them. It
+// receives the same events and the same exchange.Client it would get live.
+type Strategy interface {
+	OnKline(ctx context.Context, ex exchange.Client, e *models.WsKlineEvent) error
+	OnOrderUpdate(ctx context.Context, ex exchange.Client, o *models.Order) error
+}
+
+type Exchange interface {
+	exchange.Client
+	ProcessKline(ctx context.Context, symbol string, k *models.Kline) ([]*dbased.OrderUpdate, error)
+}
+
+// Engine replays stored klines of one or more streams through the simulated
+// exchange in close time order. Orders are matched only against the klines
+// of the shortest interval of each symbol.
+type Engine struct {
+	ex         Exchange
+	strategy   Strategy
+	streams    []models.WsKlineRequest
+	from, to   int64
+	quoteAsset string
+	pageSize   int
+}
+
+func NewEngine(ex Exchange, strategy Strategy) *Engine {
+	return &Engine{
+		ex:         ex,
+		strategy:   strategy,
+		streams:    []models.WsKlineRequest{{Symbol: "BTCUSDT", Interval: "1h"}},
+		quoteAsset: "USDT",
+		pageSize:   1000,
+	}
+}
+
+func (e *Engine) SetStreams(streams ...models.WsKlineRequest) *Engine {
+	e.streams = streams
+	return e
+}
+
+// SetPeriod limits the replay to klines opened at or after from and closed at
+// or before to, both in milliseconds. Zero to means no upper limit.
+func (e *Engine) SetPeriod(from, to int64) *Engine {
+	e.from, e.to = from, to
+	return e
+}
+
+// SetQuoteAsset sets the asset the equity and the fees are measured in.
+func (e *Engine) SetQuoteAsset(asset string) *Engine {
+	e.quoteAsset = asset
+	return e
+}
+
+func (e *Engine) SetPageSize(size int) *Engine {
+	e.pageSize = size
+	return e
+}
+
+func (e *Engine) Run(ctx context.Context) (*Result, error) {
+	if len(e.streams) == 0 {
+		return nil, fmt.Errorf("no streams to replay")
+	}
+	streams := make([]*stream, len(e.streams))
+	for i, r := range e.streams {
+		streams[i] = &stream{req: r, next: e.from, to: e.to, limit: e.pageSize}
+		if err := streams[i].load(ctx, e.ex); err != nil {
+			return nil, err
+		}
+	}
+	markMatching(streams)
+
+	run := &run{Engine: e, prices: make(map[string]float64), res: &Result{QuoteAsset: e.quoteAsset}}
+	now := int64(-1)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		s := nextStream(streams)
+		if s == nil {
+			break
+		}
+		k := s.head()
+		if now >= 0 && k.CloseTime != now {
+			if err := run.snapshot(ctx, now); err != nil {
+				return nil, err
+			}
+		}
+		now = k.CloseTime
+		if err := run.process(ctx, s, k); err != nil {
+			return nil, err
+		}
+		if err := s.pop(ctx, e.ex); err != nil {
+			return nil, err
+		}
+	}
+	if now >= 0 {
+		if err := run.snapshot(ctx, now); err != nil {
+			return nil, err
+		}
+	}
+	return run.res, nil
+}
+
+type run struct {
+	*Engine
+	prices map[string]float64
+	res    *Result
+}
+
+func (r *run) process(ctx context.Context, s *stream, k *models.Kline) error {
+	if s.matching {
+		updates, err := r.ex.ProcessKline(ctx, s.req.Symbol, k)
+		if err != nil {
+			return fmt.Errorf("process %s kline %d: %w", s.req.Symbol, k.OpenTime, err)
+		}
+		r.prices[s.req.Symbol] = k.Close
+		for _, u := range updates {
+			if u.Fill != nil {
+				r.addTrade(u)
+			}
+			if err = r.strategy.OnOrderUpdate(ctx, r.ex, u.Order); err != nil {
+				return fmt.Errorf("strategy order update: %w", err)
+			}
+		}
+	}
+
+	event := &models.WsKlineEvent{
+		Event:  "kline",
+		Time:   k.CloseTime,
+		Symbol: s.req.Symbol,
+		Kline: models.WsKline{
+			StartTime:            k.OpenTime,
+			EndTime:              k.CloseTime,
+			Symbol:               s.req.Symbol,
+			Interval:             s.req.Interval,
+			FirstTradeID:         k.FirstTradeID,
+			LastTradeID:          k.LastTradeID,
+			Open:                 k.Open,
+			Close:                k.Close,
+			High:                 k.High,
+			Low:                  k.Low,
+			Volume:               k.Volume,
+			TradeNum:             k.TradeNum,
+			IsFinal:              true,
+			QuoteVolume:          k.QuoteAssetVolume,
+			ActiveBuyVolume:      k.TakerBuyBaseAssetVolume,
+			ActiveBuyQuoteVolume: k.TakerBuyQuoteAssetVolume,
+		},
+	}
+	if err := r.strategy.OnKline(ctx, r.ex, event); err != nil {
+		return fmt.Errorf("strategy kline: %w", err)
+	}
+	return nil
+}
+
+func (r *run) addTrade(u *dbased.OrderUpdate) {
+	fee, _ := r.value(u.Fill.CommissionAsset, u.Fill.Commission)
+	if u.Fill.CommissionAsset+r.quoteAsset == u.Order.Symbol {
+		fee = u.Fill.Commission * u.Fill.Price
+	}
+	r.res.Fees += fee
+	r.res.Trades = append(r.res.Trades, &Trade{
+		OrderID:         u.Order.OrderID,
+		Symbol:          u.Order.Symbol,
+		Side:            u.Order.Side,
+		Type:            u.Order.Type,
+		Price:           u.Fill.Price,
+		Quantity:        u.Fill.Quantity,
+		Commission:      u.Fill.Commission,
+		CommissionAsset: u.Fill.CommissionAsset,
+		Fee:             fee,
+		Time:            u.Order.UpdateTime,
+	})
+}
+
+// value converts an amount of the asset to the quote asset with the last
+// replayed close price. It reports false if no price is known.
+func (r *run) value(asset string, amount float64) (float64, bool) {
+	if asset == r.quoteAsset || amount == 0 {
+		return amount, true
+	}
+	price, ok := r.prices[asset+r.quoteAsset]
+	return amount * price, ok
+}
+
+func (r *run) snapshot(ctx context.Context, time int64) error {
+	acc, err := r.ex.GetAccount(ctx)
+	if err != nil {
+		return fmt.Errorf("get account: %w", err)
+	}
+	var equity float64
+	for _, b := range acc.Balances {
+		v, _ := r.value(b.Asset, utils.Str2float(b.Free)+utils.Str2float(b.Locked))
+		equity += v
+	}
+	r.res.addEquity(time, equity)
+	return nil
+}
@@ -0,0 +1,99 @@
+package backtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"crypto_bot/pkg/exchange"
+	"crypto_bot/pkg/exchange/dbased"
+	"crypto_bot/pkg/exchange/dbased/dbasedtest"
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage/pgdb"
+)
+
+type recordingStrategy struct {
+	events  []string
+	updates []*models.Order
+}
+
+func (s *recordingStrategy) OnKline(ctx context.Context, ex exchange.Client, e *models.WsKlineEvent) error {
+	s.events = append(s.events, e.Kline.Interval)
+	if len(s.events) == 1 {
+		_, err := ex.CreateOrder(ctx, models.CreateOrderRequest{
+			Symbol: e.Symbol, Quantity: 1, Side: models.SideTypeBuy, Type: models.OrderTypeMarket,
+		})
+		return err
+	}
+	return nil
+}
+
+func (s *recordingStrategy) OnOrderUpdate(ctx context.Context, ex exchange.Client, o *models.Order) error {
+	s.updates = append(s.updates, o)
+	if o.Side == models.SideTypeBuy && o.Status == models.OrderStatusTypeFilled {
+		_, err := ex.CreateOrder(ctx, models.CreateOrderRequest{
+			Symbol: o.Symbol, Quantity: 0.999, Price: 105.5, Side: models.SideTypeSell, Type: models.OrderTypeLimit,
+		})
+		return err
+	}
+	return nil
+}
+
+func TestEngine_Run(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := dbasedtest.NewStorage()
+	s.SetBalance(1, pgdb.Balance{Asset: "USDT", Free: 1000})
+	s.AddKlines("BTCUSDT", "1m", start, 10)
+	for i := 0; i < 2; i++ {
+		openTime := start.Add(time.Duration(i) * 5 * time.Minute)
+		s.AddKline("BTCUSDT", "5m", &models.Kline{
+			OpenTime:  openTime.UnixMilli(),
+			Open:      100 + 5*float64(i),
+			High:      105 + 5*float64(i),
+			Low:       99 + 5*float64(i),
+			Close:     104.5 + 5*float64(i),
+			CloseTime: openTime.Add(5*time.Minute).UnixMilli() - 1,
+		})
+	}
+
+	ex, err := dbased.NewClient(ctx, s, "user", start.UnixMilli())
+	require.NoError(t, err)
+
+	strategy := &recordingStrategy{}
+	res, err := NewEngine(ex, strategy).
+		SetStreams(
+			models.WsKlineRequest{Symbol: "BTCUSDT", Interval: "5m"},
+			models.WsKlineRequest{Symbol: "BTCUSDT", Interval: "1m"},
+		).
+		SetPeriod(start.UnixMilli(), start.Add(10*time.Minute).UnixMilli()).
+		SetPageSize(3).
+		Run(ctx)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		"1m", "1m", "1m", "1m", "1m", "5m",
+		"1m", "1m", "1m", "1m", "1m", "5m",
+	}, strategy.events)
+	require.Len(t, strategy.updates, 2)
+
+	require.Len(t, res.Trades, 2)
+	require.Equal(t, 101.0, res.Trades[0].Price)
+	require.Equal(t, 105.5, res.Trades[1].Price)
+	require.InDelta(t, 0.001*101+0.999*105.5*0.001, res.Fees, 1e-9)
+
+	require.Len(t, res.EquityCurve, 10)
+	require.Equal(t, 1000.0, res.StartEquity())
+	require.InDelta(t, 1000-101+0.999*105.5*0.999, res.EndEquity(), 1e-9)
+	require.Zero(t, res.MaxDrawdown)
+}
+
+func TestResult_MaxDrawdown(t *testing.T) {
+	r := &Result{}
+	for i, equity := range []float64{100, 120, 90, 110, 130, 117} {
+		r.addEquity(int64(i), equity)
+	}
+	require.InDelta(t, 0.25, r.MaxDrawdown, 1e-9)
+}
@@ -0,0 +1,120 @@
+package backtest
+
+import (
+	"math"
+
+	"crypto_bot/pkg/exchange/models"
+)
+
+const msPerYear = 365.25 * 24 * 60 * 60 * 1000
+
+type Summary struct {
+	StartEquity float64 `json:"startEquity"`
+	EndEquity   float64 `json:"endEquity"`
+	TotalReturn float64 `json:"totalReturn"`
+	CAGR        float64 `json:"cagr"`
+	Sharpe      float64 `json:"sharpe"`
+	Sortino     float64 `json:"sortino"`
+	MaxDrawdown float64 `json:"maxDrawdown"`
+	// WinRate is the share of sells that closed a position with profit.
+	WinRate float64 `json:"winRate"`
+	Trades  int     `json:"trades"`
+	Fees    float64 `json:"fees"`
+}
+
+// Summary computes the performance of the run. Sharpe and Sortino ratios are
+// annualized from the returns between equity points with a zero risk free rate.
+func (r *Result) Summary() Summary {
+	s := Summary{
+		StartEquity: r.StartEquity(),
+		EndEquity:   r.EndEquity(),
+		MaxDrawdown: r.MaxDrawdown,
+		WinRate:     winRate(r.Trades),
+		Trades:      len(r.Trades),
+		Fees:        r.Fees,
+	}
+	if s.StartEquity <= 0 || len(r.EquityCurve) < 2 {
+		return s
+	}
+	s.TotalReturn = s.EndEquity/s.StartEquity - 1
+
+	first, last := r.EquityCurve[0], r.EquityCurve[len(r.EquityCurve)-1]
+	years := float64(last.Time-first.Time) / msPerYear
+	if years > 0 && s.EndEquity > 0 {
+		s.CAGR = math.Pow(s.EndEquity/s.StartEquity, 1/years) - 1
+	}
+
+	returns := make([]float64, 0, len(r.EquityCurve)-1)
+	for i := 1; i < len(r.EquityCurve); i++ {
+		if prev := r.EquityCurve[i-1].Equity; prev > 0 {
+			returns = append(returns, r.EquityCurve[i].Equity/prev-1)
+		}
+	}
+	if len(returns) == 0 || years <= 0 {
+		return s
+	}
+	periodsPerYear := float64(len(r.EquityCurve)-1) / years
+	mean, std, downside := moments(returns)
+	if std > 0 {
+		s.Sharpe = mean / std * math.Sqrt(periodsPerYear)
+	}
+	if downside > 0 {
+		s.Sortino = mean / downside * math.Sqrt(periodsPerYear)
+	}
+	return s
+}
+
+// moments returns the mean, the sample standard deviation and the downside
+// deviation of the returns.
+func moments(returns []float64) (mean, std, downside float64) {
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+	for _, r := range returns {
+		std += (r - mean) * (r - mean)
+		if r < 0 {
+			downside += r * r
+		}
+	}
+	if len(returns) > 1 {
+		std = math.Sqrt(std / float64(len(returns)-1))
+	}
+	downside = math.Sqrt(downside / float64(len(returns)))
+	return mean, std, downside
+}
+
+// winRate matches sells with the average cost of the position they close.
+// Fees of both sides are included.
+func winRate(trades []*Trade) float64 {
+	type position struct{ quantity, cost float64 }
+	positions := make(map[string]*position)
+	var closed, won int
+	for _, t := range trades {
+		p, ok := positions[t.Symbol]
+		if !ok {
+			p = &position{}
+			positions[t.Symbol] = p
+		}
+		if t.Side == models.SideTypeBuy {
+			p.quantity += t.Quantity
+			p.cost += t.Quantity*t.Price + t.Fee
+			continue
+		}
+		if p.quantity <= 0 {
+			continue
+		}
+		quantity := math.Min(t.Quantity, p.quantity)
+		cost := p.cost * quantity / p.quantity
+		p.quantity -= quantity
+		p.cost -= cost
+		closed++
+		if quantity*t.Price-t.Fee > cost {
+			won++
+		}
+	}
+	if closed == 0 {
+		return 0
+	}
+	return float64(won) / float64(closed)
+}
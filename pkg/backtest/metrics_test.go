@@ -0,0 +1,34 @@
+package backtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"crypto_bot/pkg/exchange/models"
+)
+
+func TestResult_Summary(t *testing.T) {
+	const day = 24 * 60 * 60 * 1000
+	r := &Result{
+		Trades: []*Trade{
+			{Symbol: "BTCUSDT", Side: models.SideTypeBuy, Price: 100, Quantity: 1, Fee: 0.1},
+			{Symbol: "BTCUSDT", Side: models.SideTypeSell, Price: 110, Quantity: 0.5, Fee: 0.1},
+			{Symbol: "BTCUSDT", Side: models.SideTypeSell, Price: 90, Quantity: 0.5, Fee: 0.1},
+			{Symbol: "ETHUSDT", Side: models.SideTypeSell, Price: 90, Quantity: 1, Fee: 0.1},
+		},
+		Fees: 0.4,
+	}
+	for i, equity := range []float64{100, 110, 99, 121} {
+		r.addEquity(int64(i)*365*day/3, equity)
+	}
+
+	s := r.Summary()
+	require.Equal(t, 4, s.Trades)
+	require.Equal(t, 0.5, s.WinRate)
+	require.InDelta(t, 0.21, s.TotalReturn, 1e-9)
+	require.InDelta(t, 0.21, s.CAGR, 1e-3)
+	require.InDelta(t, 0.1, s.MaxDrawdown, 1e-9)
+	require.InDelta(t, 0.78899, s.Sharpe, 1e-4)
+	require.InDelta(t, 2.22298, s.Sortino, 1e-4)
+}
@@ -0,0 +1,60 @@
+package backtest
+
+import "crypto_bot/pkg/exchange/models"
+
+type Result struct {
+	QuoteAsset  string         `json:"quoteAsset"`
+	Trades      []*Trade       `json:"trades"`
+	EquityCurve []*EquityPoint `json:"equityCurve"`
+	// Fees is the sum of commissions converted to the quote asset.
+	Fees float64 `json:"fees"`
+	// MaxDrawdown is the largest drop of the equity from its peak, a fraction of the peak.
+	MaxDrawdown float64 `json:"maxDrawdown"`
+
+	peak float64
+}
+
+type Trade struct {
+	OrderID         int64            `json:"orderId"`
+	Symbol          string           `json:"symbol"`
+	Side            models.SideType  `json:"side"`
+	Type            models.OrderType `json:"type"`
+	Price           float64          `json:"price"`
+	Quantity        float64          `json:"quantity"`
+	Commission      float64          `json:"commission"`
+	CommissionAsset string           `json:"commissionAsset"`
+	// Fee is the commission in the quote asset.
+	Fee  float64 `json:"fee"`
+	Time int64   `json:"time"`
+}
+
+type EquityPoint struct {
+	Time   int64   `json:"time"`
+	Equity float64 `json:"equity"`
+}
+
+func (r *Result) addEquity(time int64, equity float64) {
+	r.EquityCurve = append(r.EquityCurve, &EquityPoint{Time: time, Equity: equity})
+	if equity > r.peak {
+		r.peak = equity
+	}
+	if r.peak > 0 {
+		if dd := (r.peak - equity) / r.peak; dd > r.MaxDrawdown {
+			r.MaxDrawdown = dd
+		}
+	}
+}
+
+func (r *Result) StartEquity() float64 {
+	if len(r.EquityCurve) == 0 {
+		return 0
+	}
+	return r.EquityCurve[0].Equity
+}
+
+func (r *Result) EndEquity() float64 {
+	if len(r.EquityCurve) == 0 {
+		return 0
+	}
+	return r.EquityCurve[len(r.EquityCurve)-1].Equity
+}
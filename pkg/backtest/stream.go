@@ -0,0 +1,102 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+
+	"crypto_bot/pkg/exchange/models"
+)
+
+// stream pages the klines of one symbol and interval from the exchange.
+type stream struct {
+	req   models.WsKlineRequest
+	next  int64
+	to    int64
+	limit int
+
+	buf  []*models.Kline
+	pos  int
+	done bool
+	// matching streams drive the order matching of their symbol.
+	matching bool
+}
+
+func (s *stream) load(ctx context.Context, ex Exchange) error {
+	klines, err := ex.Klines(ctx, models.KlinesRequest{
+		Symbol:    s.req.Symbol,
+		Interval:  s.req.Interval,
+		StartTime: s.next,
+		EndTime:   s.to,
+		Limit:     s.limit,
+	})
+	if err != nil {
+		return fmt.Errorf("read %s %s klines: %w", s.req.Symbol, s.req.Interval, err)
+	}
+	s.buf, s.pos = klines, 0
+	if len(klines) < s.limit {
+		s.done = true
+	}
+	if len(klines) > 0 {
+		s.next = klines[len(klines)-1].OpenTime + 1
+	}
+	return nil
+}
+
+func (s *stream) head() *models.Kline {
+	if s.pos < len(s.buf) {
+		return s.buf[s.pos]
+	}
+	return nil
+}
+
+func (s *stream) pop(ctx context.Context, ex Exchange) error {
+	s.pos++
+	if s.pos < len(s.buf) || s.done {
+		return nil
+	}
+	return s.load(ctx, ex)
+}
+
+func (s *stream) span() int64 {
+	if k := s.head(); k != nil {
+		return k.CloseTime - k.OpenTime
+	}
+	return 0
+}
+
+// nextStream returns the stream whose next kline closes first. Of klines
+// closing at the same time the one of the shorter interval goes first.
+func nextStream(streams []*stream) *stream {
+	var next *stream
+	for _, s := range streams {
+		k := s.head()
+		if k == nil {
+			continue
+		}
+		if next == nil {
+			next = s
+			continue
+		}
+		n := next.head()
+		if k.CloseTime < n.CloseTime || (k.CloseTime == n.CloseTime && s.span() < next.span()) {
+			next = s
+		}
+	}
+	return next
+}
+
+// markMatching marks the stream of the shortest interval of every symbol.
+func markMatching(streams []*stream) {
+	shortest := make(map[string]*stream)
+	for _, s := range streams {
+		if s.head() == nil {
+			continue
+		}
+		if cur, ok := shortest[s.req.Symbol]; !ok || s.span() < cur.span() {
+			shortest[s.req.Symbol] = s
+		}
+	}
+	for _, s := range shortest {
+		s.matching = true
+	}
+}
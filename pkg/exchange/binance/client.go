@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"crypto_bot/pkg/exchange"
 	"crypto_bot/pkg/exchange/models"
 	"crypto_bot/pkg/exchange/utils"
 
@@ -12,6 +13,8 @@ import (
 
 const MaxLimit = 5000
 
+var _ exchange.Client = (*Client)(nil)
+
 type Client struct {
 	b *binance.Client
 }
@@ -48,54 +51,67 @@ func (c Client) Klines(ctx context.Context, r models.KlinesRequest) (res []*mode
 	return klines, nil
 }
 
-func errHandler(errs chan error) func(error) {
-	return func(err error) {
-		errs <- err
+// Symbols returns the symbols currently trading on the exchange.
+func (c Client) Symbols(ctx context.Context) ([]string, error) {
+	info, err := c.b.NewExchangeInfoService().Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	symbols := make([]string, 0, len(info.Symbols))
+	for _, s := range info.Symbols {
+		if s.Status == string(binance.SymbolStatusTypeTrading) {
+			symbols = append(symbols, s.Symbol)
+		}
 	}
+	return symbols, nil
 }
 
-func eventHandler(events chan *models.WsKlineEvent) func(*binance.WsKlineEvent) {
-	return func(event *binance.WsKlineEvent) {
-		events <- utils.FromExtWsKlineEventToInt(event)
+// send sends v to ch unless quit is closed first, so stream handlers do not
+// block the read loop of a connection nobody reads from any more.
+func send[T any](ch chan T, quit <-chan struct{}, v T) {
+	select {
+	case ch <- v:
+	case <-quit:
 	}
 }
 
-func (c Client) WsKlines(ctx context.Context, r models.WsKlineRequest) (<-chan *models.WsKlineEvent, <-chan error, error) {
-	errs := make(chan error, 100)
-	events := make(chan *models.WsKlineEvent, 100)
-
-	closeChans := func() {
-		close(errs)
-		close(events)
+func errHandler(errs chan error, quit <-chan struct{}) func(error) {
+	return func(err error) {
+		send(errs, quit, err)
 	}
+}
 
-	done, stop, err := binance.WsKlineServe(r.Symbol, r.Interval, eventHandler(events), errHandler(errs))
-	if err != nil {
-		closeChans()
-		return nil, nil, err
+func eventHandler(events chan *models.WsKlineEvent, quit <-chan struct{}) func(*binance.WsKlineEvent) {
+	return func(event *binance.WsKlineEvent) {
+		send(events, quit, utils.FromExtWsKlineEventToInt(event))
 	}
+}
 
-	go func() {
-		defer closeChans()
-		select {
-		case <-done:
-		case <-ctx.Done():
-			stop <- struct{}{}
-		}
-	}()
-
-	return events, errs, nil
+func (c Client) WsKlines(ctx context.Context, r models.WsKlineRequest) (<-chan *models.WsKlineEvent, <-chan error, error) {
+	return serveAll(ctx, []serveFunc[*models.WsKlineEvent]{
+		func(events chan *models.WsKlineEvent, errs chan error, quit <-chan struct{}) (chan struct{}, chan struct{}, error) {
+			return binance.WsKlineServe(r.Symbol, r.Interval, eventHandler(events, quit), errHandler(errs, quit))
+		},
+	})
 }
 
 func (c Client) CreateOrder(ctx context.Context, r models.CreateOrderRequest) (*models.CreateOrderResponse, error) {
-	order, err := c.b.NewCreateOrderService().
+	s := c.b.NewCreateOrderService().
 		Symbol(r.Symbol).
 		Side(binance.SideType(r.Side)).
 		Type(binance.OrderType(r.Type)).
-		TimeInForce(binance.TimeInForceType(r.InTimeForce)).
-		Quantity(utils.Float2str(r.Quantity)).
-		Price(utils.Float2str(r.Price)).
-		Do(ctx)
+		Quantity(utils.Float2str(r.Quantity))
+
+	if r.InTimeForce != "" {
+		s = s.TimeInForce(binance.TimeInForceType(r.InTimeForce))
+	}
+	if r.Price > 0 {
+		s = s.Price(utils.Float2str(r.Price))
+	}
+	if r.StopPrice > 0 {
+		s = s.StopPrice(utils.Float2str(r.StopPrice))
+	}
+	order, err := s.Do(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -106,7 +122,7 @@ func (c Client) CreateOrder(ctx context.Context, r models.CreateOrderRequest) (*
 			Price:           utils.Str2float(fill.Price),
 			Quantity:        utils.Str2float(fill.Quantity),
 			Commission:      utils.Str2float(fill.Commission),
-			CommissionAsset: utils.Str2float(fill.CommissionAsset),
+			CommissionAsset: fill.CommissionAsset,
 		}
 	}
 	return &models.CreateOrderResponse{
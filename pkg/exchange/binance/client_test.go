@@ -0,0 +1,157 @@
+package binance
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/adshao/go-binance/v2"
+	"github.com/stretchr/testify/require"
+
+	"crypto_bot/pkg/exchange/exchangetest"
+	"crypto_bot/pkg/exchange/models"
+)
+
+// TestClient_Conformance runs against the Binance spot testnet and is skipped
+// unless BINANCE_TESTNET_API_KEY and BINANCE_TESTNET_SECRET_KEY are set.
+func TestClient_Conformance(t *testing.T) {
+	apiKey, secretKey := os.Getenv("BINANCE_TESTNET_API_KEY"), os.Getenv("BINANCE_TESTNET_SECRET_KEY")
+	if apiKey == "" || secretKey == "" {
+		t.Skip("binance testnet credentials are not set")
+	}
+	binance.UseTestnet = true
+	defer func() { binance.UseTestnet = false }()
+
+	to := time.Now().Truncate(time.Minute)
+	exchangetest.Run(t, NewClient(apiKey, secretKey), exchangetest.Config{
+		Symbol:    "BTCUSDT",
+		Interval:  "1m",
+		StartTime: to.Add(-30 * time.Minute).UnixMilli(),
+		EndTime:   to.UnixMilli(),
+		Order: models.CreateOrderRequest{
+			Symbol:      "BTCUSDT",
+			Quantity:    0.001,
+			Price:       10000,
+			Side:        models.SideTypeBuy,
+			Type:        models.OrderTypeLimit,
+			InTimeForce: models.TimeInForceTypeGTC,
+		},
+	})
+}
+
+func TestCombinedStreams(t *testing.T) {
+	pairs := []models.WsKlineRequest{
+		{Symbol: "BTCUSDT", Interval: "1m"},
+		{Symbol: "ethusdt", Interval: "1m"},
+		{Symbol: "BTCUSDT", Interval: "1h"},
+		{Symbol: "btcusdt", Interval: "1m"},
+		{Symbol: "BTCUSDT", Interval: "1d"},
+	}
+	require.Equal(t, []map[string]string{
+		{"BTCUSDT": "1m", "ETHUSDT": "1m"},
+		{"BTCUSDT": "1h"},
+		{"BTCUSDT": "1d"},
+	}, combinedStreams(pairs))
+
+	pairs = make([]models.WsKlineRequest, MaxCombinedStreams+1)
+	for i := range pairs {
+		pairs[i] = models.WsKlineRequest{Symbol: fmt.Sprintf("S%dUSDT", i), Interval: "1m"}
+	}
+	conns := combinedStreams(pairs)
+	require.Len(t, conns, 2)
+	require.Len(t, conns[0], MaxCombinedStreams)
+	require.Len(t, conns[1], 1)
+}
+
+func TestSymbolChunks(t *testing.T) {
+	_, err := symbolChunks(nil)
+	require.Error(t, err)
+
+	chunks, err := symbolChunks([]string{"BTCUSDT", "ethusdt", "btcusdt"})
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"BTCUSDT", "ETHUSDT"}}, chunks)
+
+	symbols := make([]string, MaxCombinedStreams+1)
+	for i := range symbols {
+		symbols[i] = fmt.Sprintf("S%dUSDT", i)
+	}
+	chunks, err = symbolChunks(symbols)
+	require.NoError(t, err)
+	require.Len(t, chunks, 2)
+	require.Len(t, chunks[0], MaxCombinedStreams)
+	require.Equal(t, []string{symbols[MaxCombinedStreams]}, chunks[1])
+}
+
+// TestClient_WsCombinedKlines needs the network and is skipped with the conformance suite.
+func TestClient_WsCombinedKlines(t *testing.T) {
+	if os.Getenv("BINANCE_TESTNET_API_KEY") == "" {
+		t.Skip("binance testnet credentials are not set")
+	}
+	binance.UseTestnet = true
+	defer func() { binance.UseTestnet = false }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	pairs := []models.WsKlineRequest{
+		{Symbol: "BTCUSDT", Interval: "1m"},
+		{Symbol: "BTCUSDT", Interval: "3m"},
+		{Symbol: "ETHUSDT", Interval: "1m"},
+	}
+	events, _, err := NewClient("", "").WsCombinedKlines(ctx, models.WsCombinedKlineRequest{Pairs: pairs})
+	require.NoError(t, err)
+
+	seen := make(map[models.WsKlineRequest]bool)
+	for e := range events {
+		seen[models.WsKlineRequest{Symbol: e.Symbol, Interval: e.Kline.Interval}] = true
+		if len(seen) == len(pairs) {
+			cancel()
+		}
+	}
+	for _, p := range pairs {
+		require.True(t, seen[p], p)
+	}
+}
+
+// fakeConn serves events as a connection does: its read loop calls the handler
+// for every message until stop is closed and then closes done.
+func fakeConn(done *chan struct{}) serveFunc[int] {
+	return func(events chan int, _ chan error, quit <-chan struct{}) (chan struct{}, chan struct{}, error) {
+		*done = make(chan struct{})
+		stop := make(chan struct{})
+		go func() {
+			defer close(*done)
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				send(events, quit, i)
+			}
+		}()
+		return *done, stop, nil
+	}
+}
+
+func TestServeAll_StalledConsumer(t *testing.T) {
+	var done chan struct{}
+	ctx, cancel := context.WithCancel(context.Background())
+	events, errs, err := serveAll(ctx, []serveFunc[int]{fakeConn(&done)})
+	require.NoError(t, err)
+
+	// Nobody reads events once the buffer is full, as when the consumer
+	// returned on an error.
+	require.Eventually(t, func() bool { return len(events) == cap(events) }, time.Second, time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("connection is not closed")
+	}
+	for range events {
+	}
+	_, ok := <-errs
+	require.False(t, ok)
+}
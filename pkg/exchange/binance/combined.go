@@ -0,0 +1,116 @@
+package binance
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/adshao/go-binance/v2"
+
+	"crypto_bot/pkg/exchange/models"
+)
+
+// MaxCombinedStreams is the number of streams Binance allows on one connection.
+const MaxCombinedStreams = 1024
+
+// WsCombinedKlines subscribes to every pair over combined stream connections, as few
+// as possible: one connection carries up to MaxCombinedStreams streams, but only one
+// interval of a symbol. Events of all pairs are sent to the same channel, which is
+// closed with the errors channel when ctx is done or any of the connections drops.
+func (c Client) WsCombinedKlines(ctx context.Context, r models.WsCombinedKlineRequest) (<-chan *models.WsKlineEvent, <-chan error, error) {
+	if len(r.Pairs) == 0 {
+		return nil, nil, errors.New("no pairs to subscribe")
+	}
+	var conns []serveFunc[*models.WsKlineEvent]
+	for _, streams := range combinedStreams(r.Pairs) {
+		conns = append(conns, func(events chan *models.WsKlineEvent, errs chan error, quit <-chan struct{}) (chan struct{}, chan struct{}, error) {
+			return binance.WsCombinedKlineServe(streams, eventHandler(events, quit), errHandler(errs, quit))
+		})
+	}
+	return serveAll(ctx, conns)
+}
+
+// serveFunc connects to a stream sending its events and errors to the channels
+// until quit is closed.
+type serveFunc[E any] func(events chan E, errs chan error, quit <-chan struct{}) (done, stop chan struct{}, err error)
+
+// serveAll connects every conn sending events of all of them to the same channel,
+// which is closed with the errors channel when ctx is done or any of the
+// connections drops.
+func serveAll[E any](ctx context.Context, conns []serveFunc[E]) (<-chan E, <-chan error, error) {
+	errs := make(chan error, 100)
+	events := make(chan E, 100)
+
+	// quit is closed first on stop, it releases handlers blocked on the
+	// channels, which would keep the connections from closing.
+	quit := make(chan struct{})
+	var dones, stops []chan struct{}
+	stopAll := func() {
+		close(quit)
+		for _, stop := range stops {
+			close(stop)
+		}
+		for _, done := range dones {
+			<-done
+		}
+		close(errs)
+		close(events)
+	}
+
+	for _, conn := range conns {
+		done, stop, err := conn(events, errs, quit)
+		if err != nil {
+			stopAll()
+			return nil, nil, err
+		}
+		dones, stops = append(dones, done), append(stops, stop)
+	}
+
+	go func() {
+		anyDone := make(chan struct{})
+		var once sync.Once
+		for _, done := range dones {
+			go func() {
+				select {
+				case <-done:
+					once.Do(func() { close(anyDone) })
+				case <-anyDone:
+				}
+			}()
+		}
+		select {
+		case <-anyDone:
+		case <-ctx.Done():
+			once.Do(func() { close(anyDone) })
+		}
+		stopAll()
+	}()
+
+	return events, errs, nil
+}
+
+// combinedStreams splits pairs into symbol to interval maps of combined stream connections.
+func combinedStreams(pairs []models.WsKlineRequest) []map[string]string {
+	var conns []map[string]string
+	seen := make(map[models.WsKlineRequest]bool)
+	for _, p := range pairs {
+		p.Symbol = strings.ToUpper(p.Symbol)
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+
+		i := 0
+		for ; i < len(conns); i++ {
+			if _, ok := conns[i][p.Symbol]; !ok && len(conns[i]) < MaxCombinedStreams {
+				break
+			}
+		}
+		if i == len(conns) {
+			conns = append(conns, make(map[string]string))
+		}
+		conns[i][p.Symbol] = p.Interval
+	}
+	return conns
+}
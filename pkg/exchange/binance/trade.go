@@ -0,0 +1,130 @@
+package binance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/adshao/go-binance/v2"
+
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/exchange/utils"
+)
+
+// MaxTradesLimit is the most trades or aggregate trades returned at once.
+const MaxTradesLimit = 1000
+
+// Trades returns trades from r.FromID on with the historical trades endpoint,
+// or the most recent ones if it is zero.
+func (c Client) Trades(ctx context.Context, r models.TradesRequest) ([]*models.Trade, error) {
+	if r.Limit > MaxTradesLimit {
+		return nil, fmt.Errorf("limit exceeded")
+	}
+	var (
+		extTrades []*binance.Trade
+		err       error
+	)
+	if r.FromID > 0 {
+		s := c.b.NewHistoricalTradesService().Symbol(r.Symbol).FromID(r.FromID)
+		if r.Limit > 0 {
+			s = s.Limit(r.Limit)
+		}
+		extTrades, err = s.Do(ctx)
+	} else {
+		s := c.b.NewRecentTradesService().Symbol(r.Symbol)
+		if r.Limit > 0 {
+			s = s.Limit(r.Limit)
+		}
+		extTrades, err = s.Do(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	trades := make([]*models.Trade, len(extTrades))
+	for i, t := range extTrades {
+		trades[i] = utils.FromExtTradeToInt(t)
+	}
+	return trades, nil
+}
+
+func (c Client) AggTrades(ctx context.Context, r models.AggTradesRequest) ([]*models.AggTrade, error) {
+	s := c.b.NewAggTradesService().Symbol(r.Symbol)
+	if r.FromID > 0 {
+		s = s.FromID(r.FromID)
+	} else {
+		if r.StartTime > 0 {
+			s = s.StartTime(r.StartTime)
+		}
+		if r.EndTime > 0 {
+			s = s.EndTime(r.EndTime)
+		}
+	}
+	if r.Limit > 0 {
+		if r.Limit > MaxTradesLimit {
+			return nil, fmt.Errorf("limit exceeded")
+		}
+		s = s.Limit(r.Limit)
+	}
+	extTrades, err := s.Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	trades := make([]*models.AggTrade, len(extTrades))
+	for i, t := range extTrades {
+		trades[i] = utils.FromExtAggTradeToInt(t)
+	}
+	return trades, nil
+}
+
+// WsTrades subscribes to trades of every symbol over combined stream
+// connections of up to MaxCombinedStreams symbols, closing both channels when
+// ctx is done or any of the connections drops.
+func (c Client) WsTrades(ctx context.Context, r models.WsTradeRequest) (<-chan *models.WsTradeEvent, <-chan error, error) {
+	chunks, err := symbolChunks(r.Symbols)
+	if err != nil {
+		return nil, nil, err
+	}
+	var conns []serveFunc[*models.WsTradeEvent]
+	for _, symbols := range chunks {
+		conns = append(conns, func(events chan *models.WsTradeEvent, errs chan error, quit <-chan struct{}) (chan struct{}, chan struct{}, error) {
+			return binance.WsCombinedTradeServe(symbols, func(event *binance.WsCombinedTradeEvent) {
+				send(events, quit, utils.FromExtWsTradeEventToInt(&event.Data))
+			}, errHandler(errs, quit))
+		})
+	}
+	return serveAll(ctx, conns)
+}
+
+// WsAggTrades subscribes to aggregate trades as WsTrades does to trades.
+func (c Client) WsAggTrades(ctx context.Context, r models.WsTradeRequest) (<-chan *models.WsAggTradeEvent, <-chan error, error) {
+	chunks, err := symbolChunks(r.Symbols)
+	if err != nil {
+		return nil, nil, err
+	}
+	var conns []serveFunc[*models.WsAggTradeEvent]
+	for _, symbols := range chunks {
+		conns = append(conns, func(events chan *models.WsAggTradeEvent, errs chan error, quit <-chan struct{}) (chan struct{}, chan struct{}, error) {
+			return binance.WsCombinedAggTradeServe(symbols, func(event *binance.WsAggTradeEvent) {
+				send(events, quit, utils.FromExtWsAggTradeEventToInt(event))
+			}, errHandler(errs, quit))
+		})
+	}
+	return serveAll(ctx, conns)
+}
+
+// symbolChunks splits upper cased symbols without duplicates into chunks of
+// combined stream connections.
+func symbolChunks(symbols []string) ([][]string, error) {
+	if len(symbols) == 0 {
+		return nil, errors.New("no symbols to subscribe")
+	}
+	var unique []string
+	for _, s := range symbols {
+		if s = strings.ToUpper(s); !slices.Contains(unique, s) {
+			unique = append(unique, s)
+		}
+	}
+	return slices.Collect(slices.Chunk(unique, MaxCombinedStreams)), nil
+}
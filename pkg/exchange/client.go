@@ -0,0 +1,36 @@
+package exchange
+
+import (
+	"context"
+
+	"crypto_bot/pkg/exchange/models"
+)
+
+// Client is the method set shared by every exchange implementation, so strategy
+// code can run against the live exchange or the db based simulator unchanged.
+type Client interface {
+	KlineService
+	WsKlineService
+	OrderService
+	AccountService
+}
+
+type KlineService interface {
+	Klines(context.Context, models.KlinesRequest) ([]*models.Kline, error)
+}
+
+type WsKlineService interface {
+	WsKlines(context.Context, models.WsKlineRequest) (<-chan *models.WsKlineEvent, <-chan error, error)
+}
+
+type OrderService interface {
+	CreateOrder(context.Context, models.CreateOrderRequest) (*models.CreateOrderResponse, error)
+	GetOrder(context.Context, models.ReadOrderRequest) (*models.Order, error)
+	CancelOrder(context.Context, models.CancelOrderRequest) (*models.CancelOrderResponse, error)
+	ListOrders(context.Context, models.ListOrdersRequest) ([]*models.Order, error)
+	ListOpenOrders(context.Context, models.ListOpenOrdersRequest) ([]*models.Order, error)
+}
+
+type AccountService interface {
+	GetAccount(context.Context) (*models.Account, error)
+}
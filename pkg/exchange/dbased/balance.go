@@ -0,0 +1,160 @@
+package dbased
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage/pgdb"
+)
+
+// DefaultCommissionRate is the Binance spot rate without discounts.
+const DefaultCommissionRate = 0.001
+
+// MarketBuySlippage is the share of the estimated cost market buys lock on
+// top of it when the free balance allows, they fill at a price only known later.
+const MarketBuySlippage = 0.05
+
+var ErrInsufficientBalance = errors.New("account has insufficient balance for requested action")
+
+// quoteAssets are checked in order, so an asset must go before any of its suffixes.
+var quoteAssets = []string{
+	"USDT", "FDUSD", "USDC", "TUSD", "BUSD", "DAI", "BTC", "ETH", "BNB", "XRP", "TRX", "DOGE",
+	"EUR", "GBP", "TRY", "BRL", "ARS", "JPY", "MXN", "PLN", "RON", "UAH", "ZAR", "IDR",
+}
+
+func splitSymbol(symbol string) (base, quote string, err error) {
+	for _, q := range quoteAssets {
+		if b, ok := strings.CutSuffix(symbol, q); ok && b != "" {
+			return b, q, nil
+		}
+	}
+	return "", "", fmt.Errorf("unknown quote asset of symbol %q", symbol)
+}
+
+func (c *Client) SetCommissionRates(maker, taker float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.makerRate = maker
+	c.takerRate = taker
+}
+
+// spentAsset returns the asset the order pays with: the quote asset for buys
+// and the base asset for sells.
+func spentAsset(symbol string, side models.SideType) (string, error) {
+	base, quote, err := splitSymbol(symbol)
+	if err != nil {
+		return "", err
+	}
+	if side == models.SideTypeBuy {
+		return quote, nil
+	}
+	return base, nil
+}
+
+// lockAmount returns the amount reserved for the order. Buy orders without a
+// limit price are estimated with the stop price or the last known price, they
+// lock MarketBuySlippage on top of the estimate as far as the free balance
+// allows. The real cost is checked when the order is filled.
+func (c *Client) lockAmount(ctx context.Context, r models.CreateOrderRequest, asset string) (float64, error) {
+	if r.Side == models.SideTypeSell {
+		return r.Quantity, nil
+	}
+	price := r.Price
+	switch r.Type {
+	case models.OrderTypeMarket:
+		last, ok := c.prices[r.Symbol]
+		if !ok {
+			return 0, fmt.Errorf("no price of %s is known yet", r.Symbol)
+		}
+		price = last
+	case models.OrderTypeStopLoss, models.OrderTypeTakeProfit:
+		price = r.StopPrice
+	default:
+		return r.Quantity * price, nil
+	}
+	estimate := r.Quantity * price
+	b, err := c.s.ReadBalance(ctx, pgdb.ReadBalanceRequest{UserUID: c.user.UID, Asset: asset})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return estimate, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read balance: %w", err)
+	}
+	// An estimate above the free balance is locked as is to be rejected.
+	return math.Max(estimate, math.Min(estimate*(1+MarketBuySlippage), b.Free)), nil
+}
+
+func (c *Client) lock(ctx context.Context, asset string, amount float64) error {
+	return c.changeBalances(ctx, pgdb.BalanceChange{Asset: asset, Free: -amount, Locked: amount})
+}
+
+// unlockChanges returns the balance changes releasing the funds locked by the order.
+func unlockChanges(o *pgdb.Order) ([]pgdb.BalanceChange, error) {
+	asset, err := spentAsset(o.Symbol, models.SideType(o.Side))
+	if err != nil {
+		return nil, err
+	}
+	return []pgdb.BalanceChange{{Asset: asset, Free: o.Locked, Locked: -o.Locked}}, nil
+}
+
+// fillChanges returns the balance changes moving the funds of a filled order.
+// The commission is taken from the received asset, the difference between the
+// locked and the spent amount of market buys is returned to or taken from the
+// free balance.
+func (c *Client) fillChanges(o *pgdb.Order, e *execution) ([]pgdb.BalanceChange, error) {
+	base, quote, err := splitSymbol(o.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	rate := c.takerRate
+	if e.maker {
+		rate = c.makerRate
+	}
+	cost := o.Quantity * e.price
+
+	if models.SideType(o.Side) == models.SideTypeBuy {
+		e.commission, e.commissionAsset = o.Quantity*rate, base
+		return []pgdb.BalanceChange{
+			{Asset: quote, Free: o.Locked - cost, Locked: -o.Locked},
+			{Asset: base, Free: o.Quantity - e.commission},
+		}, nil
+	}
+	e.commission, e.commissionAsset = cost*rate, quote
+	return []pgdb.BalanceChange{
+		{Asset: base, Locked: -o.Locked},
+		{Asset: quote, Free: cost - e.commission},
+	}, nil
+}
+
+// settleOrder applies the balance changes and the new status of the order in
+// one transaction. It returns ErrInsufficientBalance and changes nothing if
+// any balance would become negative.
+func (c *Client) settleOrder(ctx context.Context, changes []pgdb.BalanceChange, status pgdb.UpdateOrderStatusRequest) (*pgdb.Order, error) {
+	o, err := c.s.SettleOrder(ctx, pgdb.SettleOrderRequest{UserUID: c.user.UID, Changes: changes, Status: status})
+	if errors.Is(err, pgdb.ErrNegativeBalance) {
+		return nil, ErrInsufficientBalance
+	}
+	if err != nil {
+		return nil, fmt.Errorf("settle order %d: %w", status.ID, err)
+	}
+	return o, nil
+}
+
+// changeBalances applies the changes to balances of the user in one
+// transaction, returning ErrInsufficientBalance if any would become negative.
+func (c *Client) changeBalances(ctx context.Context, changes ...pgdb.BalanceChange) error {
+	_, err := c.s.ChangeBalances(ctx, pgdb.ChangeBalancesRequest{UserUID: c.user.UID, Changes: changes})
+	if errors.Is(err, pgdb.ErrNegativeBalance) {
+		return ErrInsufficientBalance
+	}
+	if err != nil {
+		return fmt.Errorf("change balances: %w", err)
+	}
+	return nil
+}
@@ -2,54 +2,86 @@ package dbased
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"time"
+	"math"
+	"sync"
 
+	"github.com/jackc/pgx/v5"
+
+	"crypto_bot/pkg/exchange"
 	"crypto_bot/pkg/exchange/models"
 	"crypto_bot/pkg/exchange/utils"
+	"crypto_bot/pkg/storage"
 	"crypto_bot/pkg/storage/pgdb"
 )
 
+var _ exchange.Client = (*Client)(nil)
+
+var (
+	ErrUnknownOrder   = errors.New("unknown order sent")
+	ErrOrderNotExist  = errors.New("order does not exist")
+	ErrImmediateMatch = errors.New("order would immediately match and take")
+)
+
+// Accounts keeps the users the client trades as with their orders and balances.
+type Accounts interface {
+	ReadUser(context.Context, pgdb.ReadUserRequest) (*pgdb.User, error)
+	CreateOrder(context.Context, pgdb.CreateOrderRequest) (*pgdb.Order, error)
+	ReadOrder(context.Context, pgdb.ReadOrderRequest) (*pgdb.Order, error)
+	ReadOrders(context.Context, pgdb.ReadOrdersRequest) ([]*pgdb.Order, error)
+	ReadBalance(context.Context, pgdb.ReadBalanceRequest) (*pgdb.Balance, error)
+	ReadBalances(context.Context, pgdb.ReadBalancesRequest) ([]*pgdb.Balance, error)
+	ChangeBalances(context.Context, pgdb.ChangeBalancesRequest) ([]*pgdb.Balance, error)
+	SettleOrder(context.Context, pgdb.SettleOrderRequest) (*pgdb.Order, error)
+}
+
+type Storage interface {
+	Accounts
+	ReadKlines(context.Context, storage.ReadKlinesRequest) ([]*models.Kline, error)
+}
+
 type Client struct {
-	s         *pgdb.Client
+	s         Storage
 	user      *pgdb.User
 	startTime int64
+
+	mu sync.Mutex
+	// now is the simulated time, the close time of the last processed kline.
+	now       int64
+	prices    map[string]float64
+	makerRate float64
+	takerRate float64
 }
 
-func NewClient(ctx context.Context, s *pgdb.Client, username string, startTime int64) (*Client, error) {
+func NewClient(ctx context.Context, s Storage, username string, startTime int64) (*Client, error) {
 	user, err := s.ReadUser(ctx, pgdb.ReadUserRequest{Login: username})
 	if err != nil {
 		return nil, err
 	}
-	return &Client{s: s, user: user, startTime: startTime}, err
+	return &Client{
+		s:         s,
+		user:      user,
+		startTime: startTime,
+		now:       startTime,
+		prices:    make(map[string]float64),
+		makerRate: DefaultCommissionRate,
+		takerRate: DefaultCommissionRate,
+	}, nil
 }
 
 func (c *Client) Klines(ctx context.Context, r models.KlinesRequest) ([]*models.Kline, error) {
-	klines, err := c.s.ReadKlines(ctx, pgdb.ReadKlinesRequest{
+	return c.s.ReadKlines(ctx, storage.ReadKlinesRequest{
 		Symbol:    r.Symbol,
 		Interval:  r.Interval,
 		OpenTime:  r.StartTime,
 		CloseTime: r.EndTime,
+		Limit:     uint64(r.Limit),
 	})
-	if err != nil {
-		return nil, err
-	}
-	res := make([]*models.Kline, len(klines))
-	for i, k := range klines {
-		res[i] = &models.Kline{
-			OpenTime:  k.OpenTime,
-			Open:      k.Open,
-			High:      k.High,
-			Low:       k.Low,
-			Close:     k.Close,
-			Volume:    k.Volume,
-			CloseTime: k.CloseTime,
-			TradeNum:  k.TradeNum,
-		}
-	}
-	return res, nil
 }
 
+// WsKlines replays stored klines starting from the client start time. Open
+// orders are matched against every kline before it is sent.
 func (c *Client) WsKlines(ctx context.Context, r models.WsKlineRequest) (<-chan *models.WsKlineEvent, <-chan error, error) {
 	klines, err := c.Klines(ctx, models.KlinesRequest{
 		Symbol:    r.Symbol,
@@ -61,126 +93,247 @@ func (c *Client) WsKlines(ctx context.Context, r models.WsKlineRequest) (<-chan
 	}
 
 	ch := make(chan *models.WsKlineEvent)
-	errs := make(chan error)
+	errs := make(chan error, 1)
 	go func() {
 		defer close(ch)
 		defer close(errs)
 		for _, k := range klines {
+			if _, err := c.ProcessKline(ctx, r.Symbol, k); err != nil {
+				errs <- err
+				return
+			}
+			event := &models.WsKlineEvent{
+				Event:  "pgdb",
+				Time:   k.OpenTime,
+				Symbol: r.Symbol,
+				Kline: models.WsKline{
+					StartTime:            k.OpenTime,
+					EndTime:              k.CloseTime,
+					Symbol:               r.Symbol,
+					Interval:             r.Interval,
+					FirstTradeID:         k.FirstTradeID,
+					LastTradeID:          k.LastTradeID,
+					Open:                 k.Open,
+					Close:                k.Close,
+					High:                 k.High,
+					Low:                  k.Low,
+					Volume:               k.Volume,
+					TradeNum:             k.TradeNum,
+					IsFinal:              true,
+					QuoteVolume:          k.QuoteAssetVolume,
+					ActiveBuyVolume:      k.TakerBuyBaseAssetVolume,
+					ActiveBuyQuoteVolume: k.TakerBuyQuoteAssetVolume,
+				},
+			}
 			select {
 			case <-ctx.Done():
 				return
-			default:
-				ch <- &models.WsKlineEvent{
-					Event:  "pgdb",
-					Time:   k.OpenTime,
-					Symbol: r.Symbol,
-					Kline: models.WsKline{
-						StartTime: k.OpenTime,
-						EndTime:   k.CloseTime,
-						Symbol:    r.Symbol,
-						Interval:  r.Interval,
-						Open:      k.Open,
-						Close:     k.Close,
-						High:      k.High,
-						Low:       k.Low,
-						Volume:    k.Volume,
-						TradeNum:  k.TradeNum,
-						IsFinal:   true,
-					},
-				}
+			case ch <- event:
 			}
 		}
-
 	}()
 	return ch, errs, nil
 }
 
+// CreateOrder places an order that rests until a processed kline crosses its
+// price. Market orders are filled at the open of the next kline.
 func (c *Client) CreateOrder(ctx context.Context, r models.CreateOrderRequest) (*models.CreateOrderResponse, error) {
+	if err := validateOrder(r); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if r.Type == models.OrderTypeLimitMaker {
+		if price, ok := c.prices[r.Symbol]; ok && better(r.Side, price, r.Price) {
+			return nil, ErrImmediateMatch
+		}
+	}
+	asset, err := spentAsset(r.Symbol, r.Side)
+	if err != nil {
+		return nil, err
+	}
+	locked, err := c.lockAmount(ctx, r, asset)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.lock(ctx, asset, locked); err != nil {
+		return nil, err
+	}
+
+	timeInForce := r.InTimeForce
+	if timeInForce == "" && r.Type != models.OrderTypeMarket {
+		timeInForce = models.TimeInForceTypeGTC
+	}
+
 	order, err := c.s.CreateOrder(ctx, pgdb.CreateOrderRequest{
-		Symbol:   r.Symbol,
-		Price:    r.Price,
-		Quantity: r.Quantity,
-		Type:     string(r.Type),
-		Side:     string(r.Side),
+		Symbol:      r.Symbol,
+		Price:       r.Price,
+		Quantity:    r.Quantity,
+		Type:        string(r.Type),
+		Side:        string(r.Side),
+		StopPrice:   r.StopPrice,
+		TimeInForce: string(timeInForce),
+		Status:      string(models.OrderStatusTypeNew),
+		IsWorking:   !isStop(r.Type),
+		Time:        c.now,
+		Locked:      locked,
+		UserUID:     c.user.UID,
 	})
 	if err != nil {
+		// Without the order nothing would ever release the locked funds.
+		unlockErr := c.changeBalances(ctx, pgdb.BalanceChange{Asset: asset, Free: locked, Locked: -locked})
+		if unlockErr != nil {
+			err = errors.Join(err, unlockErr)
+		}
 		return nil, err
 	}
 	return &models.CreateOrderResponse{
-		Symbol:                   order.Symbol,
-		OrderID:                  order.ID,
-		TransactTime:             time.Now().UnixMilli(),
-		Price:                    order.Price,
-		OrigQuantity:             order.Quantity,
-		ExecutedQuantity:         order.Quantity,
-		CummulativeQuoteQuantity: order.Quantity,
-		IsIsolated:               false,
-		Status:                   models.OrderStatusTypeFilled,
-		TimeInForce:              models.TimeInForceTypeGTC,
-		Type:                     models.OrderTypeMarket,
-		Side:                     r.Side,
-		Fills: []*models.Fill{{
-			TradeID:         order.ID,
-			Price:           order.Price,
-			Quantity:        order.Quantity,
-			Commission:      0.1,
-			CommissionAsset: 0.1,
-		}},
+		Symbol:                  order.Symbol,
+		OrderID:                 order.ID,
+		TransactTime:            order.Time,
+		Price:                   order.Price,
+		OrigQuantity:            order.Quantity,
+		IsIsolated:              false,
+		Status:                  models.OrderStatusType(order.Status),
+		TimeInForce:             models.TimeInForceType(order.TimeInForce),
+		Type:                    models.OrderType(order.Type),
+		Side:                    models.SideType(order.Side),
+		SelfTradePreventionMode: models.SelfTradePreventionModeNone,
 	}, nil
 }
 
+func validateOrder(r models.CreateOrderRequest) error {
+	if r.Symbol == "" {
+		return fmt.Errorf("mandatory parameter 'symbol' was not sent")
+	}
+	if r.Quantity <= 0 {
+		return fmt.Errorf("invalid quantity")
+	}
+	if r.Side != models.SideTypeBuy && r.Side != models.SideTypeSell {
+		return fmt.Errorf("invalid side: %q", r.Side)
+	}
+	switch r.Type {
+	case models.OrderTypeMarket:
+	case models.OrderTypeLimit, models.OrderTypeLimitMaker:
+		if r.Price <= 0 {
+			return fmt.Errorf("invalid price")
+		}
+	case models.OrderTypeStopLoss, models.OrderTypeTakeProfit:
+		if r.StopPrice <= 0 {
+			return fmt.Errorf("invalid stop price")
+		}
+	case models.OrderTypeStopLossLimit, models.OrderTypeTakeProfitLimit:
+		if r.Price <= 0 {
+			return fmt.Errorf("invalid price")
+		}
+		if r.StopPrice <= 0 {
+			return fmt.Errorf("invalid stop price")
+		}
+	default:
+		return fmt.Errorf("invalid order type: %q", r.Type)
+	}
+	return nil
+}
+
 func (c *Client) GetOrder(ctx context.Context, r models.ReadOrderRequest) (*models.Order, error) {
 	o, err := c.s.ReadOrder(ctx, pgdb.ReadOrderRequest{ID: r.ID})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrOrderNotExist
+	}
 	if err != nil {
 		return nil, err
 	}
-	return &models.Order{
+	if r.Symbol != "" && o.Symbol != r.Symbol {
+		return nil, ErrOrderNotExist
+	}
+	return toOrder(o), nil
+}
+
+func (c *Client) CancelOrder(ctx context.Context, r models.CancelOrderRequest) (*models.CancelOrderResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	o, err := c.s.ReadOrder(ctx, pgdb.ReadOrderRequest{ID: r.ID})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUnknownOrder
+	}
+	if err != nil {
+		return nil, err
+	}
+	if o.Symbol != r.Symbol || !isOpen(o.Status) {
+		return nil, ErrUnknownOrder
+	}
+	changes, err := unlockChanges(o)
+	if err != nil {
+		return nil, err
+	}
+	o, err = c.settleOrder(ctx, changes, pgdb.UpdateOrderStatusRequest{
+		ID:                       o.ID,
+		Status:                   string(models.OrderStatusTypeCanceled),
+		IsWorking:                o.IsWorking,
+		ExecutedQuantity:         o.ExecutedQuantity,
+		CummulativeQuoteQuantity: o.CummulativeQuoteQuantity,
+		UpdateTime:               c.now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &models.CancelOrderResponse{
 		Symbol:                   o.Symbol,
 		OrderID:                  o.ID,
+		TransactTime:             o.UpdateTime,
 		Price:                    o.Price,
 		OrigQuantity:             o.Quantity,
-		ExecutedQuantity:         o.Quantity,
-		CummulativeQuoteQuantity: o.Quantity,
-		Status:                   models.OrderStatusTypeFilled,
-		TimeInForce:              models.TimeInForceTypeGTC,
+		ExecutedQuantity:         o.ExecutedQuantity,
+		CummulativeQuoteQuantity: o.CummulativeQuoteQuantity,
+		Status:                   models.OrderStatusType(o.Status),
+		TimeInForce:              models.TimeInForceType(o.TimeInForce),
 		Type:                     models.OrderType(o.Type),
 		Side:                     models.SideType(o.Side),
-		StopPrice:                o.Price,
-		OrigQuoteOrderQuantity:   o.Quantity,
+		SelfTradePreventionMode:  models.SelfTradePreventionModeNone,
 	}, nil
 }
 
-func (c *Client) CancelOrder(_ context.Context, _ models.CancelOrderRequest) (*models.CancelOrderResponse, error) {
-	return nil, fmt.Errorf("not found")
+func (c *Client) ListOrders(ctx context.Context, r models.ListOrdersRequest) ([]*models.Order, error) {
+	return c.listOrders(ctx, pgdb.ReadOrdersRequest{UserUID: c.user.UID, Symbol: r.Symbol})
 }
 
-func (c *Client) ListOrders(ctx context.Context, r models.ListOrdersRequest) ([]*models.Order, error) {
-	orders, err := c.s.ReadOrders(ctx, pgdb.ReadOrdersRequest{UserUID: c.user.UID, Symbol: r.Symbol})
+func (c *Client) ListOpenOrders(ctx context.Context, r models.ListOpenOrdersRequest) ([]*models.Order, error) {
+	return c.listOrders(ctx, pgdb.ReadOrdersRequest{UserUID: c.user.UID, Symbol: r.Symbol, Statuses: openStatuses})
+}
+
+func (c *Client) listOrders(ctx context.Context, r pgdb.ReadOrdersRequest) ([]*models.Order, error) {
+	orders, err := c.s.ReadOrders(ctx, r)
 	if err != nil {
 		return nil, err
 	}
 	res := make([]*models.Order, len(orders))
 	for i, o := range orders {
-		res[i] = &models.Order{
-			Symbol:                   o.Symbol,
-			OrderID:                  o.ID,
-			Price:                    o.Price,
-			OrigQuantity:             o.Quantity,
-			ExecutedQuantity:         o.Quantity,
-			CummulativeQuoteQuantity: o.Quantity,
-			Status:                   models.OrderStatusTypeFilled,
-			TimeInForce:              models.TimeInForceTypeGTC,
-			Type:                     models.OrderType(o.Type),
-			Side:                     models.SideType(o.Side),
-			StopPrice:                o.Price,
-			OrigQuoteOrderQuantity:   o.Quantity,
-		}
+		res[i] = toOrder(o)
 	}
 	return res, nil
 }
 
-func (c *Client) ListOpenOrders(_ context.Context, _ models.ListOpenOrdersRequest) ([]*models.Order, error) {
-	return nil, nil
+func toOrder(o *pgdb.Order) *models.Order {
+	return &models.Order{
+		Symbol:                   o.Symbol,
+		OrderID:                  o.ID,
+		OrderListId:              -1,
+		Price:                    o.Price,
+		OrigQuantity:             o.Quantity,
+		ExecutedQuantity:         o.ExecutedQuantity,
+		CummulativeQuoteQuantity: o.CummulativeQuoteQuantity,
+		Status:                   models.OrderStatusType(o.Status),
+		TimeInForce:              models.TimeInForceType(o.TimeInForce),
+		Type:                     models.OrderType(o.Type),
+		Side:                     models.SideType(o.Side),
+		StopPrice:                o.StopPrice,
+		Time:                     o.Time,
+		UpdateTime:               o.UpdateTime,
+		IsWorking:                o.IsWorking,
+	}
 }
 
 func (c *Client) GetAccount(ctx context.Context) (*models.Account, error) {
@@ -196,17 +349,32 @@ func (c *Client) GetAccount(ctx context.Context) (*models.Account, error) {
 			Locked: utils.Float2str(b.Locked),
 		}
 	}
+	c.mu.Lock()
+	maker, taker := c.makerRate, c.takerRate
+	c.mu.Unlock()
 	return &models.Account{
+		MakerCommission: int64(math.Round(maker * 10000)),
+		TakerCommission: int64(math.Round(taker * 10000)),
+		CommissionRates: models.CommissionRates{
+			Maker:  utils.Float2str(maker),
+			Taker:  utils.Float2str(taker),
+			Buyer:  utils.Float2str(0),
+			Seller: utils.Float2str(0),
+		},
 		CanTrade:    true,
 		CanWithdraw: true,
 		CanDeposit:  true,
+		AccountType: "SPOT",
 		Balances:    exchangeBalances,
 		UID:         c.user.UID,
 	}, nil
 }
 
 func (c *Client) SetStartTime(startTime int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.startTime = startTime
+	c.now = startTime
 }
 
 func (c *Client) SetUsername(ctx context.Context, username string) error {
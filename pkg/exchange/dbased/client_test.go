@@ -0,0 +1,38 @@
+package dbased
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"crypto_bot/pkg/exchange/dbased/dbasedtest"
+	"crypto_bot/pkg/exchange/exchangetest"
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage/pgdb"
+)
+
+func TestClient_Conformance(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := dbasedtest.NewStorage()
+	s.AddKlines("BTCUSDT", "1m", start, 10)
+	s.SetBalance(1, pgdb.Balance{Asset: "USDT", Free: 1000})
+
+	c, err := NewClient(context.Background(), s, "user", start.UnixMilli())
+	require.NoError(t, err)
+
+	exchangetest.Run(t, c, exchangetest.Config{
+		Symbol:    "BTCUSDT",
+		Interval:  "1m",
+		StartTime: start.UnixMilli(),
+		EndTime:   start.Add(10 * time.Minute).UnixMilli(),
+		Order: models.CreateOrderRequest{
+			Symbol:   "BTCUSDT",
+			Quantity: 0.1,
+			Price:    100,
+			Side:     models.SideTypeBuy,
+			Type:     models.OrderTypeLimit,
+		},
+	})
+}
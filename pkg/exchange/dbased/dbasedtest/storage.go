@@ -0,0 +1,74 @@
+package dbasedtest
+
+import (
+	"context"
+	"time"
+
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage"
+	"crypto_bot/pkg/storage/memory"
+	"crypto_bot/pkg/storage/pgdb"
+)
+
+// Storage keeps the users, klines, orders and balances used by dbased.Client
+// in memory. It has a single user "user" with uid 1.
+type Storage struct {
+	*memory.Accounts
+	*memory.KlineRepository
+}
+
+func NewStorage() *Storage {
+	s := &Storage{Accounts: memory.NewAccounts(), KlineRepository: memory.NewKlineRepository()}
+	if _, err := s.CreateUser(context.Background(), pgdb.CreateUserRequest{Login: "user"}); err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func (s *Storage) Klines(symbol, interval string) []*models.Kline {
+	klines, err := s.ReadKlines(context.Background(), storage.ReadKlinesRequest{Symbol: symbol, Interval: interval})
+	if err != nil {
+		panic(err)
+	}
+	return klines
+}
+
+func (s *Storage) SetBalance(uid int64, b pgdb.Balance) {
+	ctx := context.Background()
+	_, err := s.ReadBalance(ctx, pgdb.ReadBalanceRequest{UserUID: uid, Asset: b.Asset})
+	if err == nil {
+		_, err = s.UpdateBalance(ctx, pgdb.UpdateBalanceRequest{UserUID: uid, Asset: b.Asset, Free: b.Free, Locked: b.Locked})
+	} else {
+		_, err = s.CreateBalance(ctx, pgdb.CreateBalanceRequest{UserUID: uid, Asset: b.Asset, Free: b.Free, Locked: b.Locked})
+	}
+	if err != nil {
+		panic(err)
+	}
+}
+
+// AddKline adds a kline.
+func (s *Storage) AddKline(symbol, interval string, k *models.Kline) {
+	err := s.WriteKlines(context.Background(), storage.WriteKlinesRequest{Symbol: symbol, Interval: interval, Klines: []*models.Kline{k}})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// AddKlines adds n one minute klines starting at from. Prices start at 100
+// and grow by one each kline.
+func (s *Storage) AddKlines(symbol, interval string, from time.Time, n int) {
+	for i := 0; i < n; i++ {
+		openTime := from.Add(time.Duration(i) * time.Minute)
+		price := 100 + float64(i)
+		s.AddKline(symbol, interval, &models.Kline{
+			OpenTime:  openTime.UnixMilli(),
+			Open:      price,
+			High:      price + 1,
+			Low:       price - 1,
+			Close:     price + 0.5,
+			Volume:    10,
+			CloseTime: openTime.Add(time.Minute).UnixMilli() - 1,
+			TradeNum:  5,
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package dbased
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage/pgdb"
+)
+
+var openStatuses = []string{
+	string(models.OrderStatusTypeNew),
+	string(models.OrderStatusTypePartiallyFilled),
+}
+
+type OrderUpdate struct {
+	Order *models.Order
+	// Fill is set when the update filled the order.
+	Fill *models.Fill
+}
+
+// ProcessKline advances the simulated clock to the end of the kline and
+// executes every open order of the symbol whose price was crossed by it.
+// It returns the orders changed by the kline.
+func (c *Client) ProcessKline(ctx context.Context, symbol string, k *models.Kline) ([]*OrderUpdate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	orders, err := c.s.ReadOrders(ctx, pgdb.ReadOrdersRequest{
+		UserUID:  c.user.UID,
+		Symbol:   symbol,
+		Statuses: openStatuses,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read open orders: %w", err)
+	}
+	var updates []*OrderUpdate
+	for _, o := range orders {
+		e, ok := match(o, k)
+		if !ok {
+			continue
+		}
+		updated, err := c.execute(ctx, o, &e)
+		if err != nil {
+			return nil, err
+		}
+		u := &OrderUpdate{Order: toOrder(updated)}
+		if e.price > 0 {
+			u.Fill = &models.Fill{
+				TradeID:         o.ID,
+				Price:           e.price,
+				Quantity:        o.Quantity,
+				Commission:      e.commission,
+				CommissionAsset: e.commissionAsset,
+			}
+		}
+		updates = append(updates, u)
+	}
+	c.now = k.CloseTime
+	c.prices[symbol] = k.Close
+	return updates, nil
+}
+
+// execute stores the execution of the order together with the balance
+// changes it makes.
+func (c *Client) execute(ctx context.Context, o *pgdb.Order, e *execution) (*pgdb.Order, error) {
+	var (
+		changes []pgdb.BalanceChange
+		err     error
+	)
+	switch e.status {
+	case models.OrderStatusTypeFilled:
+		changes, err = c.fillChanges(o, e)
+	case models.OrderStatusTypeExpired:
+		changes, err = unlockChanges(o)
+	}
+	if err != nil {
+		return nil, err
+	}
+	updated, err := c.settleOrder(ctx, changes, e.update(o))
+	if !errors.Is(err, ErrInsufficientBalance) || e.status != models.OrderStatusTypeFilled {
+		return updated, err
+	}
+	// The fill costs more than the order locked and the free balance holds,
+	// so the order expires unfilled.
+	*e = execution{status: models.OrderStatusTypeExpired, isWorking: true, time: e.time}
+	return c.execute(ctx, o, e)
+}
+
+type execution struct {
+	status    models.OrderStatusType
+	isWorking bool
+	// price is zero when nothing was filled.
+	price float64
+	maker bool
+	time  int64
+
+	commission      float64
+	commissionAsset string
+}
+
+func (e execution) update(o *pgdb.Order) pgdb.UpdateOrderStatusRequest {
+	r := pgdb.UpdateOrderStatusRequest{
+		ID:              o.ID,
+		Status:          string(e.status),
+		IsWorking:       e.isWorking,
+		UpdateTime:      e.time,
+		Commission:      e.commission,
+		CommissionAsset: e.commissionAsset,
+	}
+	if e.price > 0 {
+		r.ExecutedQuantity = o.Quantity
+		r.CummulativeQuoteQuantity = o.Quantity * e.price
+	}
+	return r
+}
+
+func filledAt(price float64, maker bool, time int64) execution {
+	return execution{status: models.OrderStatusTypeFilled, isWorking: true, price: price, maker: maker, time: time}
+}
+
+// match decides what happens to an open order during the kline. Orders that
+// are hit by the kline open are filled at the open price as a taker, orders
+// crossed later inside the kline are filled at their own price.
+func match(o *pgdb.Order, k *models.Kline) (execution, bool) {
+	switch t := models.OrderType(o.Type); t {
+	case models.OrderTypeMarket:
+		return filledAt(k.Open, false, k.OpenTime), true
+	case models.OrderTypeLimit, models.OrderTypeLimitMaker:
+		return matchLimit(o, k, t == models.OrderTypeLimitMaker)
+	case models.OrderTypeStopLoss, models.OrderTypeTakeProfit:
+		price, ok := trigger(o, k)
+		if !ok {
+			return execution{}, false
+		}
+		if price == k.Open {
+			return filledAt(price, false, k.OpenTime), true
+		}
+		return filledAt(price, false, k.CloseTime), true
+	case models.OrderTypeStopLossLimit, models.OrderTypeTakeProfitLimit:
+		if o.IsWorking {
+			return matchLimit(o, k, false)
+		}
+		if _, ok := trigger(o, k); !ok {
+			return execution{}, false
+		}
+		// The order of prices inside the kline is unknown, so after the trigger
+		// the limit part is only filled at its own price.
+		if crossed(models.SideType(o.Side), o.Price, k) {
+			return filledAt(o.Price, true, k.CloseTime), true
+		}
+		return execution{status: models.OrderStatusTypeNew, isWorking: true, time: k.CloseTime}, true
+	}
+	return execution{}, false
+}
+
+func matchLimit(o *pgdb.Order, k *models.Kline, makerOnly bool) (execution, bool) {
+	side := models.SideType(o.Side)
+	if !crossed(side, o.Price, k) {
+		switch models.TimeInForceType(o.TimeInForce) {
+		case models.TimeInForceTypeIOC, models.TimeInForceTypeFOK:
+			return execution{status: models.OrderStatusTypeExpired, isWorking: true, time: k.CloseTime}, true
+		}
+		return execution{}, false
+	}
+	if !makerOnly && better(side, k.Open, o.Price) {
+		return filledAt(k.Open, false, k.OpenTime), true
+	}
+	return filledAt(o.Price, true, k.CloseTime), true
+}
+
+// crossed reports whether a limit order at price could be filled during the kline.
+func crossed(side models.SideType, price float64, k *models.Kline) bool {
+	if side == models.SideTypeBuy {
+		return k.Low <= price
+	}
+	return k.High >= price
+}
+
+// better reports whether a is the same or a better execution price than b for the side.
+func better(side models.SideType, a, b float64) bool {
+	if side == models.SideTypeBuy {
+		return a <= b
+	}
+	return a >= b
+}
+
+// trigger reports whether the stop price of the order was reached during the
+// kline and the price the resulting market order would be filled at.
+func trigger(o *pgdb.Order, k *models.Kline) (float64, bool) {
+	side := models.SideType(o.Side)
+	stopLoss := isStopLoss(models.OrderType(o.Type))
+
+	// A buy stop loss and a sell take profit wait for the price to rise.
+	rising := (side == models.SideTypeBuy) == stopLoss
+	if rising {
+		if k.Open >= o.StopPrice {
+			return k.Open, true
+		}
+		return o.StopPrice, k.High >= o.StopPrice
+	}
+	if k.Open <= o.StopPrice {
+		return k.Open, true
+	}
+	return o.StopPrice, k.Low <= o.StopPrice
+}
+
+func isStopLoss(t models.OrderType) bool {
+	return t == models.OrderTypeStopLoss || t == models.OrderTypeStopLossLimit
+}
+
+func isStop(t models.OrderType) bool {
+	switch t {
+	case models.OrderTypeStopLoss, models.OrderTypeStopLossLimit,
+		models.OrderTypeTakeProfit, models.OrderTypeTakeProfitLimit:
+		return true
+	}
+	return false
+}
+
+func isOpen(status string) bool {
+	for _, s := range openStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
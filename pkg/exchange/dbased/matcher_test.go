@@ -0,0 +1,385 @@
+package dbased
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"crypto_bot/pkg/exchange/dbased/dbasedtest"
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage/pgdb"
+)
+
+func TestMatch(t *testing.T) {
+	k := &models.Kline{OpenTime: 0, Open: 100, High: 110, Low: 90, Close: 105, CloseTime: 59}
+
+	testCases := []struct {
+		name   string
+		order  pgdb.Order
+		want   execution
+		wantOk bool
+	}{
+		{
+			name:   "market fills at open",
+			order:  pgdb.Order{Type: "MARKET", Side: "BUY"},
+			want:   filledAt(100, false, 0),
+			wantOk: true,
+		},
+		{
+			name:   "buy limit inside range fills at limit as maker",
+			order:  pgdb.Order{Type: "LIMIT", Side: "BUY", Price: 95, TimeInForce: "GTC"},
+			want:   filledAt(95, true, 59),
+			wantOk: true,
+		},
+		{
+			name:   "buy limit above open fills at open as taker",
+			order:  pgdb.Order{Type: "LIMIT", Side: "BUY", Price: 101, TimeInForce: "GTC"},
+			want:   filledAt(100, false, 0),
+			wantOk: true,
+		},
+		{
+			name:  "buy limit below low rests",
+			order: pgdb.Order{Type: "LIMIT", Side: "BUY", Price: 89, TimeInForce: "GTC"},
+		},
+		{
+			name:   "sell limit above high expires with ioc",
+			order:  pgdb.Order{Type: "LIMIT", Side: "SELL", Price: 111, TimeInForce: "IOC"},
+			want:   execution{status: models.OrderStatusTypeExpired, isWorking: true, time: 59},
+			wantOk: true,
+		},
+		{
+			name:   "sell limit maker below open fills at limit",
+			order:  pgdb.Order{Type: "LIMIT_MAKER", Side: "SELL", Price: 99, TimeInForce: "GTC"},
+			want:   filledAt(99, true, 59),
+			wantOk: true,
+		},
+		{
+			name:   "sell stop loss triggers at stop",
+			order:  pgdb.Order{Type: "STOP_LOSS", Side: "SELL", StopPrice: 95},
+			want:   filledAt(95, false, 59),
+			wantOk: true,
+		},
+		{
+			name:   "sell stop loss gapped fills at open",
+			order:  pgdb.Order{Type: "STOP_LOSS", Side: "SELL", StopPrice: 102},
+			want:   filledAt(100, false, 0),
+			wantOk: true,
+		},
+		{
+			name:  "buy stop loss not reached",
+			order: pgdb.Order{Type: "STOP_LOSS", Side: "BUY", StopPrice: 111},
+		},
+		{
+			name:   "sell take profit triggers at stop",
+			order:  pgdb.Order{Type: "TAKE_PROFIT", Side: "SELL", StopPrice: 108},
+			want:   filledAt(108, false, 59),
+			wantOk: true,
+		},
+		{
+			name:   "buy take profit triggers at stop",
+			order:  pgdb.Order{Type: "TAKE_PROFIT", Side: "BUY", StopPrice: 92},
+			want:   filledAt(92, false, 59),
+			wantOk: true,
+		},
+		{
+			name:   "stop loss limit triggered and filled",
+			order:  pgdb.Order{Type: "STOP_LOSS_LIMIT", Side: "SELL", StopPrice: 95, Price: 94, TimeInForce: "GTC"},
+			want:   filledAt(94, true, 59),
+			wantOk: true,
+		},
+		{
+			name:   "stop loss limit triggered but not filled",
+			order:  pgdb.Order{Type: "STOP_LOSS_LIMIT", Side: "SELL", StopPrice: 95, Price: 111, TimeInForce: "GTC"},
+			want:   execution{status: models.OrderStatusTypeNew, isWorking: true, time: 59},
+			wantOk: true,
+		},
+		{
+			name:   "working take profit limit fills as limit",
+			order:  pgdb.Order{Type: "TAKE_PROFIT_LIMIT", Side: "SELL", StopPrice: 120, Price: 109, TimeInForce: "GTC", IsWorking: true},
+			want:   filledAt(109, true, 59),
+			wantOk: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := match(&tc.order, k)
+			require.Equal(t, tc.wantOk, ok)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestClient_ProcessKline(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := dbasedtest.NewStorage()
+	s.AddKlines("BTCUSDT", "1m", start, 5)
+	s.SetBalance(1, pgdb.Balance{Asset: "USDT", Free: 1000})
+	s.SetBalance(1, pgdb.Balance{Asset: "BTC", Free: 1})
+	klines := s.Klines("BTCUSDT", "1m")
+
+	c, err := NewClient(ctx, s, "user", start.UnixMilli())
+	require.NoError(t, err)
+	c.SetCommissionRates(0.0005, 0.001)
+
+	_, err = c.CreateOrder(ctx, models.CreateOrderRequest{
+		Symbol: "BTCUSDT", Quantity: 1, Side: models.SideTypeBuy, Type: models.OrderTypeMarket,
+	})
+	require.Error(t, err, "market buy without known price")
+
+	requireProcessKline(t, c, "BTCUSDT", klines[0])
+
+	market, err := c.CreateOrder(ctx, models.CreateOrderRequest{
+		Symbol: "BTCUSDT", Quantity: 1, Side: models.SideTypeBuy, Type: models.OrderTypeMarket,
+	})
+	require.NoError(t, err)
+	require.Equal(t, models.OrderStatusTypeNew, market.Status)
+	requireBalance(t, s, "USDT", 1000-100.5*1.05, 100.5*1.05)
+
+	limit, err := c.CreateOrder(ctx, models.CreateOrderRequest{
+		Symbol: "BTCUSDT", Quantity: 1, Price: 103.5, Side: models.SideTypeSell, Type: models.OrderTypeLimit,
+	})
+	require.NoError(t, err)
+	requireBalance(t, s, "BTC", 0, 1)
+
+	requireProcessKline(t, c, "BTCUSDT", klines[1])
+
+	o, err := c.GetOrder(ctx, models.ReadOrderRequest{ID: market.OrderID, Symbol: "BTCUSDT"})
+	require.NoError(t, err)
+	require.Equal(t, models.OrderStatusTypeFilled, o.Status)
+	require.Equal(t, klines[1].Open, o.CummulativeQuoteQuantity)
+	require.Equal(t, klines[1].OpenTime, o.UpdateTime)
+	requireBalance(t, s, "USDT", 899, 0)
+	requireBalance(t, s, "BTC", 0.999, 1)
+
+	open, err := c.ListOpenOrders(ctx, models.ListOpenOrdersRequest{Symbol: "BTCUSDT"})
+	require.NoError(t, err)
+	require.Equal(t, []int64{limit.OrderID}, orderIDs(open))
+
+	_, err = c.CreateOrder(ctx, models.CreateOrderRequest{
+		Symbol: "BTCUSDT", Quantity: 0.5, Price: 99, Side: models.SideTypeSell, Type: models.OrderTypeLimitMaker,
+	})
+	require.ErrorIs(t, err, ErrImmediateMatch)
+
+	requireProcessKline(t, c, "BTCUSDT", klines[2])
+	requireProcessKline(t, c, "BTCUSDT", klines[3])
+
+	o, err = c.GetOrder(ctx, models.ReadOrderRequest{ID: limit.OrderID, Symbol: "BTCUSDT"})
+	require.NoError(t, err)
+	require.Equal(t, models.OrderStatusTypeFilled, o.Status)
+	require.Equal(t, 103.5, o.CummulativeQuoteQuantity)
+	requireBalance(t, s, "USDT", 899+103.5*(1-0.0005), 0)
+	requireBalance(t, s, "BTC", 0.999, 0)
+
+	_, err = c.CancelOrder(ctx, models.CancelOrderRequest{ID: limit.OrderID, Symbol: "BTCUSDT"})
+	require.ErrorIs(t, err, ErrUnknownOrder)
+}
+
+func TestClient_Balance(t *testing.T) {
+	ctx := context.Background()
+	s := dbasedtest.NewStorage()
+	s.SetBalance(1, pgdb.Balance{Asset: "USDT", Free: 100})
+
+	c, err := NewClient(ctx, s, "user", 0)
+	require.NoError(t, err)
+	c.SetCommissionRates(0.0002, 0.0004)
+
+	_, err = c.CreateOrder(ctx, models.CreateOrderRequest{
+		Symbol: "BTCUSDT", Quantity: 2, Price: 60, Side: models.SideTypeBuy, Type: models.OrderTypeLimit,
+	})
+	require.ErrorIs(t, err, ErrInsufficientBalance)
+	_, err = c.CreateOrder(ctx, models.CreateOrderRequest{
+		Symbol: "BTCUSDT", Quantity: 1, Price: 60, Side: models.SideTypeSell, Type: models.OrderTypeLimit,
+	})
+	require.ErrorIs(t, err, ErrInsufficientBalance)
+
+	o, err := c.CreateOrder(ctx, models.CreateOrderRequest{
+		Symbol: "BTCUSDT", Quantity: 1, Price: 60, Side: models.SideTypeBuy, Type: models.OrderTypeLimit,
+	})
+	require.NoError(t, err)
+	requireBalance(t, s, "USDT", 40, 60)
+
+	_, err = c.CancelOrder(ctx, models.CancelOrderRequest{ID: o.OrderID, Symbol: "BTCUSDT"})
+	require.NoError(t, err)
+	requireBalance(t, s, "USDT", 100, 0)
+
+	acc, err := c.GetAccount(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), acc.MakerCommission)
+	require.Equal(t, int64(4), acc.TakerCommission)
+	require.Equal(t, "0.00020000", acc.CommissionRates.Maker)
+	require.Equal(t, "0.00040000", acc.CommissionRates.Taker)
+}
+
+func TestClient_MarketBuySlippage(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := dbasedtest.NewStorage()
+	s.AddKline("BTCUSDT", "1m", &models.Kline{OpenTime: 0, Open: 100, High: 100, Low: 100, Close: 100, CloseTime: 59})
+	s.AddKline("BTCUSDT", "1m", &models.Kline{OpenTime: 60, Open: 110, High: 110, Low: 110, Close: 110, CloseTime: 119})
+	s.SetBalance(1, pgdb.Balance{Asset: "USDT", Free: 215})
+
+	c, err := NewClient(ctx, s, "user", start.UnixMilli())
+	require.NoError(t, err)
+	c.SetCommissionRates(0, 0)
+	klines := s.Klines("BTCUSDT", "1m")
+	requireProcessKline(t, c, "BTCUSDT", klines[0])
+
+	// Both orders lock 105 but fill at 110, only the first one finds the
+	// difference in the free balance.
+	first, err := c.CreateOrder(ctx, models.CreateOrderRequest{
+		Symbol: "BTCUSDT", Quantity: 1, Side: models.SideTypeBuy, Type: models.OrderTypeMarket,
+	})
+	require.NoError(t, err)
+	second, err := c.CreateOrder(ctx, models.CreateOrderRequest{
+		Symbol: "BTCUSDT", Quantity: 1, Side: models.SideTypeBuy, Type: models.OrderTypeMarket,
+	})
+	require.NoError(t, err)
+	requireBalance(t, s, "USDT", 5, 210)
+
+	updates, err := c.ProcessKline(ctx, "BTCUSDT", klines[1])
+	require.NoError(t, err)
+	require.Len(t, updates, 2)
+	statuses := map[int64]models.OrderStatusType{}
+	for _, u := range updates {
+		statuses[u.Order.OrderID] = u.Order.Status
+	}
+	require.Equal(t, map[int64]models.OrderStatusType{
+		first.OrderID:  models.OrderStatusTypeFilled,
+		second.OrderID: models.OrderStatusTypeExpired,
+	}, statuses)
+	requireBalance(t, s, "USDT", 105, 0)
+	requireBalance(t, s, "BTC", 1, 0)
+}
+
+func TestClient_MarketBuyLockCap(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := dbasedtest.NewStorage()
+	s.AddKline("BTCUSDT", "1m", &models.Kline{OpenTime: 0, Open: 100, High: 100, Low: 100, Close: 100, CloseTime: 59})
+	s.AddKline("BTCUSDT", "1m", &models.Kline{OpenTime: 60, Open: 100.5, High: 101, Low: 100, Close: 101, CloseTime: 119})
+	s.SetBalance(1, pgdb.Balance{Asset: "USDT", Free: 100})
+
+	c, err := NewClient(ctx, s, "user", start.UnixMilli())
+	require.NoError(t, err)
+	c.SetCommissionRates(0, 0)
+	klines := s.Klines("BTCUSDT", "1m")
+	requireProcessKline(t, c, "BTCUSDT", klines[0])
+
+	// The estimated cost above the free balance is rejected.
+	_, err = c.CreateOrder(ctx, models.CreateOrderRequest{
+		Symbol: "BTCUSDT", Quantity: 1.01, Side: models.SideTypeBuy, Type: models.OrderTypeMarket,
+	})
+	require.ErrorIs(t, err, ErrInsufficientBalance)
+
+	// The slippage on top of the estimated 99 is capped by the free balance.
+	_, err = c.CreateOrder(ctx, models.CreateOrderRequest{
+		Symbol: "BTCUSDT", Quantity: 0.99, Side: models.SideTypeBuy, Type: models.OrderTypeMarket,
+	})
+	require.NoError(t, err)
+	requireBalance(t, s, "USDT", 0, 100)
+
+	updates, err := c.ProcessKline(ctx, "BTCUSDT", klines[1])
+	require.NoError(t, err)
+	require.Len(t, updates, 1)
+	require.Equal(t, models.OrderStatusTypeFilled, updates[0].Order.Status)
+	requireBalance(t, s, "USDT", 100-0.99*100.5, 0)
+	requireBalance(t, s, "BTC", 0.99, 0)
+}
+
+// failingOrders fails to store orders.
+type failingOrders struct {
+	*dbasedtest.Storage
+}
+
+var errStorage = errors.New("storage failed")
+
+func (failingOrders) CreateOrder(context.Context, pgdb.CreateOrderRequest) (*pgdb.Order, error) {
+	return nil, errStorage
+}
+
+func TestClient_CreateOrderFailed(t *testing.T) {
+	ctx := context.Background()
+	s := dbasedtest.NewStorage()
+	s.SetBalance(1, pgdb.Balance{Asset: "USDT", Free: 100})
+
+	c, err := NewClient(ctx, failingOrders{s}, "user", 0)
+	require.NoError(t, err)
+
+	_, err = c.CreateOrder(ctx, models.CreateOrderRequest{
+		Symbol: "BTCUSDT", Quantity: 1, Price: 60, Side: models.SideTypeBuy, Type: models.OrderTypeLimit,
+	})
+	require.ErrorIs(t, err, errStorage)
+	requireBalance(t, s, "USDT", 100, 0)
+}
+
+// failingSettlement fails to settle orders once.
+type failingSettlement struct {
+	*dbasedtest.Storage
+	failed bool
+}
+
+func (s *failingSettlement) SettleOrder(ctx context.Context, r pgdb.SettleOrderRequest) (*pgdb.Order, error) {
+	if !s.failed {
+		s.failed = true
+		return nil, errStorage
+	}
+	return s.Storage.SettleOrder(ctx, r)
+}
+
+func TestClient_ProcessKlineSettleFailed(t *testing.T) {
+	ctx := context.Background()
+	s := dbasedtest.NewStorage()
+	s.SetBalance(1, pgdb.Balance{Asset: "USDT", Free: 100})
+	k := &models.Kline{OpenTime: 0, Open: 50, High: 55, Low: 45, Close: 50, CloseTime: 59}
+
+	c, err := NewClient(ctx, &failingSettlement{Storage: s}, "user", 0)
+	require.NoError(t, err)
+	c.SetCommissionRates(0, 0)
+	order, err := c.CreateOrder(ctx, models.CreateOrderRequest{
+		Symbol: "BTCUSDT", Quantity: 1, Price: 60, Side: models.SideTypeBuy, Type: models.OrderTypeLimit,
+	})
+	require.NoError(t, err)
+
+	// Neither the balances nor the order change when settling fails, so
+	// the next kline fills the order once.
+	_, err = c.ProcessKline(ctx, "BTCUSDT", k)
+	require.ErrorIs(t, err, errStorage)
+	requireBalance(t, s, "USDT", 40, 60)
+	o, err := c.GetOrder(ctx, models.ReadOrderRequest{ID: order.OrderID})
+	require.NoError(t, err)
+	require.Equal(t, models.OrderStatusTypeNew, o.Status)
+
+	updates, err := c.ProcessKline(ctx, "BTCUSDT", k)
+	require.NoError(t, err)
+	require.Len(t, updates, 1)
+	require.Equal(t, models.OrderStatusTypeFilled, updates[0].Order.Status)
+	requireBalance(t, s, "USDT", 50, 0)
+	requireBalance(t, s, "BTC", 1, 0)
+}
+
+func requireProcessKline(t *testing.T, c *Client, symbol string, k *models.Kline) {
+	t.Helper()
+	_, err := c.ProcessKline(context.Background(), symbol, k)
+	require.NoError(t, err)
+}
+
+func requireBalance(t *testing.T, s *dbasedtest.Storage, asset string, free, locked float64) {
+	t.Helper()
+	b, err := s.ReadBalance(context.Background(), pgdb.ReadBalanceRequest{UserUID: 1, Asset: asset})
+	require.NoError(t, err)
+	require.InDelta(t, free, b.Free, 1e-9, "free %s", asset)
+	require.InDelta(t, locked, b.Locked, 1e-9, "locked %s", asset)
+}
+
+func orderIDs(orders []*models.Order) []int64 {
+	ids := make([]int64, len(orders))
+	for i, o := range orders {
+		ids[i] = o.OrderID
+	}
+	return ids
+}
@@ -0,0 +1,171 @@
+package exchangetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"crypto_bot/pkg/exchange"
+	"crypto_bot/pkg/exchange/models"
+)
+
+const wsTimeout = 30 * time.Second
+
+type Config struct {
+	Symbol   string
+	Interval string
+	// StartTime and EndTime bound a range in which the exchange is known to have klines.
+	StartTime int64
+	EndTime   int64
+	// Order is placed by the order tests. They are skipped when it is empty.
+	Order models.CreateOrderRequest
+}
+
+// Run checks the behaviour every exchange.Client implementation must share.
+func Run(t *testing.T, c exchange.Client, cfg Config) {
+	t.Run("Klines", func(t *testing.T) { testKlines(t, c, cfg) })
+	t.Run("KlinesLimit", func(t *testing.T) { testKlinesLimit(t, c, cfg) })
+	t.Run("WsKlines", func(t *testing.T) { testWsKlines(t, c, cfg) })
+	t.Run("Orders", func(t *testing.T) { testOrders(t, c, cfg) })
+	t.Run("CancelUnknownOrder", func(t *testing.T) { testCancelUnknownOrder(t, c, cfg) })
+	t.Run("Account", func(t *testing.T) { testAccount(t, c) })
+}
+
+func testKlines(t *testing.T, c exchange.Client, cfg Config) {
+	klines, err := c.Klines(context.Background(), models.KlinesRequest{
+		Symbol:    cfg.Symbol,
+		Interval:  cfg.Interval,
+		StartTime: cfg.StartTime,
+		EndTime:   cfg.EndTime,
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, klines)
+
+	for i, k := range klines {
+		require.GreaterOrEqual(t, k.OpenTime, cfg.StartTime)
+		require.LessOrEqual(t, k.OpenTime, cfg.EndTime)
+		require.Greater(t, k.CloseTime, k.OpenTime)
+		require.GreaterOrEqual(t, k.High, k.Low)
+		if i > 0 {
+			require.Greater(t, k.OpenTime, klines[i-1].OpenTime, "klines must be sorted by open time")
+		}
+	}
+}
+
+func testKlinesLimit(t *testing.T, c exchange.Client, cfg Config) {
+	klines, err := c.Klines(context.Background(), models.KlinesRequest{
+		Symbol:    cfg.Symbol,
+		Interval:  cfg.Interval,
+		StartTime: cfg.StartTime,
+		EndTime:   cfg.EndTime,
+		Limit:     2,
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, klines)
+	require.LessOrEqual(t, len(klines), 2)
+}
+
+func testWsKlines(t *testing.T, c exchange.Client, cfg Config) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, errs, err := c.WsKlines(ctx, models.WsKlineRequest{Symbol: cfg.Symbol, Interval: cfg.Interval})
+	require.NoError(t, err)
+	go func() {
+		for range errs {
+		}
+	}()
+
+	select {
+	case e, ok := <-events:
+		require.True(t, ok, "events closed before the first event")
+		require.NotNil(t, e)
+		require.Equal(t, cfg.Symbol, e.Kline.Symbol)
+		require.Equal(t, cfg.Interval, e.Kline.Interval)
+	case <-time.After(wsTimeout):
+		t.Fatal("no event received")
+	}
+
+	cancel()
+	timeout := time.After(wsTimeout)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("events are not closed after context cancellation")
+		}
+	}
+}
+
+func testOrders(t *testing.T, c exchange.Client, cfg Config) {
+	if cfg.Order.Symbol == "" {
+		t.Skip("no order configured")
+	}
+	ctx := context.Background()
+
+	created, err := c.CreateOrder(ctx, cfg.Order)
+	require.NoError(t, err)
+	require.Equal(t, cfg.Order.Symbol, created.Symbol)
+	require.Equal(t, cfg.Order.Side, created.Side)
+	require.Equal(t, cfg.Order.Type, created.Type)
+	require.Equal(t, cfg.Order.Quantity, created.OrigQuantity)
+
+	order, err := c.GetOrder(ctx, models.ReadOrderRequest{ID: created.OrderID, Symbol: cfg.Order.Symbol})
+	require.NoError(t, err)
+	require.Equal(t, created.OrderID, order.OrderID)
+	require.Equal(t, cfg.Order.Symbol, order.Symbol)
+	require.Equal(t, cfg.Order.Side, order.Side)
+	require.Equal(t, cfg.Order.Type, order.Type)
+
+	orders, err := c.ListOrders(ctx, models.ListOrdersRequest{Symbol: cfg.Order.Symbol})
+	require.NoError(t, err)
+	require.Contains(t, orderIDs(orders), created.OrderID)
+
+	openOrders, err := c.ListOpenOrders(ctx, models.ListOpenOrdersRequest{Symbol: cfg.Order.Symbol})
+	require.NoError(t, err)
+	for _, o := range openOrders {
+		require.Equal(t, cfg.Order.Symbol, o.Symbol)
+	}
+	if created.Status != models.OrderStatusTypeNew {
+		return
+	}
+	require.Contains(t, orderIDs(openOrders), created.OrderID)
+
+	canceled, err := c.CancelOrder(ctx, models.CancelOrderRequest{ID: created.OrderID, Symbol: cfg.Order.Symbol})
+	require.NoError(t, err)
+	require.Equal(t, models.OrderStatusTypeCanceled, canceled.Status)
+
+	openOrders, err = c.ListOpenOrders(ctx, models.ListOpenOrdersRequest{Symbol: cfg.Order.Symbol})
+	require.NoError(t, err)
+	require.NotContains(t, orderIDs(openOrders), created.OrderID)
+
+	_, err = c.CancelOrder(ctx, models.CancelOrderRequest{ID: created.OrderID, Symbol: cfg.Order.Symbol})
+	require.Error(t, err, "canceled order must not be canceled twice")
+}
+
+func testCancelUnknownOrder(t *testing.T, c exchange.Client, cfg Config) {
+	_, err := c.CancelOrder(context.Background(), models.CancelOrderRequest{ID: -1, Symbol: cfg.Symbol})
+	require.Error(t, err)
+}
+
+func testAccount(t *testing.T, c exchange.Client) {
+	acc, err := c.GetAccount(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, acc)
+	for _, b := range acc.Balances {
+		require.NotEmpty(t, b.Asset)
+	}
+}
+
+func orderIDs(orders []*models.Order) []int64 {
+	ids := make([]int64, len(orders))
+	for i, o := range orders {
+		ids[i] = o.OrderID
+	}
+	return ids
+}
@@ -12,6 +12,10 @@ type Kline struct {
 	TradeNum                 int64   `json:"tradeNum"`
 	TakerBuyBaseAssetVolume  float64 `json:"takerBuyBaseAssetVolume"`
 	TakerBuyQuoteAssetVolume float64 `json:"takerBuyQuoteAssetVolume"`
+	// FirstTradeID and LastTradeID come with stream klines only, they are
+	// zero for klines of the REST api.
+	FirstTradeID int64 `json:"firstTradeId"`
+	LastTradeID  int64 `json:"lastTradeId"`
 }
 
 type KlinesRequest struct {
@@ -0,0 +1,181 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Symbol is an upper case trading pair name like BTCUSDT.
+type Symbol string
+
+var symbolPattern = regexp.MustCompile(`^[A-Z0-9]{2,20}$`)
+
+// ParseSymbol upper cases s and checks it is made of letters and digits only.
+func ParseSymbol(s string) (Symbol, error) {
+	symbol := Symbol(strings.ToUpper(s))
+	if err := symbol.Validate(); err != nil {
+		return "", err
+	}
+	return symbol, nil
+}
+
+func (s Symbol) Validate() error {
+	if !symbolPattern.MatchString(string(s)) {
+		return fmt.Errorf("invalid symbol %q", string(s))
+	}
+	return nil
+}
+
+// ValidateIn checks the symbol is one of symbols, e.g. the ones of exchange info.
+func (s Symbol) ValidateIn(symbols []string) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	for _, symbol := range symbols {
+		if string(s) == symbol {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown symbol %q", string(s))
+}
+
+// Interval is a kline interval supported by Binance.
+type Interval string
+
+const (
+	Interval1s  Interval = "1s"
+	Interval1m  Interval = "1m"
+	Interval3m  Interval = "3m"
+	Interval5m  Interval = "5m"
+	Interval15m Interval = "15m"
+	Interval30m Interval = "30m"
+	Interval1h  Interval = "1h"
+	Interval2h  Interval = "2h"
+	Interval4h  Interval = "4h"
+	Interval6h  Interval = "6h"
+	Interval8h  Interval = "8h"
+	Interval12h Interval = "12h"
+	Interval1d  Interval = "1d"
+	Interval3d  Interval = "3d"
+	Interval1w  Interval = "1w"
+	Interval1M  Interval = "1M"
+)
+
+var intervalDurations = map[Interval]time.Duration{
+	Interval1s:  time.Second,
+	Interval1m:  time.Minute,
+	Interval3m:  3 * time.Minute,
+	Interval5m:  5 * time.Minute,
+	Interval15m: 15 * time.Minute,
+	Interval30m: 30 * time.Minute,
+	Interval1h:  time.Hour,
+	Interval2h:  2 * time.Hour,
+	Interval4h:  4 * time.Hour,
+	Interval6h:  6 * time.Hour,
+	Interval8h:  8 * time.Hour,
+	Interval12h: 12 * time.Hour,
+	Interval1d:  24 * time.Hour,
+	Interval3d:  3 * 24 * time.Hour,
+	Interval1w:  7 * 24 * time.Hour,
+	Interval1M:  30 * 24 * time.Hour,
+}
+
+var Intervals = []Interval{
+	Interval1s, Interval1m, Interval3m, Interval5m, Interval15m, Interval30m,
+	Interval1h, Interval2h, Interval4h, Interval6h, Interval8h, Interval12h,
+	Interval1d, Interval3d, Interval1w, Interval1M,
+}
+
+// ParseInterval checks s is one of Intervals. It is case sensitive, as 1m is
+// a minute and 1M is a month.
+func ParseInterval(s string) (Interval, error) {
+	interval := Interval(s)
+	if err := interval.Validate(); err != nil {
+		return "", err
+	}
+	return interval, nil
+}
+
+func (i Interval) Validate() error {
+	for _, interval := range Intervals {
+		if i == interval {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid interval %q", string(i))
+}
+
+// Duration returns the length of one kline. Months differ in length, so 1M
+// gives a nominal 30 days; use Add to step between monthly klines.
+func (i Interval) Duration() time.Duration {
+	return intervalDurations[i]
+}
+
+// Add returns the open time of the kline n intervals after the one opened at
+// openTime, both in unix milliseconds. Monthly klines open on the first day of
+// a calendar month in UTC.
+func (i Interval) Add(openTime int64, n int) int64 {
+	if i == Interval1M {
+		return time.UnixMilli(openTime).UTC().AddDate(0, n, 0).UnixMilli()
+	}
+	return openTime + int64(n)*i.Duration().Milliseconds()
+}
+
+// Count returns how many whole klines of the interval fit from start to end,
+// both inclusive unix milliseconds, when the first one opens at start.
+func (i Interval) Count(start, end int64) int64 {
+	if end < start {
+		return 0
+	}
+	if i != Interval1M {
+		return (end - start + 1) / i.Duration().Milliseconds()
+	}
+	var n int64
+	for i.Add(start, int(n+1)) <= end+1 {
+		n++
+	}
+	return n
+}
+
+// Aligned reports whether openTime, in unix milliseconds, is where a kline of
+// the interval opens on Binance. Weekly klines open on Monday and monthly ones
+// on the first day of the month, in UTC. 3d klines are only checked to open at
+// midnight, as where their 3 day cycle starts is not documented.
+func (i Interval) Aligned(openTime int64) bool {
+	const day = int64(24 * time.Hour / time.Millisecond)
+	switch i {
+	case Interval1M:
+		t := time.UnixMilli(openTime).UTC()
+		return t.Day() == 1 && openTime%day == 0
+	case Interval1w:
+		// The unix epoch is a Thursday, the first Monday is 4 days later.
+		week := 7 * day
+		return ((openTime-4*day)%week+week)%week == 0
+	case Interval3d:
+		return openTime%day == 0
+	}
+	d := i.Duration().Milliseconds()
+	return d > 0 && openTime%d == 0
+}
+
+// Truncate returns the open time of the kline of the interval that t, in unix
+// milliseconds, falls in. 3d klines are counted from the unix epoch, which may
+// not be where Binance starts them.
+func (i Interval) Truncate(t int64) int64 {
+	const day = int64(24 * time.Hour / time.Millisecond)
+	switch i {
+	case Interval1M:
+		tm := time.UnixMilli(t).UTC()
+		return time.Date(tm.Year(), tm.Month(), 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	case Interval1w:
+		return truncate(t-4*day, 7*day) + 4*day
+	}
+	return truncate(t, i.Duration().Milliseconds())
+}
+
+// truncate rounds t down to a multiple of d, negative t included.
+func truncate(t, d int64) int64 {
+	return t - ((t%d)+d)%d
+}
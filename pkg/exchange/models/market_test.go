@@ -0,0 +1,159 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSymbol(t *testing.T) {
+	testCases := []struct {
+		in      string
+		want    Symbol
+		wantErr bool
+	}{
+		{in: "BTCUSDT", want: "BTCUSDT"},
+		{in: "ethusdt", want: "ETHUSDT"},
+		{in: "1000SATSUSDT", want: "1000SATSUSDT"},
+		{in: "", wantErr: true},
+		{in: "B", wantErr: true},
+		{in: "BTC_USDT", wantErr: true},
+		{in: "btcusdt; drop table users", wantErr: true},
+		{in: "ABCDEFGHIJKLMNOPQRSTU", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := ParseSymbol(tc.in)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+
+	require.NoError(t, Symbol("BTCUSDT").ValidateIn([]string{"ETHUSDT", "BTCUSDT"}))
+	require.Error(t, Symbol("BNBUSDT").ValidateIn([]string{"ETHUSDT", "BTCUSDT"}))
+}
+
+func TestParseInterval(t *testing.T) {
+	for _, in := range []string{"1s", "1m", "15m", "1h", "12h", "1d", "3d", "1w", "1M"} {
+		got, err := ParseInterval(in)
+		require.NoError(t, err, in)
+		require.Equal(t, Interval(in), got)
+	}
+	for _, in := range []string{"", "2m", "1H", "1D", "1W", "1mo", "1m'"} {
+		_, err := ParseInterval(in)
+		require.Error(t, err, in)
+	}
+}
+
+func TestInterval_Add(t *testing.T) {
+	at := func(year int, month time.Month, day int) int64 {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC).UnixMilli()
+	}
+	testCases := []struct {
+		interval Interval
+		openTime int64
+		n        int
+		want     int64
+	}{
+		{interval: Interval1m, openTime: 0, n: 3, want: 3 * 60_000},
+		{interval: Interval12h, openTime: at(2024, 1, 1), n: 2, want: at(2024, 1, 2)},
+		{interval: Interval1d, openTime: at(2024, 2, 28), n: 2, want: at(2024, 3, 1)},
+		{interval: Interval3d, openTime: at(2024, 1, 1), n: 1, want: at(2024, 1, 4)},
+		{interval: Interval1w, openTime: at(2024, 1, 1), n: 5, want: at(2024, 2, 5)},
+		{interval: Interval1M, openTime: at(2024, 1, 1), n: 1, want: at(2024, 2, 1)},
+		{interval: Interval1M, openTime: at(2024, 2, 1), n: 1, want: at(2024, 3, 1)},
+		{interval: Interval1M, openTime: at(2023, 11, 1), n: 3, want: at(2024, 2, 1)},
+		{interval: Interval1M, openTime: at(2024, 3, 1), n: -1, want: at(2024, 2, 1)},
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.want, tc.interval.Add(tc.openTime, tc.n), "%s %d", tc.interval, tc.n)
+	}
+}
+
+func TestInterval_Duration(t *testing.T) {
+	for _, interval := range Intervals {
+		require.Positive(t, interval.Duration(), interval)
+	}
+	require.Equal(t, 7*24*time.Hour, Interval1w.Duration())
+}
+
+func TestInterval_Count(t *testing.T) {
+	at := func(year int, month time.Month, day int) int64 {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC).UnixMilli()
+	}
+	testCases := []struct {
+		interval   Interval
+		start, end int64
+		want       int64
+	}{
+		{interval: Interval1m, start: 0, end: 59_999, want: 1},
+		{interval: Interval1m, start: 0, end: 119_998, want: 1},
+		{interval: Interval1m, start: 0, end: -1, want: 0},
+		{interval: Interval1d, start: at(2024, 1, 1), end: at(2024, 2, 1) - 1, want: 31},
+		{interval: Interval1w, start: at(2024, 1, 1), end: at(2024, 1, 29) - 1, want: 4},
+		{interval: Interval1M, start: at(2024, 1, 1), end: at(2025, 1, 1) - 1, want: 12},
+		{interval: Interval1M, start: at(2024, 1, 1), end: at(2024, 2, 29), want: 1},
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.want, tc.interval.Count(tc.start, tc.end), "%s %d %d", tc.interval, tc.start, tc.end)
+	}
+}
+
+func TestInterval_Aligned(t *testing.T) {
+	at := func(year int, month time.Month, day, hour, min int) int64 {
+		return time.Date(year, month, day, hour, min, 0, 0, time.UTC).UnixMilli()
+	}
+	testCases := []struct {
+		interval Interval
+		openTime int64
+		want     bool
+	}{
+		{interval: Interval1m, openTime: at(2024, 1, 1, 0, 1), want: true},
+		{interval: Interval1m, openTime: at(2024, 1, 1, 0, 1) + 30_000, want: false},
+		{interval: Interval15m, openTime: at(2024, 1, 1, 0, 45), want: true},
+		{interval: Interval15m, openTime: at(2024, 1, 1, 0, 50), want: false},
+		{interval: Interval4h, openTime: at(2024, 1, 1, 8, 0), want: true},
+		{interval: Interval4h, openTime: at(2024, 1, 1, 6, 0), want: false},
+		{interval: Interval1d, openTime: at(2024, 1, 1, 0, 0), want: true},
+		{interval: Interval1d, openTime: at(2024, 1, 1, 12, 0), want: false},
+		{interval: Interval1w, openTime: at(2024, 1, 1, 0, 0), want: true},
+		{interval: Interval1w, openTime: at(2024, 1, 4, 0, 0), want: false},
+		{interval: Interval1w, openTime: at(1969, 12, 29, 0, 0), want: true},
+		{interval: Interval1M, openTime: at(2024, 2, 1, 0, 0), want: true},
+		{interval: Interval1M, openTime: at(2024, 2, 2, 0, 0), want: false},
+		{interval: Interval1M, openTime: at(2024, 2, 1, 1, 0), want: false},
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.want, tc.interval.Aligned(tc.openTime), "%s %s", tc.interval, time.UnixMilli(tc.openTime).UTC())
+	}
+}
+
+func TestInterval_Truncate(t *testing.T) {
+	at := func(year int, month time.Month, day, hour, min int) int64 {
+		return time.Date(year, month, day, hour, min, 0, 0, time.UTC).UnixMilli()
+	}
+	testCases := []struct {
+		interval Interval
+		t        int64
+		want     int64
+	}{
+		{interval: Interval1m, t: at(2024, 1, 1, 0, 1) + 59_999, want: at(2024, 1, 1, 0, 1)},
+		{interval: Interval15m, t: at(2024, 1, 1, 0, 44), want: at(2024, 1, 1, 0, 30)},
+		{interval: Interval4h, t: at(2024, 1, 1, 7, 59), want: at(2024, 1, 1, 4, 0)},
+		{interval: Interval1d, t: at(2024, 2, 29, 23, 59), want: at(2024, 2, 29, 0, 0)},
+		{interval: Interval1w, t: at(2024, 1, 7, 23, 59), want: at(2024, 1, 1, 0, 0)},
+		{interval: Interval1w, t: at(2024, 1, 8, 0, 0), want: at(2024, 1, 8, 0, 0)},
+		{interval: Interval1w, t: at(1970, 1, 1, 0, 0), want: at(1969, 12, 29, 0, 0)},
+		{interval: Interval1M, t: at(2024, 2, 29, 23, 59), want: at(2024, 2, 1, 0, 0)},
+	}
+	for _, tc := range testCases {
+		got := tc.interval.Truncate(tc.t)
+		require.Equal(t, tc.want, got, "%s %s", tc.interval, time.UnixMilli(tc.t).UTC())
+		require.True(t, tc.interval.Aligned(got), "%s %s", tc.interval, time.UnixMilli(got).UTC())
+	}
+}
@@ -65,7 +65,7 @@ type Fill struct {
 	Price           float64
 	Quantity        float64
 	Commission      float64
-	CommissionAsset float64
+	CommissionAsset string
 }
 
 type (
@@ -111,6 +111,7 @@ type CreateOrderRequest struct {
 	Symbol      string
 	Quantity    float64
 	Price       float64
+	StopPrice   float64
 	Side        SideType
 	Type        OrderType
 	InTimeForce TimeInForceType
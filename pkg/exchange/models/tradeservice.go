@@ -0,0 +1,43 @@
+package models
+
+// Trade is a trade of the exchange, trade ids of a symbol follow one another
+// without gaps.
+type Trade struct {
+	ID            int64   `json:"id"`
+	Price         float64 `json:"price"`
+	Quantity      float64 `json:"qty"`
+	QuoteQuantity float64 `json:"quoteQty"`
+	Time          int64   `json:"time"`
+	IsBuyerMaker  bool    `json:"isBuyerMaker"`
+	IsBestMatch   bool    `json:"isBestMatch"`
+}
+
+// TradesRequest requests trades from FromID on, the most recent ones if it is zero.
+type TradesRequest struct {
+	Symbol string
+	FromID int64
+	Limit  int
+}
+
+// AggTrade is trades of one taker order at the same price, aggregate trade ids
+// of a symbol follow one another without gaps.
+type AggTrade struct {
+	ID               int64   `json:"a"`
+	Price            float64 `json:"p"`
+	Quantity         float64 `json:"q"`
+	FirstTradeID     int64   `json:"f"`
+	LastTradeID      int64   `json:"l"`
+	Time             int64   `json:"T"`
+	IsBuyerMaker     bool    `json:"m"`
+	IsBestPriceMatch bool    `json:"M"`
+}
+
+// AggTradesRequest requests aggregate trades from FromID on or, if it is zero,
+// the ones made from StartTime to EndTime.
+type AggTradesRequest struct {
+	Symbol    string
+	FromID    int64
+	StartTime int64
+	EndTime   int64
+	Limit     int
+}
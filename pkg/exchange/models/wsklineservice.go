@@ -30,3 +30,7 @@ type WsKline struct {
 	ActiveBuyVolume      float64 `json:"V"`
 	ActiveBuyQuoteVolume float64 `json:"Q"`
 }
+
+type WsCombinedKlineRequest struct {
+	Pairs []WsKlineRequest
+}
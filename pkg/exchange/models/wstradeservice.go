@@ -0,0 +1,19 @@
+package models
+
+type WsTradeRequest struct {
+	Symbols []string
+}
+
+type WsTradeEvent struct {
+	Event  string `json:"e"`
+	Time   int64  `json:"E"`
+	Symbol string `json:"s"`
+	Trade  Trade  `json:"t"`
+}
+
+type WsAggTradeEvent struct {
+	Event    string   `json:"e"`
+	Time     int64    `json:"E"`
+	Symbol   string   `json:"s"`
+	AggTrade AggTrade `json:"a"`
+}
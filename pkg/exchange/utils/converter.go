@@ -94,3 +94,75 @@ func Str2float(str string) float64 {
 func Float2str(f float64) string {
 	return fmt.Sprintf("%.8f", f)
 }
+
+func FromExtTradeToInt(t *binance.Trade) *models.Trade {
+	return &models.Trade{
+		ID:            t.ID,
+		Price:         Str2float(t.Price),
+		Quantity:      Str2float(t.Quantity),
+		QuoteQuantity: Str2float(t.QuoteQuantity),
+		Time:          t.Time,
+		IsBuyerMaker:  t.IsBuyerMaker,
+		IsBestMatch:   t.IsBestMatch,
+	}
+}
+
+func FromExtAggTradeToInt(t *binance.AggTrade) *models.AggTrade {
+	return &models.AggTrade{
+		ID:               t.AggTradeID,
+		Price:            Str2float(t.Price),
+		Quantity:         Str2float(t.Quantity),
+		FirstTradeID:     t.FirstTradeID,
+		LastTradeID:      t.LastTradeID,
+		Time:             t.Timestamp,
+		IsBuyerMaker:     t.IsBuyerMaker,
+		IsBestPriceMatch: t.IsBestPriceMatch,
+	}
+}
+
+// FromExtWsTradeEventToInt converts a trade event. Stream trades carry no quote
+// quantity, it is price times quantity, and no best match flag, which spot
+// trades of the REST api always have set.
+func FromExtWsTradeEventToInt(event *binance.WsTradeEvent) *models.WsTradeEvent {
+	if event == nil {
+		return nil
+	}
+	price, quantity := Str2float(event.Price), Str2float(event.Quantity)
+	return &models.WsTradeEvent{
+		Event:  event.Event,
+		Time:   event.Time,
+		Symbol: event.Symbol,
+		Trade: models.Trade{
+			ID:            event.TradeID,
+			Price:         price,
+			Quantity:      quantity,
+			QuoteQuantity: price * quantity,
+			Time:          event.TradeTime,
+			IsBuyerMaker:  event.IsBuyerMaker,
+			IsBestMatch:   true,
+		},
+	}
+}
+
+// FromExtWsAggTradeEventToInt converts an aggregate trade event, the best price
+// match flag is set as FromExtWsTradeEventToInt sets the best match one.
+func FromExtWsAggTradeEventToInt(event *binance.WsAggTradeEvent) *models.WsAggTradeEvent {
+	if event == nil {
+		return nil
+	}
+	return &models.WsAggTradeEvent{
+		Event:  event.Event,
+		Time:   event.Time,
+		Symbol: event.Symbol,
+		AggTrade: models.AggTrade{
+			ID:               event.AggTradeID,
+			Price:            Str2float(event.Price),
+			Quantity:         Str2float(event.Quantity),
+			FirstTradeID:     event.FirstBreakdownTradeID,
+			LastTradeID:      event.LastBreakdownTradeID,
+			Time:             event.TradeTime,
+			IsBuyerMaker:     event.IsBuyerMaker,
+			IsBestPriceMatch: true,
+		},
+	}
+}
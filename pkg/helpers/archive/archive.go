@@ -0,0 +1,235 @@
+package archive
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage"
+)
+
+//go:generate mockgen -source=archive.go -destination=mocks/archive.go
+type Storage interface {
+	WriteKlines(context.Context, storage.WriteKlinesRequest) error
+}
+
+// File is a kline archive of Binance public data (data.binance.vision), like
+// BTCUSDT-1m-2024-01.zip for a month or BTCUSDT-1m-2024-01-15.zip for a day.
+// Archives of monthly klines are named with 1mo, like BTCUSDT-1mo-2024-01.zip.
+type File struct {
+	Path     string
+	Symbol   string
+	Interval string
+	// Period is the month or the day of the klines, 2024-01 or 2024-01-15.
+	Period string
+}
+
+var fileNamePattern = regexp.MustCompile(`^([A-Z0-9]+)-(\w+)-(\d{4}-\d{2}(?:-\d{2})?)\.zip$`)
+
+// ParseFile parses the name of the archive at path.
+func ParseFile(path string) (File, error) {
+	m := fileNamePattern.FindStringSubmatch(filepath.Base(path))
+	if m == nil {
+		return File{}, fmt.Errorf("%s is not named like SYMBOL-INTERVAL-PERIOD.zip", path)
+	}
+	symbol, err := models.ParseSymbol(m[1])
+	if err != nil {
+		return File{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if m[2] == "1mo" {
+		m[2] = string(models.Interval1M)
+	}
+	interval, err := models.ParseInterval(m[2])
+	if err != nil {
+		return File{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return File{Path: path, Symbol: string(symbol), Interval: string(interval), Period: m[3]}, nil
+}
+
+// FindFiles returns the archives under dir ordered by symbol, interval and
+// period, monthly ones before the daily ones of the same month. Zip files not
+// named like kline archives, like the trade ones, are skipped and returned
+// with the reason why.
+func FindFiles(dir string) ([]File, []error, error) {
+	var (
+		files   []File
+		skipped []error
+	)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".zip" {
+			return nil
+		}
+		f, err := ParseFile(path)
+		if err != nil {
+			skipped = append(skipped, err)
+			return nil
+		}
+		files = append(files, f)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if a.Symbol != b.Symbol {
+			return a.Symbol < b.Symbol
+		}
+		if a.Interval != b.Interval {
+			return a.Interval < b.Interval
+		}
+		return a.Period < b.Period
+	})
+	return files, skipped, nil
+}
+
+// ErrNoChecksum is returned by VerifyChecksum if the archive has no .CHECKSUM file.
+var ErrNoChecksum = errors.New("no checksum file")
+
+// VerifyChecksum checks the sha256 of the archive at path against the one in
+// path.CHECKSUM, which is published next to every archive.
+func VerifyChecksum(path string) error {
+	content, err := os.ReadFile(path + ".CHECKSUM")
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%s: %w", path, ErrNoChecksum)
+	}
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(content))
+	if len(fields) == 0 {
+		return fmt.Errorf("%s.CHECKSUM is empty", path)
+	}
+	want := strings.ToLower(fields[0])
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return fmt.Errorf("%s: checksum %s does not match %s", path, got, want)
+	}
+	return nil
+}
+
+// ReadKlines reads the klines of every csv file in the archive at path.
+func ReadKlines(path string) ([]*models.Kline, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var klines []*models.Kline
+	for _, f := range r.File {
+		if filepath.Ext(f.Name) != ".csv" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		read, err := readCSV(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		klines = append(klines, read...)
+	}
+	return klines, nil
+}
+
+// readCSV reads rows of open time, open, high, low, close, volume, close time,
+// quote asset volume, number of trades, taker buy base asset volume and taker
+// buy quote asset volume. A header row is skipped. Archives have no trade ids,
+// so they are left zero.
+func readCSV(r io.Reader) ([]*models.Kline, error) {
+	cr := csv.NewReader(bufio.NewReader(r))
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	var klines []*models.Kline
+	for line := 1; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return klines, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && len(record) > 0 && record[0] == "open_time" {
+			continue
+		}
+		k, err := parseRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		klines = append(klines, k)
+	}
+}
+
+func parseRecord(record []string) (*models.Kline, error) {
+	if len(record) < 11 {
+		return nil, fmt.Errorf("expected at least 11 fields, got %d", len(record))
+	}
+	var (
+		k    models.Kline
+		errs []error
+	)
+	parseInt := func(s string) int64 {
+		v, err := strconv.ParseInt(s, 10, 64)
+		errs = append(errs, err)
+		return v
+	}
+	parseFloat := func(s string) float64 {
+		v, err := strconv.ParseFloat(s, 64)
+		errs = append(errs, err)
+		return v
+	}
+	k.OpenTime = toMilli(parseInt(record[0]))
+	k.Open = parseFloat(record[1])
+	k.High = parseFloat(record[2])
+	k.Low = parseFloat(record[3])
+	k.Close = parseFloat(record[4])
+	k.Volume = parseFloat(record[5])
+	k.CloseTime = toMilli(parseInt(record[6]))
+	k.QuoteAssetVolume = parseFloat(record[7])
+	k.TradeNum = parseInt(record[8])
+	k.TakerBuyBaseAssetVolume = parseFloat(record[9])
+	k.TakerBuyQuoteAssetVolume = parseFloat(record[10])
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// toMilli converts timestamps to milliseconds, as spot archives from 2025 on
+// are in microseconds.
+func toMilli(t int64) int64 {
+	if t >= 1e15 {
+		return t / 1000
+	}
+	return t
+}
@@ -0,0 +1,190 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"crypto_bot/pkg/exchange/models"
+	mockarchive "crypto_bot/pkg/helpers/archive/mocks"
+	"crypto_bot/pkg/storage"
+)
+
+const (
+	csvMilli = "1704067200000,42283.58,42298.62,42261.02,42298.61,35.92724,1704067259999,1519032.07,1327,20.40954,863002.42,0\n" +
+		"1704067260000,42298.62,42320.00,42298.61,42320.00,21.05,1704067319999,890670.37,876,15.5,655866.2,0\n"
+	csvMicro = "open_time,open,high,low,close,volume,close_time,quote_volume,count,taker_buy_volume,taker_buy_quote_volume,ignore\n" +
+		"1735689600000000,93576.00,93610.93,93537.50,93610.93,8.21827,1735689659999999,768978.76,1875,3.95133,369727.82,0\n"
+)
+
+var (
+	klinesMilli = []*models.Kline{
+		{OpenTime: 1704067200000, Open: 42283.58, High: 42298.62, Low: 42261.02, Close: 42298.61, Volume: 35.92724,
+			CloseTime: 1704067259999, QuoteAssetVolume: 1519032.07, TradeNum: 1327,
+			TakerBuyBaseAssetVolume: 20.40954, TakerBuyQuoteAssetVolume: 863002.42},
+		{OpenTime: 1704067260000, Open: 42298.62, High: 42320, Low: 42298.61, Close: 42320, Volume: 21.05,
+			CloseTime: 1704067319999, QuoteAssetVolume: 890670.37, TradeNum: 876,
+			TakerBuyBaseAssetVolume: 15.5, TakerBuyQuoteAssetVolume: 655866.2},
+	}
+	klinesMicro = []*models.Kline{
+		{OpenTime: 1735689600000, Open: 93576, High: 93610.93, Low: 93537.5, Close: 93610.93, Volume: 8.21827,
+			CloseTime: 1735689659999, QuoteAssetVolume: 768978.76, TradeNum: 1875,
+			TakerBuyBaseAssetVolume: 3.95133, TakerBuyQuoteAssetVolume: 369727.82},
+	}
+)
+
+// writeArchive writes name zipping content as a csv into dir with its
+// .CHECKSUM file and returns its path.
+func writeArchive(t *testing.T, dir, name, content string) string {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create(strings.TrimSuffix(name, ".zip") + ".csv")
+	require.NoError(t, err)
+	_, err = w.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+	sum := sha256.Sum256(buf.Bytes())
+	require.NoError(t, os.WriteFile(path+".CHECKSUM", []byte(hex.EncodeToString(sum[:])+"  "+name+"\n"), 0o644))
+	return path
+}
+
+func TestParseFile(t *testing.T) {
+	testCases := []struct {
+		path    string
+		want    File
+		wantErr bool
+	}{
+		{path: "data/BTCUSDT-1m-2024-01.zip", want: File{Path: "data/BTCUSDT-1m-2024-01.zip", Symbol: "BTCUSDT", Interval: "1m", Period: "2024-01"}},
+		{path: "ETHBTC-1M-2023-12-31.zip", want: File{Path: "ETHBTC-1M-2023-12-31.zip", Symbol: "ETHBTC", Interval: "1M", Period: "2023-12-31"}},
+		{path: "ETHBTC-1mo-2023-12.zip", want: File{Path: "ETHBTC-1mo-2023-12.zip", Symbol: "ETHBTC", Interval: "1M", Period: "2023-12"}},
+		{path: "BTCUSDT-2m-2024-01.zip", wantErr: true},
+		{path: "BTCUSDT-1m-2024.zip", wantErr: true},
+		{path: "BTCUSDT-trades-2024-01.zip", wantErr: true},
+	}
+	for _, tc := range testCases {
+		got, err := ParseFile(tc.path)
+		if tc.wantErr {
+			require.Error(t, err, tc.path)
+			continue
+		}
+		require.NoError(t, err, tc.path)
+		require.Equal(t, tc.want, got)
+	}
+}
+
+func TestFindFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeArchive(t, filepath.Join(dir, "daily"), "BTCUSDT-1m-2024-02-01.zip", csvMilli)
+	writeArchive(t, filepath.Join(dir, "monthly"), "BTCUSDT-1m-2024-01.zip", csvMilli)
+	writeArchive(t, dir, "ADAUSDT-1h-2024-01.zip", csvMilli)
+	writeArchive(t, dir, "ADAUSDT-1mo-2024-01.zip", csvMilli)
+	writeArchive(t, dir, "ADAUSDT-trades-2024-01.zip", "")
+
+	files, skipped, err := FindFiles(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, f := range files {
+		names = append(names, filepath.Base(f.Path))
+	}
+	require.Equal(t, []string{"ADAUSDT-1mo-2024-01.zip", "ADAUSDT-1h-2024-01.zip", "BTCUSDT-1m-2024-01.zip", "BTCUSDT-1m-2024-02-01.zip"}, names)
+	require.Len(t, skipped, 1)
+	require.ErrorContains(t, skipped[0], "ADAUSDT-trades-2024-01.zip")
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	dir := t.TempDir()
+	path := writeArchive(t, dir, "BTCUSDT-1m-2024-01.zip", csvMilli)
+	require.NoError(t, VerifyChecksum(path))
+
+	require.NoError(t, os.WriteFile(path+".CHECKSUM", []byte(strings.Repeat("0", 64)+"  BTCUSDT-1m-2024-01.zip\n"), 0o644))
+	require.ErrorContains(t, VerifyChecksum(path), "does not match")
+
+	require.NoError(t, os.Remove(path+".CHECKSUM"))
+	require.ErrorIs(t, VerifyChecksum(path), ErrNoChecksum)
+}
+
+func TestReadKlines(t *testing.T) {
+	dir := t.TempDir()
+
+	klines, err := ReadKlines(writeArchive(t, dir, "BTCUSDT-1m-2024-01.zip", csvMilli))
+	require.NoError(t, err)
+	require.Equal(t, klinesMilli, klines)
+
+	klines, err = ReadKlines(writeArchive(t, dir, "BTCUSDT-1m-2025-01.zip", csvMicro))
+	require.NoError(t, err)
+	require.Equal(t, klinesMicro, klines)
+
+	_, err = ReadKlines(writeArchive(t, dir, "BTCUSDT-1m-2025-02.zip", "1735689600000,1,2\n"))
+	require.ErrorContains(t, err, "line 1")
+}
+
+func TestImporter_Import(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mockarchive.NewMockStorage(ctrl)
+
+	dir := t.TempDir()
+	writeArchive(t, dir, "BTCUSDT-1m-2024-01.zip", csvMilli)
+	writeArchive(t, dir, "BTCUSDT-1m-2025-01.zip", csvMicro)
+	writeArchive(t, dir, "ETHUSDT-1m-2024-01.zip", csvMilli)
+
+	s.EXPECT().WriteKlines(gomock.Any(), storage.WriteKlinesRequest{Symbol: "BTCUSDT", Interval: "1m", Klines: klinesMilli})
+	s.EXPECT().WriteKlines(gomock.Any(), storage.WriteKlinesRequest{Symbol: "BTCUSDT", Interval: "1m", Klines: klinesMicro})
+
+	btc := models.WsKlineRequest{Symbol: "BTCUSDT", Interval: "1m"}
+	var out bytes.Buffer
+	res, err := NewImporter(s).
+		SetProgress(&out).
+		Import(context.Background(), ImportRequest{Dir: dir, Pairs: []models.WsKlineRequest{btc}})
+	require.NoError(t, err)
+	require.Equal(t, &ImportResult{
+		Files:         2,
+		Klines:        3,
+		LastCloseTime: map[models.WsKlineRequest]int64{btc: 1735689659999},
+	}, res)
+	require.Equal(t, "imported BTCUSDT-1m-2024-01.zip: 2 klines\nimported BTCUSDT-1m-2025-01.zip: 1 klines\n", out.String())
+}
+
+func TestImporter_AggregatedInterval(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mockarchive.NewMockStorage(ctrl)
+
+	dir := t.TempDir()
+	writeArchive(t, dir, "BTCUSDT-1h-2024-01.zip", csvMilli)
+	writeArchive(t, dir, "BTCUSDT-1m-2024-01.zip", csvMilli)
+
+	s.EXPECT().WriteKlines(gomock.Any(), storage.WriteKlinesRequest{Symbol: "BTCUSDT", Interval: "1h", Klines: klinesMilli}).
+		Return(fmt.Errorf("BTCUSDT 1h: %w", storage.ErrAggregatedInterval))
+	s.EXPECT().WriteKlines(gomock.Any(), storage.WriteKlinesRequest{Symbol: "BTCUSDT", Interval: "1m", Klines: klinesMilli})
+
+	res, err := NewImporter(s).SetProgress(io.Discard).Import(context.Background(), ImportRequest{Dir: dir})
+	require.NoError(t, err)
+	require.Equal(t, 1, res.Files)
+	require.Equal(t, 2, res.Klines)
+	require.Len(t, res.Skipped, 1)
+	require.ErrorIs(t, res.Skipped[0], storage.ErrAggregatedInterval)
+}
+
+func TestImporter_ChecksumMismatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	dir := t.TempDir()
+	path := writeArchive(t, dir, "BTCUSDT-1m-2024-01.zip", csvMilli)
+	require.NoError(t, os.WriteFile(path+".CHECKSUM", []byte(strings.Repeat("0", 64)), 0o644))
+
+	_, err := NewImporter(mockarchive.NewMockStorage(ctrl)).Import(context.Background(), ImportRequest{Dir: dir})
+	require.ErrorContains(t, err, "does not match")
+}
@@ -0,0 +1,115 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage"
+)
+
+// Importer loads kline archives into storage.
+type Importer struct {
+	s              Storage
+	verifyChecksum bool
+	progressOut    io.Writer
+}
+
+func NewImporter(s Storage) *Importer {
+	return &Importer{s: s, verifyChecksum: true, progressOut: io.Discard}
+}
+
+// SetVerifyChecksum sets whether archives are checked against their .CHECKSUM
+// files, which is on by default. Archives without one fail when it is on.
+func (im *Importer) SetVerifyChecksum(verify bool) *Importer {
+	im.verifyChecksum = verify
+	return im
+}
+
+// SetProgress sets where a line is printed for every imported archive, it is
+// discarded by default.
+func (im *Importer) SetProgress(out io.Writer) *Importer {
+	im.progressOut = out
+	return im
+}
+
+type ImportRequest struct {
+	Dir string
+	// Pairs limits the import to the archives of these pairs, all are imported if empty.
+	Pairs []models.WsKlineRequest
+}
+
+type ImportResult struct {
+	Files  int
+	Klines int
+	// LastCloseTime is the latest close time imported of every pair, gaps
+	// after it are left to fix from the exchange.
+	LastCloseTime map[models.WsKlineRequest]int64
+	// Skipped holds why zip files were skipped: they are not named like kline
+	// archives or the storage aggregates their interval from 1m klines.
+	Skipped []error
+}
+
+func (im *Importer) Import(ctx context.Context, req ImportRequest) (*ImportResult, error) {
+	files, skipped, err := FindFiles(req.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("find archives: %w", err)
+	}
+	for _, err := range skipped {
+		fmt.Fprintf(im.progressOut, "skipped %s\n", err)
+	}
+	wanted := make(map[models.WsKlineRequest]bool, len(req.Pairs))
+	for _, p := range req.Pairs {
+		wanted[p] = true
+	}
+
+	res := &ImportResult{LastCloseTime: make(map[models.WsKlineRequest]int64), Skipped: skipped}
+	for _, f := range files {
+		pair := models.WsKlineRequest{Symbol: f.Symbol, Interval: f.Interval}
+		if len(wanted) > 0 && !wanted[pair] {
+			continue
+		}
+		if err = ctx.Err(); err != nil {
+			return res, err
+		}
+		n, last, err := im.importFile(ctx, f)
+		if errors.Is(err, storage.ErrAggregatedInterval) {
+			// The storage builds the klines from 1m ones, import those instead.
+			res.Skipped = append(res.Skipped, err)
+			fmt.Fprintf(im.progressOut, "skipped %s\n", err)
+			continue
+		}
+		if err != nil {
+			return res, err
+		}
+		res.Files++
+		res.Klines += n
+		res.LastCloseTime[pair] = max(res.LastCloseTime[pair], last)
+		fmt.Fprintf(im.progressOut, "imported %s: %d klines\n", filepath.Base(f.Path), n)
+	}
+	return res, nil
+}
+
+func (im *Importer) importFile(ctx context.Context, f File) (int, int64, error) {
+	if im.verifyChecksum {
+		if err := VerifyChecksum(f.Path); err != nil {
+			return 0, 0, err
+		}
+	}
+	klines, err := ReadKlines(f.Path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("read %s: %w", f.Path, err)
+	}
+	err = im.s.WriteKlines(ctx, storage.WriteKlinesRequest{Symbol: f.Symbol, Interval: f.Interval, Klines: klines})
+	if err != nil {
+		return 0, 0, fmt.Errorf("write %s: %w", f.Path, err)
+	}
+	var last int64
+	for _, k := range klines {
+		last = max(last, k.CloseTime)
+	}
+	return len(klines), last, nil
+}
@@ -0,0 +1,56 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: archive.go
+//
+// Generated by this command:
+//
+//	mockgen -source=archive.go -destination=mocks/archive.go
+//
+
+// Package mock_archive is a generated GoMock package.
+package mock_archive
+
+import (
+	context "context"
+	storage "crypto_bot/pkg/storage"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockStorage is a mock of Storage interface.
+type MockStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockStorageMockRecorder is the mock recorder for MockStorage.
+type MockStorageMockRecorder struct {
+	mock *MockStorage
+}
+
+// NewMockStorage creates a new mock instance.
+func NewMockStorage(ctrl *gomock.Controller) *MockStorage {
+	mock := &MockStorage{ctrl: ctrl}
+	mock.recorder = &MockStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
+	return m.recorder
+}
+
+// WriteKlines mocks base method.
+func (m *MockStorage) WriteKlines(arg0 context.Context, arg1 storage.WriteKlinesRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteKlines", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteKlines indicates an expected call of WriteKlines.
+func (mr *MockStorageMockRecorder) WriteKlines(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteKlines", reflect.TypeOf((*MockStorage)(nil).WriteKlines), arg0, arg1)
+}
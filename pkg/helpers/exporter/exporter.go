@@ -0,0 +1,176 @@
+package exporter
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage"
+)
+
+//go:generate mockgen -source=exporter.go -destination=mocks/exporter.go
+type Storage interface {
+	ReadKlines(context.Context, storage.ReadKlinesRequest) ([]*models.Kline, error)
+}
+
+type Format string
+
+const (
+	FormatCSV     Format = "csv"
+	FormatNDJSON  Format = "ndjson"
+	FormatParquet Format = "parquet"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatCSV, FormatNDJSON, FormatParquet:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected csv, ndjson or parquet", s)
+}
+
+type Compression string
+
+const (
+	CompressionNone Compression = "none"
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+func ParseCompression(s string) (Compression, error) {
+	switch c := Compression(s); c {
+	case CompressionNone, CompressionGzip, CompressionZstd:
+		return c, nil
+	}
+	return "", fmt.Errorf("unknown compression %q, expected none, gzip or zstd", s)
+}
+
+// Ext returns the file extension of the format with the compression, like
+// .csv.gz. Parquet files compress their pages themselves, so they stay .parquet.
+func Ext(f Format, c Compression) string {
+	ext := "." + string(f)
+	if f == FormatParquet {
+		return ext
+	}
+	switch c {
+	case CompressionGzip:
+		ext += ".gz"
+	case CompressionZstd:
+		ext += ".zst"
+	}
+	return ext
+}
+
+// Exporter streams stored klines page by page into a file format.
+type Exporter struct {
+	s        Storage
+	pageSize int
+}
+
+func NewExporter(s Storage) *Exporter {
+	return &Exporter{s: s, pageSize: 10_000}
+}
+
+// SetPageSize sets how many klines are read from storage at once.
+func (e *Exporter) SetPageSize(pageSize int) *Exporter {
+	e.pageSize = pageSize
+	return e
+}
+
+type ExportRequest struct {
+	Symbol   string
+	Interval string
+	// From and To filter klines by open and close time, a zero To exports
+	// up to the last kline.
+	From, To    time.Time
+	Format      Format
+	Compression Compression
+}
+
+// rowWriter writes klines in a format, close flushes what is buffered
+// without closing the underlying writer.
+type rowWriter interface {
+	write([]*models.Kline) error
+	close() error
+}
+
+// Export writes the klines of req to w and returns how many were written.
+func (e *Exporter) Export(ctx context.Context, w io.Writer, req ExportRequest) (int, error) {
+	out, closeOut, err := compress(w, req.Format, req.Compression)
+	if err != nil {
+		return 0, err
+	}
+	var rw rowWriter
+	switch req.Format {
+	case FormatCSV:
+		rw = newCSVWriter(out)
+	case FormatNDJSON:
+		rw = newNDJSONWriter(out)
+	case FormatParquet:
+		rw = newParquetWriter(out, req.Compression)
+	default:
+		return 0, fmt.Errorf("unknown format %q", req.Format)
+	}
+
+	var (
+		n        int
+		openTime = req.From.UnixMilli()
+		to       int64
+	)
+	if !req.To.IsZero() {
+		to = req.To.UnixMilli()
+	}
+	for {
+		klines, err := e.s.ReadKlines(ctx, storage.ReadKlinesRequest{
+			Symbol:    req.Symbol,
+			Interval:  req.Interval,
+			OpenTime:  openTime,
+			CloseTime: to,
+			Limit:     uint64(e.pageSize),
+		})
+		if err != nil {
+			return n, fmt.Errorf("read klines: %w", err)
+		}
+		if err = rw.write(klines); err != nil {
+			return n, fmt.Errorf("write klines: %w", err)
+		}
+		n += len(klines)
+		if len(klines) < e.pageSize {
+			break
+		}
+		openTime = klines[len(klines)-1].OpenTime + 1
+	}
+
+	if err = rw.close(); err != nil {
+		return n, err
+	}
+	return n, closeOut()
+}
+
+// compress wraps w with the compression of csv and ndjson files, parquet ones
+// are compressed by their writer.
+func compress(w io.Writer, f Format, c Compression) (io.Writer, func() error, error) {
+	noop := func() error { return nil }
+	if f == FormatParquet {
+		return w, noop, nil
+	}
+	switch c {
+	case CompressionNone, "":
+		return w, noop, nil
+	case CompressionGzip:
+		gw := gzip.NewWriter(w)
+		return gw, gw.Close, nil
+	case CompressionZstd:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zw, zw.Close, nil
+	}
+	return nil, nil, fmt.Errorf("unknown compression %q", c)
+}
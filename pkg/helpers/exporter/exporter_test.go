@@ -0,0 +1,180 @@
+package exporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"crypto_bot/pkg/exchange/models"
+	mockexporter "crypto_bot/pkg/helpers/exporter/mocks"
+	"crypto_bot/pkg/storage"
+)
+
+var from = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func testKlines(n int) []*models.Kline {
+	klines := make([]*models.Kline, n)
+	for i := range klines {
+		openTime := from.Add(time.Duration(i) * time.Minute).UnixMilli()
+		price := 100 + float64(i)/4
+		klines[i] = &models.Kline{
+			OpenTime: openTime, Open: price, High: price + 1, Low: price - 1, Close: price + 0.5,
+			Volume: 10.125, CloseTime: openTime + 59_999, TradeNum: 5, QuoteAssetVolume: 1000,
+			TakerBuyBaseAssetVolume: 4, TakerBuyQuoteAssetVolume: 400, FirstTradeID: int64(i * 5), LastTradeID: int64(i*5 + 4),
+		}
+	}
+	return klines
+}
+
+// expectPages expects klines to be read in pages of 2.
+func expectPages(s *mockexporter.MockStorage, klines []*models.Kline, to int64) {
+	openTime := from.UnixMilli()
+	for i := 0; ; i += 2 {
+		page := klines[i:min(i+2, len(klines))]
+		s.EXPECT().ReadKlines(gomock.Any(), storage.ReadKlinesRequest{Symbol: "BTCUSDT", Interval: "1m",
+			OpenTime: openTime, CloseTime: to, Limit: 2}).Return(page, nil)
+		if len(page) < 2 {
+			return
+		}
+		openTime = page[len(page)-1].OpenTime + 1
+	}
+}
+
+func decompress(t *testing.T, c Compression, r io.Reader) io.Reader {
+	switch c {
+	case CompressionGzip:
+		gr, err := gzip.NewReader(r)
+		require.NoError(t, err)
+		return gr
+	case CompressionZstd:
+		zr, err := zstd.NewReader(r)
+		require.NoError(t, err)
+		return zr
+	}
+	return r
+}
+
+func readCSV(t *testing.T, r io.Reader) []*models.Kline {
+	records, err := csv.NewReader(r).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, csvHeader, records[0])
+	var klines []*models.Kline
+	for _, record := range records[1:] {
+		// The columns are named like the json fields.
+		fields := make(map[string]any, len(record))
+		for i, v := range record {
+			fields[csvHeader[i]] = json.Number(v)
+		}
+		b, err := json.Marshal(fields)
+		require.NoError(t, err)
+		klines = append(klines, decodeRow(t, b))
+	}
+	return klines
+}
+
+func readNDJSON(t *testing.T, r io.Reader) []*models.Kline {
+	var klines []*models.Kline
+	dec := json.NewDecoder(r)
+	for dec.More() {
+		var raw json.RawMessage
+		require.NoError(t, dec.Decode(&raw))
+		klines = append(klines, decodeRow(t, raw))
+	}
+	return klines
+}
+
+func readParquet(t *testing.T, b []byte) []*models.Kline {
+	rows, err := parquet.Read[row](bytes.NewReader(b), int64(len(b)))
+	require.NoError(t, err)
+	klines := make([]*models.Kline, 0, len(rows))
+	for _, r := range rows {
+		klines = append(klines, fromRow(r))
+	}
+	return klines
+}
+
+func decodeRow(t *testing.T, b []byte) *models.Kline {
+	var r row
+	require.NoError(t, json.Unmarshal(b, &r))
+	return fromRow(r)
+}
+
+func fromRow(r row) *models.Kline {
+	return &models.Kline{
+		OpenTime: r.OpenTime, Open: r.Open, High: r.High, Low: r.Low, Close: r.Close, Volume: r.Volume,
+		CloseTime: r.CloseTime, TradeNum: r.TradeNum, QuoteAssetVolume: r.QuoteAssetVolume,
+		TakerBuyBaseAssetVolume: r.TakerBuyBaseAssetVolume, TakerBuyQuoteAssetVolume: r.TakerBuyQuoteAssetVolume,
+		FirstTradeID: r.FirstTradeID, LastTradeID: r.LastTradeID,
+	}
+}
+
+func TestExporter_Export(t *testing.T) {
+	for _, format := range []Format{FormatCSV, FormatNDJSON, FormatParquet} {
+		for _, c := range []Compression{CompressionNone, CompressionGzip, CompressionZstd} {
+			t.Run(string(format)+"/"+string(c), func(t *testing.T) {
+				ctrl := gomock.NewController(t)
+				s := mockexporter.NewMockStorage(ctrl)
+				klines := testKlines(5)
+				to := from.Add(time.Hour)
+				expectPages(s, klines, to.UnixMilli())
+
+				var buf bytes.Buffer
+				n, err := NewExporter(s).SetPageSize(2).Export(context.Background(), &buf, ExportRequest{
+					Symbol:      "BTCUSDT",
+					Interval:    "1m",
+					From:        from,
+					To:          to,
+					Format:      format,
+					Compression: c,
+				})
+				require.NoError(t, err)
+				require.Equal(t, len(klines), n)
+
+				var got []*models.Kline
+				switch format {
+				case FormatCSV:
+					got = readCSV(t, decompress(t, c, &buf))
+				case FormatNDJSON:
+					got = readNDJSON(t, decompress(t, c, &buf))
+				case FormatParquet:
+					got = readParquet(t, buf.Bytes())
+				}
+				require.Equal(t, klines, got)
+			})
+		}
+	}
+}
+
+func TestExporter_ExportEmpty(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mockexporter.NewMockStorage(ctrl)
+	s.EXPECT().ReadKlines(gomock.Any(), gomock.Any())
+
+	var buf bytes.Buffer
+	n, err := NewExporter(s).Export(context.Background(), &buf, ExportRequest{
+		Symbol:   "BTCUSDT",
+		Interval: "1m",
+		Format:   FormatCSV,
+	})
+	require.NoError(t, err)
+	require.Zero(t, n)
+	require.Equal(t, "open_time,open,high,low,close,volume,close_time,trade_num,quote_asset_volume,"+
+		"taker_buy_base_asset_volume,taker_buy_quote_asset_volume,first_trade_id,last_trade_id\n", buf.String())
+}
+
+func TestExt(t *testing.T) {
+	require.Equal(t, ".csv", Ext(FormatCSV, CompressionNone))
+	require.Equal(t, ".ndjson.gz", Ext(FormatNDJSON, CompressionGzip))
+	require.Equal(t, ".csv.zst", Ext(FormatCSV, CompressionZstd))
+	require.Equal(t, ".parquet", Ext(FormatParquet, CompressionZstd))
+}
@@ -0,0 +1,146 @@
+package exporter
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/parquet-go/parquet-go"
+
+	"crypto_bot/pkg/exchange/models"
+)
+
+// row is a kline as written to files, times are unix milliseconds.
+type row struct {
+	OpenTime                 int64   `json:"open_time" parquet:"open_time,timestamp(millisecond)"`
+	Open                     float64 `json:"open" parquet:"open"`
+	High                     float64 `json:"high" parquet:"high"`
+	Low                      float64 `json:"low" parquet:"low"`
+	Close                    float64 `json:"close" parquet:"close"`
+	Volume                   float64 `json:"volume" parquet:"volume"`
+	CloseTime                int64   `json:"close_time" parquet:"close_time,timestamp(millisecond)"`
+	TradeNum                 int64   `json:"trade_num" parquet:"trade_num"`
+	QuoteAssetVolume         float64 `json:"quote_asset_volume" parquet:"quote_asset_volume"`
+	TakerBuyBaseAssetVolume  float64 `json:"taker_buy_base_asset_volume" parquet:"taker_buy_base_asset_volume"`
+	TakerBuyQuoteAssetVolume float64 `json:"taker_buy_quote_asset_volume" parquet:"taker_buy_quote_asset_volume"`
+	FirstTradeID             int64   `json:"first_trade_id" parquet:"first_trade_id"`
+	LastTradeID              int64   `json:"last_trade_id" parquet:"last_trade_id"`
+}
+
+func toRow(k *models.Kline) row {
+	return row{
+		OpenTime:                 k.OpenTime,
+		Open:                     k.Open,
+		High:                     k.High,
+		Low:                      k.Low,
+		Close:                    k.Close,
+		Volume:                   k.Volume,
+		CloseTime:                k.CloseTime,
+		TradeNum:                 k.TradeNum,
+		QuoteAssetVolume:         k.QuoteAssetVolume,
+		TakerBuyBaseAssetVolume:  k.TakerBuyBaseAssetVolume,
+		TakerBuyQuoteAssetVolume: k.TakerBuyQuoteAssetVolume,
+		FirstTradeID:             k.FirstTradeID,
+		LastTradeID:              k.LastTradeID,
+	}
+}
+
+var csvHeader = []string{
+	"open_time", "open", "high", "low", "close", "volume", "close_time", "trade_num",
+	"quote_asset_volume", "taker_buy_base_asset_volume", "taker_buy_quote_asset_volume",
+	"first_trade_id", "last_trade_id",
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (cw *csvWriter) write(klines []*models.Kline) error {
+	if !cw.header {
+		cw.header = true
+		if err := cw.w.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	ints := func(v int64) string { return strconv.FormatInt(v, 10) }
+	floats := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	record := make([]string, len(csvHeader))
+	for _, k := range klines {
+		record[0], record[1], record[2], record[3] = ints(k.OpenTime), floats(k.Open), floats(k.High), floats(k.Low)
+		record[4], record[5], record[6], record[7] = floats(k.Close), floats(k.Volume), ints(k.CloseTime), ints(k.TradeNum)
+		record[8], record[9], record[10] = floats(k.QuoteAssetVolume), floats(k.TakerBuyBaseAssetVolume), floats(k.TakerBuyQuoteAssetVolume)
+		record[11], record[12] = ints(k.FirstTradeID), ints(k.LastTradeID)
+		if err := cw.w.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cw *csvWriter) close() error {
+	// An empty export still gets its header.
+	if err := cw.write(nil); err != nil {
+		return err
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type ndjsonWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	buf := bufio.NewWriter(w)
+	return &ndjsonWriter{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+func (nw *ndjsonWriter) write(klines []*models.Kline) error {
+	for _, k := range klines {
+		if err := nw.enc.Encode(toRow(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (nw *ndjsonWriter) close() error {
+	return nw.buf.Flush()
+}
+
+type parquetWriter struct {
+	w    *parquet.GenericWriter[row]
+	rows []row
+}
+
+func newParquetWriter(w io.Writer, c Compression) *parquetWriter {
+	var options []parquet.WriterOption
+	switch c {
+	case CompressionGzip:
+		options = append(options, parquet.Compression(&parquet.Gzip))
+	case CompressionZstd:
+		options = append(options, parquet.Compression(&parquet.Zstd))
+	}
+	return &parquetWriter{w: parquet.NewGenericWriter[row](w, options...)}
+}
+
+func (pw *parquetWriter) write(klines []*models.Kline) error {
+	pw.rows = pw.rows[:0]
+	for _, k := range klines {
+		pw.rows = append(pw.rows, toRow(k))
+	}
+	_, err := pw.w.Write(pw.rows)
+	return err
+}
+
+func (pw *parquetWriter) close() error {
+	return pw.w.Close()
+}
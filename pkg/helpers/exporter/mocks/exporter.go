@@ -0,0 +1,110 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: exporter.go
+//
+// Generated by this command:
+//
+//	mockgen -source=exporter.go -destination=mocks/exporter.go
+//
+
+// Package mock_exporter is a generated GoMock package.
+package mock_exporter
+
+import (
+	context "context"
+	models "crypto_bot/pkg/exchange/models"
+	storage "crypto_bot/pkg/storage"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockStorage is a mock of Storage interface.
+type MockStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockStorageMockRecorder is the mock recorder for MockStorage.
+type MockStorageMockRecorder struct {
+	mock *MockStorage
+}
+
+// NewMockStorage creates a new mock instance.
+func NewMockStorage(ctrl *gomock.Controller) *MockStorage {
+	mock := &MockStorage{ctrl: ctrl}
+	mock.recorder = &MockStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
+	return m.recorder
+}
+
+// ReadKlines mocks base method.
+func (m *MockStorage) ReadKlines(arg0 context.Context, arg1 storage.ReadKlinesRequest) ([]*models.Kline, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadKlines", arg0, arg1)
+	ret0, _ := ret[0].([]*models.Kline)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadKlines indicates an expected call of ReadKlines.
+func (mr *MockStorageMockRecorder) ReadKlines(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadKlines", reflect.TypeOf((*MockStorage)(nil).ReadKlines), arg0, arg1)
+}
+
+// MockrowWriter is a mock of rowWriter interface.
+type MockrowWriter struct {
+	ctrl     *gomock.Controller
+	recorder *MockrowWriterMockRecorder
+	isgomock struct{}
+}
+
+// MockrowWriterMockRecorder is the mock recorder for MockrowWriter.
+type MockrowWriterMockRecorder struct {
+	mock *MockrowWriter
+}
+
+// NewMockrowWriter creates a new mock instance.
+func NewMockrowWriter(ctrl *gomock.Controller) *MockrowWriter {
+	mock := &MockrowWriter{ctrl: ctrl}
+	mock.recorder = &MockrowWriterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockrowWriter) EXPECT() *MockrowWriterMockRecorder {
+	return m.recorder
+}
+
+// close mocks base method.
+func (m *MockrowWriter) close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// close indicates an expected call of close.
+func (mr *MockrowWriterMockRecorder) close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "close", reflect.TypeOf((*MockrowWriter)(nil).close))
+}
+
+// write mocks base method.
+func (m *MockrowWriter) write(arg0 []*models.Kline) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "write", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// write indicates an expected call of write.
+func (mr *MockrowWriterMockRecorder) write(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "write", reflect.TypeOf((*MockrowWriter)(nil).write), arg0)
+}
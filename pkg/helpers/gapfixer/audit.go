@@ -0,0 +1,147 @@
+package gapfixer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage"
+	"crypto_bot/pkg/storage/pgdb"
+)
+
+//go:generate mockgen -source=audit.go -destination=mocks/audit.go
+type EmptyRanges interface {
+	ReadEmptyRanges(context.Context, pgdb.ReadEmptyRangesRequest) ([]*pgdb.EmptyRange, error)
+	WriteEmptyRange(context.Context, pgdb.WriteEmptyRangeRequest) error
+}
+
+type AuditRequest struct {
+	Pairs     []models.WsKlineRequest
+	From, To  time.Time
+	ChunkSize int
+}
+
+// PairAudit is the kline coverage of a pair from From to To.
+type PairAudit struct {
+	Symbol   string `json:"symbol"`
+	Interval string `json:"interval"`
+	From     int64  `json:"from"`
+	To       int64  `json:"to"`
+	// Expected is the number of klines the range fits, Missing of them are
+	// not stored and ExchangeEmpty of them the exchange has no data for.
+	Expected      int64          `json:"expected"`
+	Missing       int64          `json:"missing"`
+	ExchangeEmpty int64          `json:"exchange_empty"`
+	Ranges        []MissingRange `json:"ranges"`
+}
+
+// Coverage returns the percentage of expected klines that are stored.
+func (a *PairAudit) Coverage() float64 {
+	if a.Expected == 0 {
+		return 100
+	}
+	return 100 * float64(a.Expected-a.Missing-a.ExchangeEmpty) / float64(a.Expected)
+}
+
+// MissingRange is a range of klines not stored, from Start to End inclusive.
+type MissingRange struct {
+	Start  int64 `json:"start"`
+	End    int64 `json:"end"`
+	Klines int64 `json:"klines"`
+	// ExchangeEmpty is set when the exchange has no data for the range, so
+	// fixing gaps skips it.
+	ExchangeEmpty bool `json:"exchange_empty"`
+}
+
+// Audit finds the gaps of every pair in storage without asking the exchange.
+// empty may be nil if ranges the exchange has no data for are not tracked.
+func Audit(ctx context.Context, s Storage, empty EmptyRanges, req AuditRequest) ([]*PairAudit, error) {
+	audits := make([]*PairAudit, 0, len(req.Pairs))
+	for _, p := range req.Pairs {
+		a, err := auditPair(ctx, s, empty, p, req.From.UnixMilli(), req.To.UnixMilli(), req.ChunkSize)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", p.Symbol, p.Interval, err)
+		}
+		audits = append(audits, a)
+	}
+	return audits, nil
+}
+
+func auditPair(ctx context.Context, s Storage, empty EmptyRanges, p models.WsKlineRequest, from, to int64, chunkSize int) (*PairAudit, error) {
+	interval, err := models.ParseInterval(p.Interval)
+	if err != nil {
+		return nil, err
+	}
+	empties, err := readEmptyRanges(ctx, empty, p.Symbol, p.Interval, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &PairAudit{Symbol: p.Symbol, Interval: p.Interval, From: from, To: to, Expected: interval.Count(from, to)}
+	for openTime := from; to > openTime; {
+		klines, err := s.ReadKlines(ctx, storage.ReadKlinesRequest{
+			Symbol:    p.Symbol,
+			Interval:  p.Interval,
+			OpenTime:  openTime,
+			CloseTime: to,
+			Limit:     uint64(chunkSize),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("read klines: %w", err)
+		}
+		for _, g := range findGaps(klines, openTime, to, chunkSize) {
+			for _, part := range splitGap(g, empties) {
+				// Parts shorter than a kline, like the one of the kline
+				// still open at to, are not missing.
+				n := interval.Count(part.start, part.end)
+				if n == 0 {
+					continue
+				}
+				a.Ranges = append(a.Ranges, MissingRange{Start: part.start, End: part.end, Klines: n, ExchangeEmpty: part.empty})
+				if part.empty {
+					a.ExchangeEmpty += n
+				} else {
+					a.Missing += n
+				}
+			}
+		}
+		if len(klines) < chunkSize {
+			break
+		}
+		openTime = klines[len(klines)-1].CloseTime + 1
+	}
+	return a, nil
+}
+
+func readEmptyRanges(ctx context.Context, empty EmptyRanges, symbol, interval string, from, to int64) ([]*pgdb.EmptyRange, error) {
+	if empty == nil {
+		return nil, nil
+	}
+	ranges, err := empty.ReadEmptyRanges(ctx, pgdb.ReadEmptyRangesRequest{Symbol: symbol, Interval: interval, From: from, To: to})
+	if err != nil {
+		return nil, fmt.Errorf("read empty ranges: %w", err)
+	}
+	return ranges, nil
+}
+
+// splitGap splits g by the ranges the exchange has no data for, which must be
+// ordered by start. The parts are in order and those in empties are marked.
+func splitGap(g gap, empties []*pgdb.EmptyRange) []gap {
+	var parts []gap
+	start := g.start
+	for _, e := range empties {
+		if e.End < start || e.Start > g.end {
+			continue
+		}
+		if e.Start > start {
+			parts = append(parts, gap{start: start, end: e.Start - 1})
+		}
+		parts = append(parts, gap{start: max(e.Start, start), end: min(e.End, g.end), empty: true})
+		start = e.End + 1
+		if start > g.end {
+			return parts
+		}
+	}
+	return append(parts, gap{start: start, end: g.end})
+}
@@ -0,0 +1,135 @@
+package gapfixer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"crypto_bot/pkg/exchange/models"
+	mockgapfixer "crypto_bot/pkg/helpers/gapfixer/mocks"
+	"crypto_bot/pkg/storage"
+	"crypto_bot/pkg/storage/pgdb"
+)
+
+func TestSplitGap(t *testing.T) {
+	testCases := []struct {
+		name    string
+		g       gap
+		empties []*pgdb.EmptyRange
+		want    []gap
+	}{
+		{
+			name: "no empties",
+			g:    gap{start: 10, end: 19},
+			want: []gap{{start: 10, end: 19}},
+		},
+		{
+			name:    "empty outside",
+			g:       gap{start: 10, end: 19},
+			empties: []*pgdb.EmptyRange{{Start: 0, End: 9}, {Start: 20, End: 29}},
+			want:    []gap{{start: 10, end: 19}},
+		},
+		{
+			name:    "empty covers gap",
+			g:       gap{start: 10, end: 19},
+			empties: []*pgdb.EmptyRange{{Start: 0, End: 29}},
+			want:    []gap{{start: 10, end: 19, empty: true}},
+		},
+		{
+			name:    "empty in middle",
+			g:       gap{start: 10, end: 39},
+			empties: []*pgdb.EmptyRange{{Start: 20, End: 29}},
+			want:    []gap{{start: 10, end: 19}, {start: 20, end: 29, empty: true}, {start: 30, end: 39}},
+		},
+		{
+			name:    "empties at both ends",
+			g:       gap{start: 10, end: 39},
+			empties: []*pgdb.EmptyRange{{Start: 0, End: 14}, {Start: 35, End: 49}},
+			want:    []gap{{start: 10, end: 14, empty: true}, {start: 15, end: 34}, {start: 35, end: 39, empty: true}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, splitGap(tc.g, tc.empties))
+		})
+	}
+}
+
+func TestAudit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mockgapfixer.NewMockStorage(ctrl)
+	empty := mockgapfixer.NewMockEmptyRanges(ctrl)
+
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(10*time.Minute - time.Millisecond)
+	minute := func(i int) int64 { return from.Add(time.Duration(i) * time.Minute).UnixMilli() }
+	kline := func(i int) *models.Kline { return &models.Kline{OpenTime: minute(i), CloseTime: minute(i+1) - 1} }
+
+	empty.EXPECT().ReadEmptyRanges(gomock.Any(), pgdb.ReadEmptyRangesRequest{Symbol: "BTCUSDT", Interval: "1m",
+		From: from.UnixMilli(), To: to.UnixMilli()}).
+		Return([]*pgdb.EmptyRange{{Start: minute(5), End: minute(7) - 1}}, nil)
+	s.EXPECT().ReadKlines(gomock.Any(), storage.ReadKlinesRequest{Symbol: "BTCUSDT", Interval: "1m",
+		OpenTime: from.UnixMilli(), CloseTime: to.UnixMilli(), Limit: 4}).
+		Return([]*models.Kline{kline(0), kline(1), kline(3), kline(4)}, nil)
+	s.EXPECT().ReadKlines(gomock.Any(), storage.ReadKlinesRequest{Symbol: "BTCUSDT", Interval: "1m",
+		OpenTime: minute(5), CloseTime: to.UnixMilli(), Limit: 4}).
+		Return([]*models.Kline{kline(8)}, nil)
+
+	audits, err := Audit(context.Background(), s, empty, AuditRequest{
+		Pairs:     []models.WsKlineRequest{{Symbol: "BTCUSDT", Interval: "1m"}},
+		From:      from,
+		To:        to,
+		ChunkSize: 4,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []*PairAudit{{
+		Symbol:        "BTCUSDT",
+		Interval:      "1m",
+		From:          from.UnixMilli(),
+		To:            to.UnixMilli(),
+		Expected:      10,
+		Missing:       3,
+		ExchangeEmpty: 2,
+		Ranges: []MissingRange{
+			{Start: minute(2), End: minute(3) - 1, Klines: 1},
+			{Start: minute(5), End: minute(7) - 1, Klines: 2, ExchangeEmpty: true},
+			{Start: minute(7), End: minute(8) - 1, Klines: 1},
+			{Start: minute(9), End: to.UnixMilli(), Klines: 1},
+		},
+	}}, audits)
+	require.Equal(t, 50.0, audits[0].Coverage())
+}
+
+func TestFixer_RecordEmpty(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mockgapfixer.NewMockStorage(ctrl)
+	ex := mockgapfixer.NewMockExchange(ctrl)
+	empty := mockgapfixer.NewMockEmptyRanges(ctrl)
+
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(5*time.Minute - time.Millisecond)
+	minute := func(i int) int64 { return from.Add(time.Duration(i) * time.Minute).UnixMilli() }
+
+	// The first minute is known to be empty, so only the rest is requested.
+	empty.EXPECT().ReadEmptyRanges(gomock.Any(), pgdb.ReadEmptyRangesRequest{Symbol: "BTCUSDT", Interval: "1m",
+		From: from.UnixMilli(), To: to.UnixMilli()}).
+		Return([]*pgdb.EmptyRange{{Start: minute(0), End: minute(1) - 1}}, nil)
+	s.EXPECT().ReadKlines(gomock.Any(), gomock.Any())
+	ex.EXPECT().Klines(gomock.Any(), models.KlinesRequest{Symbol: "BTCUSDT", Interval: "1m",
+		StartTime: minute(1), EndTime: to.UnixMilli(), Limit: 10}).
+		Return([]*models.Kline{{OpenTime: minute(3), CloseTime: minute(4) - 1}}, nil)
+	// The exchange skipped minutes 1 and 2 and has nothing after minute 3.
+	empty.EXPECT().WriteEmptyRange(gomock.Any(), pgdb.WriteEmptyRangeRequest{Symbol: "BTCUSDT", Interval: "1m",
+		Start: minute(1), End: minute(3) - 1})
+	empty.EXPECT().WriteEmptyRange(gomock.Any(), pgdb.WriteEmptyRangeRequest{Symbol: "BTCUSDT", Interval: "1m",
+		Start: minute(4), End: to.UnixMilli()})
+	s.EXPECT().WriteKlines(gomock.Any(), storage.WriteKlinesRequest{Symbol: "BTCUSDT", Interval: "1m",
+		Klines: []*models.Kline{{OpenTime: minute(3), CloseTime: minute(4) - 1}}})
+
+	f := &fixer{ex: ex, s: s, limiter: noLimit{}, progress: &Progress{}, empty: empty, now: time.Now,
+		symbol: "BTCUSDT", interval: "1m", chunkSize: 10}
+	require.NoError(t, f.fix(context.Background(), from.UnixMilli(), to.UnixMilli()))
+}
@@ -5,70 +5,111 @@ import (
 	"fmt"
 	"time"
 
+	"crypto_bot/pkg/exchange"
 	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage"
 	"crypto_bot/pkg/storage/pgdb"
 )
 
 //go:generate mockgen -source=kline.go -destination=mocks/kline.go
 type Exchange interface {
-	WsKlines(context.Context, models.WsKlineRequest) (<-chan *models.WsKlineEvent, <-chan error, error)
-	Klines(context.Context, models.KlinesRequest) ([]*models.Kline, error)
+	exchange.KlineService
 }
 
 type Storage interface {
-	WriteKlines(context.Context, pgdb.WriteKlinesRequest) ([]*pgdb.Kline, error)
-	ReadKlines(context.Context, pgdb.ReadKlinesRequest) ([]*pgdb.Kline, error)
+	WriteKlines(context.Context, storage.WriteKlinesRequest) error
+	ReadKlines(context.Context, storage.ReadKlinesRequest) ([]*models.Kline, error)
 }
 
 func FixGaps(ctx context.Context, ex Exchange, s Storage, symbol, interval string, from, to time.Time, chunkSize int) error {
-	_, err := time.ParseDuration(interval)
-	if err != nil {
+	if _, err := models.ParseInterval(interval); err != nil {
 		return fmt.Errorf("could not parse interval: %w", err)
 	}
-	var openTime, closeTime = from.UnixMilli(), to.UnixMilli()
-	for i := 0; closeTime > openTime; i++ {
-		klines, err := s.ReadKlines(ctx, pgdb.ReadKlinesRequest{
-			Symbol:    symbol,
-			Interval:  interval,
+	f := &fixer{ex: ex, s: s, limiter: noLimit{}, progress: &Progress{}, now: time.Now,
+		symbol: symbol, interval: interval, chunkSize: chunkSize}
+	return f.fix(ctx, from.UnixMilli(), to.UnixMilli())
+}
+
+// klinesWeight is the request weight of the klines endpoint.
+const klinesWeight = 2
+
+// fixer fixes gaps of one pair.
+type fixer struct {
+	ex       Exchange
+	s        Storage
+	limiter  Limiter
+	progress *Progress
+	// empty, if set, records the ranges the exchange has no data for, which
+	// are skipped afterwards.
+	empty EmptyRanges
+	now   func() time.Time
+
+	symbol, interval string
+	chunkSize        int
+
+	// checked, if set, is called each time klines up to checkedTo have no gaps.
+	checked func(ctx context.Context, checkedTo int64) error
+}
+
+func (f *fixer) fix(ctx context.Context, openTime, closeTime int64) error {
+	empties, err := readEmptyRanges(ctx, f.empty, f.symbol, f.interval, openTime, closeTime)
+	if err != nil {
+		return err
+	}
+	for closeTime > openTime {
+		klines, err := f.s.ReadKlines(ctx, storage.ReadKlinesRequest{
+			Symbol:    f.symbol,
+			Interval:  f.interval,
 			OpenTime:  openTime,
 			CloseTime: closeTime,
-			Limit:     uint64(chunkSize),
+			Limit:     uint64(f.chunkSize),
 		})
 		if err != nil {
 			return fmt.Errorf("read klines: %w", err)
 		}
 
-		gaps := findGaps(klines, openTime, closeTime, chunkSize)
-		for _, g := range gaps {
-			if err = fixGap(ctx, ex, s, symbol, interval, chunkSize, g); err != nil {
-				return fmt.Errorf("get gap klines: %w", err)
+		for _, g := range findGaps(klines, openTime, closeTime, f.chunkSize) {
+			for _, part := range splitGap(g, empties) {
+				if part.empty {
+					continue
+				}
+				if err = f.fixGap(ctx, part); err != nil {
+					return fmt.Errorf("get gap klines: %w", err)
+				}
 			}
+		}
 
+		checkedTo := closeTime
+		if len(klines) == f.chunkSize {
+			checkedTo = klines[len(klines)-1].CloseTime
+		}
+		if f.checked != nil {
+			if err = f.checked(ctx, checkedTo); err != nil {
+				return err
+			}
 		}
-		if len(klines) < chunkSize {
+		if len(klines) < f.chunkSize {
 			return nil
 		}
-		openTime = klines[len(klines)-1].CloseTime + 1
+		openTime = checkedTo + 1
 	}
 	return nil
 }
 
 type gap struct {
 	start, end int64
+	// empty is set when the exchange has no data for the gap.
+	empty bool
 }
 
-func findGaps(klines []*pgdb.Kline, from, to int64, chunkSize int) []gap {
+func findGaps(klines []*models.Kline, from, to int64, chunkSize int) []gap {
 	if len(klines) == 0 {
 		return []gap{{start: from, end: to}}
 	}
 
+	// Klines are compared by their own boundaries rather than a fixed
+	// duration, as monthly klines differ in length.
 	first, last := klines[0], klines[len(klines)-1]
-	duration := first.CloseTime - first.OpenTime + 1
-	chunkLastCloseTime := first.OpenTime + duration*int64(chunkSize) - 1
-	if len(klines) == chunkSize && first.OpenTime == from && last.CloseTime == chunkLastCloseTime {
-		return nil
-	}
-
 	var gaps []gap
 	if first.OpenTime > from {
 		gaps = append(gaps, gap{start: from, end: first.OpenTime - 1})
@@ -87,31 +128,46 @@ func findGaps(klines []*pgdb.Kline, from, to int64, chunkSize int) []gap {
 	return gaps
 }
 
-func fixGap(ctx context.Context, ex Exchange, s Storage, symbol, interval string, limit int, g gap) error {
+func (f *fixer) fixGap(ctx context.Context, g gap) error {
+	if g.start < g.end {
+		f.progress.gaps.Add(1)
+	}
 	for g.start < g.end {
-		fmt.Printf("fixGap: start: %d, end: %d\n", g.start, g.end)
-		klines, err := ex.Klines(ctx, models.KlinesRequest{
-			Symbol:    symbol,
-			Interval:  interval,
-			Limit:     limit,
+		if err := f.limiter.Wait(ctx, klinesWeight); err != nil {
+			return err
+		}
+		klines, err := f.ex.Klines(ctx, models.KlinesRequest{
+			Symbol:    f.symbol,
+			Interval:  f.interval,
+			Limit:     f.chunkSize,
 			StartTime: g.start,
 			EndTime:   g.end,
 		})
 		if err != nil {
 			return fmt.Errorf("get klines: %w", err)
 		}
+		if err = f.recordEmpty(ctx, klines, g); err != nil {
+			return fmt.Errorf("record empty ranges: %w", err)
+		}
+		// A kline that is still open would be stored partial and, having no
+		// gap, never fetched again.
+		now := f.now().UnixMilli()
+		for len(klines) > 0 && klines[len(klines)-1].CloseTime >= now {
+			klines = klines[:len(klines)-1]
+		}
 		if len(klines) == 0 {
 			return nil
 		}
-		_, err = s.WriteKlines(ctx, pgdb.WriteKlinesRequest{
-			Symbol:   symbol,
-			Interval: interval,
-			Klines:   convertKlines(klines),
+		err = f.s.WriteKlines(ctx, storage.WriteKlinesRequest{
+			Symbol:   f.symbol,
+			Interval: f.interval,
+			Klines:   klines,
 		})
 		if err != nil {
 			return fmt.Errorf("write klines: %w", err)
 		}
-		if len(klines) < limit {
+		f.progress.filled.Add(int64(len(klines)))
+		if len(klines) < f.chunkSize {
 			return nil
 		}
 		g.start = klines[len(klines)-1].CloseTime + 1
@@ -119,19 +175,27 @@ func fixGap(ctx context.Context, ex Exchange, s Storage, symbol, interval string
 	return nil
 }
 
-func convertKlines(klines []*models.Kline) []*pgdb.Kline {
-	converted := make([]*pgdb.Kline, 0, len(klines))
-	for _, k := range klines {
-		converted = append(converted, &pgdb.Kline{
-			OpenTime:  k.OpenTime,
-			Open:      k.Open,
-			High:      k.High,
-			Low:       k.Low,
-			Close:     k.Close,
-			Volume:    k.Volume,
-			CloseTime: k.CloseTime,
-			TradeNum:  k.TradeNum,
+// recordEmpty records the parts of g the exchange returned no klines for.
+// Parts that end within the last interval are left out, as their klines may
+// be yet to come.
+func (f *fixer) recordEmpty(ctx context.Context, klines []*models.Kline, g gap) error {
+	if f.empty == nil {
+		return nil
+	}
+	settled := f.now().Add(-models.Interval(f.interval).Duration()).UnixMilli()
+	for _, h := range findGaps(klines, g.start, g.end, f.chunkSize) {
+		if h.end >= settled || h.start >= h.end {
+			continue
+		}
+		err := f.empty.WriteEmptyRange(ctx, pgdb.WriteEmptyRangeRequest{
+			Symbol:   f.symbol,
+			Interval: f.interval,
+			Start:    h.start,
+			End:      h.end,
 		})
+		if err != nil {
+			return err
+		}
 	}
-	return converted
+	return nil
 }
@@ -10,25 +10,33 @@ import (
 
 	"crypto_bot/pkg/exchange/models"
 	mockgapfixer "crypto_bot/pkg/helpers/gapfixer/mocks"
-	"crypto_bot/pkg/storage/pgdb"
+	"crypto_bot/pkg/storage"
 )
 
 func TestFindGaps(t *testing.T) {
+	at := func(year int, month time.Month, day int) int64 {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC).UnixMilli()
+	}
+	// kline returns the n-th kline of interval i counting from openTime.
+	kline := func(i models.Interval, openTime int64, n int) *models.Kline {
+		return &models.Kline{OpenTime: i.Add(openTime, n), CloseTime: i.Add(openTime, n+1) - 1}
+	}
+
 	testCases := []struct {
 		name      string
-		klines    []*pgdb.Kline
+		klines    []*models.Kline
 		from, to  int64
 		chunkSize int
 		want      []gap
 	}{
 		{
 			name:   "no params",
-			klines: []*pgdb.Kline{},
+			klines: []*models.Kline{},
 			want:   []gap{{start: 0, end: 0}},
 		},
 		{
 			name: "one kline",
-			klines: []*pgdb.Kline{
+			klines: []*models.Kline{
 				{OpenTime: 0, CloseTime: 9},
 			},
 			from:      0,
@@ -38,7 +46,7 @@ func TestFindGaps(t *testing.T) {
 		},
 		{
 			name: "no gaps",
-			klines: []*pgdb.Kline{
+			klines: []*models.Kline{
 				{OpenTime: 0, CloseTime: 9},
 				{OpenTime: 10, CloseTime: 19},
 				{OpenTime: 20, CloseTime: 29},
@@ -51,7 +59,7 @@ func TestFindGaps(t *testing.T) {
 		},
 		{
 			name: "gap in middle",
-			klines: []*pgdb.Kline{
+			klines: []*models.Kline{
 				{OpenTime: 0, CloseTime: 9},
 				{OpenTime: 30, CloseTime: 39},
 				{OpenTime: 40, CloseTime: 49},
@@ -64,7 +72,7 @@ func TestFindGaps(t *testing.T) {
 		},
 		{
 			name: "a few gaps in middle",
-			klines: []*pgdb.Kline{
+			klines: []*models.Kline{
 				{OpenTime: 0, CloseTime: 9},
 				{OpenTime: 30, CloseTime: 39},
 				{OpenTime: 50, CloseTime: 59},
@@ -75,7 +83,7 @@ func TestFindGaps(t *testing.T) {
 		},
 		{
 			name: "all possible gaps",
-			klines: []*pgdb.Kline{
+			klines: []*models.Kline{
 				{OpenTime: 10, CloseTime: 19},
 				{OpenTime: 30, CloseTime: 39},
 				{OpenTime: 50, CloseTime: 59},
@@ -87,7 +95,7 @@ func TestFindGaps(t *testing.T) {
 		},
 		{
 			name: "empty start",
-			klines: []*pgdb.Kline{
+			klines: []*models.Kline{
 				{OpenTime: 40, CloseTime: 49},
 				{OpenTime: 50, CloseTime: 59},
 			},
@@ -97,7 +105,7 @@ func TestFindGaps(t *testing.T) {
 		},
 		{
 			name: "empty end",
-			klines: []*pgdb.Kline{
+			klines: []*models.Kline{
 				{OpenTime: 30, CloseTime: 39},
 				{OpenTime: 40, CloseTime: 49},
 				{OpenTime: 50, CloseTime: 59},
@@ -109,11 +117,79 @@ func TestFindGaps(t *testing.T) {
 		},
 		{
 			name:   "empty klines",
-			klines: []*pgdb.Kline{},
+			klines: []*models.Kline{},
 			from:   0,
 			to:     60,
 			want:   []gap{{start: 0, end: 60}},
 		},
+		{
+			name: "1d no gaps",
+			klines: []*models.Kline{
+				kline(models.Interval1d, at(2024, 2, 28), 0),
+				kline(models.Interval1d, at(2024, 2, 28), 1),
+				kline(models.Interval1d, at(2024, 2, 28), 2),
+			},
+			from:      at(2024, 2, 28),
+			to:        at(2024, 3, 2),
+			chunkSize: 3,
+			want:      nil,
+		},
+		{
+			name: "3d gap in middle",
+			klines: []*models.Kline{
+				kline(models.Interval3d, at(2024, 1, 1), 0),
+				kline(models.Interval3d, at(2024, 1, 1), 2),
+			},
+			from:      at(2024, 1, 1),
+			to:        at(2024, 1, 10) - 1,
+			chunkSize: 3,
+			want:      []gap{{start: at(2024, 1, 4), end: at(2024, 1, 7) - 1}},
+		},
+		{
+			name: "1w empty end",
+			klines: []*models.Kline{
+				kline(models.Interval1w, at(2024, 1, 1), 0),
+				kline(models.Interval1w, at(2024, 1, 1), 1),
+			},
+			from:      at(2024, 1, 1),
+			to:        at(2024, 1, 29) - 1,
+			chunkSize: 4,
+			want:      []gap{{start: at(2024, 1, 15), end: at(2024, 1, 29) - 1}},
+		},
+		{
+			name: "1M no gaps across months of different length",
+			klines: []*models.Kline{
+				kline(models.Interval1M, at(2024, 1, 1), 0),
+				kline(models.Interval1M, at(2024, 1, 1), 1),
+				kline(models.Interval1M, at(2024, 1, 1), 2),
+				kline(models.Interval1M, at(2024, 1, 1), 3),
+			},
+			from:      at(2024, 1, 1),
+			to:        at(2024, 5, 1) - 1,
+			chunkSize: 4,
+			want:      nil,
+		},
+		{
+			name: "1M gap in february",
+			klines: []*models.Kline{
+				kline(models.Interval1M, at(2024, 1, 1), 0),
+				kline(models.Interval1M, at(2024, 1, 1), 2),
+			},
+			from:      at(2024, 1, 1),
+			to:        at(2024, 4, 1) - 1,
+			chunkSize: 3,
+			want:      []gap{{start: at(2024, 2, 1), end: at(2024, 3, 1) - 1}},
+		},
+		{
+			name: "1M empty start",
+			klines: []*models.Kline{
+				kline(models.Interval1M, at(2023, 12, 1), 0),
+			},
+			from:      at(2023, 10, 1),
+			to:        at(2024, 1, 1) - 1,
+			chunkSize: 3,
+			want:      []gap{{start: at(2023, 10, 1), end: at(2023, 12, 1) - 1}},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -133,10 +209,10 @@ func TestFixGaps_NoStartKlines(t *testing.T) {
 	to := from.Add(5 * time.Minute)
 
 	s.EXPECT().
-		ReadKlines(gomock.Any(), pgdb.ReadKlinesRequest{Symbol: symbol, Interval: interval,
+		ReadKlines(gomock.Any(), storage.ReadKlinesRequest{Symbol: symbol, Interval: interval,
 			OpenTime:  from.UnixMilli(),
 			CloseTime: to.UnixMilli(), Limit: 2}).
-		Return([]*pgdb.Kline{
+		Return([]*models.Kline{
 			{OpenTime: to.Add(-1 * time.Minute).UnixMilli(), CloseTime: to.Add(-1 * time.Millisecond).UnixMilli()},
 		}, nil)
 
@@ -148,12 +224,12 @@ func TestFixGaps_NoStartKlines(t *testing.T) {
 			{OpenTime: from.UnixMilli(), CloseTime: from.Add(1*time.Minute).UnixMilli() - 1},
 			{OpenTime: from.Add(1 * time.Minute).UnixMilli(), CloseTime: from.Add(2*time.Minute).UnixMilli() - 1},
 		}, nil)
-	s.EXPECT().WriteKlines(gomock.Any(), pgdb.WriteKlinesRequest{Symbol: symbol, Interval: interval,
-		Klines: []*pgdb.Kline{
+	s.EXPECT().WriteKlines(gomock.Any(), storage.WriteKlinesRequest{Symbol: symbol, Interval: interval,
+		Klines: []*models.Kline{
 			{OpenTime: from.UnixMilli(), CloseTime: from.Add(1*time.Minute).UnixMilli() - 1},
 			{OpenTime: from.Add(1 * time.Minute).UnixMilli(), CloseTime: from.Add(2*time.Minute).UnixMilli() - 1},
 		},
-	}).Return(nil, nil)
+	}).Return(nil)
 
 	ex.EXPECT().
 		Klines(gomock.Any(), models.KlinesRequest{Symbol: symbol, Interval: interval,
@@ -163,12 +239,12 @@ func TestFixGaps_NoStartKlines(t *testing.T) {
 			{OpenTime: from.Add(2 * time.Minute).UnixMilli(), CloseTime: from.Add(3*time.Minute).UnixMilli() - 1},
 			{OpenTime: from.Add(3 * time.Minute).UnixMilli(), CloseTime: from.Add(4*time.Minute).UnixMilli() - 1},
 		}, nil)
-	s.EXPECT().WriteKlines(gomock.Any(), pgdb.WriteKlinesRequest{Symbol: symbol, Interval: interval,
-		Klines: []*pgdb.Kline{
+	s.EXPECT().WriteKlines(gomock.Any(), storage.WriteKlinesRequest{Symbol: symbol, Interval: interval,
+		Klines: []*models.Kline{
 			{OpenTime: from.Add(2 * time.Minute).UnixMilli(), CloseTime: from.Add(3*time.Minute).UnixMilli() - 1},
 			{OpenTime: from.Add(3 * time.Minute).UnixMilli(), CloseTime: from.Add(4*time.Minute).UnixMilli() - 1},
 		},
-	}).Return(nil, nil)
+	}).Return(nil)
 
 	err := FixGaps(context.Background(), ex, s, symbol, interval, from, to, 2)
 	require.NoError(t, err)
@@ -184,10 +260,10 @@ func TestFixGaps_NoEndKlines(t *testing.T) {
 	to := from.Add(5 * time.Minute)
 
 	s.EXPECT().
-		ReadKlines(gomock.Any(), pgdb.ReadKlinesRequest{Symbol: symbol, Interval: interval,
+		ReadKlines(gomock.Any(), storage.ReadKlinesRequest{Symbol: symbol, Interval: interval,
 			OpenTime:  from.UnixMilli(),
 			CloseTime: to.UnixMilli(), Limit: 2}).
-		Return([]*pgdb.Kline{
+		Return([]*models.Kline{
 			{OpenTime: from.UnixMilli(), CloseTime: from.Add(1*time.Minute).UnixMilli() - 1},
 		}, nil)
 
@@ -199,12 +275,12 @@ func TestFixGaps_NoEndKlines(t *testing.T) {
 			{OpenTime: from.Add(1 * time.Minute).UnixMilli(), CloseTime: from.Add(2*time.Minute).UnixMilli() - 1},
 			{OpenTime: from.Add(2 * time.Minute).UnixMilli(), CloseTime: from.Add(3*time.Minute).UnixMilli() - 1},
 		}, nil)
-	s.EXPECT().WriteKlines(gomock.Any(), pgdb.WriteKlinesRequest{Symbol: symbol, Interval: interval,
-		Klines: []*pgdb.Kline{
+	s.EXPECT().WriteKlines(gomock.Any(), storage.WriteKlinesRequest{Symbol: symbol, Interval: interval,
+		Klines: []*models.Kline{
 			{OpenTime: from.Add(1 * time.Minute).UnixMilli(), CloseTime: from.Add(2*time.Minute).UnixMilli() - 1},
 			{OpenTime: from.Add(2 * time.Minute).UnixMilli(), CloseTime: from.Add(3*time.Minute).UnixMilli() - 1},
 		},
-	}).Return(nil, nil)
+	}).Return(nil)
 
 	ex.EXPECT().
 		Klines(gomock.Any(), models.KlinesRequest{Symbol: symbol, Interval: interval,
@@ -214,13 +290,21 @@ func TestFixGaps_NoEndKlines(t *testing.T) {
 			{OpenTime: from.Add(3 * time.Minute).UnixMilli(), CloseTime: from.Add(4*time.Minute).UnixMilli() - 1},
 			{OpenTime: from.Add(4 * time.Minute).UnixMilli(), CloseTime: to.Add(5*time.Minute).UnixMilli() - 1},
 		}, nil)
-	s.EXPECT().WriteKlines(gomock.Any(), pgdb.WriteKlinesRequest{Symbol: symbol, Interval: interval,
-		Klines: []*pgdb.Kline{
+	s.EXPECT().WriteKlines(gomock.Any(), storage.WriteKlinesRequest{Symbol: symbol, Interval: interval,
+		Klines: []*models.Kline{
 			{OpenTime: from.Add(3 * time.Minute).UnixMilli(), CloseTime: from.Add(4*time.Minute).UnixMilli() - 1},
 			{OpenTime: from.Add(4 * time.Minute).UnixMilli(), CloseTime: to.Add(5*time.Minute).UnixMilli() - 1},
 		},
-	}).Return(nil, nil)
+	}).Return(nil)
 
 	err := FixGaps(context.Background(), ex, s, symbol, interval, from, to, 2)
 	require.NoError(t, err)
 }
+
+func TestFixGaps_InvalidInterval(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	err := FixGaps(context.Background(), mockgapfixer.NewMockExchange(ctrl), mockgapfixer.NewMockStorage(ctrl),
+		"symbol", "1mo", from, from.AddDate(0, 1, 0), 2)
+	require.Error(t, err)
+}
@@ -0,0 +1,54 @@
+package gapfixer
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter blocks until a request of the given weight fits the budget.
+type Limiter interface {
+	Wait(ctx context.Context, weight int) error
+}
+
+type noLimit struct{}
+
+func (noLimit) Wait(context.Context, int) error { return nil }
+
+// WeightLimiter keeps the request weight spent within a minute under a budget,
+// like the REQUEST_WEIGHT limit of Binance. The budget refills continuously.
+type WeightLimiter struct {
+	mu        sync.Mutex
+	perMinute float64
+	available float64
+	last      time.Time
+}
+
+func NewWeightLimiter(perMinute int) *WeightLimiter {
+	return &WeightLimiter{perMinute: float64(perMinute), available: float64(perMinute), last: time.Now()}
+}
+
+func (l *WeightLimiter) Wait(ctx context.Context, weight int) error {
+	w := min(float64(weight), l.perMinute)
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.available = min(l.perMinute, l.available+now.Sub(l.last).Minutes()*l.perMinute)
+		l.last = now
+		if l.available >= w {
+			l.available -= w
+			l.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((w - l.available) / l.perMinute * float64(time.Minute))
+		l.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
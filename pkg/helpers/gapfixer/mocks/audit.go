@@ -0,0 +1,71 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: audit.go
+//
+// Generated by this command:
+//
+//	mockgen -source=audit.go -destination=mocks/audit.go
+//
+
+// Package mock_gapfixer is a generated GoMock package.
+package mock_gapfixer
+
+import (
+	context "context"
+	pgdb "crypto_bot/pkg/storage/pgdb"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockEmptyRanges is a mock of EmptyRanges interface.
+type MockEmptyRanges struct {
+	ctrl     *gomock.Controller
+	recorder *MockEmptyRangesMockRecorder
+	isgomock struct{}
+}
+
+// MockEmptyRangesMockRecorder is the mock recorder for MockEmptyRanges.
+type MockEmptyRangesMockRecorder struct {
+	mock *MockEmptyRanges
+}
+
+// NewMockEmptyRanges creates a new mock instance.
+func NewMockEmptyRanges(ctrl *gomock.Controller) *MockEmptyRanges {
+	mock := &MockEmptyRanges{ctrl: ctrl}
+	mock.recorder = &MockEmptyRangesMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockEmptyRanges) EXPECT() *MockEmptyRangesMockRecorder {
+	return m.recorder
+}
+
+// ReadEmptyRanges mocks base method.
+func (m *MockEmptyRanges) ReadEmptyRanges(arg0 context.Context, arg1 pgdb.ReadEmptyRangesRequest) ([]*pgdb.EmptyRange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadEmptyRanges", arg0, arg1)
+	ret0, _ := ret[0].([]*pgdb.EmptyRange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadEmptyRanges indicates an expected call of ReadEmptyRanges.
+func (mr *MockEmptyRangesMockRecorder) ReadEmptyRanges(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadEmptyRanges", reflect.TypeOf((*MockEmptyRanges)(nil).ReadEmptyRanges), arg0, arg1)
+}
+
+// WriteEmptyRange mocks base method.
+func (m *MockEmptyRanges) WriteEmptyRange(arg0 context.Context, arg1 pgdb.WriteEmptyRangeRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteEmptyRange", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteEmptyRange indicates an expected call of WriteEmptyRange.
+func (mr *MockEmptyRangesMockRecorder) WriteEmptyRange(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteEmptyRange", reflect.TypeOf((*MockEmptyRanges)(nil).WriteEmptyRange), arg0, arg1)
+}
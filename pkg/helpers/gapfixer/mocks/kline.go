@@ -12,7 +12,7 @@ package mock_gapfixer
 import (
 	context "context"
 	models "crypto_bot/pkg/exchange/models"
-	pgdb "crypto_bot/pkg/storage/pgdb"
+	storage "crypto_bot/pkg/storage"
 	reflect "reflect"
 
 	gomock "go.uber.org/mock/gomock"
@@ -57,22 +57,6 @@ func (mr *MockExchangeMockRecorder) Klines(arg0, arg1 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Klines", reflect.TypeOf((*MockExchange)(nil).Klines), arg0, arg1)
 }
 
-// WsKlines mocks base method.
-func (m *MockExchange) WsKlines(arg0 context.Context, arg1 models.WsKlineRequest) (<-chan *models.WsKlineEvent, <-chan error, error) {
-	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "WsKlines", arg0, arg1)
-	ret0, _ := ret[0].(<-chan *models.WsKlineEvent)
-	ret1, _ := ret[1].(<-chan error)
-	ret2, _ := ret[2].(error)
-	return ret0, ret1, ret2
-}
-
-// WsKlines indicates an expected call of WsKlines.
-func (mr *MockExchangeMockRecorder) WsKlines(arg0, arg1 any) *gomock.Call {
-	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WsKlines", reflect.TypeOf((*MockExchange)(nil).WsKlines), arg0, arg1)
-}
-
 // MockStorage is a mock of Storage interface.
 type MockStorage struct {
 	ctrl     *gomock.Controller
@@ -98,10 +82,10 @@ func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
 }
 
 // ReadKlines mocks base method.
-func (m *MockStorage) ReadKlines(arg0 context.Context, arg1 pgdb.ReadKlinesRequest) ([]*pgdb.Kline, error) {
+func (m *MockStorage) ReadKlines(arg0 context.Context, arg1 storage.ReadKlinesRequest) ([]*models.Kline, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "ReadKlines", arg0, arg1)
-	ret0, _ := ret[0].([]*pgdb.Kline)
+	ret0, _ := ret[0].([]*models.Kline)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -113,12 +97,11 @@ func (mr *MockStorageMockRecorder) ReadKlines(arg0, arg1 any) *gomock.Call {
 }
 
 // WriteKlines mocks base method.
-func (m *MockStorage) WriteKlines(arg0 context.Context, arg1 pgdb.WriteKlinesRequest) ([]*pgdb.Kline, error) {
+func (m *MockStorage) WriteKlines(arg0 context.Context, arg1 storage.WriteKlinesRequest) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "WriteKlines", arg0, arg1)
-	ret0, _ := ret[0].([]*pgdb.Kline)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
 // WriteKlines indicates an expected call of WriteKlines.
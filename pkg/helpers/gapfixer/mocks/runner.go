@@ -0,0 +1,71 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: runner.go
+//
+// Generated by this command:
+//
+//	mockgen -source=runner.go -destination=mocks/runner.go
+//
+
+// Package mock_gapfixer is a generated GoMock package.
+package mock_gapfixer
+
+import (
+	context "context"
+	pgdb "crypto_bot/pkg/storage/pgdb"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockCheckpoints is a mock of Checkpoints interface.
+type MockCheckpoints struct {
+	ctrl     *gomock.Controller
+	recorder *MockCheckpointsMockRecorder
+	isgomock struct{}
+}
+
+// MockCheckpointsMockRecorder is the mock recorder for MockCheckpoints.
+type MockCheckpointsMockRecorder struct {
+	mock *MockCheckpoints
+}
+
+// NewMockCheckpoints creates a new mock instance.
+func NewMockCheckpoints(ctrl *gomock.Controller) *MockCheckpoints {
+	mock := &MockCheckpoints{ctrl: ctrl}
+	mock.recorder = &MockCheckpointsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCheckpoints) EXPECT() *MockCheckpointsMockRecorder {
+	return m.recorder
+}
+
+// ReadGapCheckpoint mocks base method.
+func (m *MockCheckpoints) ReadGapCheckpoint(arg0 context.Context, arg1 pgdb.ReadGapCheckpointRequest) (*pgdb.GapCheckpoint, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadGapCheckpoint", arg0, arg1)
+	ret0, _ := ret[0].(*pgdb.GapCheckpoint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadGapCheckpoint indicates an expected call of ReadGapCheckpoint.
+func (mr *MockCheckpointsMockRecorder) ReadGapCheckpoint(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadGapCheckpoint", reflect.TypeOf((*MockCheckpoints)(nil).ReadGapCheckpoint), arg0, arg1)
+}
+
+// WriteGapCheckpoint mocks base method.
+func (m *MockCheckpoints) WriteGapCheckpoint(arg0 context.Context, arg1 pgdb.WriteGapCheckpointRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteGapCheckpoint", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteGapCheckpoint indicates an expected call of WriteGapCheckpoint.
+func (mr *MockCheckpointsMockRecorder) WriteGapCheckpoint(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteGapCheckpoint", reflect.TypeOf((*MockCheckpoints)(nil).WriteGapCheckpoint), arg0, arg1)
+}
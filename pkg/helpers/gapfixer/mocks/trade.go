@@ -0,0 +1,140 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: trade.go
+//
+// Generated by this command:
+//
+//	mockgen -source=trade.go -destination=mocks/trade.go
+//
+
+// Package mock_gapfixer is a generated GoMock package.
+package mock_gapfixer
+
+import (
+	context "context"
+	models "crypto_bot/pkg/exchange/models"
+	storage "crypto_bot/pkg/storage"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockTradeExchange is a mock of TradeExchange interface.
+type MockTradeExchange struct {
+	ctrl     *gomock.Controller
+	recorder *MockTradeExchangeMockRecorder
+	isgomock struct{}
+}
+
+// MockTradeExchangeMockRecorder is the mock recorder for MockTradeExchange.
+type MockTradeExchangeMockRecorder struct {
+	mock *MockTradeExchange
+}
+
+// NewMockTradeExchange creates a new mock instance.
+func NewMockTradeExchange(ctrl *gomock.Controller) *MockTradeExchange {
+	mock := &MockTradeExchange{ctrl: ctrl}
+	mock.recorder = &MockTradeExchangeMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTradeExchange) EXPECT() *MockTradeExchangeMockRecorder {
+	return m.recorder
+}
+
+// AggTrades mocks base method.
+func (m *MockTradeExchange) AggTrades(arg0 context.Context, arg1 models.AggTradesRequest) ([]*models.AggTrade, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AggTrades", arg0, arg1)
+	ret0, _ := ret[0].([]*models.AggTrade)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AggTrades indicates an expected call of AggTrades.
+func (mr *MockTradeExchangeMockRecorder) AggTrades(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AggTrades", reflect.TypeOf((*MockTradeExchange)(nil).AggTrades), arg0, arg1)
+}
+
+// Trades mocks base method.
+func (m *MockTradeExchange) Trades(arg0 context.Context, arg1 models.TradesRequest) ([]*models.Trade, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Trades", arg0, arg1)
+	ret0, _ := ret[0].([]*models.Trade)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Trades indicates an expected call of Trades.
+func (mr *MockTradeExchangeMockRecorder) Trades(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Trades", reflect.TypeOf((*MockTradeExchange)(nil).Trades), arg0, arg1)
+}
+
+// MockTradeStorage is a mock of TradeStorage interface.
+type MockTradeStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockTradeStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockTradeStorageMockRecorder is the mock recorder for MockTradeStorage.
+type MockTradeStorageMockRecorder struct {
+	mock *MockTradeStorage
+}
+
+// NewMockTradeStorage creates a new mock instance.
+func NewMockTradeStorage(ctrl *gomock.Controller) *MockTradeStorage {
+	mock := &MockTradeStorage{ctrl: ctrl}
+	mock.recorder = &MockTradeStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTradeStorage) EXPECT() *MockTradeStorageMockRecorder {
+	return m.recorder
+}
+
+// ReadTradeGaps mocks base method.
+func (m *MockTradeStorage) ReadTradeGaps(arg0 context.Context, arg1 storage.ReadTradeGapsRequest) ([]*storage.TradeGap, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadTradeGaps", arg0, arg1)
+	ret0, _ := ret[0].([]*storage.TradeGap)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadTradeGaps indicates an expected call of ReadTradeGaps.
+func (mr *MockTradeStorageMockRecorder) ReadTradeGaps(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadTradeGaps", reflect.TypeOf((*MockTradeStorage)(nil).ReadTradeGaps), arg0, arg1)
+}
+
+// WriteAggTrades mocks base method.
+func (m *MockTradeStorage) WriteAggTrades(arg0 context.Context, arg1 storage.WriteAggTradesRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteAggTrades", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteAggTrades indicates an expected call of WriteAggTrades.
+func (mr *MockTradeStorageMockRecorder) WriteAggTrades(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteAggTrades", reflect.TypeOf((*MockTradeStorage)(nil).WriteAggTrades), arg0, arg1)
+}
+
+// WriteTrades mocks base method.
+func (m *MockTradeStorage) WriteTrades(arg0 context.Context, arg1 storage.WriteTradesRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteTrades", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteTrades indicates an expected call of WriteTrades.
+func (mr *MockTradeStorageMockRecorder) WriteTrades(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteTrades", reflect.TypeOf((*MockTradeStorage)(nil).WriteTrades), arg0, arg1)
+}
@@ -0,0 +1,41 @@
+package gapfixer
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Progress counts the work done by gap fix jobs. It is safe for concurrent use.
+type Progress struct {
+	start time.Time
+
+	pairs, pairsDone atomic.Int64
+	// span is the kline time in ms to check over all pairs, checked is the part done.
+	span, checked atomic.Int64
+	gaps, filled  atomic.Int64
+}
+
+func NewProgress() *Progress {
+	return &Progress{start: time.Now()}
+}
+
+// ETA extrapolates the time spent so far over the kline time left to check,
+// it is zero until something is checked.
+func (p *Progress) ETA() time.Duration {
+	checked, span := p.checked.Load(), p.span.Load()
+	if checked == 0 || checked >= span {
+		return 0
+	}
+	elapsed := time.Since(p.start)
+	return time.Duration(float64(elapsed) * float64(span-checked) / float64(checked)).Round(time.Second)
+}
+
+func (p *Progress) String() string {
+	var percent float64
+	if span := p.span.Load(); span > 0 {
+		percent = 100 * float64(p.checked.Load()) / float64(span)
+	}
+	return fmt.Sprintf("pairs %d/%d, checked %.1f%%, gaps found %d, klines filled %d, eta %s",
+		p.pairsDone.Load(), p.pairs.Load(), percent, p.gaps.Load(), p.filled.Load(), p.ETA())
+}
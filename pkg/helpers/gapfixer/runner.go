@@ -0,0 +1,236 @@
+package gapfixer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage/pgdb"
+)
+
+//go:generate mockgen -source=runner.go -destination=mocks/runner.go
+type Checkpoints interface {
+	ReadGapCheckpoint(context.Context, pgdb.ReadGapCheckpointRequest) (*pgdb.GapCheckpoint, error)
+	WriteGapCheckpoint(context.Context, pgdb.WriteGapCheckpointRequest) error
+}
+
+// Runner fixes gaps of many pairs concurrently. It checkpoints every pair as
+// it goes, so a rerun over the same range resumes where the last one stopped.
+type Runner struct {
+	ex    Exchange
+	s     Storage
+	cp    Checkpoints
+	empty EmptyRanges
+
+	limiter          Limiter
+	concurrency      int
+	chunkSize        int
+	resume           bool
+	progressOut      io.Writer
+	progressInterval time.Duration
+	now              func() time.Time
+}
+
+// NewRunner returns a runner checkpointing pairs to cp, which may be nil to
+// neither checkpoint nor resume them.
+func NewRunner(ex Exchange, s Storage, cp Checkpoints) *Runner {
+	return &Runner{
+		ex:               ex,
+		s:                s,
+		cp:               cp,
+		limiter:          noLimit{},
+		concurrency:      1,
+		chunkSize:        1000,
+		resume:           true,
+		progressOut:      io.Discard,
+		progressInterval: 10 * time.Second,
+		now:              time.Now,
+	}
+}
+
+func (r *Runner) SetConcurrency(concurrency int) *Runner {
+	r.concurrency = max(concurrency, 1)
+	return r
+}
+
+func (r *Runner) SetChunkSize(chunkSize int) *Runner {
+	r.chunkSize = chunkSize
+	return r
+}
+
+// SetLimiter sets the limiter all requests to the exchange wait for.
+func (r *Runner) SetLimiter(limiter Limiter) *Runner {
+	r.limiter = limiter
+	return r
+}
+
+// SetEmptyRanges sets where ranges the exchange has no data for are recorded,
+// so they are not retried. They are not tracked by default.
+func (r *Runner) SetEmptyRanges(empty EmptyRanges) *Runner {
+	r.empty = empty
+	return r
+}
+
+// SetResume sets whether pairs resume from their checkpoints, true by default.
+func (r *Runner) SetResume(resume bool) *Runner {
+	r.resume = resume
+	return r
+}
+
+// SetProgress sets where and how often progress is printed, it is discarded by default.
+func (r *Runner) SetProgress(out io.Writer, interval time.Duration) *Runner {
+	r.progressOut = out
+	r.progressInterval = interval
+	return r
+}
+
+type RunRequest struct {
+	Pairs    []models.WsKlineRequest
+	From, To time.Time
+}
+
+// job is the part of a pair left to check.
+type job struct {
+	pair models.WsKlineRequest
+	// from is where the checked range of the pair begins, start is where it
+	// goes on from, they differ when resumed from a checkpoint.
+	from, start, to int64
+}
+
+// Run fixes gaps of every pair from req.From to req.To. A pair that fails does
+// not stop the others, the errors are joined.
+func (r *Runner) Run(ctx context.Context, req RunRequest) error {
+	for _, p := range req.Pairs {
+		if _, err := models.ParseInterval(p.Interval); err != nil {
+			return fmt.Errorf("pair %s: %w", p.Symbol, err)
+		}
+	}
+
+	progress := NewProgress()
+	jobs := make([]job, 0, len(req.Pairs))
+	for _, p := range req.Pairs {
+		j, err := r.job(ctx, p, req.From.UnixMilli(), req.To.UnixMilli())
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", p.Symbol, p.Interval, err)
+		}
+		jobs = append(jobs, j)
+		progress.span.Add(max(j.to-j.start, 0))
+	}
+	progress.pairs.Store(int64(len(jobs)))
+
+	done := make(chan struct{})
+	reported := make(chan struct{})
+	go func() {
+		defer close(reported)
+		ticker := time.NewTicker(r.progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				fmt.Fprintln(r.progressOut, progress)
+				return
+			case <-ticker.C:
+				fmt.Fprintln(r.progressOut, progress)
+			}
+		}
+	}()
+
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+	queue := make(chan job)
+	for i := 0; i < r.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				if err := r.run(ctx, j, progress); err != nil {
+					mu.Lock()
+					errs = append(errs, fmt.Errorf("%s %s: %w", j.pair.Symbol, j.pair.Interval, err))
+					mu.Unlock()
+				}
+				progress.pairsDone.Add(1)
+			}
+		}()
+	}
+feed:
+	for _, j := range jobs {
+		select {
+		case <-ctx.Done():
+			break feed
+		case queue <- j:
+		}
+	}
+	close(queue)
+	wg.Wait()
+	close(done)
+	<-reported
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Runner) job(ctx context.Context, p models.WsKlineRequest, from, to int64) (job, error) {
+	j := job{pair: p, from: from, start: from, to: to}
+	if !r.resume || r.cp == nil {
+		return j, nil
+	}
+	cp, err := r.cp.ReadGapCheckpoint(ctx, pgdb.ReadGapCheckpointRequest{Symbol: p.Symbol, Interval: p.Interval})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return j, nil
+	}
+	if err != nil {
+		return j, fmt.Errorf("read checkpoint: %w", err)
+	}
+	// The checkpoint only helps if its checked range covers from.
+	if cp.From <= from && cp.CheckedTo >= from {
+		j.from, j.start = cp.From, cp.CheckedTo+1
+	}
+	return j, nil
+}
+
+func (r *Runner) run(ctx context.Context, j job, progress *Progress) error {
+	if j.start >= j.to {
+		return nil
+	}
+	last := j.start
+	// The last kline fetched is usually still open, checkpoints stop before
+	// it so a resumed run fetches it again once it is closed.
+	closed := models.Interval(j.pair.Interval).Truncate(r.now().UnixMilli()) - 1
+	f := &fixer{
+		ex:        r.ex,
+		s:         r.s,
+		limiter:   r.limiter,
+		progress:  progress,
+		empty:     r.empty,
+		now:       r.now,
+		symbol:    j.pair.Symbol,
+		interval:  j.pair.Interval,
+		chunkSize: r.chunkSize,
+		checked: func(ctx context.Context, checkedTo int64) error {
+			progress.checked.Add(checkedTo - last)
+			last = checkedTo
+			checkedTo = min(checkedTo, closed)
+			if r.cp == nil || checkedTo < j.from {
+				return nil
+			}
+			return r.cp.WriteGapCheckpoint(ctx, pgdb.WriteGapCheckpointRequest{
+				Symbol:    j.pair.Symbol,
+				Interval:  j.pair.Interval,
+				From:      j.from,
+				CheckedTo: checkedTo,
+			})
+		},
+	}
+	return f.fix(ctx, j.start, j.to)
+}
@@ -0,0 +1,168 @@
+package gapfixer
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"crypto_bot/pkg/exchange/models"
+	mockgapfixer "crypto_bot/pkg/helpers/gapfixer/mocks"
+	"crypto_bot/pkg/storage"
+	"crypto_bot/pkg/storage/pgdb"
+)
+
+func TestRunner_Run(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mockgapfixer.NewMockStorage(ctrl)
+	ex := mockgapfixer.NewMockExchange(ctrl)
+	cp := mockgapfixer.NewMockCheckpoints(ctrl)
+
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(5 * time.Minute)
+	minute := func(i int) *models.Kline {
+		openTime := from.Add(time.Duration(i) * time.Minute).UnixMilli()
+		return &models.Kline{OpenTime: openTime, CloseTime: openTime + 59_999}
+	}
+	btc := models.WsKlineRequest{Symbol: "BTCUSDT", Interval: "1m"}
+	eth := models.WsKlineRequest{Symbol: "ETHUSDT", Interval: "1m"}
+	bnb := models.WsKlineRequest{Symbol: "BNBUSDT", Interval: "1m"}
+
+	// BTCUSDT has no checkpoint and no gaps.
+	cp.EXPECT().ReadGapCheckpoint(gomock.Any(), pgdb.ReadGapCheckpointRequest{Symbol: btc.Symbol, Interval: btc.Interval}).
+		Return(nil, pgx.ErrNoRows)
+	s.EXPECT().ReadKlines(gomock.Any(), storage.ReadKlinesRequest{Symbol: btc.Symbol, Interval: btc.Interval,
+		OpenTime: from.UnixMilli(), CloseTime: to.UnixMilli(), Limit: 10}).
+		Return([]*models.Kline{minute(0), minute(1), minute(2), minute(3), minute(4)}, nil)
+	cp.EXPECT().WriteGapCheckpoint(gomock.Any(), pgdb.WriteGapCheckpointRequest{Symbol: btc.Symbol, Interval: btc.Interval,
+		From: from.UnixMilli(), CheckedTo: to.UnixMilli()})
+
+	// ETHUSDT resumes after the first 3 minutes and fills the rest.
+	cp.EXPECT().ReadGapCheckpoint(gomock.Any(), pgdb.ReadGapCheckpointRequest{Symbol: eth.Symbol, Interval: eth.Interval}).
+		Return(&pgdb.GapCheckpoint{From: from.Add(-time.Hour).UnixMilli(), CheckedTo: minute(2).CloseTime}, nil)
+	s.EXPECT().ReadKlines(gomock.Any(), storage.ReadKlinesRequest{Symbol: eth.Symbol, Interval: eth.Interval,
+		OpenTime: minute(3).OpenTime, CloseTime: to.UnixMilli(), Limit: 10})
+	ex.EXPECT().Klines(gomock.Any(), models.KlinesRequest{Symbol: eth.Symbol, Interval: eth.Interval,
+		StartTime: minute(3).OpenTime, EndTime: to.UnixMilli(), Limit: 10}).
+		Return([]*models.Kline{
+			{OpenTime: minute(3).OpenTime, CloseTime: minute(3).CloseTime},
+			{OpenTime: minute(4).OpenTime, CloseTime: minute(4).CloseTime},
+		}, nil)
+	s.EXPECT().WriteKlines(gomock.Any(), storage.WriteKlinesRequest{Symbol: eth.Symbol, Interval: eth.Interval,
+		Klines: []*models.Kline{minute(3), minute(4)}})
+	cp.EXPECT().WriteGapCheckpoint(gomock.Any(), pgdb.WriteGapCheckpointRequest{Symbol: eth.Symbol, Interval: eth.Interval,
+		From: from.Add(-time.Hour).UnixMilli(), CheckedTo: to.UnixMilli()})
+
+	// BNBUSDT is checked already.
+	cp.EXPECT().ReadGapCheckpoint(gomock.Any(), pgdb.ReadGapCheckpointRequest{Symbol: bnb.Symbol, Interval: bnb.Interval}).
+		Return(&pgdb.GapCheckpoint{From: from.UnixMilli(), CheckedTo: to.UnixMilli()}, nil)
+
+	var out bytes.Buffer
+	err := NewRunner(ex, s, cp).
+		SetConcurrency(2).
+		SetChunkSize(10).
+		SetLimiter(NewWeightLimiter(100)).
+		SetProgress(&out, time.Hour).
+		Run(context.Background(), RunRequest{Pairs: []models.WsKlineRequest{btc, eth, bnb}, From: from, To: to})
+	require.NoError(t, err)
+	require.Equal(t, "pairs 3/3, checked 100.0%, gaps found 1, klines filled 2, eta 0s\n", out.String())
+}
+
+func TestRunner_NoResume(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mockgapfixer.NewMockStorage(ctrl)
+	cp := mockgapfixer.NewMockCheckpoints(ctrl)
+
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(time.Minute)
+	s.EXPECT().ReadKlines(gomock.Any(), storage.ReadKlinesRequest{Symbol: "BTCUSDT", Interval: "1m",
+		OpenTime: from.UnixMilli(), CloseTime: to.UnixMilli(), Limit: 10}).
+		Return([]*models.Kline{{OpenTime: from.UnixMilli(), CloseTime: to.UnixMilli() - 1}}, nil)
+	cp.EXPECT().WriteGapCheckpoint(gomock.Any(), pgdb.WriteGapCheckpointRequest{Symbol: "BTCUSDT", Interval: "1m",
+		From: from.UnixMilli(), CheckedTo: to.UnixMilli()})
+
+	err := NewRunner(mockgapfixer.NewMockExchange(ctrl), s, cp).
+		SetChunkSize(10).
+		SetResume(false).
+		Run(context.Background(), RunRequest{
+			Pairs: []models.WsKlineRequest{{Symbol: "BTCUSDT", Interval: "1m"}},
+			From:  from,
+			To:    to,
+		})
+	require.NoError(t, err)
+}
+
+func TestRunner_OpenKline(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mockgapfixer.NewMockStorage(ctrl)
+	ex := mockgapfixer.NewMockExchange(ctrl)
+	cp := mockgapfixer.NewMockCheckpoints(ctrl)
+
+	// The run is 30 seconds into the third minute, which is still open.
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := from.Add(2*time.Minute + 30*time.Second)
+	klines := []*models.Kline{
+		{OpenTime: from.UnixMilli(), CloseTime: from.Add(time.Minute).UnixMilli() - 1},
+		{OpenTime: from.Add(time.Minute).UnixMilli(), CloseTime: from.Add(2*time.Minute).UnixMilli() - 1},
+		{OpenTime: from.Add(2 * time.Minute).UnixMilli(), CloseTime: from.Add(3*time.Minute).UnixMilli() - 1},
+	}
+	s.EXPECT().ReadKlines(gomock.Any(), storage.ReadKlinesRequest{Symbol: "BTCUSDT", Interval: "1m",
+		OpenTime: from.UnixMilli(), CloseTime: now.UnixMilli(), Limit: 10})
+	ex.EXPECT().Klines(gomock.Any(), models.KlinesRequest{Symbol: "BTCUSDT", Interval: "1m",
+		StartTime: from.UnixMilli(), EndTime: now.UnixMilli(), Limit: 10}).
+		Return(klines, nil)
+	// The open kline is neither stored nor checkpointed.
+	s.EXPECT().WriteKlines(gomock.Any(), storage.WriteKlinesRequest{Symbol: "BTCUSDT", Interval: "1m", Klines: klines[:2]})
+	cp.EXPECT().WriteGapCheckpoint(gomock.Any(), pgdb.WriteGapCheckpointRequest{Symbol: "BTCUSDT", Interval: "1m",
+		From: from.UnixMilli(), CheckedTo: klines[1].CloseTime})
+
+	r := NewRunner(ex, s, cp).SetChunkSize(10).SetResume(false)
+	r.now = func() time.Time { return now }
+	err := r.Run(context.Background(), RunRequest{
+		Pairs: []models.WsKlineRequest{{Symbol: "BTCUSDT", Interval: "1m"}},
+		From:  from,
+		To:    now,
+	})
+	require.NoError(t, err)
+}
+
+func TestRunner_NoCheckpoints(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mockgapfixer.NewMockStorage(ctrl)
+
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(time.Minute)
+	s.EXPECT().ReadKlines(gomock.Any(), storage.ReadKlinesRequest{Symbol: "BTCUSDT", Interval: "1m",
+		OpenTime: from.UnixMilli(), CloseTime: to.UnixMilli(), Limit: 10}).
+		Return([]*models.Kline{{OpenTime: from.UnixMilli(), CloseTime: to.UnixMilli() - 1}}, nil)
+
+	err := NewRunner(mockgapfixer.NewMockExchange(ctrl), s, nil).
+		SetChunkSize(10).
+		Run(context.Background(), RunRequest{
+			Pairs: []models.WsKlineRequest{{Symbol: "BTCUSDT", Interval: "1m"}},
+			From:  from,
+			To:    to,
+		})
+	require.NoError(t, err)
+}
+
+func TestWeightLimiter(t *testing.T) {
+	l := NewWeightLimiter(60_000)
+	ctx := context.Background()
+
+	start := time.Now()
+	require.NoError(t, l.Wait(ctx, 60_000))
+	require.Less(t, time.Since(start), 50*time.Millisecond)
+
+	// The budget refills at 1000 a second.
+	require.NoError(t, l.Wait(ctx, 100))
+	require.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	require.ErrorIs(t, l.Wait(ctx, 60_000), context.Canceled)
+}
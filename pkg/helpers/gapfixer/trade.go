@@ -0,0 +1,144 @@
+package gapfixer
+
+import (
+	"context"
+	"fmt"
+
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage"
+)
+
+//go:generate mockgen -source=trade.go -destination=mocks/trade.go
+type TradeExchange interface {
+	Trades(context.Context, models.TradesRequest) ([]*models.Trade, error)
+	AggTrades(context.Context, models.AggTradesRequest) ([]*models.AggTrade, error)
+}
+
+type TradeStorage interface {
+	ReadTradeGaps(context.Context, storage.ReadTradeGapsRequest) ([]*storage.TradeGap, error)
+	WriteTrades(context.Context, storage.WriteTradesRequest) error
+	WriteAggTrades(context.Context, storage.WriteAggTradesRequest) error
+}
+
+// Request weights of the historical trades and aggregate trades endpoints.
+const (
+	tradesWeight    = 25
+	aggTradesWeight = 4
+)
+
+type FixTradeGapsRequest struct {
+	Symbol string
+	// FromID and ToID bound the checked trade ids, both inclusive.
+	FromID int64
+	ToID   int64
+	// Agg fixes aggregate trades instead of trades.
+	Agg       bool
+	ChunkSize int
+}
+
+// FixTradeGaps fetches the trades missing from stored ones by their ids, which
+// follow one another without gaps, and returns the number of trades written.
+// l limits exchange requests, it may be nil.
+func FixTradeGaps(ctx context.Context, ex TradeExchange, s TradeStorage, l Limiter, req FixTradeGapsRequest) (int, error) {
+	if l == nil {
+		l = noLimit{}
+	}
+	gaps, err := s.ReadTradeGaps(ctx, storage.ReadTradeGapsRequest{Symbol: req.Symbol, FromID: req.FromID, ToID: req.ToID, Agg: req.Agg})
+	if err != nil {
+		return 0, fmt.Errorf("read trade gaps: %w", err)
+	}
+	written := 0
+	for _, g := range gaps {
+		n, err := fixTradeGap(ctx, ex, s, l, req, g)
+		written += n
+		if err != nil {
+			return written, fmt.Errorf("fix trades %d-%d: %w", g.Start, g.End, err)
+		}
+	}
+	return written, nil
+}
+
+// tradeSource fetches and writes trades of type T, like trades or aggregate
+// trades.
+type tradeSource[T any] struct {
+	name   string
+	weight int
+	get    func(ctx context.Context, from int64) ([]T, error)
+	id     func(T) int64
+	write  func(ctx context.Context, trades []T) error
+}
+
+func newTradeSource(ex TradeExchange, s TradeStorage, req FixTradeGapsRequest) tradeSource[*models.Trade] {
+	return tradeSource[*models.Trade]{
+		name:   "trades",
+		weight: tradesWeight,
+		get: func(ctx context.Context, from int64) ([]*models.Trade, error) {
+			return ex.Trades(ctx, models.TradesRequest{Symbol: req.Symbol, FromID: from, Limit: req.ChunkSize})
+		},
+		id: func(t *models.Trade) int64 { return t.ID },
+		write: func(ctx context.Context, trades []*models.Trade) error {
+			return s.WriteTrades(ctx, storage.WriteTradesRequest{Symbol: req.Symbol, Trades: trades})
+		},
+	}
+}
+
+func newAggTradeSource(ex TradeExchange, s TradeStorage, req FixTradeGapsRequest) tradeSource[*models.AggTrade] {
+	return tradeSource[*models.AggTrade]{
+		name:   "aggregate trades",
+		weight: aggTradesWeight,
+		get: func(ctx context.Context, from int64) ([]*models.AggTrade, error) {
+			return ex.AggTrades(ctx, models.AggTradesRequest{Symbol: req.Symbol, FromID: from, Limit: req.ChunkSize})
+		},
+		id: func(t *models.AggTrade) int64 { return t.ID },
+		write: func(ctx context.Context, trades []*models.AggTrade) error {
+			return s.WriteAggTrades(ctx, storage.WriteAggTradesRequest{Symbol: req.Symbol, AggTrades: trades})
+		},
+	}
+}
+
+func fixTradeGap(ctx context.Context, ex TradeExchange, s TradeStorage, l Limiter, req FixTradeGapsRequest, g *storage.TradeGap) (int, error) {
+	if req.Agg {
+		return fixGap(ctx, newAggTradeSource(ex, s, req), l, g)
+	}
+	return fixGap(ctx, newTradeSource(ex, s, req), l, g)
+}
+
+func fixGap[T any](ctx context.Context, src tradeSource[T], l Limiter, g *storage.TradeGap) (int, error) {
+	written := 0
+	for from := g.Start; from <= g.End; {
+		n, last, err := fixChunk(ctx, src, l, from, g.End)
+		written += n
+		if err != nil || last < from {
+			// The exchange has no trades from there on.
+			return written, err
+		}
+		from = last + 1
+	}
+	return written, nil
+}
+
+// fixChunk writes trades from id from up to to, returning their number and
+// the last id the exchange returned, which is below from if there were none.
+func fixChunk[T any](ctx context.Context, src tradeSource[T], l Limiter, from, to int64) (int, int64, error) {
+	if err := l.Wait(ctx, src.weight); err != nil {
+		return 0, 0, err
+	}
+	trades, err := src.get(ctx, from)
+	if err != nil {
+		return 0, 0, fmt.Errorf("get %s: %w", src.name, err)
+	}
+	if len(trades) == 0 {
+		return 0, from - 1, nil
+	}
+	last := src.id(trades[len(trades)-1])
+	for i, t := range trades {
+		if src.id(t) > to {
+			trades = trades[:i]
+			break
+		}
+	}
+	if err = src.write(ctx, trades); err != nil {
+		return 0, 0, fmt.Errorf("write %s: %w", src.name, err)
+	}
+	return len(trades), last, nil
+}
@@ -0,0 +1,63 @@
+package gapfixer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"crypto_bot/pkg/exchange/models"
+	mockgapfixer "crypto_bot/pkg/helpers/gapfixer/mocks"
+	"crypto_bot/pkg/storage"
+)
+
+func tradesOf(ids ...int64) []*models.Trade {
+	res := make([]*models.Trade, len(ids))
+	for i, id := range ids {
+		res[i] = &models.Trade{ID: id}
+	}
+	return res
+}
+
+func TestFixTradeGaps(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	ex := mockgapfixer.NewMockTradeExchange(ctrl)
+	s := mockgapfixer.NewMockTradeStorage(ctrl)
+	ctx := context.Background()
+
+	s.EXPECT().ReadTradeGaps(ctx, storage.ReadTradeGapsRequest{Symbol: "BTCUSDT", FromID: 1, ToID: 20}).
+		Return([]*storage.TradeGap{{Start: 2, End: 4}, {Start: 10, End: 20}}, nil)
+	gomock.InOrder(
+		// Trades past the gap are not written.
+		ex.EXPECT().Trades(ctx, models.TradesRequest{Symbol: "BTCUSDT", FromID: 2, Limit: 3}).Return(tradesOf(2, 3, 4), nil),
+		s.EXPECT().WriteTrades(ctx, storage.WriteTradesRequest{Symbol: "BTCUSDT", Trades: tradesOf(2, 3, 4)}),
+		ex.EXPECT().Trades(ctx, models.TradesRequest{Symbol: "BTCUSDT", FromID: 10, Limit: 3}).Return(tradesOf(10, 11, 12), nil),
+		s.EXPECT().WriteTrades(ctx, storage.WriteTradesRequest{Symbol: "BTCUSDT", Trades: tradesOf(10, 11, 12)}),
+		// The exchange has no trades after 14 yet.
+		ex.EXPECT().Trades(ctx, models.TradesRequest{Symbol: "BTCUSDT", FromID: 13, Limit: 3}).Return(tradesOf(13, 14), nil),
+		s.EXPECT().WriteTrades(ctx, storage.WriteTradesRequest{Symbol: "BTCUSDT", Trades: tradesOf(13, 14)}),
+		ex.EXPECT().Trades(ctx, models.TradesRequest{Symbol: "BTCUSDT", FromID: 15, Limit: 3}).Return(nil, nil),
+	)
+
+	n, err := FixTradeGaps(ctx, ex, s, nil, FixTradeGapsRequest{Symbol: "BTCUSDT", FromID: 1, ToID: 20, ChunkSize: 3})
+	require.NoError(t, err)
+	require.Equal(t, 8, n)
+}
+
+func TestFixTradeGaps_Agg(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	ex := mockgapfixer.NewMockTradeExchange(ctrl)
+	s := mockgapfixer.NewMockTradeStorage(ctrl)
+	ctx := context.Background()
+
+	aggTrades := []*models.AggTrade{{ID: 5}, {ID: 6}, {ID: 7}}
+	s.EXPECT().ReadTradeGaps(ctx, storage.ReadTradeGapsRequest{Symbol: "BTCUSDT", FromID: 5, ToID: 9, Agg: true}).
+		Return([]*storage.TradeGap{{Start: 5, End: 6}}, nil)
+	ex.EXPECT().AggTrades(ctx, models.AggTradesRequest{Symbol: "BTCUSDT", FromID: 5, Limit: 10}).Return(aggTrades, nil)
+	s.EXPECT().WriteAggTrades(ctx, storage.WriteAggTradesRequest{Symbol: "BTCUSDT", AggTrades: aggTrades[:2]})
+
+	n, err := FixTradeGaps(ctx, ex, s, nil, FixTradeGapsRequest{Symbol: "BTCUSDT", FromID: 5, ToID: 9, Agg: true, ChunkSize: 10})
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+}
@@ -0,0 +1,140 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: resampler.go
+//
+// Generated by this command:
+//
+//	mockgen -source=resampler.go -destination=mocks/resampler.go
+//
+
+// Package mock_resampler is a generated GoMock package.
+package mock_resampler
+
+import (
+	context "context"
+	models "crypto_bot/pkg/exchange/models"
+	storage "crypto_bot/pkg/storage"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockStorage is a mock of Storage interface.
+type MockStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockStorageMockRecorder is the mock recorder for MockStorage.
+type MockStorageMockRecorder struct {
+	mock *MockStorage
+}
+
+// NewMockStorage creates a new mock instance.
+func NewMockStorage(ctrl *gomock.Controller) *MockStorage {
+	mock := &MockStorage{ctrl: ctrl}
+	mock.recorder = &MockStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
+	return m.recorder
+}
+
+// ReadKlines mocks base method.
+func (m *MockStorage) ReadKlines(arg0 context.Context, arg1 storage.ReadKlinesRequest) ([]*models.Kline, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadKlines", arg0, arg1)
+	ret0, _ := ret[0].([]*models.Kline)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadKlines indicates an expected call of ReadKlines.
+func (mr *MockStorageMockRecorder) ReadKlines(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadKlines", reflect.TypeOf((*MockStorage)(nil).ReadKlines), arg0, arg1)
+}
+
+// WriteKlines mocks base method.
+func (m *MockStorage) WriteKlines(arg0 context.Context, arg1 storage.WriteKlinesRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteKlines", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteKlines indicates an expected call of WriteKlines.
+func (mr *MockStorageMockRecorder) WriteKlines(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteKlines", reflect.TypeOf((*MockStorage)(nil).WriteKlines), arg0, arg1)
+}
+
+// MockWatcherStorage is a mock of WatcherStorage interface.
+type MockWatcherStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockWatcherStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockWatcherStorageMockRecorder is the mock recorder for MockWatcherStorage.
+type MockWatcherStorageMockRecorder struct {
+	mock *MockWatcherStorage
+}
+
+// NewMockWatcherStorage creates a new mock instance.
+func NewMockWatcherStorage(ctrl *gomock.Controller) *MockWatcherStorage {
+	mock := &MockWatcherStorage{ctrl: ctrl}
+	mock.recorder = &MockWatcherStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWatcherStorage) EXPECT() *MockWatcherStorageMockRecorder {
+	return m.recorder
+}
+
+// ReadKlines mocks base method.
+func (m *MockWatcherStorage) ReadKlines(arg0 context.Context, arg1 storage.ReadKlinesRequest) ([]*models.Kline, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadKlines", arg0, arg1)
+	ret0, _ := ret[0].([]*models.Kline)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadKlines indicates an expected call of ReadKlines.
+func (mr *MockWatcherStorageMockRecorder) ReadKlines(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadKlines", reflect.TypeOf((*MockWatcherStorage)(nil).ReadKlines), arg0, arg1)
+}
+
+// ReadLastKline mocks base method.
+func (m *MockWatcherStorage) ReadLastKline(arg0 context.Context, arg1 storage.ReadLastKlineRequest) (*models.Kline, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadLastKline", arg0, arg1)
+	ret0, _ := ret[0].(*models.Kline)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadLastKline indicates an expected call of ReadLastKline.
+func (mr *MockWatcherStorageMockRecorder) ReadLastKline(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadLastKline", reflect.TypeOf((*MockWatcherStorage)(nil).ReadLastKline), arg0, arg1)
+}
+
+// WriteKlines mocks base method.
+func (m *MockWatcherStorage) WriteKlines(arg0 context.Context, arg1 storage.WriteKlinesRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteKlines", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteKlines indicates an expected call of WriteKlines.
+func (mr *MockWatcherStorageMockRecorder) WriteKlines(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteKlines", reflect.TypeOf((*MockWatcherStorage)(nil).WriteKlines), arg0, arg1)
+}
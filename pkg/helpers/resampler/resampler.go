@@ -0,0 +1,231 @@
+package resampler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage"
+)
+
+//go:generate mockgen -source=resampler.go -destination=mocks/resampler.go
+type Storage interface {
+	ReadKlines(context.Context, storage.ReadKlinesRequest) ([]*models.Kline, error)
+	WriteKlines(context.Context, storage.WriteKlinesRequest) error
+}
+
+// WatcherStorage is the storage of the kline watcher, which ResamplingStorage wraps.
+type WatcherStorage interface {
+	Storage
+	ReadLastKline(context.Context, storage.ReadLastKlineRequest) (*models.Kline, error)
+}
+
+// DefaultTargets are the intervals resampled from 1m klines by default.
+var DefaultTargets = []models.Interval{
+	models.Interval5m, models.Interval15m, models.Interval1h, models.Interval4h, models.Interval1d,
+}
+
+// Resampler builds klines of higher intervals from the stored klines of a base
+// interval and writes them to their own tables.
+//
+// A higher kline is written once all of its base klines are stored, or once a
+// later base kline is, so a hole the exchange never fills does not keep it
+// from being built. Klines are replaced when written, so resampling again
+// after a gap was fixed corrects them.
+type Resampler struct {
+	s         Storage
+	base      models.Interval
+	targets   []models.Interval
+	chunkSize int
+}
+
+// NewResampler returns a resampler of base klines into targets. Every target
+// must be a whole number of base klines. 3d klines are not supported either
+// way, as Binance does not start them at the unix epoch.
+func NewResampler(s Storage, base models.Interval, targets ...models.Interval) (*Resampler, error) {
+	if _, err := models.ParseInterval(string(base)); err != nil {
+		return nil, err
+	}
+	for _, target := range targets {
+		if _, err := models.ParseInterval(string(target)); err != nil {
+			return nil, err
+		}
+		if base == models.Interval3d || base == models.Interval1M || target == models.Interval3d ||
+			target.Duration() <= base.Duration() || target.Duration()%base.Duration() != 0 {
+			return nil, fmt.Errorf("can not resample %s klines into %s", base, target)
+		}
+	}
+	return &Resampler{s: s, base: base, targets: targets, chunkSize: 1000}, nil
+}
+
+// SetChunkSize sets how many klines are read and written at once.
+func (r *Resampler) SetChunkSize(chunkSize int) *Resampler {
+	r.chunkSize = chunkSize
+	return r
+}
+
+// Base returns the interval klines are resampled from.
+func (r *Resampler) Base() models.Interval {
+	return r.base
+}
+
+type ResampleRequest struct {
+	Symbol string
+	// From and To filter base klines by open and close time, From is moved
+	// back to the open time of the target klines it falls in. A zero To
+	// resamples up to the last base kline.
+	From, To time.Time
+}
+
+// Resample builds the target klines of req from the stored base klines and
+// returns how many were written.
+func (r *Resampler) Resample(ctx context.Context, req ResampleRequest) (int, error) {
+	from := make(map[models.Interval]int64, len(r.targets))
+	for _, target := range r.targets {
+		from[target] = target.Truncate(req.From.UnixMilli())
+	}
+	var to int64
+	if !req.To.IsZero() {
+		to = req.To.UnixMilli()
+	}
+	return r.resample(ctx, req.Symbol, from, to)
+}
+
+// Update resamples the target klines that the written base klines of symbol,
+// in open time order, belong to. A target kline the last of them leaves open
+// is skipped until a later update closes it.
+func (r *Resampler) Update(ctx context.Context, symbol string, written []*models.Kline) error {
+	if len(written) == 0 {
+		return nil
+	}
+	first, last := written[0], written[len(written)-1]
+	from := make(map[models.Interval]int64, len(r.targets))
+	for _, target := range r.targets {
+		start := target.Truncate(first.OpenTime)
+		// Skip the kline that is still open, it is built when it closes.
+		if start == target.Truncate(last.OpenTime) && last.CloseTime != target.Add(start, 1)-1 {
+			continue
+		}
+		from[target] = start
+	}
+	if len(from) == 0 {
+		return nil
+	}
+	_, err := r.resample(ctx, symbol, from, last.CloseTime)
+	return err
+}
+
+// resample streams the base klines of symbol from the earliest from to to and
+// writes the target klines from their from on.
+func (r *Resampler) resample(ctx context.Context, symbol string, from map[models.Interval]int64, to int64) (int, error) {
+	openTime := int64(-1)
+	for _, f := range from {
+		if openTime < 0 || f < openTime {
+			openTime = f
+		}
+	}
+
+	var (
+		n       int
+		buckets = make(map[models.Interval]*bucket, len(from))
+		out     = make(map[models.Interval][]*models.Kline, len(from))
+	)
+	flush := func(target models.Interval, atLeast int) error {
+		if len(out[target]) == 0 || len(out[target]) < atLeast {
+			return nil
+		}
+		err := r.s.WriteKlines(ctx, storage.WriteKlinesRequest{
+			Symbol:   symbol,
+			Interval: string(target),
+			Klines:   out[target],
+			Replace:  true,
+		})
+		if err != nil {
+			return fmt.Errorf("write %s klines: %w", target, err)
+		}
+		n += len(out[target])
+		out[target] = nil
+		return nil
+	}
+
+	for {
+		klines, err := r.s.ReadKlines(ctx, storage.ReadKlinesRequest{
+			Symbol:    symbol,
+			Interval:  string(r.base),
+			OpenTime:  openTime,
+			CloseTime: to,
+			Limit:     uint64(r.chunkSize),
+		})
+		if err != nil {
+			return n, fmt.Errorf("read %s klines: %w", r.base, err)
+		}
+		for _, k := range klines {
+			for target, f := range from {
+				if k.OpenTime < f {
+					continue
+				}
+				start := target.Truncate(k.OpenTime)
+				b := buckets[target]
+				if b != nil && b.kline.OpenTime != start {
+					// A later base kline closes the previous target one.
+					out[target] = append(out[target], b.kline)
+					b = nil
+				}
+				if b == nil {
+					b = newBucket(start, target.Add(start, 1)-1)
+					buckets[target] = b
+				}
+				b.add(k)
+			}
+		}
+		for target := range from {
+			if err = flush(target, r.chunkSize); err != nil {
+				return n, err
+			}
+		}
+		if len(klines) < r.chunkSize {
+			break
+		}
+		openTime = klines[len(klines)-1].OpenTime + 1
+	}
+
+	for target, b := range buckets {
+		if b.n == r.base.Count(b.kline.OpenTime, b.kline.CloseTime) {
+			out[target] = append(out[target], b.kline)
+		}
+		if err := flush(target, 0); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// bucket aggregates the base klines of a target kline.
+type bucket struct {
+	kline *models.Kline
+	n     int64
+}
+
+func newBucket(openTime, closeTime int64) *bucket {
+	return &bucket{kline: &models.Kline{OpenTime: openTime, CloseTime: closeTime}}
+}
+
+// add adds k, base klines must be added in open time order.
+func (b *bucket) add(k *models.Kline) {
+	agg := b.kline
+	if b.n == 0 {
+		agg.Open, agg.High, agg.Low = k.Open, k.High, k.Low
+		agg.FirstTradeID = k.FirstTradeID
+	}
+	b.n++
+	agg.High = max(agg.High, k.High)
+	agg.Low = min(agg.Low, k.Low)
+	agg.Close = k.Close
+	agg.Volume += k.Volume
+	agg.QuoteAssetVolume += k.QuoteAssetVolume
+	agg.TakerBuyBaseAssetVolume += k.TakerBuyBaseAssetVolume
+	agg.TakerBuyQuoteAssetVolume += k.TakerBuyQuoteAssetVolume
+	agg.TradeNum += k.TradeNum
+	agg.LastTradeID = k.LastTradeID
+}
@@ -0,0 +1,171 @@
+package resampler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"crypto_bot/pkg/exchange/models"
+	mockresampler "crypto_bot/pkg/helpers/resampler/mocks"
+	"crypto_bot/pkg/storage"
+)
+
+var from = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func minute(i int) int64 {
+	return from.Add(time.Duration(i) * time.Minute).UnixMilli()
+}
+
+// klines returns 1m klines opened at the given minutes.
+func klines(minutes ...int) []*models.Kline {
+	klines := make([]*models.Kline, 0, len(minutes))
+	for _, i := range minutes {
+		price := 100 + float64(i)
+		klines = append(klines, &models.Kline{
+			OpenTime: minute(i), Open: price, High: price + 2, Low: price - 1, Close: price + 1,
+			Volume: 1.5, CloseTime: minute(i+1) - 1, TradeNum: 3, QuoteAssetVolume: 150,
+			TakerBuyBaseAssetVolume: 0.5, TakerBuyQuoteAssetVolume: 50, FirstTradeID: int64(i * 3), LastTradeID: int64(i*3 + 2),
+		})
+	}
+	return klines
+}
+
+func TestBucket(t *testing.T) {
+	b := newBucket(minute(0), minute(5)-1)
+	for _, k := range klines(0, 1, 2, 3, 4) {
+		b.add(k)
+	}
+	require.EqualValues(t, 5, b.n)
+	require.Equal(t, &models.Kline{
+		OpenTime: minute(0), Open: 100, High: 106, Low: 99, Close: 105, Volume: 7.5, CloseTime: minute(5) - 1,
+		TradeNum: 15, QuoteAssetVolume: 750, TakerBuyBaseAssetVolume: 2.5, TakerBuyQuoteAssetVolume: 250,
+		FirstTradeID: 0, LastTradeID: 14,
+	}, b.kline)
+}
+
+func TestNewResampler(t *testing.T) {
+	testCases := []struct {
+		base    models.Interval
+		targets []models.Interval
+		wantErr bool
+	}{
+		{base: models.Interval1m, targets: DefaultTargets},
+		{base: models.Interval1m, targets: []models.Interval{models.Interval1w, models.Interval1M}},
+		{base: models.Interval1d, targets: []models.Interval{models.Interval1M}},
+		{base: models.Interval1m, targets: []models.Interval{models.Interval1m}, wantErr: true},
+		{base: models.Interval5m, targets: []models.Interval{models.Interval3m}, wantErr: true},
+		{base: models.Interval1m, targets: []models.Interval{models.Interval3d}, wantErr: true},
+		{base: models.Interval1w, targets: []models.Interval{models.Interval1M}, wantErr: true},
+		{base: "2m", targets: []models.Interval{models.Interval1h}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		_, err := NewResampler(nil, tc.base, tc.targets...)
+		if tc.wantErr {
+			require.Error(t, err, "%s %v", tc.base, tc.targets)
+		} else {
+			require.NoError(t, err, "%s %v", tc.base, tc.targets)
+		}
+	}
+}
+
+// aggregate returns the target kline of klines.
+func aggregate(openTime, closeTime int64, klines []*models.Kline) *models.Kline {
+	b := newBucket(openTime, closeTime)
+	for _, k := range klines {
+		b.add(k)
+	}
+	return b.kline
+}
+
+func TestResampler_Resample(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mockresampler.NewMockStorage(ctrl)
+
+	// Minute 7 is missing and minutes 10 and 11 are not followed by the rest
+	// of their 5m kline yet.
+	base := klines(0, 1, 2, 3, 4, 5, 6, 8, 9, 10, 11)
+	s.EXPECT().ReadKlines(gomock.Any(), storage.ReadKlinesRequest{Symbol: "BTCUSDT", Interval: "1m",
+		OpenTime: minute(0), Limit: 6}).Return(base[:6], nil)
+	s.EXPECT().ReadKlines(gomock.Any(), storage.ReadKlinesRequest{Symbol: "BTCUSDT", Interval: "1m",
+		OpenTime: minute(5) + 1, Limit: 6}).Return(base[6:], nil)
+	s.EXPECT().WriteKlines(gomock.Any(), storage.WriteKlinesRequest{Symbol: "BTCUSDT", Interval: "5m", Replace: true,
+		Klines: []*models.Kline{
+			aggregate(minute(0), minute(5)-1, base[:5]),
+			aggregate(minute(5), minute(10)-1, base[5:9]),
+		}})
+
+	r, err := NewResampler(s, models.Interval1m, models.Interval5m, models.Interval15m)
+	require.NoError(t, err)
+	n, err := r.SetChunkSize(6).Resample(context.Background(), ResampleRequest{Symbol: "BTCUSDT", From: from.Add(2 * time.Minute)})
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+}
+
+func TestResampler_Update(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mockresampler.NewMockStorage(ctrl)
+	r, err := NewResampler(s, models.Interval1m, models.Interval5m, models.Interval15m)
+	require.NoError(t, err)
+
+	// The 5m and 15m klines are still open.
+	require.NoError(t, r.Update(context.Background(), "BTCUSDT", klines(2, 3)))
+
+	// Minute 4 closes the 5m kline only.
+	base := klines(0, 1, 2, 3, 4)
+	s.EXPECT().ReadKlines(gomock.Any(), storage.ReadKlinesRequest{Symbol: "BTCUSDT", Interval: "1m",
+		OpenTime: minute(0), CloseTime: minute(5) - 1, Limit: 1000}).Return(base, nil)
+	s.EXPECT().WriteKlines(gomock.Any(), storage.WriteKlinesRequest{Symbol: "BTCUSDT", Interval: "5m", Replace: true,
+		Klines: []*models.Kline{aggregate(minute(0), minute(5)-1, base)}})
+	require.NoError(t, r.Update(context.Background(), "BTCUSDT", klines(3, 4)))
+
+	// Klines written across 5m klines build the closed ones.
+	base = klines(5, 6, 7, 8, 9, 10)
+	s.EXPECT().ReadKlines(gomock.Any(), storage.ReadKlinesRequest{Symbol: "BTCUSDT", Interval: "1m",
+		OpenTime: minute(5), CloseTime: minute(11) - 1, Limit: 1000}).Return(base, nil)
+	s.EXPECT().WriteKlines(gomock.Any(), storage.WriteKlinesRequest{Symbol: "BTCUSDT", Interval: "5m", Replace: true,
+		Klines: []*models.Kline{aggregate(minute(5), minute(10)-1, base[:5])}})
+	require.NoError(t, r.Update(context.Background(), "BTCUSDT", klines(8, 9, 10)))
+}
+
+func TestResamplingStorage_WriteKlines(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mockresampler.NewMockWatcherStorage(ctrl)
+	r, err := NewResampler(s, models.Interval1m, models.Interval5m)
+	require.NoError(t, err)
+	rs := NewResamplingStorage(s, r)
+
+	// Other intervals are only written.
+	req := storage.WriteKlinesRequest{Symbol: "BTCUSDT", Interval: "5m", Klines: klines(0)}
+	s.EXPECT().WriteKlines(gomock.Any(), req).Return(nil)
+	require.NoError(t, rs.WriteKlines(context.Background(), req))
+
+	base := klines(0, 1, 2, 3, 4)
+	req = storage.WriteKlinesRequest{Symbol: "BTCUSDT", Interval: "1m", Klines: base[3:]}
+	gomock.InOrder(
+		s.EXPECT().WriteKlines(gomock.Any(), req).Return(nil),
+		s.EXPECT().ReadKlines(gomock.Any(), gomock.Any()).Return(base, nil),
+		s.EXPECT().WriteKlines(gomock.Any(), storage.WriteKlinesRequest{Symbol: "BTCUSDT", Interval: "5m", Replace: true,
+			Klines: []*models.Kline{aggregate(minute(0), minute(5)-1, base)}}),
+	)
+	require.NoError(t, rs.WriteKlines(context.Background(), req))
+
+	// Resampling errors are handled apart, the base klines are written.
+	var handled []error
+	rs.SetErrorHandler(func(err error) { handled = append(handled, err) })
+	gomock.InOrder(
+		s.EXPECT().WriteKlines(gomock.Any(), req).Return(nil),
+		s.EXPECT().ReadKlines(gomock.Any(), gomock.Any()).Return(nil, errors.New("read failed")),
+	)
+	require.NoError(t, rs.WriteKlines(context.Background(), req))
+	require.Len(t, handled, 1)
+	require.ErrorContains(t, handled[0], "read failed")
+
+	// Base write errors are returned and nothing is resampled.
+	s.EXPECT().WriteKlines(gomock.Any(), req).Return(errors.New("write failed"))
+	require.EqualError(t, rs.WriteKlines(context.Background(), req), "write failed")
+	require.Len(t, handled, 1)
+}
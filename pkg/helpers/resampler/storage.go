@@ -0,0 +1,48 @@
+package resampler
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"crypto_bot/pkg/storage"
+)
+
+// ResamplingStorage passes klines through to the watcher storage and, after
+// base interval ones are written, updates the target klines they belong to.
+//
+// Resampling errors go to the error handler instead of being returned, as the
+// base klines are stored by then and must not be written again. Target klines
+// left behind are rebuilt by the resample command.
+type ResamplingStorage struct {
+	WatcherStorage
+	r          *Resampler
+	errHandler func(err error)
+}
+
+// NewResamplingStorage wraps s, r should resample from the same storage.
+func NewResamplingStorage(s WatcherStorage, r *Resampler) *ResamplingStorage {
+	return &ResamplingStorage{
+		WatcherStorage: s,
+		r:              r,
+		errHandler: func(err error) {
+			log.Println(err)
+		},
+	}
+}
+
+// SetErrorHandler sets the handler of resampling errors.
+func (s *ResamplingStorage) SetErrorHandler(handler func(error)) *ResamplingStorage {
+	s.errHandler = handler
+	return s
+}
+
+func (s *ResamplingStorage) WriteKlines(ctx context.Context, req storage.WriteKlinesRequest) error {
+	if err := s.WatcherStorage.WriteKlines(ctx, req); err != nil || req.Interval != string(s.r.base) {
+		return err
+	}
+	if err := s.r.Update(ctx, req.Symbol, req.Klines); err != nil {
+		s.errHandler(fmt.Errorf("resample %s: %w", req.Symbol, err))
+	}
+	return nil
+}
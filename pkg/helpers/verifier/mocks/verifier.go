@@ -0,0 +1,163 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: verifier.go
+//
+// Generated by this command:
+//
+//	mockgen -source=verifier.go -destination=mocks/verifier.go
+//
+
+// Package mock_verifier is a generated GoMock package.
+package mock_verifier
+
+import (
+	context "context"
+	models "crypto_bot/pkg/exchange/models"
+	storage "crypto_bot/pkg/storage"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockExchange is a mock of Exchange interface.
+type MockExchange struct {
+	ctrl     *gomock.Controller
+	recorder *MockExchangeMockRecorder
+	isgomock struct{}
+}
+
+// MockExchangeMockRecorder is the mock recorder for MockExchange.
+type MockExchangeMockRecorder struct {
+	mock *MockExchange
+}
+
+// NewMockExchange creates a new mock instance.
+func NewMockExchange(ctrl *gomock.Controller) *MockExchange {
+	mock := &MockExchange{ctrl: ctrl}
+	mock.recorder = &MockExchangeMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockExchange) EXPECT() *MockExchangeMockRecorder {
+	return m.recorder
+}
+
+// Klines mocks base method.
+func (m *MockExchange) Klines(arg0 context.Context, arg1 models.KlinesRequest) ([]*models.Kline, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Klines", arg0, arg1)
+	ret0, _ := ret[0].([]*models.Kline)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Klines indicates an expected call of Klines.
+func (mr *MockExchangeMockRecorder) Klines(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Klines", reflect.TypeOf((*MockExchange)(nil).Klines), arg0, arg1)
+}
+
+// MockStorage is a mock of Storage interface.
+type MockStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockStorageMockRecorder
+	isgomock struct{}
+}
+
+// MockStorageMockRecorder is the mock recorder for MockStorage.
+type MockStorageMockRecorder struct {
+	mock *MockStorage
+}
+
+// NewMockStorage creates a new mock instance.
+func NewMockStorage(ctrl *gomock.Controller) *MockStorage {
+	mock := &MockStorage{ctrl: ctrl}
+	mock.recorder = &MockStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
+	return m.recorder
+}
+
+// DeleteKlines mocks base method.
+func (m *MockStorage) DeleteKlines(arg0 context.Context, arg1 storage.DeleteKlinesRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteKlines", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteKlines indicates an expected call of DeleteKlines.
+func (mr *MockStorageMockRecorder) DeleteKlines(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteKlines", reflect.TypeOf((*MockStorage)(nil).DeleteKlines), arg0, arg1)
+}
+
+// ReadKlines mocks base method.
+func (m *MockStorage) ReadKlines(arg0 context.Context, arg1 storage.ReadKlinesRequest) ([]*models.Kline, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadKlines", arg0, arg1)
+	ret0, _ := ret[0].([]*models.Kline)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadKlines indicates an expected call of ReadKlines.
+func (mr *MockStorageMockRecorder) ReadKlines(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadKlines", reflect.TypeOf((*MockStorage)(nil).ReadKlines), arg0, arg1)
+}
+
+// WriteKlines mocks base method.
+func (m *MockStorage) WriteKlines(arg0 context.Context, arg1 storage.WriteKlinesRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteKlines", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteKlines indicates an expected call of WriteKlines.
+func (mr *MockStorageMockRecorder) WriteKlines(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteKlines", reflect.TypeOf((*MockStorage)(nil).WriteKlines), arg0, arg1)
+}
+
+// MockLimiter is a mock of Limiter interface.
+type MockLimiter struct {
+	ctrl     *gomock.Controller
+	recorder *MockLimiterMockRecorder
+	isgomock struct{}
+}
+
+// MockLimiterMockRecorder is the mock recorder for MockLimiter.
+type MockLimiterMockRecorder struct {
+	mock *MockLimiter
+}
+
+// NewMockLimiter creates a new mock instance.
+func NewMockLimiter(ctrl *gomock.Controller) *MockLimiter {
+	mock := &MockLimiter{ctrl: ctrl}
+	mock.recorder = &MockLimiterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLimiter) EXPECT() *MockLimiterMockRecorder {
+	return m.recorder
+}
+
+// Wait mocks base method.
+func (m *MockLimiter) Wait(ctx context.Context, weight int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Wait", ctx, weight)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Wait indicates an expected call of Wait.
+func (mr *MockLimiterMockRecorder) Wait(ctx, weight any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Wait", reflect.TypeOf((*MockLimiter)(nil).Wait), ctx, weight)
+}
@@ -0,0 +1,274 @@
+package verifier
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"crypto_bot/pkg/exchange"
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage"
+)
+
+//go:generate mockgen -source=verifier.go -destination=mocks/verifier.go
+type Exchange interface {
+	exchange.KlineService
+}
+
+type Storage interface {
+	ReadKlines(context.Context, storage.ReadKlinesRequest) ([]*models.Kline, error)
+	WriteKlines(context.Context, storage.WriteKlinesRequest) error
+	DeleteKlines(context.Context, storage.DeleteKlinesRequest) error
+}
+
+// Limiter blocks until a request of the given weight fits the budget.
+type Limiter interface {
+	Wait(ctx context.Context, weight int) error
+}
+
+// Problem is something wrong with a stored kline.
+type Problem string
+
+const (
+	HighBelowBody   Problem = "high below open or close"
+	LowAboveBody    Problem = "low above open or close"
+	NegativeVolume  Problem = "negative volume"
+	WrongCloseTime  Problem = "close time is not open time + interval - 1"
+	MisalignedOpen  Problem = "open time is not aligned to the interval"
+	DuplicateOpen   Problem = "open time is the same as the previous kline"
+	OverlapPrevious Problem = "opens before the previous kline closes"
+)
+
+// Issue is a stored kline with problems.
+type Issue struct {
+	Symbol   string
+	Interval string
+	Kline    *models.Kline
+	Problems []Problem
+	// Repaired is set when the kline was replaced with or deleted in favour of
+	// the exchange data.
+	Repaired bool
+}
+
+// klinesWeight is the request weight of the klines endpoint.
+const klinesWeight = 2
+
+// Verifier scans stored klines for rows that are wrong rather than missing
+// and, if an exchange is set with SetRepair, repairs them from it.
+type Verifier struct {
+	s         Storage
+	ex        Exchange
+	limiter   Limiter
+	chunkSize int
+}
+
+func NewVerifier(s Storage) *Verifier {
+	return &Verifier{s: s, limiter: noLimit{}, chunkSize: 1000}
+}
+
+// SetRepair makes Verify fetch the klines with issues from ex and replace them.
+func (v *Verifier) SetRepair(ex Exchange) *Verifier {
+	v.ex = ex
+	return v
+}
+
+// SetLimiter sets the limiter requests to the exchange wait for.
+func (v *Verifier) SetLimiter(limiter Limiter) *Verifier {
+	v.limiter = limiter
+	return v
+}
+
+// SetChunkSize sets how many klines are read from storage and requested from
+// the exchange at once.
+func (v *Verifier) SetChunkSize(chunkSize int) *Verifier {
+	v.chunkSize = chunkSize
+	return v
+}
+
+type VerifyRequest struct {
+	Pairs    []models.WsKlineRequest
+	From, To time.Time
+}
+
+// Verify returns the issues of the klines of every pair opened from req.From
+// to req.To, in open time order.
+func (v *Verifier) Verify(ctx context.Context, req VerifyRequest) ([]*Issue, error) {
+	var issues []*Issue
+	for _, p := range req.Pairs {
+		pairIssues, err := v.verifyPair(ctx, p, req.From.UnixMilli(), req.To.UnixMilli())
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", p.Symbol, p.Interval, err)
+		}
+		issues = append(issues, pairIssues...)
+	}
+	return issues, nil
+}
+
+func (v *Verifier) verifyPair(ctx context.Context, p models.WsKlineRequest, from, to int64) ([]*Issue, error) {
+	interval, err := models.ParseInterval(p.Interval)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		issues []*Issue
+		prev   *models.Kline
+	)
+	for openTime := from; openTime <= to; {
+		klines, err := v.s.ReadKlines(ctx, storage.ReadKlinesRequest{
+			Symbol:   p.Symbol,
+			Interval: p.Interval,
+			OpenTime: openTime,
+			Limit:    uint64(v.chunkSize),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("read klines: %w", err)
+		}
+		for _, k := range klines {
+			if k.OpenTime > to {
+				break
+			}
+			if problems := check(interval, prev, k); len(problems) > 0 {
+				issues = append(issues, &Issue{Symbol: p.Symbol, Interval: p.Interval, Kline: k, Problems: problems})
+			}
+			prev = k
+		}
+		if len(klines) < v.chunkSize {
+			break
+		}
+		// Close times may be wrong, so the next chunk goes on from the open time.
+		openTime = klines[len(klines)-1].OpenTime + 1
+	}
+
+	if v.ex != nil {
+		if err = v.repair(ctx, interval, issues); err != nil {
+			return nil, fmt.Errorf("repair: %w", err)
+		}
+	}
+	return issues, nil
+}
+
+// check returns the problems of k, prev is the kline stored before it or nil.
+func check(interval models.Interval, prev, k *models.Kline) []Problem {
+	var problems []Problem
+	if k.High < max(k.Open, k.Close) {
+		problems = append(problems, HighBelowBody)
+	}
+	if k.Low > min(k.Open, k.Close) {
+		problems = append(problems, LowAboveBody)
+	}
+	if k.Volume < 0 || k.QuoteAssetVolume < 0 || k.TakerBuyBaseAssetVolume < 0 || k.TakerBuyQuoteAssetVolume < 0 {
+		problems = append(problems, NegativeVolume)
+	}
+	if k.CloseTime != interval.Add(k.OpenTime, 1)-1 {
+		problems = append(problems, WrongCloseTime)
+	}
+	if !interval.Aligned(k.OpenTime) {
+		problems = append(problems, MisalignedOpen)
+	}
+	if prev != nil {
+		if k.OpenTime == prev.OpenTime {
+			problems = append(problems, DuplicateOpen)
+		} else if k.OpenTime <= prev.CloseTime {
+			problems = append(problems, OverlapPrevious)
+		}
+	}
+	return problems
+}
+
+// span is a range of open times, both inclusive, whose klines are refetched.
+type span struct {
+	start, end int64
+	issues     []*Issue
+}
+
+// spans groups issues of klines that follow each other to fetch them at once.
+func spans(interval models.Interval, issues []*Issue) []*span {
+	var res []*span
+	for _, issue := range issues {
+		start := issue.Kline.OpenTime
+		end := interval.Add(start, 1) - 1
+		if n := len(res); n > 0 && start <= res[n-1].end+1 {
+			res[n-1].end = max(res[n-1].end, end)
+			res[n-1].issues = append(res[n-1].issues, issue)
+			continue
+		}
+		res = append(res, &span{start: start, end: end, issues: []*Issue{issue}})
+	}
+	return res
+}
+
+// repair replaces the klines of issues with the ones of the exchange. Stored
+// klines the exchange has no kline with the same open time for are deleted,
+// unless the exchange has no data for their span at all.
+func (v *Verifier) repair(ctx context.Context, interval models.Interval, issues []*Issue) error {
+	if len(issues) == 0 {
+		return nil
+	}
+	symbol := issues[0].Symbol
+	for _, sp := range spans(interval, issues) {
+		fetched, err := v.fetch(ctx, symbol, interval, sp.start, sp.end)
+		if err != nil {
+			return err
+		}
+		if len(fetched) == 0 {
+			continue
+		}
+		err = v.s.WriteKlines(ctx, storage.WriteKlinesRequest{
+			Symbol:   symbol,
+			Interval: string(interval),
+			Klines:   fetched,
+			Replace:  true,
+		})
+		if err != nil {
+			return fmt.Errorf("write klines: %w", err)
+		}
+
+		openTimes := make(map[int64]bool, len(fetched))
+		for _, k := range fetched {
+			openTimes[k.OpenTime] = true
+		}
+		var stale []int64
+		for _, issue := range sp.issues {
+			if !openTimes[issue.Kline.OpenTime] {
+				stale = append(stale, issue.Kline.OpenTime)
+			}
+			issue.Repaired = true
+		}
+		err = v.s.DeleteKlines(ctx, storage.DeleteKlinesRequest{Symbol: symbol, Interval: string(interval), OpenTimes: stale})
+		if err != nil {
+			return fmt.Errorf("delete klines: %w", err)
+		}
+	}
+	return nil
+}
+
+// fetch returns the exchange klines opened from start to end.
+func (v *Verifier) fetch(ctx context.Context, symbol string, interval models.Interval, start, end int64) ([]*models.Kline, error) {
+	var res []*models.Kline
+	for start <= end {
+		if err := v.limiter.Wait(ctx, klinesWeight); err != nil {
+			return nil, err
+		}
+		klines, err := v.ex.Klines(ctx, models.KlinesRequest{
+			Symbol:    symbol,
+			Interval:  string(interval),
+			StartTime: start,
+			EndTime:   end,
+			Limit:     v.chunkSize,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("get klines: %w", err)
+		}
+		res = append(res, klines...)
+		if len(klines) < v.chunkSize {
+			break
+		}
+		start = klines[len(klines)-1].OpenTime + 1
+	}
+	return res, nil
+}
+
+type noLimit struct{}
+
+func (noLimit) Wait(context.Context, int) error { return nil }
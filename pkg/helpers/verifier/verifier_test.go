@@ -0,0 +1,205 @@
+package verifier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"crypto_bot/pkg/exchange/models"
+	mockverifier "crypto_bot/pkg/helpers/verifier/mocks"
+	"crypto_bot/pkg/storage"
+)
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// minute returns a valid 1m kline opened i minutes after start.
+func minute(i int) *models.Kline {
+	openTime := start.Add(time.Duration(i) * time.Minute).UnixMilli()
+	return &models.Kline{OpenTime: openTime, Open: 10, High: 12, Low: 9, Close: 11, Volume: 1, CloseTime: openTime + 59_999}
+}
+
+func TestCheck(t *testing.T) {
+	month := func() *models.Kline {
+		openTime := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+		closeTime := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC).UnixMilli() - 1
+		return &models.Kline{OpenTime: openTime, Open: 10, High: 12, Low: 9, Close: 11, CloseTime: closeTime}
+	}
+	testCases := []struct {
+		name     string
+		interval models.Interval
+		prev     *models.Kline
+		kline    func() *models.Kline
+		want     []Problem
+	}{
+		{
+			name:     "valid",
+			interval: models.Interval1m,
+			prev:     minute(0),
+			kline:    func() *models.Kline { return minute(1) },
+		},
+		{
+			name:     "valid month",
+			interval: models.Interval1M,
+			kline:    month,
+		},
+		{
+			name:     "high below close",
+			interval: models.Interval1m,
+			kline:    func() *models.Kline { k := minute(0); k.High = 10.5; return k },
+			want:     []Problem{HighBelowBody},
+		},
+		{
+			name:     "low above open",
+			interval: models.Interval1m,
+			kline:    func() *models.Kline { k := minute(0); k.Low = 10.5; return k },
+			want:     []Problem{LowAboveBody},
+		},
+		{
+			name:     "negative volume",
+			interval: models.Interval1m,
+			kline:    func() *models.Kline { k := minute(0); k.TakerBuyBaseAssetVolume = -1; return k },
+			want:     []Problem{NegativeVolume},
+		},
+		{
+			name:     "wrong close time",
+			interval: models.Interval1m,
+			kline:    func() *models.Kline { k := minute(0); k.CloseTime++; return k },
+			want:     []Problem{WrongCloseTime},
+		},
+		{
+			name:     "month close time of 30 days",
+			interval: models.Interval1M,
+			kline:    func() *models.Kline { k := month(); k.CloseTime = k.OpenTime + 30*24*3600_000 - 1; return k },
+			want:     []Problem{WrongCloseTime},
+		},
+		{
+			name:     "misaligned",
+			interval: models.Interval1m,
+			kline: func() *models.Kline {
+				k := minute(0)
+				k.OpenTime += 30_000
+				k.CloseTime += 30_000
+				return k
+			},
+			want: []Problem{MisalignedOpen},
+		},
+		{
+			name:     "duplicate",
+			interval: models.Interval1m,
+			prev:     minute(1),
+			kline:    func() *models.Kline { return minute(1) },
+			want:     []Problem{DuplicateOpen},
+		},
+		{
+			name:     "overlap",
+			interval: models.Interval1m,
+			prev:     &models.Kline{OpenTime: minute(0).OpenTime, CloseTime: minute(2).OpenTime},
+			kline:    func() *models.Kline { return minute(1) },
+			want:     []Problem{OverlapPrevious},
+		},
+		{
+			name:     "several",
+			interval: models.Interval1m,
+			kline:    func() *models.Kline { k := minute(0); k.High, k.Low, k.Volume = 1, 20, -1; return k },
+			want:     []Problem{HighBelowBody, LowAboveBody, NegativeVolume},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, check(tc.interval, tc.prev, tc.kline()))
+		})
+	}
+}
+
+func TestSpans(t *testing.T) {
+	issue := func(i int) *Issue { return &Issue{Kline: minute(i)} }
+	issues := []*Issue{issue(0), issue(1), issue(2), issue(5), issue(7), issue(8)}
+
+	got := spans(models.Interval1m, issues)
+	require.Len(t, got, 3)
+	require.Equal(t, []int64{minute(0).OpenTime, minute(3).OpenTime - 1}, []int64{got[0].start, got[0].end})
+	require.Equal(t, issues[:3], got[0].issues)
+	require.Equal(t, []int64{minute(5).OpenTime, minute(6).OpenTime - 1}, []int64{got[1].start, got[1].end})
+	require.Equal(t, []int64{minute(7).OpenTime, minute(9).OpenTime - 1}, []int64{got[2].start, got[2].end})
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mockverifier.NewMockStorage(ctrl)
+
+	bad := minute(2)
+	bad.High = 0
+	s.EXPECT().ReadKlines(gomock.Any(), storage.ReadKlinesRequest{Symbol: "BTCUSDT", Interval: "1m",
+		OpenTime: start.UnixMilli(), Limit: 2}).
+		Return([]*models.Kline{minute(0), minute(1)}, nil)
+	s.EXPECT().ReadKlines(gomock.Any(), storage.ReadKlinesRequest{Symbol: "BTCUSDT", Interval: "1m",
+		OpenTime: minute(1).OpenTime + 1, Limit: 2}).
+		Return([]*models.Kline{bad, minute(3)}, nil)
+	// Klines opened after to are left out.
+	s.EXPECT().ReadKlines(gomock.Any(), storage.ReadKlinesRequest{Symbol: "BTCUSDT", Interval: "1m",
+		OpenTime: minute(3).OpenTime + 1, Limit: 2}).
+		Return([]*models.Kline{minute(4), {OpenTime: minute(5).OpenTime}}, nil)
+
+	issues, err := NewVerifier(s).SetChunkSize(2).Verify(context.Background(), VerifyRequest{
+		Pairs: []models.WsKlineRequest{{Symbol: "BTCUSDT", Interval: "1m"}},
+		From:  start,
+		To:    start.Add(4 * time.Minute),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []*Issue{{Symbol: "BTCUSDT", Interval: "1m", Kline: bad, Problems: []Problem{HighBelowBody}}}, issues)
+}
+
+func TestVerifier_Repair(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mockverifier.NewMockStorage(ctrl)
+	ex := mockverifier.NewMockExchange(ctrl)
+
+	bad := minute(1)
+	bad.Low = 100
+	misaligned := minute(1)
+	misaligned.OpenTime += 30_000
+	misaligned.CloseTime += 30_000
+	lost := minute(5)
+	lost.Volume = -1
+
+	s.EXPECT().ReadKlines(gomock.Any(), gomock.Any()).
+		Return([]*models.Kline{minute(0), bad, misaligned, minute(2), lost}, nil)
+
+	// bad, misaligned and minute 2 it overlaps are fetched at once, the
+	// exchange has minute 1 and 2.
+	ex.EXPECT().Klines(gomock.Any(), models.KlinesRequest{Symbol: "BTCUSDT", Interval: "1m",
+		StartTime: minute(1).OpenTime, EndTime: minute(3).OpenTime - 1, Limit: 10}).
+		Return([]*models.Kline{
+			{OpenTime: minute(1).OpenTime, Open: 10, High: 12, Low: 9, Close: 11, Volume: 1, CloseTime: minute(1).CloseTime},
+			{OpenTime: minute(2).OpenTime, Open: 10, High: 12, Low: 9, Close: 11, Volume: 1, CloseTime: minute(2).CloseTime},
+		}, nil)
+	s.EXPECT().WriteKlines(gomock.Any(), storage.WriteKlinesRequest{Symbol: "BTCUSDT", Interval: "1m",
+		Klines: []*models.Kline{minute(1), minute(2)}, Replace: true})
+	s.EXPECT().DeleteKlines(gomock.Any(), storage.DeleteKlinesRequest{Symbol: "BTCUSDT", Interval: "1m",
+		OpenTimes: []int64{misaligned.OpenTime}})
+	// The exchange has no data for minute 5, so it is kept as it is.
+	ex.EXPECT().Klines(gomock.Any(), models.KlinesRequest{Symbol: "BTCUSDT", Interval: "1m",
+		StartTime: minute(5).OpenTime, EndTime: minute(5).CloseTime, Limit: 10})
+
+	issues, err := NewVerifier(s).
+		SetRepair(ex).
+		SetChunkSize(10).
+		Verify(context.Background(), VerifyRequest{
+			Pairs: []models.WsKlineRequest{{Symbol: "BTCUSDT", Interval: "1m"}},
+			From:  start,
+			To:    start.Add(time.Hour),
+		})
+	require.NoError(t, err)
+	require.Len(t, issues, 4)
+	require.Equal(t, []Problem{LowAboveBody}, issues[0].Problems)
+	require.True(t, issues[0].Repaired)
+	require.Equal(t, []Problem{MisalignedOpen, OverlapPrevious}, issues[1].Problems)
+	require.True(t, issues[1].Repaired)
+	require.Equal(t, []Problem{OverlapPrevious}, issues[2].Problems)
+	require.True(t, issues[2].Repaired)
+	require.Equal(t, []Problem{NegativeVolume}, issues[3].Problems)
+	require.False(t, issues[3].Repaired)
+}
@@ -0,0 +1,84 @@
+package indicators
+
+import (
+	"math"
+
+	"crypto_bot/pkg/exchange/models"
+)
+
+// Indicator is updated with one closed kline at a time. Values of an
+// indicator that has not seen enough klines yet are NaN.
+type Indicator[T any] interface {
+	Update(k *models.Kline) T
+	Ready() bool
+}
+
+// Series feeds the klines to the indicator and returns its value after each of them.
+func Series[T any](klines []*models.Kline, ind Indicator[T]) []T {
+	res := make([]T, len(klines))
+	for i, k := range klines {
+		res[i] = ind.Update(k)
+	}
+	return res
+}
+
+// window keeps the last n values.
+type window struct {
+	values []float64
+	pos    int
+	full   bool
+}
+
+func newWindow(n int) *window {
+	return &window{values: make([]float64, n)}
+}
+
+// push adds the value and returns the one it replaced.
+func (w *window) push(v float64) float64 {
+	old := w.values[w.pos]
+	w.values[w.pos] = v
+	w.pos++
+	if w.pos == len(w.values) {
+		w.pos, w.full = 0, true
+	}
+	return old
+}
+
+func (w *window) len() int {
+	if w.full {
+		return len(w.values)
+	}
+	return w.pos
+}
+
+// at returns the i-th value from the oldest one.
+func (w *window) at(i int) float64 {
+	if !w.full {
+		return w.values[i]
+	}
+	return w.values[(w.pos+i)%len(w.values)]
+}
+
+func (w *window) max() float64 {
+	m := math.Inf(-1)
+	for i := 0; i < w.len(); i++ {
+		m = math.Max(m, w.at(i))
+	}
+	return m
+}
+
+func (w *window) min() float64 {
+	m := math.Inf(1)
+	for i := 0; i < w.len(); i++ {
+		m = math.Min(m, w.at(i))
+	}
+	return m
+}
+
+// trueRange returns the true range of the kline, prevClose is NaN for the first one.
+func trueRange(k *models.Kline, prevClose float64) float64 {
+	if math.IsNaN(prevClose) {
+		return k.High - k.Low
+	}
+	return math.Max(k.High-k.Low, math.Max(math.Abs(k.High-prevClose), math.Abs(k.Low-prevClose)))
+}
@@ -0,0 +1,166 @@
+package indicators
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"crypto_bot/pkg/exchange/models"
+)
+
+// testdata/golden.json holds the values for testdata/klines.json computed over
+// the whole series with the textbook definitions by testdata/gen.py, which
+// generates both files. null stands for a value that is not ready. The
+// definitions are checked against published values by TestReferenceValues.
+func TestIndicators(t *testing.T) {
+	var klines []*models.Kline
+	readJSON(t, "testdata/klines.json", &klines)
+	var golden map[string][]any
+	readJSON(t, "testdata/golden.json", &golden)
+
+	tests := []struct {
+		name  string
+		check func(t *testing.T, klines []*models.Kline, want []any)
+	}{
+		{name: "sma", check: checker[float64](NewSMA(10), 9)},
+		{name: "ema", check: checker[float64](NewEMA(10), 9)},
+		{name: "wma", check: checker[float64](NewWMA(10), 9)},
+		{name: "rsi", check: checker[float64](NewRSI(14), 14)},
+		{name: "macd", check: checker[MACDValue](NewMACD(12, 26, 9), 33)},
+		{name: "bollinger", check: checker[BollingerValue](NewBollinger(20, 2), 19)},
+		{name: "atr", check: checker[float64](NewATR(14), 14)},
+		{name: "stochastic", check: checker[StochasticValue](NewStochastic(14, 3), 15)},
+		{name: "obv", check: checker[float64](NewOBV(), 0)},
+		{name: "vwap", check: checker[float64](NewVWAP(0), 0)},
+		{name: "vwapSession", check: checker[float64](NewVWAP(24*60*60*1000), 0)},
+		{name: "adx", check: checker[ADXValue](NewADX(14), 27)},
+		{name: "ichimoku", check: checker[IchimokuValue](NewIchimoku(9, 26, 52, 26), 77)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, ok := golden[tt.name]
+			require.True(t, ok)
+			require.Len(t, want, len(klines))
+			tt.check(t, klines, want)
+		})
+	}
+}
+
+// closeKlines returns klines with the closes.
+func closeKlines(closes ...float64) []*models.Kline {
+	klines := make([]*models.Kline, len(closes))
+	for i, c := range closes {
+		klines[i] = &models.Kline{Open: c, High: c, Low: c, Close: c}
+	}
+	return klines
+}
+
+// TestReferenceValues pins indicators to values published independently of
+// this package and of testdata/gen.py.
+func TestReferenceValues(t *testing.T) {
+	t.Run("sma and ema", func(t *testing.T) {
+		// The 10 day moving averages of the StockCharts ChartSchool article
+		// "Moving Averages - Simple and Exponential", rounded to cents.
+		klines := closeKlines(22.27, 22.19, 22.08, 22.17, 22.18, 22.13, 22.23, 22.43, 22.24, 22.29,
+			22.15, 22.39, 22.38, 22.61, 23.36, 24.05, 23.75, 23.83, 23.95, 23.63, 23.82, 23.87, 23.65)
+		sma := Series[float64](klines, NewSMA(10))
+		require.InDelta(t, 22.22, sma[9], 0.005)
+		ema := Series[float64](klines, NewEMA(10))
+		want := []float64{22.22, 22.21, 22.24, 22.27, 22.33, 22.52, 22.80, 22.97, 23.13, 23.28, 23.34, 23.43, 23.51}
+		for i, w := range want {
+			require.InDelta(t, w, ema[9+i], 0.005, "kline %d", 9+i)
+		}
+	})
+
+	t.Run("rsi", func(t *testing.T) {
+		// The 14 day RSI of the StockCharts ChartSchool article "Relative
+		// Strength Index (RSI)", rounded to hundredths.
+		klines := closeKlines(44.3389, 44.0902, 44.1497, 43.6124, 44.3278, 44.8264, 45.0955, 45.4245, 45.8433,
+			46.0826, 45.8931, 46.0328, 45.6140, 46.2820, 46.2820, 46.0028, 46.0328, 46.4116, 46.2222, 45.6439,
+			46.2122, 46.2521, 45.7137, 46.4515, 45.7835, 45.3548, 44.0288, 44.1783, 44.2181, 44.5672, 43.4205)
+		rsi := Series[float64](klines, NewRSI(14))
+		want := []float64{70.53, 66.32, 66.55, 69.41, 66.36, 57.97, 62.93, 63.26, 56.06, 62.38, 54.71, 50.42,
+			39.99, 41.46, 41.87, 45.46, 37.30}
+		for i, w := range want {
+			require.InDelta(t, w, rsi[14+i], 0.005, "kline %d", 14+i)
+		}
+	})
+
+	t.Run("bollinger", func(t *testing.T) {
+		// The population standard deviation of 2, 4, 4, 4, 5, 5, 7, 9 is 2
+		// around the mean of 5, the example of the Wikipedia article
+		// "Standard deviation".
+		got := Series[BollingerValue](closeKlines(2, 4, 4, 4, 5, 5, 7, 9), NewBollinger(8, 2))
+		require.Equal(t, BollingerValue{Upper: 9, Middle: 5, Lower: 1}, got[7])
+	})
+
+	t.Run("macd", func(t *testing.T) {
+		// An EMA seeded with the SMA of a series rising by one every kline
+		// lags it by (period-1)/2, so MACD(12, 26, 9) is 12.5-5.5 = 7 with
+		// the same signal and no histogram.
+		closes := make([]float64, 40)
+		for i := range closes {
+			closes[i] = float64(100 + i)
+		}
+		got := Series[MACDValue](closeKlines(closes...), NewMACD(12, 26, 9))
+		for i := 33; i < len(got); i++ {
+			require.InDelta(t, 7, got[i].MACD, 1e-9, "kline %d", i)
+			require.InDelta(t, 7, got[i].Signal, 1e-9, "kline %d", i)
+			require.InDelta(t, 0, got[i].Histogram, 1e-9, "kline %d", i)
+		}
+	})
+}
+
+func TestSeries(t *testing.T) {
+	klines := make([]*models.Kline, 5)
+	for i := range klines {
+		klines[i] = &models.Kline{Close: float64(i + 1)}
+	}
+	got := Series[float64](klines, NewSMA(3))
+	require.True(t, math.IsNaN(got[0]))
+	require.True(t, math.IsNaN(got[1]))
+	require.Equal(t, []float64{2, 3, 4}, got[2:])
+}
+
+// checker updates the indicator kline by kline and compares every value with
+// the golden one, the indicator has to become ready at the kline ready.
+func checker[T any](ind Indicator[T], ready int) func(t *testing.T, klines []*models.Kline, want []any) {
+	return func(t *testing.T, klines []*models.Kline, want []any) {
+		for i, k := range klines {
+			got := ind.Update(k)
+			require.Equal(t, i >= ready, ind.Ready(), "kline %d", i)
+
+			v := reflect.ValueOf(got)
+			if v.Kind() != reflect.Struct {
+				requireFloat(t, want[i], v.Float(), "kline %d", i)
+				continue
+			}
+			fields, ok := want[i].(map[string]any)
+			require.True(t, ok, "kline %d", i)
+			for j := 0; j < v.NumField(); j++ {
+				name := v.Type().Field(j).Name
+				w, ok := fields[name]
+				require.True(t, ok, "kline %d: %s", i, name)
+				requireFloat(t, w, v.Field(j).Float(), "kline %d: %s", i, name)
+			}
+		}
+	}
+}
+
+func requireFloat(t *testing.T, want any, got float64, msgAndArgs ...any) {
+	if want == nil {
+		require.True(t, math.IsNaN(got), msgAndArgs...)
+		return
+	}
+	require.InDelta(t, want, got, 1e-9, msgAndArgs...)
+}
+
+func readJSON(t *testing.T, name string, v any) {
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, v))
+}
@@ -0,0 +1,116 @@
+package indicators
+
+import (
+	"math"
+
+	"crypto_bot/pkg/exchange/models"
+)
+
+// SMA is the simple moving average of closes.
+type SMA struct {
+	w   *window
+	sum float64
+}
+
+func NewSMA(period int) *SMA {
+	return &SMA{w: newWindow(period)}
+}
+
+func (s *SMA) Update(k *models.Kline) float64 {
+	return s.Add(k.Close)
+}
+
+func (s *SMA) Add(v float64) float64 {
+	full := s.w.full
+	old := s.w.push(v)
+	if full {
+		s.sum -= old
+	}
+	s.sum += v
+	return s.Value()
+}
+
+func (s *SMA) Ready() bool {
+	return s.w.full
+}
+
+func (s *SMA) Value() float64 {
+	if !s.Ready() {
+		return math.NaN()
+	}
+	return s.sum / float64(len(s.w.values))
+}
+
+// EMA is the exponential moving average of closes with the smoothing factor
+// 2/(period+1). It starts from the simple average of the first period values.
+type EMA struct {
+	period int
+	alpha  float64
+	n      int
+	value  float64
+}
+
+func NewEMA(period int) *EMA {
+	return &EMA{period: period, alpha: 2 / float64(period+1)}
+}
+
+func (e *EMA) Update(k *models.Kline) float64 {
+	return e.Add(k.Close)
+}
+
+func (e *EMA) Add(v float64) float64 {
+	e.n++
+	switch {
+	case e.n < e.period:
+		e.value += v
+		return math.NaN()
+	case e.n == e.period:
+		e.value = (e.value + v) / float64(e.period)
+	default:
+		e.value += e.alpha * (v - e.value)
+	}
+	return e.value
+}
+
+func (e *EMA) Ready() bool {
+	return e.n >= e.period
+}
+
+func (e *EMA) Value() float64 {
+	if !e.Ready() {
+		return math.NaN()
+	}
+	return e.value
+}
+
+// WMA is the linearly weighted moving average of closes, the latest close has
+// the weight period.
+type WMA struct {
+	w *window
+}
+
+func NewWMA(period int) *WMA {
+	return &WMA{w: newWindow(period)}
+}
+
+func (m *WMA) Update(k *models.Kline) float64 {
+	return m.Add(k.Close)
+}
+
+func (m *WMA) Add(v float64) float64 {
+	m.w.push(v)
+	if !m.Ready() {
+		return math.NaN()
+	}
+	var sum, weights float64
+	for i := 0; i < len(m.w.values); i++ {
+		weight := float64(i + 1)
+		sum += weight * m.w.at(i)
+		weights += weight
+	}
+	return sum / weights
+}
+
+func (m *WMA) Ready() bool {
+	return m.w.full
+}
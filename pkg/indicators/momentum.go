@@ -0,0 +1,118 @@
+package indicators
+
+import (
+	"math"
+
+	"crypto_bot/pkg/exchange/models"
+)
+
+// RSI is Wilder's relative strength index of closes.
+type RSI struct {
+	period    int
+	n         int
+	prevClose float64
+	gain      float64
+	loss      float64
+}
+
+func NewRSI(period int) *RSI {
+	return &RSI{period: period}
+}
+
+func (r *RSI) Update(k *models.Kline) float64 {
+	r.n++
+	if r.n == 1 {
+		r.prevClose = k.Close
+		return math.NaN()
+	}
+	change := k.Close - r.prevClose
+	r.prevClose = k.Close
+	gain, loss := math.Max(change, 0), math.Max(-change, 0)
+
+	p := float64(r.period)
+	switch {
+	case r.n <= r.period:
+		r.gain += gain
+		r.loss += loss
+		return math.NaN()
+	case r.n == r.period+1:
+		r.gain = (r.gain + gain) / p
+		r.loss = (r.loss + loss) / p
+	default:
+		r.gain = (r.gain*(p-1) + gain) / p
+		r.loss = (r.loss*(p-1) + loss) / p
+	}
+	if r.loss == 0 {
+		return 100
+	}
+	return 100 - 100/(1+r.gain/r.loss)
+}
+
+func (r *RSI) Ready() bool {
+	return r.n > r.period
+}
+
+type MACDValue struct {
+	MACD      float64
+	Signal    float64
+	Histogram float64
+}
+
+// MACD is the difference of the fast and the slow EMA of closes with its
+// signal line, the EMA of the difference.
+type MACD struct {
+	fast, slow, signal *EMA
+}
+
+func NewMACD(fast, slow, signal int) *MACD {
+	return &MACD{fast: NewEMA(fast), slow: NewEMA(slow), signal: NewEMA(signal)}
+}
+
+func (m *MACD) Update(k *models.Kline) MACDValue {
+	fast, slow := m.fast.Add(k.Close), m.slow.Add(k.Close)
+	if !m.slow.Ready() || !m.fast.Ready() {
+		return MACDValue{MACD: math.NaN(), Signal: math.NaN(), Histogram: math.NaN()}
+	}
+	v := MACDValue{MACD: fast - slow}
+	v.Signal = m.signal.Add(v.MACD)
+	v.Histogram = v.MACD - v.Signal
+	return v
+}
+
+func (m *MACD) Ready() bool {
+	return m.signal.Ready()
+}
+
+type StochasticValue struct {
+	K float64
+	D float64
+}
+
+// Stochastic is the fast stochastic oscillator: %K is the position of the close
+// in the high-low range of the last kPeriod klines, %D its simple average.
+type Stochastic struct {
+	high, low *window
+	d         *SMA
+}
+
+func NewStochastic(kPeriod, dPeriod int) *Stochastic {
+	return &Stochastic{high: newWindow(kPeriod), low: newWindow(kPeriod), d: NewSMA(dPeriod)}
+}
+
+func (s *Stochastic) Update(k *models.Kline) StochasticValue {
+	s.high.push(k.High)
+	s.low.push(k.Low)
+	if !s.high.full {
+		return StochasticValue{K: math.NaN(), D: math.NaN()}
+	}
+	var v StochasticValue
+	if hh, ll := s.high.max(), s.low.min(); hh > ll {
+		v.K = 100 * (k.Close - ll) / (hh - ll)
+	}
+	v.D = s.d.Add(v.K)
+	return v
+}
+
+func (s *Stochastic) Ready() bool {
+	return s.d.Ready()
+}
@@ -0,0 +1,202 @@
+"""Generates klines.json and golden.json for TestIndicators.
+
+The klines are a seeded random walk of 80 hourly klines, the 31st of them
+flat. The golden values are computed over the whole series at once with the
+textbook definitions, independently of the streaming indicators under test:
+
+  - EMA is seeded with the SMA of its first period.
+  - RSI, ATR and ADX use Wilder smoothing seeded with the plain mean.
+  - Bollinger bands use the population standard deviation.
+  - Stochastic %D is the SMA of %K.
+  - VWAP uses the typical price, the session one resets at UTC midnight.
+  - Ichimoku Senkou spans are reported as of the kline they are plotted at,
+    LeadingA/LeadingB are the spans computed at the kline itself.
+
+null stands for a value that is not ready yet.
+
+Run it from any directory with python3, it rewrites both files next to it.
+"""
+import json
+import math
+import os
+import random
+
+HERE = os.path.dirname(os.path.abspath(__file__))
+N = None
+H = 3600000
+
+
+def klines():
+    random.seed(7)
+    res = []
+    price = 100.0
+    t = 1700000000000
+    for i in range(80):
+        o = round(price, 2)
+        c = round(o * (1 + random.gauss(0, 0.02)), 2)
+        h = round(max(o, c) * (1 + abs(random.gauss(0, 0.008))), 2)
+        l = round(min(o, c) * (1 - abs(random.gauss(0, 0.008))), 2)
+        if i == 30:
+            h = l = o = c
+        v = round(random.uniform(10, 500), 3)
+        res.append(dict(openTime=t + i * H, open=o, high=h, low=l, close=c, volume=v, closeTime=t + (i + 1) * H - 1))
+        price = c
+    return res
+
+
+kl = klines()
+C = [k['close'] for k in kl]
+Hh = [k['high'] for k in kl]
+L = [k['low'] for k in kl]
+V = [k['volume'] for k in kl]
+n = len(kl)
+
+
+def sma(x, p):
+    return [N if i < p - 1 or any(v is None for v in x[i - p + 1:i + 1]) else sum(x[i - p + 1:i + 1]) / p
+            for i in range(len(x))]
+
+
+def ema(x, p):
+    out = [N] * len(x)
+    s = [i for i, v in enumerate(x) if v is not None][0]
+    a = 2 / (p + 1)
+    for i in range(s + p - 1, len(x)):
+        out[i] = sum(x[s:s + p]) / p if i == s + p - 1 else out[i - 1] + a * (x[i] - out[i - 1])
+    return out
+
+
+def wma(x, p):
+    return [N if i < p - 1 else sum((j + 1) * x[i - p + 1 + j] for j in range(p)) / (p * (p + 1) / 2)
+            for i in range(len(x))]
+
+
+def wilder(x, p, start):
+    """Smooths x defined from index start, the first value is the mean of p values."""
+    out = [N] * len(x)
+    for i in range(start + p - 1, len(x)):
+        out[i] = sum(x[start:start + p]) / p if i == start + p - 1 else (out[i - 1] * (p - 1) + x[i]) / p
+    return out
+
+
+def rsi(p):
+    g = [N] + [max(C[i] - C[i - 1], 0) for i in range(1, n)]
+    d = [N] + [max(C[i - 1] - C[i], 0) for i in range(1, n)]
+    ag, al = wilder(g, p, 1), wilder(d, p, 1)
+    return [N if ag[i] is None else (100 if al[i] == 0 else 100 - 100 / (1 + ag[i] / al[i])) for i in range(n)]
+
+
+def tr():
+    return [Hh[0] - L[0]] + [max(Hh[i] - L[i], abs(Hh[i] - C[i - 1]), abs(L[i] - C[i - 1])) for i in range(1, n)]
+
+
+def atr(p):
+    t = tr()
+    t[0] = None
+    return wilder(t, p, 1)
+
+
+def macd(f, s, g):
+    m = [None if a is None or b is None else a - b for a, b in zip(ema(C, f), ema(C, s))]
+    sig = ema(m, g)
+    return [dict(MACD=m[i], Signal=sig[i], Histogram=None if sig[i] is None else m[i] - sig[i]) for i in range(n)]
+
+
+def bollinger(p, w):
+    out = []
+    for i in range(n):
+        if i < p - 1:
+            out.append(dict(Upper=N, Middle=N, Lower=N))
+            continue
+        x = C[i - p + 1:i + 1]
+        m = sum(x) / p
+        sd = math.sqrt(sum((v - m) ** 2 for v in x) / p)
+        out.append(dict(Upper=m + w * sd, Middle=m, Lower=m - w * sd))
+    return out
+
+
+def stochastic(kp, dp):
+    k = []
+    for i in range(n):
+        if i < kp - 1:
+            k.append(N)
+            continue
+        hh, ll = max(Hh[i - kp + 1:i + 1]), min(L[i - kp + 1:i + 1])
+        k.append(0 if hh == ll else 100 * (C[i] - ll) / (hh - ll))
+    d = sma(k, dp)
+    return [dict(K=k[i], D=d[i]) for i in range(n)]
+
+
+def obv():
+    o = [V[0]]
+    for i in range(1, n):
+        o.append(o[-1] + (V[i] if C[i] > C[i - 1] else -V[i] if C[i] < C[i - 1] else 0))
+    return o
+
+
+def vwap(session):
+    out = []
+    pv = vv = 0
+    cur = None
+    for k in kl:
+        if session and k['openTime'] // session != cur:
+            cur = k['openTime'] // session
+            pv = vv = 0
+        pv += (k['high'] + k['low'] + k['close']) / 3 * k['volume']
+        vv += k['volume']
+        out.append(pv / vv)
+    return out
+
+
+def adx(p):
+    pdm, mdm = [N], [N]
+    for i in range(1, n):
+        u, d = Hh[i] - Hh[i - 1], L[i - 1] - L[i]
+        pdm.append(u if u > d and u > 0 else 0)
+        mdm.append(d if d > u and d > 0 else 0)
+
+    def smooth(x):
+        out = [N] * n
+        for i in range(p, n):
+            out[i] = sum(x[1:p + 1]) if i == p else out[i - 1] - out[i - 1] / p + x[i]
+        return out
+
+    st, sp, sm = smooth(tr()), smooth(pdm), smooth(mdm)
+    pdi = [N if st[i] is None else 100 * sp[i] / st[i] for i in range(n)]
+    mdi = [N if st[i] is None else 100 * sm[i] / st[i] for i in range(n)]
+    dx = [N if pdi[i] is None else (0 if pdi[i] + mdi[i] == 0 else 100 * abs(pdi[i] - mdi[i]) / (pdi[i] + mdi[i]))
+          for i in range(n)]
+    a = wilder(dx, p, p)
+    return [dict(ADX=a[i], PlusDI=pdi[i], MinusDI=mdi[i]) for i in range(n)]
+
+
+def ichimoku(t, k, s, d):
+    def mid(p):
+        return [N if i < p - 1 else (max(Hh[i - p + 1:i + 1]) + min(L[i - p + 1:i + 1])) / 2 for i in range(n)]
+
+    tenkan, kijun, b = mid(t), mid(k), mid(s)
+    a = [N if tenkan[i] is None or kijun[i] is None else (tenkan[i] + kijun[i]) / 2 for i in range(n)]
+    return [dict(Tenkan=tenkan[i], Kijun=kijun[i], SenkouA=a[i - d] if i >= d else N, SenkouB=b[i - d] if i >= d else N,
+                 LeadingA=a[i], LeadingB=b[i], Chikou=C[i]) for i in range(n)]
+
+
+golden = dict(
+    sma=sma(C, 10),
+    ema=ema(C, 10),
+    wma=wma(C, 10),
+    rsi=rsi(14),
+    macd=macd(12, 26, 9),
+    bollinger=bollinger(20, 2),
+    atr=atr(14),
+    stochastic=stochastic(14, 3),
+    obv=obv(),
+    vwap=vwap(0),
+    vwapSession=vwap(24 * H),
+    adx=adx(14),
+    ichimoku=ichimoku(9, 26, 52, 26),
+)
+
+with open(os.path.join(HERE, 'klines.json'), 'w') as f:
+    json.dump(kl, f, indent=1)
+with open(os.path.join(HERE, 'golden.json'), 'w') as f:
+    json.dump(golden, f, indent=1)
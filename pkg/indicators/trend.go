@@ -0,0 +1,162 @@
+package indicators
+
+import (
+	"math"
+
+	"crypto_bot/pkg/exchange/models"
+)
+
+type ADXValue struct {
+	ADX     float64
+	PlusDI  float64
+	MinusDI float64
+}
+
+// ADX is Wilder's average directional index with the directional indicators.
+// The indicators come after period+1 klines, the index after 2*period.
+type ADX struct {
+	period int
+	n      int
+	prev   *models.Kline
+	tr     float64
+	plusDM float64
+	minDM  float64
+	adx    float64
+}
+
+func NewADX(period int) *ADX {
+	return &ADX{period: period}
+}
+
+func (a *ADX) Update(k *models.Kline) ADXValue {
+	nan := ADXValue{ADX: math.NaN(), PlusDI: math.NaN(), MinusDI: math.NaN()}
+	prev := a.prev
+	a.prev = k
+	a.n++
+	if prev == nil {
+		return nan
+	}
+
+	up, down := k.High-prev.High, prev.Low-k.Low
+	var plusDM, minusDM float64
+	if up > down && up > 0 {
+		plusDM = up
+	}
+	if down > up && down > 0 {
+		minusDM = down
+	}
+	tr := trueRange(k, prev.Close)
+
+	p := float64(a.period)
+	if a.n <= a.period+1 {
+		a.tr += tr
+		a.plusDM += plusDM
+		a.minDM += minusDM
+		if a.n <= a.period {
+			return nan
+		}
+	} else {
+		a.tr = a.tr - a.tr/p + tr
+		a.plusDM = a.plusDM - a.plusDM/p + plusDM
+		a.minDM = a.minDM - a.minDM/p + minusDM
+	}
+
+	v := ADXValue{ADX: math.NaN()}
+	if a.tr > 0 {
+		v.PlusDI = 100 * a.plusDM / a.tr
+		v.MinusDI = 100 * a.minDM / a.tr
+	}
+	var dx float64
+	if sum := v.PlusDI + v.MinusDI; sum > 0 {
+		dx = 100 * math.Abs(v.PlusDI-v.MinusDI) / sum
+	}
+
+	// The first directional indicators come with the kline period+1.
+	switch dxN := a.n - a.period; {
+	case dxN < a.period:
+		a.adx += dx
+	case dxN == a.period:
+		a.adx = (a.adx + dx) / p
+		v.ADX = a.adx
+	default:
+		a.adx = (a.adx*(p-1) + dx) / p
+		v.ADX = a.adx
+	}
+	return v
+}
+
+func (a *ADX) Ready() bool {
+	return a.n >= 2*a.period
+}
+
+type IchimokuValue struct {
+	Tenkan float64
+	Kijun  float64
+	// SenkouA and SenkouB are the cloud at the current kline, computed
+	// displacement klines ago.
+	SenkouA float64
+	SenkouB float64
+	// LeadingA and LeadingB are computed from the current kline and form the
+	// cloud displacement klines ahead.
+	LeadingA float64
+	LeadingB float64
+	// Chikou is the current close, drawn displacement klines back.
+	Chikou float64
+}
+
+// Ichimoku is the Ichimoku Kinko Hyo with the usual periods 9, 26, 52 and
+// the displacement 26 as parameters.
+type Ichimoku struct {
+	tenkanHigh, tenkanLow *window
+	kijunHigh, kijunLow   *window
+	senkouHigh, senkouLow *window
+	leadingA, leadingB    *window
+}
+
+func NewIchimoku(tenkan, kijun, senkouB, displacement int) *Ichimoku {
+	return &Ichimoku{
+		tenkanHigh: newWindow(tenkan),
+		tenkanLow:  newWindow(tenkan),
+		kijunHigh:  newWindow(kijun),
+		kijunLow:   newWindow(kijun),
+		senkouHigh: newWindow(senkouB),
+		senkouLow:  newWindow(senkouB),
+		leadingA:   newWindow(displacement + 1),
+		leadingB:   newWindow(displacement + 1),
+	}
+}
+
+func (i *Ichimoku) Update(k *models.Kline) IchimokuValue {
+	for _, w := range []*window{i.tenkanHigh, i.kijunHigh, i.senkouHigh} {
+		w.push(k.High)
+	}
+	for _, w := range []*window{i.tenkanLow, i.kijunLow, i.senkouLow} {
+		w.push(k.Low)
+	}
+	v := IchimokuValue{
+		Tenkan:   midpoint(i.tenkanHigh, i.tenkanLow),
+		Kijun:    midpoint(i.kijunHigh, i.kijunLow),
+		LeadingB: midpoint(i.senkouHigh, i.senkouLow),
+		Chikou:   k.Close,
+	}
+	v.LeadingA = (v.Tenkan + v.Kijun) / 2
+
+	i.leadingA.push(v.LeadingA)
+	i.leadingB.push(v.LeadingB)
+	v.SenkouA, v.SenkouB = math.NaN(), math.NaN()
+	if i.leadingA.full {
+		v.SenkouA, v.SenkouB = i.leadingA.at(0), i.leadingB.at(0)
+	}
+	return v
+}
+
+func (i *Ichimoku) Ready() bool {
+	return i.senkouHigh.full && i.leadingB.full && !math.IsNaN(i.leadingB.at(0))
+}
+
+func midpoint(high, low *window) float64 {
+	if !high.full {
+		return math.NaN()
+	}
+	return (high.max() + low.min()) / 2
+}
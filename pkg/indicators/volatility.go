@@ -0,0 +1,81 @@
+package indicators
+
+import (
+	"math"
+
+	"crypto_bot/pkg/exchange/models"
+)
+
+type BollingerValue struct {
+	Upper  float64
+	Middle float64
+	Lower  float64
+}
+
+// Bollinger is the simple average of closes with bands the given number of
+// population standard deviations away.
+type Bollinger struct {
+	sma   *SMA
+	width float64
+}
+
+func NewBollinger(period int, width float64) *Bollinger {
+	return &Bollinger{sma: NewSMA(period), width: width}
+}
+
+func (b *Bollinger) Update(k *models.Kline) BollingerValue {
+	mean := b.sma.Add(k.Close)
+	if !b.sma.Ready() {
+		return BollingerValue{Upper: math.NaN(), Middle: math.NaN(), Lower: math.NaN()}
+	}
+	w := b.sma.w
+	var variance float64
+	for i := 0; i < w.len(); i++ {
+		d := w.at(i) - mean
+		variance += d * d
+	}
+	std := math.Sqrt(variance / float64(w.len()))
+	return BollingerValue{Upper: mean + b.width*std, Middle: mean, Lower: mean - b.width*std}
+}
+
+func (b *Bollinger) Ready() bool {
+	return b.sma.Ready()
+}
+
+// ATR is Wilder's average true range. The first true range needs the previous
+// close, so the first value comes after period+1 klines.
+type ATR struct {
+	period    int
+	n         int
+	prevClose float64
+	value     float64
+}
+
+func NewATR(period int) *ATR {
+	return &ATR{period: period, prevClose: math.NaN()}
+}
+
+func (a *ATR) Update(k *models.Kline) float64 {
+	prevClose := a.prevClose
+	a.prevClose = k.Close
+	a.n++
+	if a.n == 1 {
+		return math.NaN()
+	}
+	tr := trueRange(k, prevClose)
+	p := float64(a.period)
+	switch {
+	case a.n <= a.period:
+		a.value += tr
+		return math.NaN()
+	case a.n == a.period+1:
+		a.value = (a.value + tr) / p
+	default:
+		a.value = (a.value*(p-1) + tr) / p
+	}
+	return a.value
+}
+
+func (a *ATR) Ready() bool {
+	return a.n > a.period
+}
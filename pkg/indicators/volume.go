@@ -0,0 +1,68 @@
+package indicators
+
+import (
+	"math"
+
+	"crypto_bot/pkg/exchange/models"
+)
+
+// OBV is the on balance volume starting from the volume of the first kline.
+type OBV struct {
+	started   bool
+	prevClose float64
+	value     float64
+}
+
+func NewOBV() *OBV {
+	return &OBV{}
+}
+
+func (o *OBV) Update(k *models.Kline) float64 {
+	switch {
+	case !o.started:
+		o.started = true
+		o.value = k.Volume
+	case k.Close > o.prevClose:
+		o.value += k.Volume
+	case k.Close < o.prevClose:
+		o.value -= k.Volume
+	}
+	o.prevClose = k.Close
+	return o.value
+}
+
+func (o *OBV) Ready() bool {
+	return o.started
+}
+
+// VWAP is the volume weighted average of typical prices (high+low+close)/3.
+// It starts over with every kline opened at a multiple of session since the
+// epoch, a zero session never starts over.
+type VWAP struct {
+	session int64
+	current int64
+	pv      float64
+	volume  float64
+}
+
+func NewVWAP(sessionMs int64) *VWAP {
+	return &VWAP{session: sessionMs, current: -1}
+}
+
+func (v *VWAP) Update(k *models.Kline) float64 {
+	if v.session > 0 {
+		if s := k.OpenTime / v.session; s != v.current {
+			v.current, v.pv, v.volume = s, 0, 0
+		}
+	}
+	v.pv += (k.High + k.Low + k.Close) / 3 * k.Volume
+	v.volume += k.Volume
+	if v.volume == 0 {
+		return math.NaN()
+	}
+	return v.pv / v.volume
+}
+
+func (v *VWAP) Ready() bool {
+	return v.volume > 0
+}
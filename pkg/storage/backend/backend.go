@@ -0,0 +1,61 @@
+// Package backend opens the storage a command runs on by its url.
+package backend
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"crypto_bot/pkg/storage"
+	"crypto_bot/pkg/storage/memory"
+	"crypto_bot/pkg/storage/pgdb"
+	"crypto_bot/pkg/storage/sqlite"
+)
+
+// Backend is an opened storage. Only Postgres keeps gap checkpoints, empty
+// ranges and accounts, Postgres is nil for the other backends.
+type Backend struct {
+	Klines   storage.KlineRepository
+	Postgres *pgdb.Client
+
+	close func()
+}
+
+// Open opens the storage of url:
+//   - memory: keeps klines in memory until the process exits,
+//   - sqlite:<path> keeps klines in the SQLite file at path,
+//   - anything else is a Postgres connection string, maxConns limits its pool.
+func Open(ctx context.Context, url string, maxConns int32) (*Backend, error) {
+	switch {
+	case url == "memory:":
+		return &Backend{Klines: memory.NewKlineRepository(), close: func() {}}, nil
+	case strings.HasPrefix(url, "sqlite:"):
+		path := strings.TrimPrefix(strings.TrimPrefix(url, "sqlite:"), "//")
+		r, err := sqlite.Open(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		return &Backend{Klines: r, close: func() { r.Close() }}, nil
+	}
+	db, err := pgdb.Connect(ctx, url, maxConns)
+	if err != nil {
+		return nil, err
+	}
+	return &Backend{Klines: db.Klines(), Postgres: db, close: db.Close}, nil
+}
+
+// CheckWritable returns storage.ErrAggregatedInterval if the storage builds
+// klines of any of the intervals from 1m klines itself, so they can not be
+// written.
+func (b *Backend) CheckWritable(intervals ...string) error {
+	for _, interval := range intervals {
+		if b.Postgres != nil && b.Postgres.AggregatedInterval(interval) {
+			return fmt.Errorf("%s: %w", interval, storage.ErrAggregatedInterval)
+		}
+	}
+	return nil
+}
+
+func (b *Backend) Close() {
+	b.close()
+}
@@ -0,0 +1,30 @@
+package backend
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"crypto_bot/pkg/storage"
+	"crypto_bot/pkg/storage/storagetest"
+)
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	for _, url := range []string{"memory:", "sqlite:" + filepath.Join(dir, "a.db"), "sqlite://" + filepath.Join(dir, "b.db")} {
+		b, err := Open(context.Background(), url, 0)
+		require.NoError(t, err, url)
+		require.Nil(t, b.Postgres, url)
+
+		req := storage.WriteKlinesRequest{Symbol: "BTCUSDT", Interval: "1m", Klines: storagetest.Klines(0, 2)}
+		require.NoError(t, b.Klines.WriteKlines(context.Background(), req), url)
+		got, err := b.Klines.ReadKlines(context.Background(), storage.ReadKlinesRequest{Symbol: "BTCUSDT", Interval: "1m"})
+		require.NoError(t, err, url)
+		require.Equal(t, req.Klines, got, url)
+		require.NoError(t, b.CheckWritable("1m", "5m", "1h"), url)
+		b.Close()
+	}
+	require.FileExists(t, filepath.Join(dir, "b.db"))
+}
@@ -0,0 +1,74 @@
+// Package storage defines where klines are stored, independently of the
+// database: pgdb stores them in Postgres, sqlite in a file and memory in memory.
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"crypto_bot/pkg/exchange/models"
+)
+
+// ErrNoKlines is returned by ReadLastKline if there are no klines of the
+// symbol and interval.
+var ErrNoKlines = errors.New("no klines")
+
+// ErrAggregatedInterval is returned on writes and deletes of klines of an
+// interval the storage builds from 1m klines itself, like the continuous
+// aggregates of TimescaleDB. Fix the 1m klines instead.
+var ErrAggregatedInterval = errors.New("klines of the interval are aggregated from 1m klines")
+
+// KlineRepository stores the klines of every symbol and interval, ordered and
+// unique by open time.
+type KlineRepository interface {
+	ReadKlines(context.Context, ReadKlinesRequest) ([]*models.Kline, error)
+	ReadLastKline(context.Context, ReadLastKlineRequest) (*models.Kline, error)
+	WriteKlines(context.Context, WriteKlinesRequest) error
+	DeleteKlines(context.Context, DeleteKlinesRequest) error
+}
+
+type ReadKlinesRequest struct {
+	Symbol   string
+	Interval string
+	// OpenTime and CloseTime filter klines opened from and closed until them,
+	// a zero CloseTime reads up to the last kline.
+	OpenTime  int64
+	CloseTime int64
+	// Limit is the max number of klines read, all of them if it is zero.
+	Limit  uint64
+	Offset uint64
+}
+
+type ReadLastKlineRequest struct {
+	Symbol   string
+	Interval string
+}
+
+type WriteKlinesRequest struct {
+	Symbol   string
+	Interval string
+	Klines   []*models.Kline
+	// Replace overwrites stored klines with the same open time instead of
+	// skipping them, Klines must not repeat an open time then.
+	Replace bool
+}
+
+type DeleteKlinesRequest struct {
+	Symbol    string
+	Interval  string
+	OpenTimes []int64
+}
+
+// ParsePair validates a symbol and interval and returns them as they are
+// stored, the symbol in upper case.
+func ParsePair(symbol, interval string) (string, string, error) {
+	s, err := models.ParseSymbol(symbol)
+	if err != nil {
+		return "", "", err
+	}
+	i, err := models.ParseInterval(interval)
+	if err != nil {
+		return "", "", err
+	}
+	return string(s), string(i), nil
+}
@@ -0,0 +1,287 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+
+	"crypto_bot/pkg/storage/pgdb"
+)
+
+// Accounts keeps users with their orders and balances like the pgdb client
+// does, returning pgx.ErrNoRows for missing orders and balances.
+type Accounts struct {
+	mu       sync.Mutex
+	lastUID  int64
+	lastID   int64
+	users    []*pgdb.User
+	orders   []*pgdb.Order
+	owners   map[int64]int64
+	balances map[int64][]*pgdb.Balance
+}
+
+func NewAccounts() *Accounts {
+	return &Accounts{
+		owners:   make(map[int64]int64),
+		balances: make(map[int64][]*pgdb.Balance),
+	}
+}
+
+func (a *Accounts) CreateUser(_ context.Context, r pgdb.CreateUserRequest) (*pgdb.User, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, u := range a.users {
+		if u.Login == r.Login {
+			return nil, fmt.Errorf("user %s already exists", r.Login)
+		}
+	}
+	a.lastUID++
+	u := &pgdb.User{UID: a.lastUID, Login: r.Login}
+	a.users = append(a.users, u)
+	copied := *u
+	return &copied, nil
+}
+
+func (a *Accounts) ReadUser(_ context.Context, r pgdb.ReadUserRequest) (*pgdb.User, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, u := range a.users {
+		if (r.UID == 0 || u.UID == r.UID) && (r.Login == "" || u.Login == r.Login) {
+			copied := *u
+			return &copied, nil
+		}
+	}
+	return nil, pgx.ErrNoRows
+}
+
+// DeleteUser deletes the user with its orders and balances.
+func (a *Accounts) DeleteUser(_ context.Context, r pgdb.DeleteUserRequest) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.users = slices.DeleteFunc(a.users, func(u *pgdb.User) bool { return u.UID == r.UID })
+	a.orders = slices.DeleteFunc(a.orders, func(o *pgdb.Order) bool { return a.owners[o.ID] == r.UID })
+	delete(a.balances, r.UID)
+	return nil
+}
+
+func (a *Accounts) CreateOrder(_ context.Context, r pgdb.CreateOrderRequest) (*pgdb.Order, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lastID++
+	o := &pgdb.Order{
+		ID:          a.lastID,
+		Symbol:      r.Symbol,
+		Price:       r.Price,
+		Quantity:    r.Quantity,
+		Type:        r.Type,
+		Side:        r.Side,
+		StopPrice:   r.StopPrice,
+		TimeInForce: r.TimeInForce,
+		Status:      r.Status,
+		IsWorking:   r.IsWorking,
+		Time:        r.Time,
+		UpdateTime:  r.Time,
+		Locked:      r.Locked,
+	}
+	a.orders = append(a.orders, o)
+	a.owners[o.ID] = r.UserUID
+	copied := *o
+	return &copied, nil
+}
+
+func (a *Accounts) ReadOrder(_ context.Context, r pgdb.ReadOrderRequest) (*pgdb.Order, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if o := a.order(r.ID); o != nil {
+		copied := *o
+		return &copied, nil
+	}
+	return nil, pgx.ErrNoRows
+}
+
+func (a *Accounts) ReadOrders(_ context.Context, r pgdb.ReadOrdersRequest) ([]*pgdb.Order, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var res []*pgdb.Order
+	for _, o := range a.orders {
+		if a.owners[o.ID] != r.UserUID || (r.Symbol != "" && o.Symbol != r.Symbol) {
+			continue
+		}
+		if len(r.Statuses) > 0 && !slices.Contains(r.Statuses, o.Status) {
+			continue
+		}
+		copied := *o
+		res = append(res, &copied)
+	}
+	return res, nil
+}
+
+func (a *Accounts) UpdateOrderStatus(_ context.Context, r pgdb.UpdateOrderStatusRequest) (*pgdb.Order, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	o := a.order(r.ID)
+	if o == nil {
+		return nil, pgx.ErrNoRows
+	}
+	updateStatus(o, r)
+	copied := *o
+	return &copied, nil
+}
+
+func updateStatus(o *pgdb.Order, r pgdb.UpdateOrderStatusRequest) {
+	o.Status = r.Status
+	o.IsWorking = r.IsWorking
+	o.ExecutedQuantity = r.ExecutedQuantity
+	o.CummulativeQuoteQuantity = r.CummulativeQuoteQuantity
+	o.UpdateTime = r.UpdateTime
+	o.Commission = r.Commission
+	o.CommissionAsset = r.CommissionAsset
+}
+
+func (a *Accounts) order(id int64) *pgdb.Order {
+	for _, o := range a.orders {
+		if o.ID == id {
+			return o
+		}
+	}
+	return nil
+}
+
+func (a *Accounts) CreateBalance(_ context.Context, r pgdb.CreateBalanceRequest) (*pgdb.Balance, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.balance(r.UserUID, r.Asset) != nil {
+		return nil, fmt.Errorf("balance %s of user %d already exists", r.Asset, r.UserUID)
+	}
+	b := &pgdb.Balance{Asset: r.Asset, Free: r.Free, Locked: r.Locked}
+	a.balances[r.UserUID] = append(a.balances[r.UserUID], b)
+	copied := *b
+	return &copied, nil
+}
+
+func (a *Accounts) ReadBalance(_ context.Context, r pgdb.ReadBalanceRequest) (*pgdb.Balance, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if b := a.balance(r.UserUID, r.Asset); b != nil {
+		copied := *b
+		return &copied, nil
+	}
+	return nil, pgx.ErrNoRows
+}
+
+func (a *Accounts) ReadBalances(_ context.Context, r pgdb.ReadBalancesRequest) ([]*pgdb.Balance, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var res []*pgdb.Balance
+	for _, b := range a.balances[r.UserUID] {
+		copied := *b
+		res = append(res, &copied)
+	}
+	return res, nil
+}
+
+func (a *Accounts) UpdateBalance(_ context.Context, r pgdb.UpdateBalanceRequest) (*pgdb.Balance, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if b := a.balance(r.UserUID, r.Asset); b != nil {
+		b.Free, b.Locked = r.Free, r.Locked
+	}
+	return &pgdb.Balance{Asset: r.Asset, Free: r.Free, Locked: r.Locked}, nil
+}
+
+// ChangeBalance adds the amounts of r to the balance, creating it if there is
+// none.
+func (a *Accounts) ChangeBalance(_ context.Context, r pgdb.ChangeBalanceRequest) (*pgdb.Balance, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	b := a.balance(r.UserUID, r.Asset)
+	if b == nil {
+		b = &pgdb.Balance{Asset: r.Asset}
+		a.balances[r.UserUID] = append(a.balances[r.UserUID], b)
+	}
+	b.Free += r.Free
+	b.Locked += r.Locked
+	copied := *b
+	return &copied, nil
+}
+
+// balanceTolerance absorbs float rounding as pgdb does.
+const balanceTolerance = 1e-9
+
+// ChangeBalances applies every change at once. Nothing is changed and
+// pgdb.ErrNegativeBalance is returned if any balance would become negative.
+func (a *Accounts) ChangeBalances(_ context.Context, r pgdb.ChangeBalancesRequest) ([]*pgdb.Balance, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	changed, res, err := a.changeBalances(r)
+	if err != nil {
+		return nil, err
+	}
+	a.applyBalances(r.UserUID, changed)
+	return res, nil
+}
+
+// SettleOrder changes the balances and updates the order status at once like
+// the pgdb client does in a transaction.
+func (a *Accounts) SettleOrder(_ context.Context, r pgdb.SettleOrderRequest) (*pgdb.Order, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	o := a.order(r.Status.ID)
+	if o == nil {
+		return nil, pgx.ErrNoRows
+	}
+	changed, _, err := a.changeBalances(pgdb.ChangeBalancesRequest{UserUID: r.UserUID, Changes: r.Changes})
+	if err != nil {
+		return nil, err
+	}
+	a.applyBalances(r.UserUID, changed)
+	updateStatus(o, r.Status)
+	copied := *o
+	return &copied, nil
+}
+
+// changeBalances returns the balances changed by r without storing them.
+func (a *Accounts) changeBalances(r pgdb.ChangeBalancesRequest) (map[string]*pgdb.Balance, []*pgdb.Balance, error) {
+	changed := make(map[string]*pgdb.Balance)
+	res := make([]*pgdb.Balance, len(r.Changes))
+	for i, change := range r.Changes {
+		b := changed[change.Asset]
+		if b == nil {
+			b = &pgdb.Balance{Asset: change.Asset}
+			if cur := a.balance(r.UserUID, change.Asset); cur != nil {
+				*b = *cur
+			}
+			changed[change.Asset] = b
+		}
+		b.Free += change.Free
+		b.Locked += change.Locked
+		if b.Free < -balanceTolerance || b.Locked < -balanceTolerance {
+			return nil, nil, fmt.Errorf("%s: %w", b.Asset, pgdb.ErrNegativeBalance)
+		}
+		copied := *b
+		res[i] = &copied
+	}
+	return changed, res, nil
+}
+
+func (a *Accounts) applyBalances(uid int64, changed map[string]*pgdb.Balance) {
+	for asset, b := range changed {
+		if cur := a.balance(uid, asset); cur != nil {
+			*cur = *b
+		} else {
+			a.balances[uid] = append(a.balances[uid], b)
+		}
+	}
+}
+
+func (a *Accounts) balance(uid int64, asset string) *pgdb.Balance {
+	for _, b := range a.balances[uid] {
+		if b.Asset == asset {
+			return b
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+
+	"crypto_bot/pkg/storage/pgdb"
+)
+
+func TestAccounts(t *testing.T) {
+	ctx := context.Background()
+	a := NewAccounts()
+
+	user, err := a.CreateUser(ctx, pgdb.CreateUserRequest{Login: "user"})
+	require.NoError(t, err)
+	_, err = a.CreateUser(ctx, pgdb.CreateUserRequest{Login: "user"})
+	require.Error(t, err)
+	got, err := a.ReadUser(ctx, pgdb.ReadUserRequest{Login: "user"})
+	require.NoError(t, err)
+	require.Equal(t, user, got)
+
+	_, err = a.CreateBalance(ctx, pgdb.CreateBalanceRequest{UserUID: user.UID, Asset: "USDT", Free: 100})
+	require.NoError(t, err)
+	b, err := a.ChangeBalance(ctx, pgdb.ChangeBalanceRequest{UserUID: user.UID, Asset: "USDT", Free: -30, Locked: 30})
+	require.NoError(t, err)
+	require.Equal(t, &pgdb.Balance{Asset: "USDT", Free: 70, Locked: 30}, b)
+	b, err = a.ChangeBalance(ctx, pgdb.ChangeBalanceRequest{UserUID: user.UID, Asset: "BTC", Free: 1})
+	require.NoError(t, err)
+	require.Equal(t, &pgdb.Balance{Asset: "BTC", Free: 1}, b)
+
+	// Nothing is changed if any of the balances would become negative.
+	_, err = a.ChangeBalances(ctx, pgdb.ChangeBalancesRequest{UserUID: user.UID, Changes: []pgdb.BalanceChange{
+		{Asset: "BTC", Free: 1},
+		{Asset: "USDT", Free: 10, Locked: -40},
+	}})
+	require.ErrorIs(t, err, pgdb.ErrNegativeBalance)
+	balances, err := a.ChangeBalances(ctx, pgdb.ChangeBalancesRequest{UserUID: user.UID, Changes: []pgdb.BalanceChange{
+		{Asset: "USDT", Free: 10, Locked: -30},
+		{Asset: "ETH", Free: 2},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, []*pgdb.Balance{{Asset: "USDT", Free: 80}, {Asset: "ETH", Free: 2}}, balances)
+	b, err = a.ReadBalance(ctx, pgdb.ReadBalanceRequest{UserUID: user.UID, Asset: "BTC"})
+	require.NoError(t, err)
+	require.Equal(t, &pgdb.Balance{Asset: "BTC", Free: 1}, b)
+
+	o, err := a.CreateOrder(ctx, pgdb.CreateOrderRequest{UserUID: user.UID, Symbol: "BTCUSDT", Status: "NEW", Time: 5})
+	require.NoError(t, err)
+	o, err = a.UpdateOrderStatus(ctx, pgdb.UpdateOrderStatusRequest{ID: o.ID, Status: "FILLED", UpdateTime: 6})
+	require.NoError(t, err)
+	orders, err := a.ReadOrders(ctx, pgdb.ReadOrdersRequest{UserUID: user.UID, Statuses: []string{"FILLED"}})
+	require.NoError(t, err)
+	require.Equal(t, []*pgdb.Order{o}, orders)
+
+	// The order status is not updated if its balance changes fail.
+	settled, err := a.CreateOrder(ctx, pgdb.CreateOrderRequest{UserUID: user.UID, Symbol: "BTCUSDT", Status: "NEW", Time: 7})
+	require.NoError(t, err)
+	_, err = a.SettleOrder(ctx, pgdb.SettleOrderRequest{
+		UserUID: user.UID,
+		Changes: []pgdb.BalanceChange{{Asset: "USDT", Free: -100}},
+		Status:  pgdb.UpdateOrderStatusRequest{ID: settled.ID, Status: "FILLED", UpdateTime: 8},
+	})
+	require.ErrorIs(t, err, pgdb.ErrNegativeBalance)
+	settled, err = a.ReadOrder(ctx, pgdb.ReadOrderRequest{ID: settled.ID})
+	require.NoError(t, err)
+	require.Equal(t, "NEW", settled.Status)
+	settled, err = a.SettleOrder(ctx, pgdb.SettleOrderRequest{
+		UserUID: user.UID,
+		Changes: []pgdb.BalanceChange{{Asset: "USDT", Free: -50}, {Asset: "BTC", Free: 0.5}},
+		Status:  pgdb.UpdateOrderStatusRequest{ID: settled.ID, Status: "FILLED", UpdateTime: 8},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "FILLED", settled.Status)
+	b, err = a.ReadBalance(ctx, pgdb.ReadBalanceRequest{UserUID: user.UID, Asset: "USDT"})
+	require.NoError(t, err)
+	require.Equal(t, &pgdb.Balance{Asset: "USDT", Free: 30}, b)
+
+	// Orders and balances are deleted with the user.
+	require.NoError(t, a.DeleteUser(ctx, pgdb.DeleteUserRequest{UID: user.UID}))
+	_, err = a.ReadUser(ctx, pgdb.ReadUserRequest{UID: user.UID})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+	_, err = a.ReadOrder(ctx, pgdb.ReadOrderRequest{ID: o.ID})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+	_, err = a.ReadBalance(ctx, pgdb.ReadBalanceRequest{UserUID: user.UID, Asset: "USDT"})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
@@ -0,0 +1,131 @@
+// Package memory keeps storage in memory, for tests and backtests that need
+// no database.
+package memory
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage"
+)
+
+type pair struct {
+	symbol, interval string
+}
+
+// KlineRepository is a storage.KlineRepository safe for concurrent use. Klines
+// are copied in and out, so callers may keep changing theirs.
+type KlineRepository struct {
+	mu     sync.RWMutex
+	klines map[pair][]*models.Kline
+}
+
+var _ storage.KlineRepository = (*KlineRepository)(nil)
+
+func NewKlineRepository() *KlineRepository {
+	return &KlineRepository{klines: make(map[pair][]*models.Kline)}
+}
+
+func parsePair(symbol, interval string) (pair, error) {
+	s, i, err := storage.ParsePair(symbol, interval)
+	return pair{symbol: s, interval: i}, err
+}
+
+// search returns the index of the first kline opened at or after openTime.
+func search(klines []*models.Kline, openTime int64) int {
+	i, _ := slices.BinarySearchFunc(klines, openTime, func(k *models.Kline, t int64) int {
+		switch {
+		case k.OpenTime < t:
+			return -1
+		case k.OpenTime > t:
+			return 1
+		}
+		return 0
+	})
+	return i
+}
+
+func (r *KlineRepository) ReadKlines(_ context.Context, req storage.ReadKlinesRequest) ([]*models.Kline, error) {
+	p, err := parsePair(req.Symbol, req.Interval)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	klines := r.klines[p]
+	res := make([]*models.Kline, 0, min(int(req.Limit), len(klines)))
+	skip := req.Offset
+	for _, k := range klines[search(klines, req.OpenTime):] {
+		if req.CloseTime > 0 && k.CloseTime > req.CloseTime {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		if req.Limit > 0 && uint64(len(res)) == req.Limit {
+			break
+		}
+		copied := *k
+		res = append(res, &copied)
+	}
+	return res, nil
+}
+
+func (r *KlineRepository) ReadLastKline(_ context.Context, req storage.ReadLastKlineRequest) (*models.Kline, error) {
+	p, err := parsePair(req.Symbol, req.Interval)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	klines := r.klines[p]
+	if len(klines) == 0 {
+		return nil, storage.ErrNoKlines
+	}
+	copied := *klines[len(klines)-1]
+	return &copied, nil
+}
+
+func (r *KlineRepository) WriteKlines(_ context.Context, req storage.WriteKlinesRequest) error {
+	p, err := parsePair(req.Symbol, req.Interval)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	klines := r.klines[p]
+	for _, k := range req.Klines {
+		copied := *k
+		i := search(klines, k.OpenTime)
+		switch {
+		case i < len(klines) && klines[i].OpenTime == k.OpenTime:
+			if req.Replace {
+				klines[i] = &copied
+			}
+		default:
+			klines = slices.Insert(klines, i, &copied)
+		}
+	}
+	r.klines[p] = klines
+	return nil
+}
+
+func (r *KlineRepository) DeleteKlines(_ context.Context, req storage.DeleteKlinesRequest) error {
+	p, err := parsePair(req.Symbol, req.Interval)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.klines[p] = slices.DeleteFunc(r.klines[p], func(k *models.Kline) bool {
+		return slices.Contains(req.OpenTimes, k.OpenTime)
+	})
+	return nil
+}
@@ -0,0 +1,11 @@
+package memory
+
+import (
+	"testing"
+
+	"crypto_bot/pkg/storage/storagetest"
+)
+
+func TestKlineRepository(t *testing.T) {
+	storagetest.TestKlineRepository(t, NewKlineRepository())
+}
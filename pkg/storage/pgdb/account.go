@@ -2,6 +2,8 @@ package pgdb
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	sq "github.com/Masterminds/squirrel"
 )
@@ -198,7 +200,7 @@ func (c *Client) UpdateBalance(ctx context.Context, r UpdateBalanceRequest) (*Ba
 		Update("balance").
 		Set("free", r.Free).
 		Set("locked", r.Locked).
-		Where(sq.Eq{"user_uid": r.UserUID}).
+		Where(sq.Eq{"user_uid": r.UserUID, "asset": r.Asset}).
 		PlaceholderFormat(sq.Dollar).
 		ToSql()
 	if err != nil {
@@ -207,7 +209,99 @@ func (c *Client) UpdateBalance(ctx context.Context, r UpdateBalanceRequest) (*Ba
 	if _, err = c.conn.Exec(ctx, queryStr, args...); err != nil {
 		return nil, err
 	}
-	return &Balance{Free: r.Free, Locked: r.Locked}, nil
+	return &Balance{Asset: r.Asset, Free: r.Free, Locked: r.Locked}, nil
+}
+
+// ChangeBalanceRequest holds the amounts to add to the free and locked parts of
+// the balance. Negative values are subtracted.
+type ChangeBalanceRequest struct {
+	UserUID int64
+	Asset   string
+	Free    float64
+	Locked  float64
+}
+
+func (c *Client) ChangeBalance(ctx context.Context, r ChangeBalanceRequest) (*Balance, error) {
+	return changeBalance(ctx, c.conn, r)
+}
+
+// ErrNegativeBalance is returned by ChangeBalances when a change would take
+// more than a balance holds.
+var ErrNegativeBalance = errors.New("balance would become negative")
+
+// balanceTolerance absorbs float rounding of amounts locked and released again.
+const balanceTolerance = 1e-9
+
+// BalanceChange holds the amounts to add to the free and locked parts of a
+// balance of the user of ChangeBalancesRequest.
+type BalanceChange struct {
+	Asset  string
+	Free   float64
+	Locked float64
+}
+
+type ChangeBalancesRequest struct {
+	UserUID int64
+	Changes []BalanceChange
+}
+
+// ChangeBalances applies every change in one transaction. Nothing is changed
+// and ErrNegativeBalance is returned if any balance would become negative.
+func (c *Client) ChangeBalances(ctx context.Context, r ChangeBalancesRequest) ([]*Balance, error) {
+	tx, err := c.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(ctx, tx)
+
+	balances, err := changeBalances(ctx, tx, r)
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+func changeBalances(ctx context.Context, q querier, r ChangeBalancesRequest) ([]*Balance, error) {
+	balances := make([]*Balance, len(r.Changes))
+	for i, change := range r.Changes {
+		b, err := changeBalance(ctx, q, ChangeBalanceRequest{
+			UserUID: r.UserUID,
+			Asset:   change.Asset,
+			Free:    change.Free,
+			Locked:  change.Locked,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if b.Free < -balanceTolerance || b.Locked < -balanceTolerance {
+			return nil, fmt.Errorf("%s: %w", b.Asset, ErrNegativeBalance)
+		}
+		balances[i] = b
+	}
+	return balances, nil
+}
+
+func changeBalance(ctx context.Context, q querier, r ChangeBalanceRequest) (*Balance, error) {
+	queryStr, args, err := sq.
+		Insert("balance").
+		Columns("user_uid", "asset", "free", "locked").
+		Values(r.UserUID, r.Asset, r.Free, r.Locked).
+		Suffix("ON CONFLICT (asset, user_uid) DO UPDATE " +
+			"SET free = balance.free + excluded.free, locked = balance.locked + excluded.locked " +
+			"RETURNING asset, free, locked").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	var balance Balance
+	if err = q.QueryRow(ctx, queryStr, args...).Scan(&balance.Asset, &balance.Free, &balance.Locked); err != nil {
+		return nil, err
+	}
+	return &balance, nil
 }
 
 type DeleteBalanceRequest struct {
@@ -218,7 +312,7 @@ type DeleteBalanceRequest struct {
 func (c *Client) DeleteBalance(ctx context.Context, r DeleteBalanceRequest) error {
 	queryStr, args, err := sq.
 		Delete("balance").
-		Where(sq.Eq{"user_id": r.UserUID, "asset": r.Asset}).
+		Where(sq.Eq{"user_uid": r.UserUID, "asset": r.Asset}).
 		PlaceholderFormat(sq.Dollar).
 		ToSql()
 	if err != nil {
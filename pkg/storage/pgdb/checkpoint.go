@@ -0,0 +1,65 @@
+package pgdb
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// GapCheckpoint records that klines of a pair have no gaps from From to
+// CheckedTo, so a gap fix job can resume after CheckedTo.
+type GapCheckpoint struct {
+	Symbol    string
+	Interval  string
+	From      int64
+	CheckedTo int64
+	UpdatedAt time.Time
+}
+
+type ReadGapCheckpointRequest struct {
+	Symbol   string
+	Interval string
+}
+
+// ReadGapCheckpoint returns pgx.ErrNoRows if the pair has no checkpoint.
+func (c *Client) ReadGapCheckpoint(ctx context.Context, req ReadGapCheckpointRequest) (*GapCheckpoint, error) {
+	query, args, err := sq.
+		Select("symbol", "kline_interval", "from_time", "checked_to", "updated_at").
+		From("gap_checkpoint").
+		Where(sq.Eq{"symbol": req.Symbol, "kline_interval": req.Interval}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	var cp GapCheckpoint
+	err = c.conn.QueryRow(ctx, query, args...).Scan(&cp.Symbol, &cp.Interval, &cp.From, &cp.CheckedTo, &cp.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+type WriteGapCheckpointRequest struct {
+	Symbol    string
+	Interval  string
+	From      int64
+	CheckedTo int64
+}
+
+func (c *Client) WriteGapCheckpoint(ctx context.Context, req WriteGapCheckpointRequest) error {
+	query, args, err := sq.
+		Insert("gap_checkpoint").
+		Columns("symbol", "kline_interval", "from_time", "checked_to").
+		Values(req.Symbol, req.Interval, req.From, req.CheckedTo).
+		Suffix(`on conflict (symbol, kline_interval) do update
+			set from_time = excluded.from_time, checked_to = excluded.checked_to, updated_at = now()`).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.Exec(ctx, query, args...)
+	return err
+}
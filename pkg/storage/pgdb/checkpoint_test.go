@@ -0,0 +1,33 @@
+package pgdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_GapCheckpoint(t *testing.T) {
+	c := testClient(t)
+	symbol := testSymbol(t, c, "1m")
+	ctx := context.Background()
+	t.Cleanup(func() {
+		_, err := c.conn.Exec(context.Background(), "delete from gap_checkpoint where symbol = $1", symbol)
+		require.NoError(t, err)
+	})
+
+	_, err := c.ReadGapCheckpoint(ctx, ReadGapCheckpointRequest{Symbol: symbol, Interval: "1m"})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	for _, checkedTo := range []int64{59_999, 119_999} {
+		err = c.WriteGapCheckpoint(ctx, WriteGapCheckpointRequest{Symbol: symbol, Interval: "1m", CheckedTo: checkedTo})
+		require.NoError(t, err)
+
+		cp, err := c.ReadGapCheckpoint(ctx, ReadGapCheckpointRequest{Symbol: symbol, Interval: "1m"})
+		require.NoError(t, err)
+		require.Equal(t, symbol, cp.Symbol)
+		require.Equal(t, int64(0), cp.From)
+		require.Equal(t, checkedTo, cp.CheckedTo)
+	}
+}
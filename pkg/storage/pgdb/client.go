@@ -6,18 +6,120 @@ import (
 	"strings"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"crypto_bot/pkg/exchange/models"
 )
 
+// undefinedTableCode is the postgres error code of a query to a missing table.
+const undefinedTableCode = "42P01"
+
+// querier is implemented by the pool, a connection acquired from it and a transaction.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// Client is safe for concurrent use: every method takes a connection from the
+// pool for its query or transaction and returns it when done.
 type Client struct {
-	conn *pgx.Conn
+	pool *pgxpool.Pool
+	conn querier
+
+	klineLayout KlineLayout
+	// aggregates are the intervals read from continuous aggregates.
+	aggregates map[string]bool
+}
+
+func NewClient(pool *pgxpool.Pool) *Client {
+	return &Client{pool: pool, conn: pool, klineLayout: KlineLayoutTables}
+}
+
+// SetKlineLayout sets the layout of kline tables, the tables layout by default.
+func (c *Client) SetKlineLayout(layout KlineLayout) *Client {
+	c.klineLayout = layout
+	return c
+}
+
+// Connect creates a client with a new connection pool. maxConns limits the pool
+// size, if it is zero the pool_max_conns parameter of connStr or the pgxpool
+// default is used. The kline layout is taken from the kline_layout parameter
+// of connStr, which is not sent to the server.
+func Connect(ctx context.Context, connStr string, maxConns int32) (*Client, error) {
+	cfg, layout, err := parseConfig(connStr, maxConns)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err = pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	c := NewClient(pool).SetKlineLayout(layout)
+	if layout == KlineLayoutHypertable {
+		if err = c.loadKlineAggregates(ctx); err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("load kline aggregates: %w", err)
+		}
+	}
+	return c, nil
+}
+
+func parseConfig(connStr string, maxConns int32) (*pgxpool.Config, KlineLayout, error) {
+	cfg, err := pgxpool.ParseConfig(connStr)
+	if err != nil {
+		return nil, "", fmt.Errorf("parse connection string: %w", err)
+	}
+	if maxConns > 0 {
+		cfg.MaxConns = maxConns
+	}
+	layout := KlineLayoutTables
+	// Unknown parameters are sent to the server as run-time parameters.
+	if v, ok := cfg.ConnConfig.RuntimeParams[klineLayoutParam]; ok {
+		delete(cfg.ConnConfig.RuntimeParams, klineLayoutParam)
+		if layout, err = ParseKlineLayout(v); err != nil {
+			return nil, "", err
+		}
+	}
+	return cfg, layout, nil
+}
+
+// Close closes the pool and waits for the connections to be returned.
+func (c *Client) Close() {
+	c.pool.Close()
 }
 
-func NewClient(conn *pgx.Conn) *Client {
-	return &Client{conn: conn}
+// klineTable returns the quoted name of the klines table of the symbol and interval.
+// Table names are lower case, so monthly klines get the 1mo suffix to not share
+// the table of one minute klines.
+func klineTable(symbol, interval string) (string, error) {
+	s, err := models.ParseSymbol(symbol)
+	if err != nil {
+		return "", err
+	}
+	i, err := models.ParseInterval(interval)
+	if err != nil {
+		return "", err
+	}
+	suffix := string(i)
+	if i == models.Interval1M {
+		suffix = "1mo"
+	}
+	return pgx.Identifier{"kline_" + strings.ToLower(string(s)) + "_" + suffix}.Sanitize(), nil
 }
 
-func createKlineTableIfNotExists(ctx context.Context, tx pgx.Tx, symbol, interval string) error {
-	_, err := tx.Exec(ctx, fmt.Sprintf("create table if not exists kline_%s_%s (like kline including all);",
-		strings.ToLower(symbol), strings.ToLower(interval)))
+func createKlineTableIfNotExists(ctx context.Context, tx pgx.Tx, table string) error {
+	// Concurrent "create table if not exists" of the same table may fail on a
+	// unique violation, so creations are serialized per table.
+	if _, err := tx.Exec(ctx, "select pg_advisory_xact_lock(hashtext($1))", table); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, fmt.Sprintf("create table if not exists %s (like kline including all);", table))
 	return err
 }
@@ -0,0 +1,126 @@
+package pgdb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testClient connects to the database of PGDB_TEST_CONN_STR and migrates it,
+// the test is skipped if it is not set.
+func testClient(t testing.TB) *Client {
+	connStr := os.Getenv("PGDB_TEST_CONN_STR")
+	if connStr == "" {
+		t.Skip("PGDB_TEST_CONN_STR is not set")
+	}
+	ctx := context.Background()
+	c, err := Connect(ctx, connStr, 8)
+	require.NoError(t, err)
+	t.Cleanup(c.Close)
+
+	_, err = c.MigrateUp(ctx, MigrateUpRequest{})
+	require.NoError(t, err)
+	return c
+}
+
+// testSymbol returns a symbol unique to the test and drops its klines after it.
+func testSymbol(t testing.TB, c *Client, interval string) string {
+	symbol := fmt.Sprintf("T%dUSDT", time.Now().UnixNano()%1e12)
+	table, err := klineTable(symbol, interval)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, err := c.conn.Exec(context.Background(), "drop table if exists "+table)
+		require.NoError(t, err)
+		_, err = c.conn.Exec(context.Background(), "delete from klines where symbol = $1", symbol)
+		require.NoError(t, err)
+	})
+	return symbol
+}
+
+func TestClient_Concurrent(t *testing.T) {
+	c := testClient(t)
+	symbol := testSymbol(t, c, "1m")
+	ctx := context.Background()
+
+	const writers, chunks, chunkSize = 8, 10, 50
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*chunks*2)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < chunks; i++ {
+				klines := make([]*Kline, chunkSize)
+				for j := range klines {
+					openTime := int64(((w*chunks+i)*chunkSize + j) * 60_000)
+					klines[j] = &Kline{OpenTime: openTime, CloseTime: openTime + 59_999, Close: float64(j)}
+				}
+				if _, err := c.WriteKlines(ctx, WriteKlinesRequest{Symbol: symbol, Interval: "1m", Klines: klines}); err != nil {
+					errs <- err
+				}
+				if _, err := c.ReadKlines(ctx, ReadKlinesRequest{Symbol: symbol, Interval: "1m", Limit: 100}); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	klines, err := c.ReadKlines(ctx, ReadKlinesRequest{Symbol: symbol, Interval: "1m"})
+	require.NoError(t, err)
+	require.Len(t, klines, writers*chunks*chunkSize)
+
+	last, err := c.ReadLastKline(ctx, ReadLastKlineRequest{Symbol: symbol, Interval: "1m"})
+	require.NoError(t, err)
+	require.Equal(t, klines[len(klines)-1], last)
+}
+
+func TestKlineTable(t *testing.T) {
+	testCases := []struct {
+		symbol, interval string
+		want             string
+		wantErr          bool
+	}{
+		{symbol: "BTCUSDT", interval: "1m", want: `"kline_btcusdt_1m"`},
+		{symbol: "ethusdt", interval: "4h", want: `"kline_ethusdt_4h"`},
+		{symbol: "BTCUSDT", interval: "1M", want: `"kline_btcusdt_1mo"`},
+		{symbol: "BTCUSDT", interval: "1H", wantErr: true},
+		{symbol: `btcusdt_1m"; drop table users; --`, interval: "1m", wantErr: true},
+		{symbol: "BTCUSDT", interval: "1m; drop table users", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.symbol+"_"+tc.interval, func(t *testing.T) {
+			got, err := klineTable(tc.symbol, tc.interval)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, layout, err := parseConfig("postgres://localhost/db?kline_layout=hypertable&application_name=watcher", 4)
+	require.NoError(t, err)
+	require.Equal(t, KlineLayoutHypertable, layout)
+	require.EqualValues(t, 4, cfg.MaxConns)
+	require.Equal(t, map[string]string{"application_name": "watcher"}, cfg.ConnConfig.RuntimeParams)
+
+	_, layout, err = parseConfig("postgres://localhost/db", 0)
+	require.NoError(t, err)
+	require.Equal(t, KlineLayoutTables, layout)
+
+	_, _, err = parseConfig("postgres://localhost/db?kline_layout=views", 0)
+	require.Error(t, err)
+}
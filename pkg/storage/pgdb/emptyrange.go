@@ -0,0 +1,77 @@
+package pgdb
+
+import (
+	"context"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// EmptyRange is a range from Start to End, both inclusive, that the exchange
+// has no klines for.
+type EmptyRange struct {
+	Start int64
+	End   int64
+}
+
+type ReadEmptyRangesRequest struct {
+	Symbol   string
+	Interval string
+	From     int64
+	To       int64
+}
+
+// ReadEmptyRanges returns the empty ranges overlapping From to To ordered by start.
+func (c *Client) ReadEmptyRanges(ctx context.Context, req ReadEmptyRangesRequest) ([]*EmptyRange, error) {
+	query, args, err := sq.
+		Select("start_time", "end_time").
+		From("empty_kline_range").
+		Where(sq.Eq{"symbol": req.Symbol, "kline_interval": req.Interval}).
+		Where(sq.LtOrEq{"start_time": req.To}).
+		Where(sq.GtOrEq{"end_time": req.From}).
+		OrderBy("start_time").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ranges []*EmptyRange
+	for rows.Next() {
+		var r EmptyRange
+		if err = rows.Scan(&r.Start, &r.End); err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, &r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return ranges, nil
+}
+
+type WriteEmptyRangeRequest struct {
+	Symbol   string
+	Interval string
+	Start    int64
+	End      int64
+}
+
+func (c *Client) WriteEmptyRange(ctx context.Context, req WriteEmptyRangeRequest) error {
+	query, args, err := sq.
+		Insert("empty_kline_range").
+		Columns("symbol", "kline_interval", "start_time", "end_time").
+		Values(req.Symbol, req.Interval, req.Start, req.End).
+		Suffix("on conflict (symbol, kline_interval, start_time) do update set end_time = greatest(empty_kline_range.end_time, excluded.end_time)").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.Exec(ctx, query, args...)
+	return err
+}
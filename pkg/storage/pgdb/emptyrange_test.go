@@ -0,0 +1,31 @@
+package pgdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_EmptyRanges(t *testing.T) {
+	c := testClient(t)
+	symbol := testSymbol(t, c, "1m")
+	ctx := context.Background()
+	t.Cleanup(func() {
+		_, err := c.conn.Exec(context.Background(), "delete from empty_kline_range where symbol = $1", symbol)
+		require.NoError(t, err)
+	})
+
+	for _, r := range []EmptyRange{{Start: 0, End: 59_999}, {Start: 0, End: 119_999}, {Start: 600_000, End: 659_999}} {
+		err := c.WriteEmptyRange(ctx, WriteEmptyRangeRequest{Symbol: symbol, Interval: "1m", Start: r.Start, End: r.End})
+		require.NoError(t, err)
+	}
+
+	ranges, err := c.ReadEmptyRanges(ctx, ReadEmptyRangesRequest{Symbol: symbol, Interval: "1m", From: 0, To: 1_000_000})
+	require.NoError(t, err)
+	require.Equal(t, []*EmptyRange{{Start: 0, End: 119_999}, {Start: 600_000, End: 659_999}}, ranges)
+
+	ranges, err = c.ReadEmptyRanges(ctx, ReadEmptyRangesRequest{Symbol: symbol, Interval: "1m", From: 120_000, To: 599_999})
+	require.NoError(t, err)
+	require.Empty(t, ranges)
+}
@@ -4,22 +4,50 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
 	"strings"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+var klineColumns = []string{
+	"open_time", "open", "high", "low", "close", "volume", "close_time", "trade_num",
+	"quote_asset_volume", "taker_buy_base_asset_volume", "taker_buy_quote_asset_volume",
+	"first_trade_id", "last_trade_id",
+}
+
 type Kline struct {
-	OpenTime  int64
-	Open      float64
-	High      float64
-	Low       float64
-	Close     float64
-	Volume    float64
-	CloseTime int64
-	TradeNum  int64
+	OpenTime                 int64
+	Open                     float64
+	High                     float64
+	Low                      float64
+	Close                    float64
+	Volume                   float64
+	CloseTime                int64
+	TradeNum                 int64
+	QuoteAssetVolume         float64
+	TakerBuyBaseAssetVolume  float64
+	TakerBuyQuoteAssetVolume float64
+	FirstTradeID             int64
+	LastTradeID              int64
+}
+
+func scanKline(row pgx.Row) (*Kline, error) {
+	var k Kline
+	err := row.Scan(&k.OpenTime, &k.Open, &k.High, &k.Low, &k.Close, &k.Volume, &k.CloseTime, &k.TradeNum,
+		&k.QuoteAssetVolume, &k.TakerBuyBaseAssetVolume, &k.TakerBuyQuoteAssetVolume, &k.FirstTradeID, &k.LastTradeID)
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (k *Kline) values() []any {
+	return []any{
+		k.OpenTime, k.Open, k.High, k.Low, k.Close, k.Volume, k.CloseTime, k.TradeNum,
+		k.QuoteAssetVolume, k.TakerBuyBaseAssetVolume, k.TakerBuyQuoteAssetVolume, k.FirstTradeID, k.LastTradeID,
+	}
 }
 
 type ReadKlineRequest struct {
@@ -29,29 +57,22 @@ type ReadKlineRequest struct {
 }
 
 func (c *Client) ReadKline(ctx context.Context, req ReadKlineRequest) (*Kline, error) {
-	tx, err := c.conn.Begin(ctx)
+	store, err := c.klineStore(req.Symbol, req.Interval)
 	if err != nil {
 		return nil, err
 	}
 
 	query, args, err := sq.
-		Select("open_time", "open", "high", "low", "close", "volume", "close_time", "trade_num").
-		From(fmt.Sprintf("kline_%s_%s", strings.ToLower(req.Symbol), strings.ToLower(req.Interval))).
+		Select(klineColumns...).
+		From(store.table).
+		Where(store.pair).
 		Where("open_time = ?", req.OpenTime).
 		PlaceholderFormat(sq.Dollar).
 		ToSql()
 	if err != nil {
 		return nil, err
 	}
-	kline := Kline{}
-	err = tx.
-		QueryRow(ctx, query, args...).
-		Scan(&kline.OpenTime, &kline.Open, &kline.High, &kline.Low, &kline.Close,
-			&kline.Volume, &kline.CloseTime, &kline.TradeNum)
-	if err != nil {
-		return nil, err
-	}
-	return &kline, nil
+	return scanKline(c.conn.QueryRow(ctx, query, args...))
 }
 
 type ReadKlinesRequest struct {
@@ -64,14 +85,15 @@ type ReadKlinesRequest struct {
 }
 
 func (c *Client) ReadKlines(ctx context.Context, req ReadKlinesRequest) ([]*Kline, error) {
-	tx, err := c.conn.Begin(ctx)
+	store, err := c.klineStore(req.Symbol, req.Interval)
 	if err != nil {
 		return nil, err
 	}
 
 	query := sq.
-		Select("open_time", "open", "high", "low", "close", "volume", "close_time", "trade_num").
-		From(fmt.Sprintf("kline_%s_%s", strings.ToLower(req.Symbol), strings.ToLower(req.Interval))).
+		Select(klineColumns...).
+		From(store.table).
+		Where(store.pair).
 		Where("open_time >= ?", req.OpenTime).
 		OrderBy("open_time").
 		PlaceholderFormat(sq.Dollar)
@@ -89,7 +111,7 @@ func (c *Client) ReadKlines(ctx context.Context, req ReadKlinesRequest) ([]*Klin
 		return nil, err
 	}
 
-	rows, err := tx.Query(ctx, queryStr, args...)
+	rows, err := c.conn.Query(ctx, queryStr, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -100,9 +122,7 @@ func (c *Client) ReadKlines(ctx context.Context, req ReadKlinesRequest) ([]*Klin
 		klines = make([]*Kline, 0, 100)
 	}
 	for rows.Next() {
-		kline := &Kline{}
-		err = rows.Scan(&kline.OpenTime, &kline.Open, &kline.High, &kline.Low, &kline.Close,
-			&kline.Volume, &kline.CloseTime, &kline.TradeNum)
+		kline, err := scanKline(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -111,6 +131,40 @@ func (c *Client) ReadKlines(ctx context.Context, req ReadKlinesRequest) ([]*Klin
 	return klines, rows.Err()
 }
 
+type ReadLastKlineRequest struct {
+	Symbol   string
+	Interval string
+}
+
+// ReadLastKline returns the kline with the latest open time. It returns pgx.ErrNoRows
+// if there are no klines of the symbol and interval, even if their table does not exist.
+func (c *Client) ReadLastKline(ctx context.Context, req ReadLastKlineRequest) (*Kline, error) {
+	store, err := c.klineStore(req.Symbol, req.Interval)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.
+		Select(klineColumns...).
+		From(store.table).
+		Where(store.pair).
+		OrderBy("open_time DESC").
+		Limit(1).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	kline, err := scanKline(c.conn.QueryRow(ctx, query, args...))
+	if pgErr := (*pgconn.PgError)(nil); errors.As(err, &pgErr) && pgErr.Code == undefinedTableCode {
+		return nil, pgx.ErrNoRows
+	}
+	if err != nil {
+		return nil, err
+	}
+	return kline, nil
+}
+
 type WriteKlineRequest struct {
 	Symbol   string
 	Interval string
@@ -118,25 +172,25 @@ type WriteKlineRequest struct {
 }
 
 func (c *Client) WriteKline(ctx context.Context, req WriteKlineRequest) (*Kline, error) {
-	tx, err := c.conn.Begin(ctx)
+	store, err := c.klineStore(req.Symbol, req.Interval)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		if err = tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
-			log.Printf("Rollback failed: %v", err)
-		}
-	}()
-	if err = createKlineTableIfNotExists(ctx, tx, req.Symbol, req.Interval); err != nil {
+	if store.aggregated {
+		return nil, aggregatedError(req.Symbol, req.Interval)
+	}
+
+	tx, err := c.beginWrite(ctx, store)
+	if err != nil {
 		return nil, err
 	}
+	defer rollback(ctx, tx)
 
 	query, args, err := sq.
-		Insert(fmt.Sprintf("kline_%s_%s", strings.ToLower(req.Symbol), strings.ToLower(req.Interval))).
-		Columns("open_time", "open", "high", "low", "close", "volume", "close_time", "trade_num").
-		Values(req.Kline.OpenTime, req.Kline.Open, req.Kline.High, req.Kline.Low, req.Kline.Close,
-			req.Kline.Volume, req.Kline.CloseTime, req.Kline.TradeNum).
-		Suffix("ON CONFLICT DO NOTHING").
+		Insert(store.table).
+		Columns(store.columns()...).
+		Values(store.values(req.Kline)...).
+		Suffix(store.onConflict(false)).
 		PlaceholderFormat(sq.Dollar).
 		ToSql()
 	if err != nil {
@@ -156,42 +210,128 @@ type WriteKlinesRequest struct {
 	Symbol   string
 	Interval string
 	Klines   []*Kline
+	// Replace overwrites stored klines with the same open time instead of
+	// skipping them, Klines must not repeat an open time then.
+	Replace bool
 }
 
+// copyThreshold is the number of klines from which WriteKlines copies them
+// instead of inserting with one statement.
+const copyThreshold = 1000
+
+// WriteKlines writes klines skipping the ones already stored, unless req.Replace
+// is set. Klines of intervals aggregated by TimescaleDB can not be written and
+// fail with storage.ErrAggregatedInterval. Batches of at least
+// copyThreshold klines are copied into a staging table and merged from it, which
+// is faster and not limited by the number of query parameters.
 func (c *Client) WriteKlines(ctx context.Context, req WriteKlinesRequest) ([]*Kline, error) {
-	tx, err := c.conn.Begin(ctx)
-	if err != nil {
+	if err := c.writeKlines(ctx, req, len(req.Klines) >= copyThreshold); err != nil {
 		return nil, err
 	}
-	defer func() {
-		if err = tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
-			log.Printf("Rollback failed: %v", err)
-		}
-	}()
-	if err = createKlineTableIfNotExists(ctx, tx, req.Symbol, req.Interval); err != nil {
-		return nil, err
+	return req.Klines, nil
+}
+
+func (c *Client) writeKlines(ctx context.Context, req WriteKlinesRequest, useCopy bool) error {
+	store, err := c.klineStore(req.Symbol, req.Interval)
+	if err != nil {
+		return err
+	}
+	if store.aggregated {
+		return aggregatedError(req.Symbol, req.Interval)
+	}
+	if len(req.Klines) == 0 {
+		return nil
 	}
+	tx, err := c.beginWrite(ctx, store)
+	if err != nil {
+		return err
+	}
+	defer rollback(ctx, tx)
 
+	if useCopy {
+		err = copyKlines(ctx, tx, store, req.Klines, req.Replace)
+	} else {
+		err = insertKlines(ctx, tx, store, req.Klines, req.Replace)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func insertKlines(ctx context.Context, tx pgx.Tx, store *klineStore, klines []*Kline, replace bool) error {
 	query := sq.
-		Insert(fmt.Sprintf("kline_%s_%s", strings.ToLower(req.Symbol), strings.ToLower(req.Interval))).
-		Columns("open_time", "open", "high", "low", "close", "volume", "close_time", "trade_num").
-		Suffix("ON CONFLICT DO NOTHING").
+		Insert(store.table).
+		Columns(store.columns()...).
+		Suffix(store.onConflict(replace)).
 		PlaceholderFormat(sq.Dollar)
-
-	for _, kline := range req.Klines {
-		query = query.Values(
-			kline.OpenTime, kline.Open, kline.High, kline.Low, kline.Close,
-			kline.Volume, kline.CloseTime, kline.TradeNum)
+	for _, kline := range klines {
+		query = query.Values(store.values(kline)...)
 	}
 	queryStr, args, err := query.ToSql()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	if _, err = tx.Exec(ctx, queryStr, args...); err != nil {
-		return nil, err
+	_, err = tx.Exec(ctx, queryStr, args...)
+	return err
+}
+
+// copyKlines copies klines into a staging table dropped with the transaction
+// and merges them into the table.
+func copyKlines(ctx context.Context, tx pgx.Tx, store *klineStore, klines []*Kline, replace bool) error {
+	const staging = "kline_staging"
+	_, err := tx.Exec(ctx, fmt.Sprintf("create temp table %s (like kline including defaults) on commit drop", staging))
+	if err != nil {
+		return fmt.Errorf("create staging table: %w", err)
 	}
-	if err = tx.Commit(ctx); err != nil {
-		return nil, err
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{staging}, klineColumns,
+		pgx.CopyFromSlice(len(klines), func(i int) ([]any, error) {
+			return klines[i].values(), nil
+		}))
+	if err != nil {
+		return fmt.Errorf("copy klines: %w", err)
 	}
-	return req.Klines, nil
+
+	// The symbol and interval of a shared table are the first parameters.
+	selected := klineColumns
+	for i := len(store.pairValues); i > 0; i-- {
+		selected = append([]string{fmt.Sprintf("$%d", i)}, selected...)
+	}
+	_, err = tx.Exec(ctx, fmt.Sprintf("insert into %s (%s) select %s from %s %s",
+		store.table, strings.Join(store.columns(), ", "), strings.Join(selected, ", "), staging, store.onConflict(replace)),
+		store.pairValues...)
+	if err != nil {
+		return fmt.Errorf("merge klines: %w", err)
+	}
+	return nil
+}
+
+type DeleteKlinesRequest struct {
+	Symbol    string
+	Interval  string
+	OpenTimes []int64
+}
+
+func (c *Client) DeleteKlines(ctx context.Context, req DeleteKlinesRequest) error {
+	store, err := c.klineStore(req.Symbol, req.Interval)
+	if err != nil {
+		return err
+	}
+	if store.aggregated {
+		return aggregatedError(req.Symbol, req.Interval)
+	}
+	if len(req.OpenTimes) == 0 {
+		return nil
+	}
+	query, args, err := sq.
+		Delete(store.table).
+		Where(store.pair).
+		Where(sq.Eq{"open_time": req.OpenTimes}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.Exec(ctx, query, args...)
+	return err
 }
@@ -0,0 +1,152 @@
+package pgdb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testKlines(from, n int) []*Kline {
+	klines := make([]*Kline, n)
+	for i := range klines {
+		openTime := int64((from + i) * 60_000)
+		price := float64(100 + from + i)
+		klines[i] = &Kline{
+			OpenTime: openTime, Open: price, High: price + 1, Low: price - 1, Close: price + 0.5,
+			Volume: 10, CloseTime: openTime + 59_999, TradeNum: 5, QuoteAssetVolume: 1000,
+			TakerBuyBaseAssetVolume: 4, TakerBuyQuoteAssetVolume: 400, FirstTradeID: 1, LastTradeID: 5,
+		}
+	}
+	return klines
+}
+
+type writeCase struct {
+	layout  KlineLayout
+	useCopy bool
+}
+
+// writeCases are the cases of writing klines with and without copying them in
+// every layout.
+var writeCases = []writeCase{
+	{layout: KlineLayoutTables},
+	{layout: KlineLayoutTables, useCopy: true},
+	{layout: KlineLayoutHypertable},
+	{layout: KlineLayoutHypertable, useCopy: true},
+}
+
+func TestClient_WriteKlines(t *testing.T) {
+	c := testClient(t)
+	ctx := context.Background()
+
+	for _, tc := range writeCases {
+		t.Run(fmt.Sprintf("%s/copy=%t", tc.layout, tc.useCopy), func(t *testing.T) {
+			c.SetKlineLayout(tc.layout)
+			useCopy := tc.useCopy
+			symbol := testSymbol(t, c, "1m")
+			req := WriteKlinesRequest{Symbol: symbol, Interval: "1m", Klines: testKlines(0, 100)}
+			require.NoError(t, c.writeKlines(ctx, req, useCopy))
+
+			// Stored klines are kept as they are.
+			klines := testKlines(50, 100)
+			klines[0].Close = -1
+			req.Klines = append(klines, klines[len(klines)-1])
+			require.NoError(t, c.writeKlines(ctx, req, useCopy))
+
+			got, err := c.ReadKlines(ctx, ReadKlinesRequest{Symbol: symbol, Interval: "1m"})
+			require.NoError(t, err)
+			require.Equal(t, testKlines(0, 150), got)
+		})
+	}
+}
+
+func TestClient_WriteKlines_Replace(t *testing.T) {
+	c := testClient(t)
+	ctx := context.Background()
+
+	for _, tc := range writeCases {
+		t.Run(fmt.Sprintf("%s/copy=%t", tc.layout, tc.useCopy), func(t *testing.T) {
+			c.SetKlineLayout(tc.layout)
+			useCopy := tc.useCopy
+			symbol := testSymbol(t, c, "1m")
+			req := WriteKlinesRequest{Symbol: symbol, Interval: "1m", Klines: testKlines(0, 10)}
+			require.NoError(t, c.writeKlines(ctx, req, useCopy))
+
+			replaced := testKlines(5, 10)
+			for _, k := range replaced {
+				k.Close = -1
+			}
+			req = WriteKlinesRequest{Symbol: symbol, Interval: "1m", Klines: replaced, Replace: true}
+			require.NoError(t, c.writeKlines(ctx, req, useCopy))
+
+			got, err := c.ReadKlines(ctx, ReadKlinesRequest{Symbol: symbol, Interval: "1m"})
+			require.NoError(t, err)
+			require.Equal(t, append(testKlines(0, 5), replaced...), got)
+		})
+	}
+}
+
+func TestClient_DeleteKlines(t *testing.T) {
+	c := testClient(t).SetKlineLayout(KlineLayoutHypertable)
+	symbol := testSymbol(t, c, "1m")
+	ctx := context.Background()
+
+	_, err := c.WriteKlines(ctx, WriteKlinesRequest{Symbol: symbol, Interval: "1m", Klines: testKlines(0, 5)})
+	require.NoError(t, err)
+	err = c.DeleteKlines(ctx, DeleteKlinesRequest{Symbol: symbol, Interval: "1m", OpenTimes: []int64{60_000, 180_000}})
+	require.NoError(t, err)
+
+	got, err := c.ReadKlines(ctx, ReadKlinesRequest{Symbol: symbol, Interval: "1m"})
+	require.NoError(t, err)
+	all := testKlines(0, 5)
+	require.Equal(t, []*Kline{all[0], all[2], all[4]}, got)
+}
+
+func TestClient_ReadKlines_Hypertable(t *testing.T) {
+	c := testClient(t).SetKlineLayout(KlineLayoutHypertable)
+	ctx := context.Background()
+	symbol := testSymbol(t, c, "1m")
+	other := testSymbol(t, c, "1m")
+
+	// Klines of other symbols and intervals share the table.
+	_, err := c.WriteKlines(ctx, WriteKlinesRequest{Symbol: symbol, Interval: "1m", Klines: testKlines(0, 5)})
+	require.NoError(t, err)
+	_, err = c.WriteKlines(ctx, WriteKlinesRequest{Symbol: symbol, Interval: "3m", Klines: testKlines(10, 1)})
+	require.NoError(t, err)
+	_, err = c.WriteKlines(ctx, WriteKlinesRequest{Symbol: other, Interval: "1m", Klines: testKlines(20, 1)})
+	require.NoError(t, err)
+
+	got, err := c.ReadKlines(ctx, ReadKlinesRequest{Symbol: symbol, Interval: "1m"})
+	require.NoError(t, err)
+	require.Equal(t, testKlines(0, 5), got)
+	last, err := c.ReadLastKline(ctx, ReadLastKlineRequest{Symbol: symbol, Interval: "1m"})
+	require.NoError(t, err)
+	require.Equal(t, testKlines(4, 1)[0], last)
+	k, err := c.ReadKline(ctx, ReadKlineRequest{Symbol: symbol, Interval: "3m", OpenTime: 600_000})
+	require.NoError(t, err)
+	require.Equal(t, testKlines(10, 1)[0], k)
+}
+
+// BenchmarkClient_WriteKlines compares inserting klines with one statement and
+// copying them, run it with PGDB_TEST_CONN_STR set.
+func BenchmarkClient_WriteKlines(b *testing.B) {
+	for _, size := range []int{100, 1000, 5000} {
+		for _, useCopy := range []bool{false, true} {
+			b.Run(fmt.Sprintf("size=%d/copy=%t", size, useCopy), func(b *testing.B) {
+				c := testClient(b)
+				symbol := testSymbol(b, c, "1m")
+				ctx := context.Background()
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					req := WriteKlinesRequest{Symbol: symbol, Interval: "1m", Klines: testKlines(i*size, size)}
+					if err := c.writeKlines(ctx, req, useCopy); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(b.N*size)/b.Elapsed().Seconds(), "rows/s")
+			})
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage"
+)
+
+// KlineLayout is how klines are laid out in tables.
+type KlineLayout string
+
+const (
+	// KlineLayoutTables stores the klines of every symbol and interval in a
+	// kline_<symbol>_<interval> table of their own.
+	KlineLayoutTables KlineLayout = "tables"
+	// KlineLayoutHypertable stores every kline in the klines table, keyed by
+	// symbol, interval and open time. The migration creating it makes it a
+	// compressed TimescaleDB hypertable when the extension is available.
+	KlineLayoutHypertable KlineLayout = "hypertable"
+)
+
+// klineLayoutParam is the connection string parameter Connect takes the kline
+// layout from, like kline_layout=hypertable.
+const klineLayoutParam = "kline_layout"
+
+func ParseKlineLayout(s string) (KlineLayout, error) {
+	switch l := KlineLayout(s); l {
+	case KlineLayoutTables, KlineLayoutHypertable:
+		return l, nil
+	}
+	return "", fmt.Errorf("unknown kline layout %q, expected tables or hypertable", s)
+}
+
+// hypertable is the table of the hypertable layout.
+const hypertable = "klines"
+
+// aggregatedIntervals are built from 1m klines by continuous aggregates named
+// klines_<interval> when the hypertable layout runs on TimescaleDB.
+var aggregatedIntervals = []string{"5m", "15m", "1h", "4h", "1d"}
+
+// klineStore is where the klines of a symbol and interval are stored: a table
+// of their own or rows of a table shared by every symbol and interval.
+type klineStore struct {
+	table string
+	// pair selects the rows of the symbol and interval in a shared table, it
+	// is nil for a table of their own.
+	pair sq.Sqlizer
+	// pairValues are the symbol and interval columns of rows of a shared table.
+	pairValues []any
+	// aggregated klines are read from a continuous aggregate of 1m klines,
+	// writes and deletes of them fail with storage.ErrAggregatedInterval.
+	aggregated bool
+}
+
+// AggregatedInterval reports whether klines of the interval are read from a
+// continuous aggregate of 1m klines and can not be written.
+func (c *Client) AggregatedInterval(interval string) bool {
+	return c.klineLayout == KlineLayoutHypertable && c.aggregates[interval]
+}
+
+func aggregatedError(symbol, interval string) error {
+	return fmt.Errorf("%s %s: %w", symbol, interval, storage.ErrAggregatedInterval)
+}
+
+func (c *Client) klineStore(symbol, interval string) (*klineStore, error) {
+	if c.klineLayout != KlineLayoutHypertable {
+		table, err := klineTable(symbol, interval)
+		if err != nil {
+			return nil, err
+		}
+		return &klineStore{table: table}, nil
+	}
+
+	s, err := models.ParseSymbol(symbol)
+	if err != nil {
+		return nil, err
+	}
+	i, err := models.ParseInterval(interval)
+	if err != nil {
+		return nil, err
+	}
+	if c.aggregates[string(i)] {
+		return &klineStore{
+			table:      pgx.Identifier{hypertable + "_" + string(i)}.Sanitize(),
+			pair:       sq.Eq{"symbol": string(s)},
+			aggregated: true,
+		}, nil
+	}
+	return &klineStore{
+		table:      hypertable,
+		pair:       sq.Eq{"symbol": string(s), "kline_interval": string(i)},
+		pairValues: []any{string(s), string(i)},
+	}, nil
+}
+
+// columns returns the columns written to the table.
+func (s *klineStore) columns() []string {
+	if s.pair == nil {
+		return klineColumns
+	}
+	return append([]string{"symbol", "kline_interval"}, klineColumns...)
+}
+
+// values returns the values of k for columns.
+func (s *klineStore) values(k *Kline) []any {
+	return append(append([]any{}, s.pairValues...), k.values()...)
+}
+
+// onConflict returns the conflict clause of kline inserts.
+func (s *klineStore) onConflict(replace bool) string {
+	if !replace {
+		return "ON CONFLICT DO NOTHING"
+	}
+	set := make([]string, 0, len(klineColumns)-1)
+	for _, column := range klineColumns[1:] {
+		set = append(set, fmt.Sprintf("%s = excluded.%s", column, column))
+	}
+	key := "open_time"
+	if s.pair != nil {
+		key = "symbol, kline_interval, open_time"
+	}
+	return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", key, strings.Join(set, ", "))
+}
+
+// beginWrite begins the transaction of a write, creating the table of the
+// klines if they have one of their own.
+func (c *Client) beginWrite(ctx context.Context, s *klineStore) (pgx.Tx, error) {
+	tx, err := c.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s.pair == nil {
+		if err = createKlineTableIfNotExists(ctx, tx, s.table); err != nil {
+			rollback(ctx, tx)
+			return nil, err
+		}
+	}
+	return tx, nil
+}
+
+func rollback(ctx context.Context, tx pgx.Tx) {
+	if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+		log.Printf("Rollback failed: %v", err)
+	}
+}
+
+// loadKlineAggregates finds the continuous aggregates of the hypertable layout.
+func (c *Client) loadKlineAggregates(ctx context.Context) error {
+	rows, err := c.conn.Query(ctx,
+		"select i from unnest($1::text[]) as i where to_regclass($2 || '_' || i) is not null",
+		aggregatedIntervals, hypertable)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	c.aggregates = make(map[string]bool)
+	for rows.Next() {
+		var interval string
+		if err = rows.Scan(&interval); err != nil {
+			return err
+		}
+		c.aggregates[interval] = true
+	}
+	return rows.Err()
+}
+
+// MovedKlineTable is a kline_<symbol>_<interval> table moved into the klines table.
+type MovedKlineTable struct {
+	Table    string
+	Symbol   string
+	Interval string
+	Klines   int64
+}
+
+type MoveKlineTablesRequest struct {
+	// Drop drops every table once its klines are moved.
+	Drop bool
+}
+
+// MoveKlineTables copies the klines of the tables layout into the klines table
+// of the hypertable layout, one table per transaction, skipping the ones
+// already there. Tables not named after a symbol and interval are left alone.
+func (c *Client) MoveKlineTables(ctx context.Context, req MoveKlineTablesRequest) ([]*MovedKlineTable, error) {
+	rows, err := c.conn.Query(ctx, `select table_name
+		from information_schema.tables
+		where table_schema = current_schema()
+		  and table_type = 'BASE TABLE'
+		  and table_name like 'kline\_%'
+		order by table_name`)
+	if err != nil {
+		return nil, err
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	var moved []*MovedKlineTable
+	for _, name := range names {
+		symbol, interval, ok := parseKlineTable(name)
+		if !ok {
+			continue
+		}
+		m := &MovedKlineTable{Table: name, Symbol: symbol, Interval: interval}
+		if m.Klines, err = c.moveKlineTable(ctx, m, req.Drop); err != nil {
+			return moved, fmt.Errorf("move %s: %w", name, err)
+		}
+		moved = append(moved, m)
+	}
+	return moved, nil
+}
+
+func (c *Client) moveKlineTable(ctx context.Context, m *MovedKlineTable, drop bool) (int64, error) {
+	tx, err := c.conn.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer rollback(ctx, tx)
+
+	table := pgx.Identifier{m.Table}.Sanitize()
+	columns := strings.Join(klineColumns, ", ")
+	tag, err := tx.Exec(ctx, fmt.Sprintf("insert into %s (symbol, kline_interval, %s) select $1, $2, %s from %s ON CONFLICT DO NOTHING",
+		hypertable, columns, columns, table), m.Symbol, m.Interval)
+	if err != nil {
+		return 0, err
+	}
+	if drop {
+		if _, err = tx.Exec(ctx, "drop table "+table); err != nil {
+			return 0, err
+		}
+	}
+	return tag.RowsAffected(), tx.Commit(ctx)
+}
+
+// parseKlineTable returns the symbol and interval of a kline table name, the
+// reverse of klineTable.
+func parseKlineTable(name string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(name, "kline_")
+	if !ok {
+		return "", "", false
+	}
+	i := strings.LastIndex(rest, "_")
+	if i < 0 {
+		return "", "", false
+	}
+	symbol, interval := strings.ToUpper(rest[:i]), rest[i+1:]
+	if interval == "1mo" {
+		interval = string(models.Interval1M)
+	}
+	if _, err := models.ParseSymbol(symbol); err != nil {
+		return "", "", false
+	}
+	if _, err := models.ParseInterval(interval); err != nil {
+		return "", "", false
+	}
+	return symbol, interval, true
+}
@@ -0,0 +1,103 @@
+package pgdb
+
+import (
+	"context"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/require"
+
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage"
+)
+
+func TestClient_klineStore(t *testing.T) {
+	c := NewClient(nil)
+	store, err := c.klineStore("btcusdt", "1M")
+	require.NoError(t, err)
+	require.Equal(t, &klineStore{table: `"kline_btcusdt_1mo"`}, store)
+	require.Equal(t, klineColumns, store.columns())
+	require.Equal(t, "ON CONFLICT DO NOTHING", store.onConflict(false))
+
+	c.SetKlineLayout(KlineLayoutHypertable)
+	store, err = c.klineStore("btcusdt", "5m")
+	require.NoError(t, err)
+	require.Equal(t, &klineStore{
+		table:      "klines",
+		pair:       sq.Eq{"symbol": "BTCUSDT", "kline_interval": "5m"},
+		pairValues: []any{"BTCUSDT", "5m"},
+	}, store)
+	require.Equal(t, append([]string{"symbol", "kline_interval"}, klineColumns...), store.columns())
+	require.Equal(t, []any{"BTCUSDT", "5m", int64(0), 1.0, 0.0, 0.0, 0.0, 0.0, int64(0), int64(0), 0.0, 0.0, 0.0, int64(0), int64(0)},
+		store.values(&Kline{Open: 1}))
+	require.Contains(t, store.onConflict(true), "ON CONFLICT (symbol, kline_interval, open_time) DO UPDATE SET open = excluded.open,")
+
+	c.aggregates = map[string]bool{"5m": true}
+	store, err = c.klineStore("BTCUSDT", "5m")
+	require.NoError(t, err)
+	require.Equal(t, &klineStore{table: `"klines_5m"`, pair: sq.Eq{"symbol": "BTCUSDT"}, aggregated: true}, store)
+	require.True(t, c.AggregatedInterval("5m"))
+	require.False(t, c.AggregatedInterval("1m"))
+	// Writes of aggregated klines fail without touching the db.
+	ctx := context.Background()
+	err = c.writeKlines(ctx, WriteKlinesRequest{Symbol: "BTCUSDT", Interval: "5m", Klines: testKlines(0, 1)}, false)
+	require.ErrorIs(t, err, storage.ErrAggregatedInterval)
+	_, err = c.WriteKline(ctx, WriteKlineRequest{Symbol: "BTCUSDT", Interval: "5m", Kline: testKlines(0, 1)[0]})
+	require.ErrorIs(t, err, storage.ErrAggregatedInterval)
+	err = c.DeleteKlines(ctx, DeleteKlinesRequest{Symbol: "BTCUSDT", Interval: "5m", OpenTimes: []int64{0}})
+	require.ErrorIs(t, err, storage.ErrAggregatedInterval)
+	err = c.Klines().WriteKlines(ctx, storage.WriteKlinesRequest{Symbol: "BTCUSDT", Interval: "5m", Klines: []*models.Kline{{}}})
+	require.ErrorIs(t, err, storage.ErrAggregatedInterval)
+
+	_, err = c.klineStore("BTCUSDT", "1H")
+	require.Error(t, err)
+}
+
+func TestParseKlineTable(t *testing.T) {
+	testCases := []struct {
+		name             string
+		symbol, interval string
+		ok               bool
+	}{
+		{name: "kline_btcusdt_1m", symbol: "BTCUSDT", interval: "1m", ok: true},
+		{name: "kline_btcusdt_1mo", symbol: "BTCUSDT", interval: "1M", ok: true},
+		{name: "kline_staging"},
+		{name: "kline_btcusdt_2m"},
+		{name: "klines_5m"},
+	}
+	for _, tc := range testCases {
+		symbol, interval, ok := parseKlineTable(tc.name)
+		require.Equal(t, tc.ok, ok, tc.name)
+		require.Equal(t, tc.symbol, symbol, tc.name)
+		require.Equal(t, tc.interval, interval, tc.name)
+		if ok {
+			table, err := klineTable(symbol, interval)
+			require.NoError(t, err)
+			require.Equal(t, `"`+tc.name+`"`, table)
+		}
+	}
+}
+
+func TestClient_MoveKlineTables(t *testing.T) {
+	c := testClient(t)
+	ctx := context.Background()
+	symbol := testSymbol(t, c, "1m")
+	_, err := c.WriteKlines(ctx, WriteKlinesRequest{Symbol: symbol, Interval: "1m", Klines: testKlines(0, 3)})
+	require.NoError(t, err)
+
+	// Other tests' tables are moved too, so none are dropped.
+	moved, err := c.MoveKlineTables(ctx, MoveKlineTablesRequest{})
+	require.NoError(t, err)
+	var found bool
+	for _, m := range moved {
+		if m.Symbol == symbol {
+			found = true
+			require.Equal(t, &MovedKlineTable{Table: m.Table, Symbol: symbol, Interval: "1m", Klines: 3}, m)
+		}
+	}
+	require.True(t, found)
+
+	got, err := c.SetKlineLayout(KlineLayoutHypertable).ReadKlines(ctx, ReadKlinesRequest{Symbol: symbol, Interval: "1m"})
+	require.NoError(t, err)
+	require.Equal(t, testKlines(0, 3), got)
+}
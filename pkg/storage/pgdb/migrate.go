@@ -0,0 +1,301 @@
+package pgdb
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// migrationLockID is the advisory lock key held while migrating, so two
+// processes never migrate the same database at once.
+const migrationLockID = 7_295_386_104
+
+// migrationName matches flyway style names: V0001__Init.sql applies a
+// migration and U0001__Init.sql reverts it.
+var migrationName = regexp.MustCompile(`^([VU])(\d+)__(\w+)\.sql$`)
+
+type Migration struct {
+	Version     int
+	Description string
+	Up          string
+	Down        string
+}
+
+type MigrationStatus struct {
+	*Migration
+	// AppliedAt is zero if the migration is not applied.
+	AppliedAt time.Time
+}
+
+// Migrations returns the embedded migrations ordered by version.
+func Migrations() ([]*Migration, error) {
+	return parseMigrations(migrationsFS, "migrations")
+}
+
+func parseMigrations(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		m := migrationName.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("unexpected migration file name %q", e.Name())
+		}
+		version, err := strconv.Atoi(m[2])
+		if err != nil {
+			return nil, fmt.Errorf("parse migration version %q: %w", e.Name(), err)
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Description: strings.ReplaceAll(m[3], "_", " ")}
+			byVersion[version] = migration
+		}
+		if migration.Description != strings.ReplaceAll(m[3], "_", " ") {
+			return nil, fmt.Errorf("migration %d has different names", version)
+		}
+		if m[1] == "V" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d has no V file", m.Version)
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+type MigrateUpRequest struct {
+	// Version to migrate up to, all migrations are applied if it is zero.
+	Version int
+}
+
+// MigrateUp applies the migrations that are not applied yet, each in its own
+// transaction, and returns them.
+func (c *Client) MigrateUp(ctx context.Context, req MigrateUpRequest) ([]*Migration, error) {
+	var applied []*Migration
+	err := c.withMigrationLock(ctx, func(c *Client, statuses []*MigrationStatus) error {
+		for _, s := range statuses {
+			if !s.AppliedAt.IsZero() || (req.Version > 0 && s.Version > req.Version) {
+				continue
+			}
+			if err := c.runMigration(ctx, s.Migration, true); err != nil {
+				return err
+			}
+			applied = append(applied, s.Migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+type MigrateDownRequest struct {
+	// Steps is the number of the latest applied migrations to revert.
+	Steps int
+}
+
+// MigrateDown reverts the latest applied migrations and returns them.
+func (c *Client) MigrateDown(ctx context.Context, req MigrateDownRequest) ([]*Migration, error) {
+	var reverted []*Migration
+	err := c.withMigrationLock(ctx, func(c *Client, statuses []*MigrationStatus) error {
+		for i := len(statuses) - 1; i >= 0 && len(reverted) < req.Steps; i-- {
+			s := statuses[i]
+			if s.AppliedAt.IsZero() {
+				continue
+			}
+			if s.Down == "" {
+				return fmt.Errorf("migration %d can not be reverted", s.Version)
+			}
+			if err := c.runMigration(ctx, s.Migration, false); err != nil {
+				return err
+			}
+			reverted = append(reverted, s.Migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+type BaselineRequest struct {
+	Version int
+}
+
+// Baseline marks migrations up to the version applied without running them,
+// for databases created before migrations were tracked.
+func (c *Client) Baseline(ctx context.Context, req BaselineRequest) error {
+	return c.withMigrationLock(ctx, func(c *Client, statuses []*MigrationStatus) error {
+		for _, s := range statuses {
+			if !s.AppliedAt.IsZero() || s.Version > req.Version {
+				continue
+			}
+			if err := markMigration(ctx, c.conn, s.Migration, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MigrationsStatus returns every known migration and when it was applied.
+func (c *Client) MigrationsStatus(ctx context.Context) ([]*MigrationStatus, error) {
+	if err := c.createMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+	return c.migrationsStatus(ctx)
+}
+
+// withMigrationLock runs f with a client bound to one connection, as the
+// advisory lock belongs to the session that took it.
+func (c *Client) withMigrationLock(ctx context.Context, f func(*Client, []*MigrationStatus) error) (err error) {
+	conn, err := c.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	c = &Client{pool: c.pool, conn: conn}
+
+	if _, err = c.conn.Exec(ctx, "select pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("lock migrations: %w", err)
+	}
+	defer func() {
+		if _, unlockErr := c.conn.Exec(ctx, "select pg_advisory_unlock($1)", migrationLockID); unlockErr != nil {
+			err = errors.Join(err, fmt.Errorf("unlock migrations: %w", unlockErr))
+		}
+	}()
+
+	if err = c.createMigrationsTable(ctx); err != nil {
+		return err
+	}
+	statuses, err := c.migrationsStatus(ctx)
+	if err != nil {
+		return err
+	}
+	return f(c, statuses)
+}
+
+func (c *Client) createMigrationsTable(ctx context.Context) error {
+	_, err := c.conn.Exec(ctx, `create table if not exists schema_migrations
+(
+    version     integer primary key,
+    description varchar     not null,
+    applied_at  timestamptz not null default now()
+)`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) migrationsStatus(ctx context.Context) ([]*MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := sq.
+		Select("version", "applied_at").
+		From("schema_migrations").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]*MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		statuses[i] = &MigrationStatus{Migration: m, AppliedAt: applied[m.Version]}
+	}
+	return statuses, nil
+}
+
+func (c *Client) runMigration(ctx context.Context, m *Migration, up bool) error {
+	tx, err := c.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err = tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Printf("Rollback failed: %v", err)
+		}
+	}()
+
+	sql := m.Up
+	if !up {
+		sql = m.Down
+	}
+	if _, err = tx.Exec(ctx, sql); err != nil {
+		return fmt.Errorf("migration %d %s: %w", m.Version, m.Description, err)
+	}
+	if err = markMigration(ctx, tx, m, up); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func markMigration(ctx context.Context, q querier, m *Migration, applied bool) error {
+	var query sq.Sqlizer = sq.
+		Insert("schema_migrations").
+		Columns("version", "description").
+		Values(m.Version, m.Description).
+		PlaceholderFormat(sq.Dollar)
+	if !applied {
+		query = sq.
+			Delete("schema_migrations").
+			Where(sq.Eq{"version": m.Version}).
+			PlaceholderFormat(sq.Dollar)
+	}
+	queryStr, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err = q.Exec(ctx, queryStr, args...); err != nil {
+		return fmt.Errorf("mark migration %d: %w", m.Version, err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package pgdb
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	for i, m := range migrations {
+		require.Equal(t, i+1, m.Version)
+		require.NotEmpty(t, m.Up, m.Version)
+		require.NotEmpty(t, m.Down, m.Version)
+	}
+	require.Equal(t, "Init", migrations[0].Description)
+}
+
+func TestParseMigrations(t *testing.T) {
+	testCases := []struct {
+		name    string
+		files   fstest.MapFS
+		want    []*Migration
+		wantErr bool
+	}{
+		{
+			name: "ordered by version",
+			files: fstest.MapFS{
+				"m/V0002__Add_users.sql": {Data: []byte("create table users ();")},
+				"m/U0002__Add_users.sql": {Data: []byte("drop table users;")},
+				"m/V0001__Init.sql":      {Data: []byte("create table kline ();")},
+			},
+			want: []*Migration{
+				{Version: 1, Description: "Init", Up: "create table kline ();"},
+				{Version: 2, Description: "Add users", Up: "create table users ();", Down: "drop table users;"},
+			},
+		},
+		{
+			name:    "unexpected name",
+			files:   fstest.MapFS{"m/0001_init.sql": {}},
+			wantErr: true,
+		},
+		{
+			name:    "down only",
+			files:   fstest.MapFS{"m/U0001__Init.sql": {Data: []byte("drop table kline;")}},
+			wantErr: true,
+		},
+		{
+			name: "different names",
+			files: fstest.MapFS{
+				"m/V0001__Init.sql":  {Data: []byte("create table kline ();")},
+				"m/U0001__Other.sql": {Data: []byte("drop table kline;")},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseMigrations(tc.files, "m")
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,3 @@
+drop table if exists balance;
+drop table if exists orders;
+drop table if exists users;
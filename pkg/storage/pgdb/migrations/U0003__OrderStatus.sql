@@ -0,0 +1,11 @@
+drop index if exists orders_user_uid_status_idx;
+
+alter table orders
+    drop column stop_price,
+    drop column time_in_force,
+    drop column status,
+    drop column is_working,
+    drop column executed_quantity,
+    drop column cummulative_quote_quantity,
+    drop column time,
+    drop column update_time;
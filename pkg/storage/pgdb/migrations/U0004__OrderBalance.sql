@@ -0,0 +1,4 @@
+alter table orders
+    drop column locked,
+    drop column commission,
+    drop column commission_asset;
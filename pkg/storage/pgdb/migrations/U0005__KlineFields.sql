@@ -0,0 +1,27 @@
+alter table kline
+    drop column quote_asset_volume,
+    drop column taker_buy_base_asset_volume,
+    drop column taker_buy_quote_asset_volume,
+    drop column first_trade_id,
+    drop column last_trade_id;
+
+do
+$$
+    declare
+        t text;
+    begin
+        for t in select table_name
+                 from information_schema.tables
+                 where table_schema = current_schema()
+                   and table_type = 'BASE TABLE'
+                   and table_name like 'kline\_%'
+            loop
+                execute format('alter table %I
+                    drop column if exists quote_asset_volume,
+                    drop column if exists taker_buy_base_asset_volume,
+                    drop column if exists taker_buy_quote_asset_volume,
+                    drop column if exists first_trade_id,
+                    drop column if exists last_trade_id', t);
+            end loop;
+    end
+$$;
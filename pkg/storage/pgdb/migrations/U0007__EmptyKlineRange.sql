@@ -0,0 +1 @@
+drop table if exists empty_kline_range;
@@ -0,0 +1,7 @@
+drop materialized view if exists klines_5m;
+drop materialized view if exists klines_15m;
+drop materialized view if exists klines_1h;
+drop materialized view if exists klines_4h;
+drop materialized view if exists klines_1d;
+drop table if exists klines;
+drop function if exists klines_now();
@@ -0,0 +1,2 @@
+drop table if exists agg_trade;
+drop table if exists trade;
@@ -0,0 +1,11 @@
+alter table orders
+    add column stop_price                 float8  not null default 0,
+    add column time_in_force              varchar not null default 'GTC',
+    add column status                     varchar not null default 'NEW',
+    add column is_working                 boolean not null default true,
+    add column executed_quantity          float8  not null default 0,
+    add column cummulative_quote_quantity float8  not null default 0,
+    add column time                       bigint  not null default 0,
+    add column update_time                bigint  not null default 0;
+
+create index orders_user_uid_status_idx on orders (user_uid, status);
@@ -0,0 +1,4 @@
+alter table orders
+    add column locked           float8  not null default 0,
+    add column commission       float8  not null default 0,
+    add column commission_asset varchar not null default '';
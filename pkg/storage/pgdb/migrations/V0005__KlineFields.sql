@@ -0,0 +1,29 @@
+alter table kline
+    add column quote_asset_volume           float8 not null default 0,
+    add column taker_buy_base_asset_volume  float8 not null default 0,
+    add column taker_buy_quote_asset_volume float8 not null default 0,
+    add column first_trade_id               bigint not null default 0,
+    add column last_trade_id                bigint not null default 0;
+
+-- kline_<symbol>_<interval> tables are created like the kline template,
+-- so the ones created before this migration need the columns as well.
+do
+$$
+    declare
+        t text;
+    begin
+        for t in select table_name
+                 from information_schema.tables
+                 where table_schema = current_schema()
+                   and table_type = 'BASE TABLE'
+                   and table_name like 'kline\_%'
+            loop
+                execute format('alter table %I
+                    add column if not exists quote_asset_volume           float8 not null default 0,
+                    add column if not exists taker_buy_base_asset_volume  float8 not null default 0,
+                    add column if not exists taker_buy_quote_asset_volume float8 not null default 0,
+                    add column if not exists first_trade_id               bigint not null default 0,
+                    add column if not exists last_trade_id                bigint not null default 0', t);
+            end loop;
+    end
+$$;
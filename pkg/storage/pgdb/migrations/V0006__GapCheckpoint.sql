@@ -0,0 +1,10 @@
+create table gap_checkpoint
+(
+    symbol         varchar,
+    kline_interval varchar,
+    from_time      bigint      not null,
+    checked_to     bigint      not null,
+    updated_at     timestamptz not null default now(),
+
+    primary key (symbol, kline_interval)
+);
@@ -0,0 +1,12 @@
+-- Ranges the exchange has no klines for, like maintenance windows or the time
+-- before a symbol was listed, so gap fixing does not retry them.
+create table empty_kline_range
+(
+    symbol         varchar,
+    kline_interval varchar,
+    start_time     bigint      not null,
+    end_time       bigint      not null,
+    created_at     timestamptz not null default now(),
+
+    primary key (symbol, kline_interval, start_time)
+);
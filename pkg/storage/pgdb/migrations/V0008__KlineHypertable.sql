@@ -0,0 +1,88 @@
+-- Klines of every symbol and interval in one table, used by the hypertable
+-- kline layout instead of the kline_<symbol>_<interval> tables.
+create table klines
+(
+    symbol                       varchar not null,
+    kline_interval               varchar not null,
+    open_time                    bigint  not null,
+    open                         float8,
+    high                         float8,
+    low                          float8,
+    close                        float8,
+    volume                       float8,
+    close_time                   bigint,
+    trade_num                    bigint,
+    quote_asset_volume           float8  not null default 0,
+    taker_buy_base_asset_volume  float8  not null default 0,
+    taker_buy_quote_asset_volume float8  not null default 0,
+    first_trade_id               bigint  not null default 0,
+    last_trade_id                bigint  not null default 0,
+
+    primary key (symbol, kline_interval, open_time)
+);
+
+-- With TimescaleDB klines is a hypertable of weekly chunks compressed after 30
+-- days, and 1m klines are aggregated into klines_<interval> views refreshed
+-- by policies. Open times are unix milliseconds, so are the chunk and policy
+-- intervals.
+do
+$$
+    declare
+        agg record;
+    begin
+        if not exists (select from pg_available_extensions where name = 'timescaledb')
+            or coalesce(current_setting('shared_preload_libraries', true), '') not like '%timescaledb%' then
+            return;
+        end if;
+        create extension if not exists timescaledb;
+
+        perform create_hypertable('klines', 'open_time', chunk_time_interval => 604800000::bigint);
+        create function klines_now() returns bigint
+            language sql
+            stable as
+        'select (extract(epoch from now()) * 1000)::bigint';
+        perform set_integer_now_func('klines', 'klines_now');
+
+        alter table klines set (
+            timescaledb.compress,
+            timescaledb.compress_segmentby = 'symbol, kline_interval',
+            timescaledb.compress_orderby = 'open_time'
+            );
+        perform add_compression_policy('klines', compress_after => 2592000000::bigint);
+
+        for agg in select *
+                   from (values ('5m', 300000::bigint),
+                                ('15m', 900000::bigint),
+                                ('1h', 3600000::bigint),
+                                ('4h', 14400000::bigint),
+                                ('1d', 86400000::bigint)) as a(name, ms)
+            loop
+                execute format('create materialized view %I
+                    with (timescaledb.continuous, timescaledb.materialized_only = false) as
+                    select symbol,
+                           time_bucket(%s::bigint, open_time)   as open_time,
+                           first(open, open_time)               as open,
+                           max(high)                            as high,
+                           min(low)                             as low,
+                           last(close, open_time)               as close,
+                           sum(volume)                          as volume,
+                           max(close_time)                      as close_time,
+                           sum(trade_num)::bigint               as trade_num,
+                           sum(quote_asset_volume)              as quote_asset_volume,
+                           sum(taker_buy_base_asset_volume)     as taker_buy_base_asset_volume,
+                           sum(taker_buy_quote_asset_volume)    as taker_buy_quote_asset_volume,
+                           first(first_trade_id, open_time)     as first_trade_id,
+                           last(last_trade_id, open_time)       as last_trade_id
+                    from klines
+                    where kline_interval = %L
+                    group by symbol, time_bucket(%s::bigint, open_time)
+                    with no data', 'klines_' || agg.name, agg.ms, '1m', agg.ms);
+                -- Without a start offset gap fixes of any age are refreshed, only
+                -- invalidated buckets are rebuilt.
+                perform add_continuous_aggregate_policy('klines_' || agg.name,
+                                                        start_offset => null,
+                                                        end_offset => agg.ms,
+                                                        schedule_interval => make_interval(secs => least(agg.ms, 3600000) / 1000));
+            end loop;
+    end
+$$;
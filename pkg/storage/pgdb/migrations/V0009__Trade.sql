@@ -0,0 +1,30 @@
+-- Trades and aggregate trades of every symbol, keyed by their exchange ids,
+-- which follow one another without gaps.
+create table trade
+(
+    symbol         varchar not null,
+    id             bigint  not null,
+    price          float8  not null,
+    quantity       float8  not null,
+    quote_quantity float8  not null,
+    time           bigint  not null,
+    is_buyer_maker boolean not null,
+    is_best_match  boolean not null,
+
+    primary key (symbol, id)
+);
+
+create table agg_trade
+(
+    symbol              varchar not null,
+    id                  bigint  not null,
+    price               float8  not null,
+    quantity            float8  not null,
+    first_trade_id      bigint  not null,
+    last_trade_id       bigint  not null,
+    time                bigint  not null,
+    is_buyer_maker      boolean not null,
+    is_best_price_match boolean not null,
+
+    primary key (symbol, id)
+);
@@ -2,33 +2,72 @@ package pgdb
 
 import (
 	"context"
+	"strings"
 
 	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
 )
 
+var orderColumns = []string{
+	"id", "symbol", "price", "quantity", "type", "side", "stop_price", "time_in_force", "status",
+	"is_working", "executed_quantity", "cummulative_quote_quantity", "time", "update_time",
+	"locked", "commission", "commission_asset",
+}
+
 type Order struct {
-	ID       int64
-	Symbol   string
-	Price    float64
-	Quantity float64
-	Type     string
-	Side     string
+	ID                       int64
+	Symbol                   string
+	Price                    float64
+	Quantity                 float64
+	Type                     string
+	Side                     string
+	StopPrice                float64
+	TimeInForce              string
+	Status                   string
+	IsWorking                bool
+	ExecutedQuantity         float64
+	CummulativeQuoteQuantity float64
+	Time                     int64
+	UpdateTime               int64
+	// Locked is the amount of the spent asset reserved while the order is open.
+	Locked          float64
+	Commission      float64
+	CommissionAsset string
+}
+
+func scanOrder(row pgx.Row) (*Order, error) {
+	var o Order
+	err := row.Scan(&o.ID, &o.Symbol, &o.Price, &o.Quantity, &o.Type, &o.Side, &o.StopPrice, &o.TimeInForce,
+		&o.Status, &o.IsWorking, &o.ExecutedQuantity, &o.CummulativeQuoteQuantity, &o.Time, &o.UpdateTime,
+		&o.Locked, &o.Commission, &o.CommissionAsset)
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
 }
 
 type CreateOrderRequest struct {
-	Symbol   string
-	Price    float64
-	Quantity float64
-	Type     string
-	Side     string
-	UserUID  int64
+	Symbol      string
+	Price       float64
+	Quantity    float64
+	Type        string
+	Side        string
+	StopPrice   float64
+	TimeInForce string
+	Status      string
+	IsWorking   bool
+	Time        int64
+	Locked      float64
+	UserUID     int64
 }
 
 func (c *Client) CreateOrder(ctx context.Context, r CreateOrderRequest) (*Order, error) {
 	queryStr, args, err := sq.
 		Insert("orders").
-		Columns("symbol", "price", "quantity", "type", "side", "user_uid").
-		Values(r.Symbol, r.Price, r.Quantity, r.Type, r.Side, r.UserUID).
+		Columns("symbol", "price", "quantity", "type", "side", "stop_price", "time_in_force", "status",
+			"is_working", "time", "update_time", "locked", "user_uid").
+		Values(r.Symbol, r.Price, r.Quantity, r.Type, r.Side, r.StopPrice, r.TimeInForce, r.Status,
+			r.IsWorking, r.Time, r.Time, r.Locked, r.UserUID).
 		Suffix("RETURNING id").
 		PlaceholderFormat(sq.Dollar).
 		ToSql()
@@ -40,12 +79,19 @@ func (c *Client) CreateOrder(ctx context.Context, r CreateOrderRequest) (*Order,
 		return nil, err
 	}
 	return &Order{
-		ID:       id,
-		Symbol:   r.Symbol,
-		Price:    r.Price,
-		Quantity: r.Quantity,
-		Type:     r.Type,
-		Side:     r.Side,
+		ID:          id,
+		Symbol:      r.Symbol,
+		Price:       r.Price,
+		Quantity:    r.Quantity,
+		Type:        r.Type,
+		Side:        r.Side,
+		StopPrice:   r.StopPrice,
+		TimeInForce: r.TimeInForce,
+		Status:      r.Status,
+		IsWorking:   r.IsWorking,
+		Time:        r.Time,
+		UpdateTime:  r.Time,
+		Locked:      r.Locked,
 	}, nil
 }
 
@@ -55,7 +101,7 @@ type ReadOrderRequest struct {
 
 func (c *Client) ReadOrder(ctx context.Context, r ReadOrderRequest) (*Order, error) {
 	queryStr, args, err := sq.
-		Select("id", "symbol", "price", "quantity", "type", "side").
+		Select(orderColumns...).
 		From("orders").
 		Where(sq.Eq{"id": r.ID}).
 		PlaceholderFormat(sq.Dollar).
@@ -63,27 +109,28 @@ func (c *Client) ReadOrder(ctx context.Context, r ReadOrderRequest) (*Order, err
 	if err != nil {
 		return nil, err
 	}
-	var o Order
-	if err = c.conn.QueryRow(ctx, queryStr, args...).Scan(&o.ID, &o.Symbol, &o.Price, &o.Quantity, &o.Type, &o.Side); err != nil {
-		return nil, err
-	}
-	return &o, nil
+	return scanOrder(c.conn.QueryRow(ctx, queryStr, args...))
 }
 
 type ReadOrdersRequest struct {
-	UserUID int64
-	Symbol  string
+	UserUID  int64
+	Symbol   string
+	Statuses []string
 }
 
 func (c *Client) ReadOrders(ctx context.Context, r ReadOrdersRequest) ([]*Order, error) {
 	query := sq.
-		Select("id", "symbol", "price", "quantity", "type", "side").
+		Select(orderColumns...).
 		From("orders").
 		Where(sq.Eq{"user_uid": r.UserUID}).
+		OrderBy("id").
 		PlaceholderFormat(sq.Dollar)
 	if r.Symbol != "" {
 		query = query.Where(sq.Eq{"symbol": r.Symbol})
 	}
+	if len(r.Statuses) > 0 {
+		query = query.Where(sq.Eq{"status": r.Statuses})
+	}
 	queryStr, args, err := query.ToSql()
 	if err != nil {
 		return nil, err
@@ -95,14 +142,14 @@ func (c *Client) ReadOrders(ctx context.Context, r ReadOrdersRequest) ([]*Order,
 	defer rows.Close()
 	var os []*Order
 	for rows.Next() {
-		var o Order
-		if err = rows.Scan(&o.ID, &o.Symbol, &o.Price, &o.Quantity, &o.Type, &o.Side); err != nil {
+		o, err := scanOrder(rows)
+		if err != nil {
 			return nil, err
 		}
-		if rows.Err() != nil {
-			return nil, err
-		}
-		os = append(os, &o)
+		os = append(os, o)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
 	}
 	return os, nil
 }
@@ -143,6 +190,72 @@ func (c *Client) UpdateOrder(ctx context.Context, r UpdateOrderRequest) (*Order,
 	}, nil
 }
 
+type UpdateOrderStatusRequest struct {
+	ID                       int64
+	Status                   string
+	IsWorking                bool
+	ExecutedQuantity         float64
+	CummulativeQuoteQuantity float64
+	UpdateTime               int64
+	Commission               float64
+	CommissionAsset          string
+}
+
+func (c *Client) UpdateOrderStatus(ctx context.Context, r UpdateOrderStatusRequest) (*Order, error) {
+	return updateOrderStatus(ctx, c.conn, r)
+}
+
+func updateOrderStatus(ctx context.Context, q querier, r UpdateOrderStatusRequest) (*Order, error) {
+	queryStr, args, err := sq.
+		Update("orders").
+		Set("status", r.Status).
+		Set("is_working", r.IsWorking).
+		Set("executed_quantity", r.ExecutedQuantity).
+		Set("cummulative_quote_quantity", r.CummulativeQuoteQuantity).
+		Set("update_time", r.UpdateTime).
+		Set("commission", r.Commission).
+		Set("commission_asset", r.CommissionAsset).
+		Where(sq.Eq{"id": r.ID}).
+		Suffix("RETURNING " + strings.Join(orderColumns, ", ")).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	return scanOrder(q.QueryRow(ctx, queryStr, args...))
+}
+
+// SettleOrderRequest holds the balance changes of the user that go with the
+// new status of the order.
+type SettleOrderRequest struct {
+	UserUID int64
+	Changes []BalanceChange
+	Status  UpdateOrderStatusRequest
+}
+
+// SettleOrder changes the balances and updates the order status in one
+// transaction. Nothing is changed and ErrNegativeBalance is returned if any
+// balance would become negative.
+func (c *Client) SettleOrder(ctx context.Context, r SettleOrderRequest) (*Order, error) {
+	tx, err := c.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(ctx, tx)
+
+	if _, err = changeBalances(ctx, tx, ChangeBalancesRequest{UserUID: r.UserUID, Changes: r.Changes}); err != nil {
+		return nil, err
+	}
+	o, err := updateOrderStatus(ctx, tx, r.Status)
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
 type DeleteOrderRequest struct {
 	ID int64
 }
@@ -0,0 +1,109 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage"
+)
+
+// KlineRepository is the storage.KlineRepository of a client, in its kline layout.
+type KlineRepository struct {
+	c *Client
+}
+
+var _ storage.KlineRepository = (*KlineRepository)(nil)
+
+// Klines returns the kline repository of the client.
+func (c *Client) Klines() *KlineRepository {
+	return &KlineRepository{c: c}
+}
+
+func (r *KlineRepository) ReadKlines(ctx context.Context, req storage.ReadKlinesRequest) ([]*models.Kline, error) {
+	klines, err := r.c.ReadKlines(ctx, ReadKlinesRequest{
+		Symbol:    req.Symbol,
+		Interval:  req.Interval,
+		OpenTime:  req.OpenTime,
+		CloseTime: req.CloseTime,
+		Limit:     req.Limit,
+		Offset:    req.Offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*models.Kline, len(klines))
+	for i, k := range klines {
+		res[i] = k.Model()
+	}
+	return res, nil
+}
+
+func (r *KlineRepository) ReadLastKline(ctx context.Context, req storage.ReadLastKlineRequest) (*models.Kline, error) {
+	k, err := r.c.ReadLastKline(ctx, ReadLastKlineRequest{Symbol: req.Symbol, Interval: req.Interval})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, storage.ErrNoKlines
+	}
+	if err != nil {
+		return nil, err
+	}
+	return k.Model(), nil
+}
+
+func (r *KlineRepository) WriteKlines(ctx context.Context, req storage.WriteKlinesRequest) error {
+	klines := make([]*Kline, len(req.Klines))
+	for i, k := range req.Klines {
+		klines[i] = NewKline(k)
+	}
+	_, err := r.c.WriteKlines(ctx, WriteKlinesRequest{
+		Symbol:   req.Symbol,
+		Interval: req.Interval,
+		Klines:   klines,
+		Replace:  req.Replace,
+	})
+	return err
+}
+
+func (r *KlineRepository) DeleteKlines(ctx context.Context, req storage.DeleteKlinesRequest) error {
+	return r.c.DeleteKlines(ctx, DeleteKlinesRequest{Symbol: req.Symbol, Interval: req.Interval, OpenTimes: req.OpenTimes})
+}
+
+// NewKline returns the stored kline of k.
+func NewKline(k *models.Kline) *Kline {
+	return &Kline{
+		OpenTime:                 k.OpenTime,
+		Open:                     k.Open,
+		High:                     k.High,
+		Low:                      k.Low,
+		Close:                    k.Close,
+		Volume:                   k.Volume,
+		CloseTime:                k.CloseTime,
+		TradeNum:                 k.TradeNum,
+		QuoteAssetVolume:         k.QuoteAssetVolume,
+		TakerBuyBaseAssetVolume:  k.TakerBuyBaseAssetVolume,
+		TakerBuyQuoteAssetVolume: k.TakerBuyQuoteAssetVolume,
+		FirstTradeID:             k.FirstTradeID,
+		LastTradeID:              k.LastTradeID,
+	}
+}
+
+// Model returns the exchange model of the stored kline.
+func (k *Kline) Model() *models.Kline {
+	return &models.Kline{
+		OpenTime:                 k.OpenTime,
+		Open:                     k.Open,
+		High:                     k.High,
+		Low:                      k.Low,
+		Close:                    k.Close,
+		Volume:                   k.Volume,
+		CloseTime:                k.CloseTime,
+		TradeNum:                 k.TradeNum,
+		QuoteAssetVolume:         k.QuoteAssetVolume,
+		TakerBuyBaseAssetVolume:  k.TakerBuyBaseAssetVolume,
+		TakerBuyQuoteAssetVolume: k.TakerBuyQuoteAssetVolume,
+		FirstTradeID:             k.FirstTradeID,
+		LastTradeID:              k.LastTradeID,
+	}
+}
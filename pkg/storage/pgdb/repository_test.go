@@ -0,0 +1,16 @@
+package pgdb
+
+import (
+	"testing"
+
+	"crypto_bot/pkg/storage/storagetest"
+)
+
+func TestKlineRepository(t *testing.T) {
+	c := testClient(t)
+	for _, layout := range []KlineLayout{KlineLayoutTables, KlineLayoutHypertable} {
+		t.Run(string(layout), func(t *testing.T) {
+			storagetest.TestKlineRepository(t, c.SetKlineLayout(layout).Klines())
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package pgdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage"
+)
+
+const (
+	tradeTable    = "trade"
+	aggTradeTable = "agg_trade"
+)
+
+var (
+	tradeColumns = []string{
+		"id", "price", "quantity", "quote_quantity", "time", "is_buyer_maker", "is_best_match",
+	}
+	aggTradeColumns = []string{
+		"id", "price", "quantity", "first_trade_id", "last_trade_id", "time", "is_buyer_maker", "is_best_price_match",
+	}
+)
+
+func scanTrade(row pgx.Row) (*models.Trade, error) {
+	var t models.Trade
+	err := row.Scan(&t.ID, &t.Price, &t.Quantity, &t.QuoteQuantity, &t.Time, &t.IsBuyerMaker, &t.IsBestMatch)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func scanAggTrade(row pgx.Row) (*models.AggTrade, error) {
+	var t models.AggTrade
+	err := row.Scan(&t.ID, &t.Price, &t.Quantity, &t.FirstTradeID, &t.LastTradeID, &t.Time, &t.IsBuyerMaker, &t.IsBestPriceMatch)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// WriteTrades writes trades skipping the ones already stored.
+func (c *Client) WriteTrades(ctx context.Context, req storage.WriteTradesRequest) error {
+	return c.copyTrades(ctx, tradeTable, tradeColumns, req.Symbol, len(req.Trades), func(i int) []any {
+		t := req.Trades[i]
+		return []any{t.ID, t.Price, t.Quantity, t.QuoteQuantity, t.Time, t.IsBuyerMaker, t.IsBestMatch}
+	})
+}
+
+// WriteAggTrades writes aggregate trades skipping the ones already stored.
+func (c *Client) WriteAggTrades(ctx context.Context, req storage.WriteAggTradesRequest) error {
+	return c.copyTrades(ctx, aggTradeTable, aggTradeColumns, req.Symbol, len(req.AggTrades), func(i int) []any {
+		t := req.AggTrades[i]
+		return []any{t.ID, t.Price, t.Quantity, t.FirstTradeID, t.LastTradeID, t.Time, t.IsBuyerMaker, t.IsBestPriceMatch}
+	})
+}
+
+// copyTrades copies n rows into a staging table dropped with the transaction
+// and merges them into table, trades arrive in batches too large to insert
+// with one statement.
+func (c *Client) copyTrades(ctx context.Context, table string, columns []string, symbol string, n int, values func(int) []any) error {
+	s, err := models.ParseSymbol(symbol)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+	tx, err := c.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer rollback(ctx, tx)
+
+	staging := table + "_staging"
+	_, err = tx.Exec(ctx, fmt.Sprintf("create temp table %s (like %s including defaults) on commit drop", staging, table))
+	if err != nil {
+		return fmt.Errorf("create staging table: %w", err)
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{staging}, append([]string{"symbol"}, columns...),
+		pgx.CopyFromSlice(n, func(i int) ([]any, error) {
+			return append([]any{string(s)}, values(i)...), nil
+		}))
+	if err != nil {
+		return fmt.Errorf("copy trades: %w", err)
+	}
+	_, err = tx.Exec(ctx, fmt.Sprintf("insert into %s select * from %s ON CONFLICT DO NOTHING", table, staging))
+	if err != nil {
+		return fmt.Errorf("merge trades: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// ReadTrades returns trades ordered by id.
+func (c *Client) ReadTrades(ctx context.Context, req storage.ReadTradesRequest) ([]*models.Trade, error) {
+	rows, err := c.queryTrades(ctx, tradeTable, tradeColumns, req)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.Trade, error) { return scanTrade(row) })
+}
+
+// ReadAggTrades returns aggregate trades ordered by id.
+func (c *Client) ReadAggTrades(ctx context.Context, req storage.ReadTradesRequest) ([]*models.AggTrade, error) {
+	rows, err := c.queryTrades(ctx, aggTradeTable, aggTradeColumns, req)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.AggTrade, error) { return scanAggTrade(row) })
+}
+
+func (c *Client) queryTrades(ctx context.Context, table string, columns []string, req storage.ReadTradesRequest) (pgx.Rows, error) {
+	s, err := models.ParseSymbol(req.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	query := sq.
+		Select(columns...).
+		From(table).
+		Where(sq.Eq{"symbol": string(s)}).
+		Where(sq.GtOrEq{"id": req.FromID}).
+		OrderBy("id").
+		PlaceholderFormat(sq.Dollar)
+	if req.ToID > 0 {
+		query = query.Where(sq.LtOrEq{"id": req.ToID})
+	}
+	if req.Limit > 0 {
+		query = query.Limit(req.Limit)
+	}
+	queryStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	return c.conn.Query(ctx, queryStr, args...)
+}
+
+// ReadLastTrade returns the trade with the greatest id, pgx.ErrNoRows if there
+// are none.
+func (c *Client) ReadLastTrade(ctx context.Context, req storage.ReadLastTradeRequest) (*models.Trade, error) {
+	row, err := c.queryLastTrade(ctx, tradeTable, tradeColumns, req.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	return scanTrade(row)
+}
+
+// ReadLastAggTrade returns the aggregate trade with the greatest id,
+// pgx.ErrNoRows if there are none.
+func (c *Client) ReadLastAggTrade(ctx context.Context, req storage.ReadLastTradeRequest) (*models.AggTrade, error) {
+	row, err := c.queryLastTrade(ctx, aggTradeTable, aggTradeColumns, req.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	return scanAggTrade(row)
+}
+
+func (c *Client) queryLastTrade(ctx context.Context, table string, columns []string, symbol string) (pgx.Row, error) {
+	s, err := models.ParseSymbol(symbol)
+	if err != nil {
+		return nil, err
+	}
+	queryStr, args, err := sq.
+		Select(columns...).
+		From(table).
+		Where(sq.Eq{"symbol": string(s)}).
+		OrderBy("id DESC").
+		Limit(1).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	return c.conn.QueryRow(ctx, queryStr, args...), nil
+}
+
+// ReadTradeGaps returns the gaps in stored trade ids from FromID to ToID
+// ordered by start.
+func (c *Client) ReadTradeGaps(ctx context.Context, req storage.ReadTradeGapsRequest) ([]*storage.TradeGap, error) {
+	s, err := models.ParseSymbol(req.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	if req.ToID < req.FromID {
+		return nil, nil
+	}
+	table := tradeTable
+	if req.Agg {
+		table = aggTradeTable
+	}
+	// The ids around the range bound the gaps at its ends.
+	query := strings.ReplaceAll(`select id + 1, next_id - 1
+		from (select id, lead(id) over (order by id) as next_id
+		      from (select $2::bigint - 1 as id
+		            union all
+		            select id from {table} where symbol = $1 and id between $2 and $3
+		            union all
+		            select $3::bigint + 1) ids) t
+		where next_id > id + 1
+		order by id`, "{table}", table)
+	rows, err := c.conn.Query(ctx, query, string(s), req.FromID, req.ToID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*storage.TradeGap, error) {
+		var g storage.TradeGap
+		err := row.Scan(&g.Start, &g.End)
+		return &g, err
+	})
+}
@@ -0,0 +1,82 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage"
+)
+
+func trades(ids ...int64) []*models.Trade {
+	res := make([]*models.Trade, len(ids))
+	for i, id := range ids {
+		res[i] = &models.Trade{ID: id, Price: 100, Quantity: 0.5, QuoteQuantity: 50, Time: id * 10, IsBuyerMaker: id%2 == 0, IsBestMatch: true}
+	}
+	return res
+}
+
+func TestClient_Trades(t *testing.T) {
+	c := testClient(t)
+	symbol := testSymbol(t, c, "1m")
+	ctx := context.Background()
+	t.Cleanup(func() {
+		_, err := c.conn.Exec(context.Background(), "delete from trade where symbol = $1", symbol)
+		require.NoError(t, err)
+	})
+
+	_, err := c.ReadLastTrade(ctx, storage.ReadLastTradeRequest{Symbol: symbol})
+	require.True(t, errors.Is(err, pgx.ErrNoRows), err)
+
+	require.NoError(t, c.WriteTrades(ctx, storage.WriteTradesRequest{Symbol: symbol, Trades: trades(3, 4, 5, 8)}))
+	require.NoError(t, c.WriteTrades(ctx, storage.WriteTradesRequest{Symbol: symbol, Trades: trades(5, 9, 12)}))
+
+	got, err := c.ReadTrades(ctx, storage.ReadTradesRequest{Symbol: symbol, FromID: 4, ToID: 9})
+	require.NoError(t, err)
+	require.Equal(t, trades(4, 5, 8, 9), got)
+	got, err = c.ReadTrades(ctx, storage.ReadTradesRequest{Symbol: symbol, FromID: 5, Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, trades(5, 8), got)
+
+	last, err := c.ReadLastTrade(ctx, storage.ReadLastTradeRequest{Symbol: symbol})
+	require.NoError(t, err)
+	require.Equal(t, trades(12)[0], last)
+
+	gaps, err := c.ReadTradeGaps(ctx, storage.ReadTradeGapsRequest{Symbol: symbol, FromID: 1, ToID: 14})
+	require.NoError(t, err)
+	require.Equal(t, []*storage.TradeGap{{Start: 1, End: 2}, {Start: 6, End: 7}, {Start: 10, End: 11}, {Start: 13, End: 14}}, gaps)
+	gaps, err = c.ReadTradeGaps(ctx, storage.ReadTradeGapsRequest{Symbol: symbol, FromID: 3, ToID: 5})
+	require.NoError(t, err)
+	require.Empty(t, gaps)
+}
+
+func TestClient_AggTrades(t *testing.T) {
+	c := testClient(t)
+	symbol := testSymbol(t, c, "1m")
+	ctx := context.Background()
+	t.Cleanup(func() {
+		_, err := c.conn.Exec(context.Background(), "delete from agg_trade where symbol = $1", symbol)
+		require.NoError(t, err)
+	})
+
+	aggTrades := []*models.AggTrade{
+		{ID: 1, Price: 100, Quantity: 1, FirstTradeID: 1, LastTradeID: 3, Time: 10, IsBestPriceMatch: true},
+		{ID: 3, Price: 101, Quantity: 2, FirstTradeID: 6, LastTradeID: 6, Time: 20, IsBuyerMaker: true, IsBestPriceMatch: true},
+	}
+	require.NoError(t, c.WriteAggTrades(ctx, storage.WriteAggTradesRequest{Symbol: symbol, AggTrades: aggTrades}))
+
+	got, err := c.ReadAggTrades(ctx, storage.ReadTradesRequest{Symbol: symbol})
+	require.NoError(t, err)
+	require.Equal(t, aggTrades, got)
+	last, err := c.ReadLastAggTrade(ctx, storage.ReadLastTradeRequest{Symbol: symbol})
+	require.NoError(t, err)
+	require.Equal(t, aggTrades[1], last)
+
+	gaps, err := c.ReadTradeGaps(ctx, storage.ReadTradeGapsRequest{Symbol: symbol, FromID: 1, ToID: 3, Agg: true})
+	require.NoError(t, err)
+	require.Equal(t, []*storage.TradeGap{{Start: 2, End: 2}}, gaps)
+}
@@ -0,0 +1,230 @@
+// Package sqlite stores klines in a SQLite file, so the watcher runs without a
+// database server, on laptops and in CI.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	_ "modernc.org/sqlite"
+
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage"
+)
+
+const schema = `create table if not exists klines
+(
+    symbol                       text    not null,
+    kline_interval               text    not null,
+    open_time                    integer not null,
+    open                         real    not null,
+    high                         real    not null,
+    low                          real    not null,
+    close                        real    not null,
+    volume                       real    not null,
+    close_time                   integer not null,
+    trade_num                    integer not null,
+    quote_asset_volume           real    not null,
+    taker_buy_base_asset_volume  real    not null,
+    taker_buy_quote_asset_volume real    not null,
+    first_trade_id               integer not null,
+    last_trade_id                integer not null,
+
+    primary key (symbol, kline_interval, open_time)
+) without rowid`
+
+var klineColumns = []string{
+	"open_time", "open", "high", "low", "close", "volume", "close_time", "trade_num",
+	"quote_asset_volume", "taker_buy_base_asset_volume", "taker_buy_quote_asset_volume",
+	"first_trade_id", "last_trade_id",
+}
+
+// insertChunk is the number of klines inserted with one statement, SQLite
+// limits the number of parameters.
+const insertChunk = 500
+
+// KlineRepository is a storage.KlineRepository of the klines table of a
+// SQLite file, shared by every symbol and interval.
+type KlineRepository struct {
+	db *sql.DB
+}
+
+var _ storage.KlineRepository = (*KlineRepository)(nil)
+
+// Open opens the SQLite file at path, creating it and its table if they do not
+// exist. A path of :memory: keeps the database in memory.
+func Open(ctx context.Context, path string) (*KlineRepository, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite has a single writer, and an in-memory database lives in one
+	// connection.
+	db.SetMaxOpenConns(1)
+	if _, err = db.ExecContext(ctx, schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create klines table: %w", err)
+	}
+	return &KlineRepository{db: db}, nil
+}
+
+func (r *KlineRepository) Close() error {
+	return r.db.Close()
+}
+
+func scanKline(row interface{ Scan(...any) error }) (*models.Kline, error) {
+	var k models.Kline
+	err := row.Scan(&k.OpenTime, &k.Open, &k.High, &k.Low, &k.Close, &k.Volume, &k.CloseTime, &k.TradeNum,
+		&k.QuoteAssetVolume, &k.TakerBuyBaseAssetVolume, &k.TakerBuyQuoteAssetVolume, &k.FirstTradeID, &k.LastTradeID)
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+func pairWhere(symbol, interval string) (sq.Eq, error) {
+	s, i, err := storage.ParsePair(symbol, interval)
+	if err != nil {
+		return nil, err
+	}
+	return sq.Eq{"symbol": s, "kline_interval": i}, nil
+}
+
+func (r *KlineRepository) ReadKlines(ctx context.Context, req storage.ReadKlinesRequest) ([]*models.Kline, error) {
+	where, err := pairWhere(req.Symbol, req.Interval)
+	if err != nil {
+		return nil, err
+	}
+	query := sq.
+		Select(klineColumns...).
+		From("klines").
+		Where(where).
+		Where("open_time >= ?", req.OpenTime).
+		OrderBy("open_time")
+	if req.CloseTime > 0 {
+		query = query.Where("close_time <= ?", req.CloseTime)
+	}
+	if req.Limit > 0 {
+		query = query.Limit(req.Limit)
+	}
+	if req.Offset > 0 {
+		// SQLite only takes an offset with a limit.
+		if req.Limit == 0 {
+			query = query.Limit(1<<63 - 1)
+		}
+		query = query.Offset(req.Offset)
+	}
+	queryStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, queryStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	klines := make([]*models.Kline, 0, req.Limit)
+	for rows.Next() {
+		k, err := scanKline(rows)
+		if err != nil {
+			return nil, err
+		}
+		klines = append(klines, k)
+	}
+	return klines, rows.Err()
+}
+
+func (r *KlineRepository) ReadLastKline(ctx context.Context, req storage.ReadLastKlineRequest) (*models.Kline, error) {
+	where, err := pairWhere(req.Symbol, req.Interval)
+	if err != nil {
+		return nil, err
+	}
+	query, args, err := sq.
+		Select(klineColumns...).
+		From("klines").
+		Where(where).
+		OrderBy("open_time DESC").
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	k, err := scanKline(r.db.QueryRowContext(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNoKlines
+	}
+	return k, err
+}
+
+func (r *KlineRepository) WriteKlines(ctx context.Context, req storage.WriteKlinesRequest) (err error) {
+	symbol, interval, err := storage.ParsePair(req.Symbol, req.Interval)
+	if err != nil {
+		return err
+	}
+	if len(req.Klines) == 0 {
+		return nil
+	}
+
+	conflict := "ON CONFLICT DO NOTHING"
+	if req.Replace {
+		set := make([]string, 0, len(klineColumns)-1)
+		for _, column := range klineColumns[1:] {
+			set = append(set, fmt.Sprintf("%s = excluded.%s", column, column))
+		}
+		conflict = "ON CONFLICT (symbol, kline_interval, open_time) DO UPDATE SET " + strings.Join(set, ", ")
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	for start := 0; start < len(req.Klines); start += insertChunk {
+		query := sq.
+			Insert("klines").
+			Columns(append([]string{"symbol", "kline_interval"}, klineColumns...)...).
+			Suffix(conflict)
+		for _, k := range req.Klines[start:min(start+insertChunk, len(req.Klines))] {
+			query = query.Values(symbol, interval, k.OpenTime, k.Open, k.High, k.Low, k.Close, k.Volume, k.CloseTime, k.TradeNum,
+				k.QuoteAssetVolume, k.TakerBuyBaseAssetVolume, k.TakerBuyQuoteAssetVolume, k.FirstTradeID, k.LastTradeID)
+		}
+		queryStr, args, err := query.ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, queryStr, args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *KlineRepository) DeleteKlines(ctx context.Context, req storage.DeleteKlinesRequest) error {
+	where, err := pairWhere(req.Symbol, req.Interval)
+	if err != nil {
+		return err
+	}
+	if len(req.OpenTimes) == 0 {
+		return nil
+	}
+	query, args, err := sq.
+		Delete("klines").
+		Where(where).
+		Where(sq.Eq{"open_time": req.OpenTimes}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, query, args...)
+	return err
+}
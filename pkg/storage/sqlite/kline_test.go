@@ -0,0 +1,33 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"crypto_bot/pkg/storage"
+	"crypto_bot/pkg/storage/storagetest"
+)
+
+func TestKlineRepository(t *testing.T) {
+	r, err := Open(context.Background(), filepath.Join(t.TempDir(), "klines.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { r.Close() })
+	storagetest.TestKlineRepository(t, r)
+}
+
+func TestKlineRepository_InMemory(t *testing.T) {
+	ctx := context.Background()
+	r, err := Open(ctx, ":memory:")
+	require.NoError(t, err)
+	defer r.Close()
+
+	// More klines than one insert statement takes.
+	klines := storagetest.Klines(0, insertChunk*2+1)
+	require.NoError(t, r.WriteKlines(ctx, storage.WriteKlinesRequest{Symbol: "BTCUSDT", Interval: "1m", Klines: klines}))
+	got, err := r.ReadKlines(ctx, storage.ReadKlinesRequest{Symbol: "BTCUSDT", Interval: "1m"})
+	require.NoError(t, err)
+	require.Equal(t, klines, got)
+}
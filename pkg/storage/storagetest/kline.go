@@ -0,0 +1,119 @@
+// Package storagetest tests implementations of the storage interfaces against
+// the same expectations.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage"
+)
+
+// Klines returns n one minute klines opened from minute from of the unix epoch.
+func Klines(from, n int) []*models.Kline {
+	klines := make([]*models.Kline, n)
+	for i := range klines {
+		openTime := int64((from + i) * 60_000)
+		price := float64(100 + from + i)
+		klines[i] = &models.Kline{
+			OpenTime: openTime, Open: price, High: price + 1, Low: price - 1, Close: price + 0.5,
+			Volume: 10, CloseTime: openTime + 59_999, TradeNum: 5, QuoteAssetVolume: 1000,
+			TakerBuyBaseAssetVolume: 4, TakerBuyQuoteAssetVolume: 400, FirstTradeID: 1, LastTradeID: 5,
+		}
+	}
+	return klines
+}
+
+// TestKlineRepository runs the tests every storage.KlineRepository passes.
+// Symbols are unique to every run, so r may hold other klines.
+func TestKlineRepository(t *testing.T, r storage.KlineRepository) {
+	ctx := context.Background()
+	symbol := func() string { return fmt.Sprintf("T%dUSDT", time.Now().UnixNano()%1e12) }
+
+	t.Run("write and read", func(t *testing.T) {
+		s := symbol()
+		_, err := r.ReadLastKline(ctx, storage.ReadLastKlineRequest{Symbol: s, Interval: "1m"})
+		require.True(t, errors.Is(err, storage.ErrNoKlines), err)
+
+		// Klines are ordered by open time and stored ones are kept.
+		require.NoError(t, r.WriteKlines(ctx, storage.WriteKlinesRequest{Symbol: s, Interval: "1m", Klines: Klines(5, 5)}))
+		klines := Klines(0, 7)
+		klines[5].Close = -1
+		require.NoError(t, r.WriteKlines(ctx, storage.WriteKlinesRequest{Symbol: s, Interval: "1m", Klines: klines}))
+		require.NoError(t, r.WriteKlines(ctx, storage.WriteKlinesRequest{Symbol: s, Interval: "3m", Klines: Klines(20, 1)}))
+
+		got, err := r.ReadKlines(ctx, storage.ReadKlinesRequest{Symbol: s, Interval: "1m"})
+		require.NoError(t, err)
+		require.Equal(t, Klines(0, 10), got)
+
+		// Symbols are case insensitive.
+		last, err := r.ReadLastKline(ctx, storage.ReadLastKlineRequest{Symbol: strings.ToLower(s), Interval: "1m"})
+		require.NoError(t, err)
+		require.Equal(t, Klines(9, 1)[0], last)
+
+		got, err = r.ReadKlines(ctx, storage.ReadKlinesRequest{Symbol: s, Interval: "3m"})
+		require.NoError(t, err)
+		require.Equal(t, Klines(20, 1), got)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		s := symbol()
+		require.NoError(t, r.WriteKlines(ctx, storage.WriteKlinesRequest{Symbol: s, Interval: "1m", Klines: Klines(0, 10)}))
+
+		testCases := []struct {
+			req  storage.ReadKlinesRequest
+			want []*models.Kline
+		}{
+			{req: storage.ReadKlinesRequest{OpenTime: 120_000}, want: Klines(2, 8)},
+			{req: storage.ReadKlinesRequest{OpenTime: 120_001, CloseTime: 300_000}, want: Klines(3, 2)},
+			{req: storage.ReadKlinesRequest{Limit: 3}, want: Klines(0, 3)},
+			{req: storage.ReadKlinesRequest{Limit: 3, Offset: 8}, want: Klines(8, 2)},
+			{req: storage.ReadKlinesRequest{Offset: 9}, want: Klines(9, 1)},
+			{req: storage.ReadKlinesRequest{OpenTime: 600_000}, want: []*models.Kline{}},
+		}
+		for _, tc := range testCases {
+			tc.req.Symbol, tc.req.Interval = s, "1m"
+			got, err := r.ReadKlines(ctx, tc.req)
+			require.NoError(t, err, "%+v", tc.req)
+			require.Equal(t, tc.want, got, "%+v", tc.req)
+		}
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		s := symbol()
+		require.NoError(t, r.WriteKlines(ctx, storage.WriteKlinesRequest{Symbol: s, Interval: "1m", Klines: Klines(0, 5)}))
+		replaced := Klines(3, 4)
+		for _, k := range replaced {
+			k.Close = -1
+		}
+		require.NoError(t, r.WriteKlines(ctx, storage.WriteKlinesRequest{Symbol: s, Interval: "1m", Klines: replaced, Replace: true}))
+
+		got, err := r.ReadKlines(ctx, storage.ReadKlinesRequest{Symbol: s, Interval: "1m"})
+		require.NoError(t, err)
+		require.Equal(t, append(Klines(0, 3), replaced...), got)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		s := symbol()
+		require.NoError(t, r.WriteKlines(ctx, storage.WriteKlinesRequest{Symbol: s, Interval: "1m", Klines: Klines(0, 5)}))
+		require.NoError(t, r.DeleteKlines(ctx, storage.DeleteKlinesRequest{Symbol: s, Interval: "1m", OpenTimes: []int64{60_000, 180_000}}))
+
+		got, err := r.ReadKlines(ctx, storage.ReadKlinesRequest{Symbol: s, Interval: "1m"})
+		require.NoError(t, err)
+		all := Klines(0, 5)
+		require.Equal(t, []*models.Kline{all[0], all[2], all[4]}, got)
+	})
+
+	t.Run("invalid pair", func(t *testing.T) {
+		_, err := r.ReadKlines(ctx, storage.ReadKlinesRequest{Symbol: "BTCUSDT", Interval: "2m"})
+		require.Error(t, err)
+		require.Error(t, r.WriteKlines(ctx, storage.WriteKlinesRequest{Symbol: "BTC-USDT", Interval: "1m", Klines: Klines(0, 1)}))
+	})
+}
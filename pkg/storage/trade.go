@@ -0,0 +1,41 @@
+package storage
+
+import "crypto_bot/pkg/exchange/models"
+
+type WriteTradesRequest struct {
+	Symbol string
+	Trades []*models.Trade
+}
+
+type WriteAggTradesRequest struct {
+	Symbol    string
+	AggTrades []*models.AggTrade
+}
+
+// ReadTradesRequest selects trades with ids from FromID to ToID, both
+// inclusive, a zero ToID has no upper bound.
+type ReadTradesRequest struct {
+	Symbol string
+	FromID int64
+	ToID   int64
+	Limit  uint64
+}
+
+type ReadLastTradeRequest struct {
+	Symbol string
+}
+
+// TradeGap is a range of trade ids from Start to End, both inclusive, that
+// are not stored.
+type TradeGap struct {
+	Start int64
+	End   int64
+}
+
+type ReadTradeGapsRequest struct {
+	Symbol string
+	FromID int64
+	ToID   int64
+	// Agg reads the gaps of aggregate trades.
+	Agg bool
+}
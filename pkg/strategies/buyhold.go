@@ -0,0 +1,41 @@
+package strategies
+
+import (
+	"context"
+
+	"crypto_bot/pkg/backtest"
+	"crypto_bot/pkg/exchange"
+	"crypto_bot/pkg/exchange/models"
+)
+
+// BuyAndHold buys every symbol on its first kline and holds it. Params:
+// quote (USDT) and fraction (0.99) of the free quote balance to spend.
+type BuyAndHold struct {
+	quote    string
+	fraction float64
+	bought   map[string]bool
+}
+
+func NewBuyAndHold(p Params) (backtest.Strategy, error) {
+	fraction, err := p.Float("fraction", 0.99)
+	if err != nil {
+		return nil, err
+	}
+	return &BuyAndHold{
+		quote:    p.String("quote", "USDT"),
+		fraction: fraction,
+		bought:   make(map[string]bool),
+	}, nil
+}
+
+func (s *BuyAndHold) OnKline(ctx context.Context, ex exchange.Client, e *models.WsKlineEvent) error {
+	if s.bought[e.Symbol] {
+		return nil
+	}
+	s.bought[e.Symbol] = true
+	return buy(ctx, ex, e.Symbol, s.quote, e.Kline.Close, s.fraction)
+}
+
+func (s *BuyAndHold) OnOrderUpdate(context.Context, exchange.Client, *models.Order) error {
+	return nil
+}
@@ -0,0 +1,50 @@
+package strategies
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"crypto_bot/pkg/backtest"
+	"crypto_bot/pkg/exchange/dbased"
+	"crypto_bot/pkg/exchange/dbased/dbasedtest"
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage/pgdb"
+)
+
+// TestBuyAndHold_Backtest runs the default strategy of the backtest command
+// with its default params and balance.
+func TestBuyAndHold_Backtest(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := dbasedtest.NewStorage()
+	s.SetBalance(1, pgdb.Balance{Asset: "USDT", Free: 10000})
+	s.AddKlines("BTCUSDT", "1m", start, 10)
+
+	ex, err := dbased.NewClient(ctx, s, "user", start.UnixMilli())
+	require.NoError(t, err)
+	strategy, err := New("buy-and-hold", nil)
+	require.NoError(t, err)
+
+	res, err := backtest.NewEngine(ex, strategy).
+		SetStreams(models.WsKlineRequest{Symbol: "BTCUSDT", Interval: "1m"}).
+		SetPeriod(start.UnixMilli(), start.Add(10*time.Minute).UnixMilli()).
+		Run(ctx)
+	require.NoError(t, err)
+
+	// The buy is sized at the first close and filled at the next open.
+	quantity := roundDown(10000 * 0.99 / 100.5)
+	require.Len(t, res.Trades, 1)
+	require.Equal(t, models.SideTypeBuy, res.Trades[0].Side)
+	require.Equal(t, 101.0, res.Trades[0].Price)
+	require.Equal(t, quantity, res.Trades[0].Quantity)
+
+	usdt, err := freeBalance(ctx, ex, "USDT")
+	require.NoError(t, err)
+	require.InDelta(t, 10000-quantity*101, usdt, 1e-6)
+	btc, err := freeBalance(ctx, ex, "BTC")
+	require.NoError(t, err)
+	require.InDelta(t, quantity*(1-dbased.DefaultCommissionRate), btc, 1e-9)
+}
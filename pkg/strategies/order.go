@@ -0,0 +1,71 @@
+package strategies
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"crypto_bot/pkg/exchange"
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/exchange/utils"
+)
+
+// quantityPrecision is the number of decimals order quantities are rounded down to.
+const quantityPrecision = 5
+
+func freeBalance(ctx context.Context, ex exchange.Client, asset string) (float64, error) {
+	acc, err := ex.GetAccount(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("get account: %w", err)
+	}
+	for _, b := range acc.Balances {
+		if b.Asset == asset {
+			return utils.Str2float(b.Free), nil
+		}
+	}
+	return 0, nil
+}
+
+func roundDown(quantity float64) float64 {
+	p := math.Pow10(quantityPrecision)
+	return math.Floor(quantity*p) / p
+}
+
+// buy spends the fraction of the free quote balance on a market order.
+func buy(ctx context.Context, ex exchange.Client, symbol, quote string, price, fraction float64) error {
+	free, err := freeBalance(ctx, ex, quote)
+	if err != nil {
+		return err
+	}
+	quantity := roundDown(free * fraction / price)
+	if quantity <= 0 {
+		return nil
+	}
+	_, err = ex.CreateOrder(ctx, models.CreateOrderRequest{
+		Symbol:   symbol,
+		Quantity: quantity,
+		Side:     models.SideTypeBuy,
+		Type:     models.OrderTypeMarket,
+	})
+	return err
+}
+
+// sellAll sells the whole free base balance with a market order.
+func sellAll(ctx context.Context, ex exchange.Client, symbol, quote string) error {
+	free, err := freeBalance(ctx, ex, strings.TrimSuffix(symbol, quote))
+	if err != nil {
+		return err
+	}
+	quantity := roundDown(free)
+	if quantity <= 0 {
+		return nil
+	}
+	_, err = ex.CreateOrder(ctx, models.CreateOrderRequest{
+		Symbol:   symbol,
+		Quantity: quantity,
+		Side:     models.SideTypeSell,
+		Type:     models.OrderTypeMarket,
+	})
+	return err
+}
@@ -0,0 +1,82 @@
+package strategies
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"crypto_bot/pkg/exchange"
+	"crypto_bot/pkg/exchange/models"
+)
+
+// fakeExchange has the given free balances and records created orders.
+type fakeExchange struct {
+	exchange.Client
+	free   map[string]string
+	orders []models.CreateOrderRequest
+}
+
+func (f *fakeExchange) GetAccount(context.Context) (*models.Account, error) {
+	acc := &models.Account{}
+	for asset, free := range f.free {
+		acc.Balances = append(acc.Balances, models.Balance{Asset: asset, Free: free, Locked: "0"})
+	}
+	return acc, nil
+}
+
+func (f *fakeExchange) CreateOrder(_ context.Context, r models.CreateOrderRequest) (*models.CreateOrderResponse, error) {
+	f.orders = append(f.orders, r)
+	return &models.CreateOrderResponse{Symbol: r.Symbol, OrderID: int64(len(f.orders))}, nil
+}
+
+func TestRoundDown(t *testing.T) {
+	for _, tc := range []struct {
+		quantity, want float64
+	}{
+		{quantity: 1, want: 1},
+		{quantity: 0.123456789, want: 0.12345},
+		{quantity: 9.999999, want: 9.99999},
+		{quantity: 0.000009, want: 0},
+	} {
+		require.Equal(t, tc.want, roundDown(tc.quantity), "%v", tc.quantity)
+	}
+}
+
+func TestBuy(t *testing.T) {
+	ctx := context.Background()
+	ex := &fakeExchange{free: map[string]string{"USDT": "1000", "BTC": "2"}}
+
+	require.NoError(t, buy(ctx, ex, "BTCUSDT", "USDT", 30000, 0.99))
+	require.Equal(t, []models.CreateOrderRequest{{
+		Symbol:   "BTCUSDT",
+		Quantity: 0.033,
+		Side:     models.SideTypeBuy,
+		Type:     models.OrderTypeMarket,
+	}}, ex.orders)
+
+	// Nothing is bought when the quantity rounds down to zero.
+	ex = &fakeExchange{free: map[string]string{"USDT": "0.1"}}
+	require.NoError(t, buy(ctx, ex, "BTCUSDT", "USDT", 30000, 0.99))
+	require.Empty(t, ex.orders)
+	ex = &fakeExchange{}
+	require.NoError(t, buy(ctx, ex, "BTCUSDT", "USDT", 30000, 0.99))
+	require.Empty(t, ex.orders)
+}
+
+func TestSellAll(t *testing.T) {
+	ctx := context.Background()
+	ex := &fakeExchange{free: map[string]string{"USDT": "1000", "BTC": "1.234567"}}
+
+	require.NoError(t, sellAll(ctx, ex, "BTCUSDT", "USDT"))
+	require.Equal(t, []models.CreateOrderRequest{{
+		Symbol:   "BTCUSDT",
+		Quantity: 1.23456,
+		Side:     models.SideTypeSell,
+		Type:     models.OrderTypeMarket,
+	}}, ex.orders)
+
+	ex = &fakeExchange{free: map[string]string{"USDT": "1000"}}
+	require.NoError(t, sellAll(ctx, ex, "BTCUSDT", "USDT"))
+	require.Empty(t, ex.orders)
+}
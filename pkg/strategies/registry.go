@@ -0,0 +1,66 @@
+package strategies
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"crypto_bot/pkg/backtest"
+)
+
+type Params map[string]string
+
+type Factory func(Params) (backtest.Strategy, error)
+
+var registry = map[string]Factory{
+	"buy-and-hold": NewBuyAndHold,
+	"sma-cross":    NewSMACross,
+}
+
+func New(name string, params Params) (backtest.Strategy, error) {
+	f, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy %q, available: %v", name, Names())
+	}
+	return f(params)
+}
+
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (p Params) String(key, def string) string {
+	if v, ok := p[key]; ok {
+		return v
+	}
+	return def
+}
+
+func (p Params) Int(key string, def int) (int, error) {
+	v, ok := p[key]
+	if !ok {
+		return def, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("param %s: %w", key, err)
+	}
+	return i, nil
+}
+
+func (p Params) Float(key string, def float64) (float64, error) {
+	v, ok := p[key]
+	if !ok {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("param %s: %w", key, err)
+	}
+	return f, nil
+}
@@ -0,0 +1,40 @@
+package strategies
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNames(t *testing.T) {
+	require.Equal(t, []string{"buy-and-hold", "sma-cross"}, Names())
+}
+
+func TestNew(t *testing.T) {
+	s, err := New("buy-and-hold", nil)
+	require.NoError(t, err)
+	require.Equal(t, &BuyAndHold{quote: "USDT", fraction: 0.99, bought: map[string]bool{}}, s)
+
+	s, err = New("buy-and-hold", Params{"quote": "BTC", "fraction": "0.5"})
+	require.NoError(t, err)
+	require.Equal(t, &BuyAndHold{quote: "BTC", fraction: 0.5, bought: map[string]bool{}}, s)
+
+	s, err = New("sma-cross", Params{"fast": "5", "slow": "20"})
+	require.NoError(t, err)
+	cross := s.(*SMACross)
+	require.Equal(t, 5, cross.fast)
+	require.Equal(t, 20, cross.slow)
+	require.Equal(t, "USDT", cross.quote)
+	require.Equal(t, 0.99, cross.fraction)
+
+	_, err = New("unknown", nil)
+	require.EqualError(t, err, `unknown strategy "unknown", available: [buy-and-hold sma-cross]`)
+	_, err = New("buy-and-hold", Params{"fraction": "half"})
+	require.ErrorContains(t, err, "param fraction")
+	_, err = New("sma-cross", Params{"fast": "ten"})
+	require.ErrorContains(t, err, "param fast")
+	_, err = New("sma-cross", Params{"fast": "30", "slow": "10"})
+	require.EqualError(t, err, "periods must satisfy 0 < fast < slow")
+	_, err = New("sma-cross", Params{"fast": "0"})
+	require.Error(t, err)
+}
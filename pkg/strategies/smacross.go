@@ -0,0 +1,77 @@
+package strategies
+
+import (
+	"context"
+	"fmt"
+
+	"crypto_bot/pkg/backtest"
+	"crypto_bot/pkg/exchange"
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/indicators"
+)
+
+// SMACross buys when the fast simple moving average of closes crosses above
+// the slow one and sells everything when it crosses below. Params: fast (10),
+// slow (30), quote (USDT) and fraction (0.99) of the free quote balance to spend.
+type SMACross struct {
+	fast, slow int
+	quote      string
+	fraction   float64
+	averages   map[string][2]*indicators.SMA
+	above      map[string]bool
+}
+
+func NewSMACross(p Params) (backtest.Strategy, error) {
+	fast, err := p.Int("fast", 10)
+	if err != nil {
+		return nil, err
+	}
+	slow, err := p.Int("slow", 30)
+	if err != nil {
+		return nil, err
+	}
+	if fast <= 0 || slow <= fast {
+		return nil, fmt.Errorf("periods must satisfy 0 < fast < slow")
+	}
+	fraction, err := p.Float("fraction", 0.99)
+	if err != nil {
+		return nil, err
+	}
+	return &SMACross{
+		fast:     fast,
+		slow:     slow,
+		quote:    p.String("quote", "USDT"),
+		fraction: fraction,
+		averages: make(map[string][2]*indicators.SMA),
+		above:    make(map[string]bool),
+	}, nil
+}
+
+func (s *SMACross) OnKline(ctx context.Context, ex exchange.Client, e *models.WsKlineEvent) error {
+	key := e.Symbol + e.Kline.Interval
+	averages, ok := s.averages[key]
+	if !ok {
+		averages = [2]*indicators.SMA{indicators.NewSMA(s.fast), indicators.NewSMA(s.slow)}
+		s.averages[key] = averages
+	}
+	fast, slow := averages[0].Add(e.Kline.Close), averages[1].Add(e.Kline.Close)
+	if !averages[1].Ready() {
+		return nil
+	}
+
+	above := fast > slow
+	wasAbove, seen := s.above[key]
+	s.above[key] = above
+	switch {
+	case !seen || above == wasAbove:
+		return nil
+	case above:
+		return buy(ctx, ex, e.Symbol, s.quote, e.Kline.Close, s.fraction)
+	default:
+		return sellAll(ctx, ex, e.Symbol, s.quote)
+	}
+}
+
+func (s *SMACross) OnOrderUpdate(context.Context, exchange.Client, *models.Order) error {
+	return nil
+}
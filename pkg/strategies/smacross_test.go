@@ -0,0 +1,52 @@
+package strategies
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"crypto_bot/pkg/exchange/models"
+)
+
+func TestSMACross_OnKline(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewSMACross(Params{"fast": "2", "slow": "3"})
+	require.NoError(t, err)
+	ex := &fakeExchange{free: map[string]string{"USDT": "1000", "BTC": "1"}}
+
+	var sides []models.SideType
+	onKline := func(symbol string, close float64) {
+		t.Helper()
+		e := &models.WsKlineEvent{Symbol: symbol, Kline: models.WsKline{Symbol: symbol, Interval: "1m", Close: close}}
+		require.NoError(t, s.OnKline(ctx, ex, e))
+		sides = sides[:0]
+		for _, o := range ex.orders {
+			sides = append(sides, o.Side)
+		}
+	}
+
+	// Nothing is traded before the slow average is ready, nor on the first
+	// side seen after it.
+	onKline("BTCUSDT", 10)
+	onKline("BTCUSDT", 12)
+	onKline("BTCUSDT", 14)
+	require.Empty(t, sides)
+
+	// Crossing below sells, staying below does nothing.
+	onKline("BTCUSDT", 6)
+	require.Equal(t, []models.SideType{models.SideTypeSell}, sides)
+	onKline("BTCUSDT", 5)
+	require.Equal(t, []models.SideType{models.SideTypeSell}, sides)
+
+	// Crossing above buys at the close.
+	onKline("BTCUSDT", 20)
+	require.Equal(t, []models.SideType{models.SideTypeSell, models.SideTypeBuy}, sides)
+	require.Equal(t, roundDown(1000*0.99/20), ex.orders[1].Quantity)
+
+	// Every symbol has its own averages.
+	onKline("ETHUSDT", 1)
+	onKline("ETHUSDT", 1)
+	onKline("ETHUSDT", 1)
+	require.Len(t, sides, 2)
+}
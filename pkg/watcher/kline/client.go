@@ -3,51 +3,124 @@ package kline
 import (
 	"context"
 	"errors"
+	"expvar"
+	"fmt"
 	"log"
+	"sync"
+	"time"
 
+	"github.com/jpillora/backoff"
+
+	"crypto_bot/pkg/exchange"
 	"crypto_bot/pkg/exchange/models"
-	"crypto_bot/pkg/storage/pgdb"
+	"crypto_bot/pkg/helpers/gapfixer"
+	"crypto_bot/pkg/storage"
+)
+
+// backfillChunkSize is the number of klines requested at once while backfilling.
+const backfillChunkSize = 1000
+
+var errStreamClosed = errors.New("kline stream closed")
+
+// Metrics of every watcher of the process are published with expvar under
+// kline_watcher: klines buffered per symbol and interval, klines written and
+// reconnects.
+var (
+	metrics         = expvar.NewMap("kline_watcher")
+	bufferedKlines  = new(expvar.Map).Init()
+	writtenKlines   = new(expvar.Int)
+	reconnectsTotal = new(expvar.Int)
 )
 
+func init() {
+	metrics.Set("buffered", bufferedKlines)
+	metrics.Set("written", writtenKlines)
+	metrics.Set("reconnects", reconnectsTotal)
+}
+
 type Watcher struct {
 	db        Storage
 	ex        Exchange
-	symbol    string
-	interval  string
+	symbols   []string
+	intervals []string
+	pairs     []models.WsKlineRequest
 	chunkSize int
 	debug     bool
 
+	flushInterval   time.Duration
+	shutdownTimeout time.Duration
+
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	buffers   map[models.WsKlineRequest][]*models.Kline
+	backfills map[models.WsKlineRequest]*pendingBackfill
+	received  bool
+
 	errHandler func(err error)
 }
 
+// pendingBackfill is a backfill of a pair due after a reconnect, a failed one
+// is retried on the next event of the pair after retryAt.
+type pendingBackfill struct {
+	backoff backoff.Backoff
+	retryAt time.Time
+}
+
 type Exchange interface {
-	WsKlines(context.Context, models.WsKlineRequest) (<-chan *models.WsKlineEvent, <-chan error, error)
+	exchange.WsKlineService
+	exchange.KlineService
+}
+
+// CombinedExchange subscribes to many pairs at once, the watcher prefers it
+// over a stream per pair when the exchange implements it.
+type CombinedExchange interface {
+	WsCombinedKlines(context.Context, models.WsCombinedKlineRequest) (<-chan *models.WsKlineEvent, <-chan error, error)
 }
 
 type Storage interface {
-	WriteKlines(context.Context, pgdb.WriteKlinesRequest) ([]*pgdb.Kline, error)
+	WriteKlines(context.Context, storage.WriteKlinesRequest) error
+	ReadKlines(context.Context, storage.ReadKlinesRequest) ([]*models.Kline, error)
+	ReadLastKline(context.Context, storage.ReadLastKlineRequest) (*models.Kline, error)
 }
 
 func NewWatcher(ex Exchange, db Storage) *Watcher {
 	return &Watcher{
 		db:        db,
 		ex:        ex,
-		symbol:    "BTCUSDT",
-		interval:  "1h",
+		symbols:   []string{"BTCUSDT"},
+		intervals: []string{"1h"},
 		chunkSize: 10,
+
+		shutdownTimeout: 10 * time.Second,
+
+		minBackoff: time.Second,
+		maxBackoff: time.Minute,
+
+		buffers:   make(map[models.WsKlineRequest][]*models.Kline),
+		backfills: make(map[models.WsKlineRequest]*pendingBackfill),
 		errHandler: func(err error) {
 			log.Println(err)
 		},
 	}
 }
 
-func (w *Watcher) SetSymbols(symbol string) *Watcher {
-	w.symbol = symbol
+// SetSymbols sets symbols to watch, each of them is watched with every interval
+// set by SetInterval.
+func (w *Watcher) SetSymbols(symbols ...string) *Watcher {
+	w.symbols = symbols
 	return w
 }
 
-func (w *Watcher) SetInterval(interval string) *Watcher {
-	w.interval = interval
+func (w *Watcher) SetInterval(intervals ...string) *Watcher {
+	w.intervals = intervals
+	return w
+}
+
+// SetPairs sets symbol and interval pairs to watch in addition to the ones
+// made of symbols and intervals.
+func (w *Watcher) SetPairs(pairs ...models.WsKlineRequest) *Watcher {
+	w.pairs = pairs
 	return w
 }
 
@@ -56,6 +129,26 @@ func (w *Watcher) SetChunkSize(size int) *Watcher {
 	return w
 }
 
+// SetFlushInterval makes the watcher write klines buffered for a table at least
+// once an interval even if they do not fill a chunk. Zero disables it.
+func (w *Watcher) SetFlushInterval(interval time.Duration) *Watcher {
+	w.flushInterval = interval
+	return w
+}
+
+// SetShutdownTimeout limits writing buffered klines after the context is done.
+func (w *Watcher) SetShutdownTimeout(timeout time.Duration) *Watcher {
+	w.shutdownTimeout = timeout
+	return w
+}
+
+// SetReconnectBackoff sets the delay before the first reconnect, it doubles with
+// every failed attempt up to max.
+func (w *Watcher) SetReconnectBackoff(min, max time.Duration) *Watcher {
+	w.minBackoff, w.maxBackoff = min, max
+	return w
+}
+
 func (w *Watcher) SetErrorHandler(handler func(error)) *Watcher {
 	w.errHandler = handler
 	return w
@@ -66,10 +159,66 @@ func (w *Watcher) SetDebug(v bool) *Watcher {
 	return w
 }
 
+// Pairs returns every symbol and interval pair the watcher subscribes to.
+func (w *Watcher) Pairs() []models.WsKlineRequest {
+	pairs := make([]models.WsKlineRequest, 0, len(w.symbols)*len(w.intervals)+len(w.pairs))
+	seen := make(map[models.WsKlineRequest]bool)
+	add := func(p models.WsKlineRequest) {
+		if !seen[p] {
+			seen[p] = true
+			pairs = append(pairs, p)
+		}
+	}
+	for _, symbol := range w.symbols {
+		for _, interval := range w.intervals {
+			add(models.WsKlineRequest{Symbol: symbol, Interval: interval})
+		}
+	}
+	for _, p := range w.pairs {
+		add(p)
+	}
+	return pairs
+}
+
+// Start watches klines until ctx is done. When the stream drops, it reconnects with
+// exponential backoff and, before resuming, backfills klines missed since the last
+// stored ones with the REST api. A failed backfill is reported to the error
+// handler and retried with backoff per pair while the stream stays up.
 func (w *Watcher) Start(ctx context.Context) error {
-	events, errs, err := w.ex.WsKlines(ctx, models.WsKlineRequest{Symbol: w.symbol, Interval: w.interval})
+	pairs := w.Pairs()
+	if len(pairs) == 0 {
+		return errors.New("no symbols to watch")
+	}
+
+	b := &backoff.Backoff{Min: w.minBackoff, Max: w.maxBackoff, Factor: 2, Jitter: true}
+	for reconnect := false; ; reconnect = true {
+		err := w.watch(ctx, pairs, reconnect)
+		if ctx.Err() != nil {
+			return err
+		}
+		if w.received {
+			b.Reset()
+		}
+		d := b.Duration()
+		reconnectsTotal.Add(1)
+		w.errHandler(fmt.Errorf("reconnect in %s: %w", d, err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+		}
+	}
+}
+
+// watch processes klines of one connection until it drops.
+func (w *Watcher) watch(ctx context.Context, pairs []models.WsKlineRequest, backfill bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w.received = false
+	events, errs, err := w.subscribe(ctx, pairs)
 	if err != nil {
-		return err
+		return fmt.Errorf("subscribe: %w", err)
 	}
 
 	go func() {
@@ -78,56 +227,206 @@ func (w *Watcher) Start(ctx context.Context) error {
 		}
 	}()
 
+	if backfill {
+		for _, p := range pairs {
+			w.backfills[p] = &pendingBackfill{
+				backoff: backoff.Backoff{Min: w.minBackoff, Max: w.maxBackoff, Factor: 2, Jitter: true},
+			}
+		}
+	}
+	var flush <-chan time.Time
+	if w.flushInterval > 0 {
+		ticker := time.NewTicker(w.flushInterval)
+		defer ticker.Stop()
+		flush = ticker.C
+	}
 	for {
-		if err = w.processChunk(ctx, events); err != nil {
+		if err = w.processChunk(ctx, events, flush); err != nil {
 			return err
 		}
 	}
 }
 
-func (w *Watcher) processChunk(ctx context.Context, events <-chan *models.WsKlineEvent) (err error) {
-	klinesToWrite := make([]*pgdb.Kline, 0, w.chunkSize)
-	defer func() {
-		if err != nil && len(klinesToWrite) == 0 {
-			return
+// subscribe opens a combined stream if the exchange supports it or a stream
+// per pair and merges them into one otherwise.
+func (w *Watcher) subscribe(ctx context.Context, pairs []models.WsKlineRequest) (<-chan *models.WsKlineEvent, <-chan error, error) {
+	if len(pairs) == 0 {
+		return nil, nil, errors.New("no symbols to watch")
+	}
+	if ex, ok := w.ex.(CombinedExchange); ok {
+		return ex.WsCombinedKlines(ctx, models.WsCombinedKlineRequest{Pairs: pairs})
+	}
+	ctx, cancel := context.WithCancel(ctx)
+
+	events := make(chan *models.WsKlineEvent, 100)
+	errs := make(chan error, 100)
+	var wg sync.WaitGroup
+	for _, p := range pairs {
+		pairEvents, pairErrs, err := w.ex.WsKlines(ctx, p)
+		if err != nil {
+			cancel()
+			wg.Wait()
+			return nil, nil, err
 		}
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for e := range pairEvents {
+				select {
+				case events <- e:
+				case <-ctx.Done():
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for e := range pairErrs {
+				select {
+				case errs <- e:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		cancel()
+		close(events)
+		close(errs)
+	}()
+	return events, errs, nil
+}
 
-		_, writeErr := w.db.WriteKlines(ctx, pgdb.WriteKlinesRequest{
-			Symbol:   w.symbol,
-			Interval: w.interval,
-			Klines:   klinesToWrite,
-		})
-		if writeErr != nil {
-			err = errors.Join(err, writeErr)
-			return
+// processChunk reads events until a table collects chunk size klines and writes them.
+// If flush fires, the context is done or the stream is closed, it writes everything
+// collected so far.
+func (w *Watcher) processChunk(ctx context.Context, events <-chan *models.WsKlineEvent, flush <-chan time.Time) (err error) {
+	var full []models.WsKlineRequest
+	defer func() {
+		if err != nil {
+			full = w.buffered()
 		}
-		if w.debug {
-			log.Printf("wrote %d klines", len(klinesToWrite))
+		writeCtx := ctx
+		if ctx.Err() != nil {
+			var cancel context.CancelFunc
+			writeCtx, cancel = context.WithTimeout(context.WithoutCancel(ctx), w.shutdownTimeout)
+			defer cancel()
+		}
+		for _, p := range full {
+			if writeErr := w.write(writeCtx, p); writeErr != nil {
+				err = errors.Join(err, writeErr)
+			}
 		}
 	}()
 
-	for event := range events {
+	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		default:
+		case <-flush:
+			full = w.buffered()
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return errStreamClosed
+			}
+			p := models.WsKlineRequest{Symbol: event.Symbol, Interval: event.Kline.Interval}
+			w.received = true
+			if b := w.backfills[p]; b != nil {
+				// Klines of the pair are not buffered until its backfill
+				// succeeds, written ones would hide the gap from the retry,
+				// which fetches them instead.
+				if time.Now().Before(b.retryAt) {
+					continue
+				}
+				if backfillErr := w.backfill(ctx, p, event.Kline.StartTime); backfillErr != nil {
+					if ctx.Err() != nil {
+						return ctx.Err()
+					}
+					d := b.backoff.Duration()
+					b.retryAt = time.Now().Add(d)
+					w.errHandler(fmt.Errorf("backfill %s %s, retry in %s: %w", p.Symbol, p.Interval, d, backfillErr))
+					continue
+				}
+				delete(w.backfills, p)
+			}
 			if !event.Kline.IsFinal {
 				continue
 			}
-			klinesToWrite = append(klinesToWrite, &pgdb.Kline{
-				OpenTime:  event.Kline.StartTime,
-				Open:      event.Kline.Open,
-				High:      event.Kline.High,
-				Low:       event.Kline.Low,
-				Close:     event.Kline.Close,
-				Volume:    event.Kline.Volume,
-				CloseTime: event.Kline.EndTime,
-				TradeNum:  event.Kline.TradeNum,
+			w.buffers[p] = append(w.buffers[p], &models.Kline{
+				OpenTime:                 event.Kline.StartTime,
+				Open:                     event.Kline.Open,
+				High:                     event.Kline.High,
+				Low:                      event.Kline.Low,
+				Close:                    event.Kline.Close,
+				Volume:                   event.Kline.Volume,
+				CloseTime:                event.Kline.EndTime,
+				TradeNum:                 event.Kline.TradeNum,
+				QuoteAssetVolume:         event.Kline.QuoteVolume,
+				TakerBuyBaseAssetVolume:  event.Kline.ActiveBuyVolume,
+				TakerBuyQuoteAssetVolume: event.Kline.ActiveBuyQuoteVolume,
+				FirstTradeID:             event.Kline.FirstTradeID,
+				LastTradeID:              event.Kline.LastTradeID,
 			})
-			if len(klinesToWrite) >= w.chunkSize {
+			bufferedKlines.Add(metricKey(p), 1)
+			if len(w.buffers[p]) >= w.chunkSize {
+				full = append(full, p)
 				return nil
 			}
 		}
 	}
+}
+
+// backfill writes klines opened after the last stored one and before until.
+// Nothing is written if there are no klines stored yet.
+func (w *Watcher) backfill(ctx context.Context, p models.WsKlineRequest, until int64) error {
+	last, err := w.db.ReadLastKline(ctx, storage.ReadLastKlineRequest{Symbol: p.Symbol, Interval: p.Interval})
+	if errors.Is(err, storage.ErrNoKlines) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read last kline: %w", err)
+	}
+	if last.CloseTime+1 >= until {
+		return nil
+	}
+	if w.debug {
+		log.Printf("backfill %s %s from %d to %d", p.Symbol, p.Interval, last.CloseTime+1, until-1)
+	}
+	return gapfixer.FixGaps(ctx, w.ex, w.db, p.Symbol, p.Interval,
+		time.UnixMilli(last.CloseTime+1), time.UnixMilli(until-1), backfillChunkSize)
+}
+
+func (w *Watcher) write(ctx context.Context, p models.WsKlineRequest) error {
+	klines := w.buffers[p]
+	err := w.db.WriteKlines(ctx, storage.WriteKlinesRequest{
+		Symbol:   p.Symbol,
+		Interval: p.Interval,
+		Klines:   klines,
+	})
+	if err != nil {
+		return err
+	}
+	delete(w.buffers, p)
+	bufferedKlines.Add(metricKey(p), -int64(len(klines)))
+	writtenKlines.Add(int64(len(klines)))
+	if w.debug {
+		log.Printf("wrote %d klines of %s %s", len(klines), p.Symbol, p.Interval)
+	}
 	return nil
 }
+
+// buffered returns pairs with buffered klines.
+func (w *Watcher) buffered() []models.WsKlineRequest {
+	var pairs []models.WsKlineRequest
+	for p, klines := range w.buffers {
+		if len(klines) > 0 {
+			pairs = append(pairs, p)
+		}
+	}
+	return pairs
+}
+
+func metricKey(p models.WsKlineRequest) string {
+	return p.Symbol + "_" + p.Interval
+}
@@ -0,0 +1,375 @@
+package kline
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage"
+)
+
+const minute = int64(time.Minute / time.Millisecond)
+
+type fakeExchange struct {
+	mu         sync.Mutex
+	streams    map[models.WsKlineRequest]chan *models.WsKlineEvent
+	errs       map[models.WsKlineRequest]chan error
+	subscribed chan models.WsKlineRequest
+	klines     []*models.Kline
+	// klinesErr is returned by the next Klines call.
+	klinesErr error
+}
+
+func newFakeExchange() *fakeExchange {
+	return &fakeExchange{
+		streams:    make(map[models.WsKlineRequest]chan *models.WsKlineEvent),
+		errs:       make(map[models.WsKlineRequest]chan error),
+		subscribed: make(chan models.WsKlineRequest, 100),
+	}
+}
+
+func (ex *fakeExchange) WsKlines(_ context.Context, r models.WsKlineRequest) (<-chan *models.WsKlineEvent, <-chan error, error) {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	ex.streams[r] = make(chan *models.WsKlineEvent, 10)
+	ex.errs[r] = make(chan error)
+	ex.subscribed <- r
+	return ex.streams[r], ex.errs[r], nil
+}
+
+func (ex *fakeExchange) Klines(_ context.Context, r models.KlinesRequest) ([]*models.Kline, error) {
+	ex.mu.Lock()
+	err := ex.klinesErr
+	ex.klinesErr = nil
+	ex.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var res []*models.Kline
+	for _, k := range ex.klines {
+		if k.OpenTime >= r.StartTime && k.OpenTime <= r.EndTime && len(res) < r.Limit {
+			res = append(res, k)
+		}
+	}
+	return res, nil
+}
+
+func (ex *fakeExchange) stream(p models.WsKlineRequest) chan *models.WsKlineEvent {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	return ex.streams[p]
+}
+
+// drop closes the stream as a dropped connection does.
+func (ex *fakeExchange) drop(p models.WsKlineRequest) {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	close(ex.streams[p])
+	close(ex.errs[p])
+}
+
+// send sends the kline opened at the i-th minute.
+func (ex *fakeExchange) send(p models.WsKlineRequest, i int64, final bool) {
+	ex.stream(p) <- &models.WsKlineEvent{
+		Symbol: p.Symbol,
+		Kline:  models.WsKline{StartTime: i * minute, EndTime: (i+1)*minute - 1, Interval: p.Interval, IsFinal: final},
+	}
+}
+
+type fakeStorage struct {
+	mu     sync.Mutex
+	writes []storage.WriteKlinesRequest
+	klines map[models.WsKlineRequest][]*models.Kline
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{klines: make(map[models.WsKlineRequest][]*models.Kline)}
+}
+
+func (s *fakeStorage) WriteKlines(ctx context.Context, req storage.WriteKlinesRequest) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes = append(s.writes, req)
+	p := models.WsKlineRequest{Symbol: req.Symbol, Interval: req.Interval}
+	klines := append(s.klines[p], req.Klines...)
+	sort.Slice(klines, func(i, j int) bool { return klines[i].OpenTime < klines[j].OpenTime })
+	s.klines[p] = klines
+	return nil
+}
+
+func (s *fakeStorage) ReadKlines(_ context.Context, req storage.ReadKlinesRequest) ([]*models.Kline, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []*models.Kline
+	for _, k := range s.klines[models.WsKlineRequest{Symbol: req.Symbol, Interval: req.Interval}] {
+		if k.OpenTime >= req.OpenTime && k.CloseTime <= req.CloseTime && uint64(len(res)) < req.Limit {
+			res = append(res, k)
+		}
+	}
+	return res, nil
+}
+
+func (s *fakeStorage) ReadLastKline(_ context.Context, req storage.ReadLastKlineRequest) (*models.Kline, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	klines := s.klines[models.WsKlineRequest{Symbol: req.Symbol, Interval: req.Interval}]
+	if len(klines) == 0 {
+		return nil, storage.ErrNoKlines
+	}
+	return klines[len(klines)-1], nil
+}
+
+func (s *fakeStorage) openTimes(p models.WsKlineRequest) []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []int64
+	for _, k := range s.klines[p] {
+		res = append(res, k.OpenTime/minute)
+	}
+	return res
+}
+
+func TestWatcher_Pairs(t *testing.T) {
+	w := NewWatcher(nil, nil).
+		SetSymbols("BTCUSDT", "ETHUSDT").
+		SetInterval("1m", "1h").
+		SetPairs(models.WsKlineRequest{Symbol: "BNBUSDT", Interval: "5m"}, models.WsKlineRequest{Symbol: "BTCUSDT", Interval: "1m"})
+	require.Equal(t, []models.WsKlineRequest{
+		{Symbol: "BTCUSDT", Interval: "1m"},
+		{Symbol: "BTCUSDT", Interval: "1h"},
+		{Symbol: "ETHUSDT", Interval: "1m"},
+		{Symbol: "ETHUSDT", Interval: "1h"},
+		{Symbol: "BNBUSDT", Interval: "5m"},
+	}, w.Pairs())
+}
+
+func TestWatcher_processChunk(t *testing.T) {
+	btc := models.WsKlineRequest{Symbol: "BTCUSDT", Interval: "1m"}
+	eth := models.WsKlineRequest{Symbol: "ETHUSDT", Interval: "1h"}
+	ex, db := newFakeExchange(), newFakeStorage()
+	w := NewWatcher(ex, db).SetSymbols().SetPairs(btc, eth).SetChunkSize(2)
+
+	ctx := context.Background()
+	events, _, err := w.subscribe(ctx, w.Pairs())
+	require.NoError(t, err)
+
+	ex.send(btc, 1, true)
+	ex.send(eth, 1, false)
+	ex.send(eth, 1, true)
+	ex.send(btc, 2, true)
+	require.NoError(t, w.processChunk(ctx, events, nil))
+	require.Len(t, db.writes, 1)
+	require.Equal(t, "BTCUSDT", db.writes[0].Symbol)
+	require.Equal(t, "1m", db.writes[0].Interval)
+	require.Equal(t, []int64{1, 2}, db.openTimes(btc))
+
+	ex.send(eth, 2, true)
+	require.NoError(t, w.processChunk(ctx, events, nil))
+	require.Len(t, db.writes, 2)
+	require.Equal(t, "ETHUSDT", db.writes[1].Symbol)
+	require.Len(t, db.writes[1].Klines, 2)
+	require.Empty(t, w.buffers)
+}
+
+func TestWatcher_processChunkFields(t *testing.T) {
+	btc := models.WsKlineRequest{Symbol: "BTCUSDT", Interval: "1m"}
+	ex, db := newFakeExchange(), newFakeStorage()
+	w := NewWatcher(ex, db).SetSymbols("BTCUSDT").SetInterval("1m").SetChunkSize(1)
+
+	ctx := context.Background()
+	events, _, err := w.subscribe(ctx, w.Pairs())
+	require.NoError(t, err)
+
+	ex.stream(btc) <- &models.WsKlineEvent{
+		Symbol: "BTCUSDT",
+		Kline: models.WsKline{
+			StartTime: 0, EndTime: minute - 1, Interval: "1m", FirstTradeID: 10, LastTradeID: 14,
+			Open: 1, Close: 2, High: 3, Low: 0.5, Volume: 7, TradeNum: 5, IsFinal: true,
+			QuoteVolume: 12, ActiveBuyVolume: 4, ActiveBuyQuoteVolume: 6,
+		},
+	}
+	require.NoError(t, w.processChunk(ctx, events, nil))
+	require.Equal(t, []*models.Kline{{
+		OpenTime: 0, Open: 1, High: 3, Low: 0.5, Close: 2, Volume: 7, CloseTime: minute - 1, TradeNum: 5,
+		QuoteAssetVolume: 12, TakerBuyBaseAssetVolume: 4, TakerBuyQuoteAssetVolume: 6, FirstTradeID: 10, LastTradeID: 14,
+	}}, db.writes[0].Klines)
+}
+
+func TestWatcher_Start(t *testing.T) {
+	btc := models.WsKlineRequest{Symbol: "BTCUSDT", Interval: "1m"}
+	ex, db := newFakeExchange(), newFakeStorage()
+	for i := int64(0); i < 10; i++ {
+		ex.klines = append(ex.klines, &models.Kline{OpenTime: i * minute, CloseTime: (i+1)*minute - 1})
+	}
+	var mu sync.Mutex
+	var errs []error
+	w := NewWatcher(ex, db).
+		SetSymbols("BTCUSDT").
+		SetInterval("1m").
+		SetChunkSize(2).
+		SetReconnectBackoff(time.Millisecond, 10*time.Millisecond).
+		SetErrorHandler(func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Start(ctx) }()
+
+	require.Equal(t, btc, <-ex.subscribed)
+	ex.send(btc, 0, true)
+	ex.send(btc, 1, false)
+	ex.drop(btc)
+
+	// The buffered kline is written when the stream drops, klines missed
+	// during the reconnect are backfilled before the stream resumes.
+	require.Equal(t, btc, <-ex.subscribed)
+	ex.send(btc, 4, false)
+	require.Eventually(t, func() bool { return len(db.openTimes(btc)) == 4 }, time.Second, time.Millisecond)
+	require.Equal(t, []int64{0, 1, 2, 3}, db.openTimes(btc))
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, errs, 1)
+	require.ErrorIs(t, errs[0], errStreamClosed)
+}
+
+func TestWatcher_StartBackfillRetry(t *testing.T) {
+	btc := models.WsKlineRequest{Symbol: "BTCUSDT", Interval: "1m"}
+	eth := models.WsKlineRequest{Symbol: "ETHUSDT", Interval: "1m"}
+	ex, db := newFakeExchange(), newFakeStorage()
+	for i := int64(0); i < 10; i++ {
+		ex.klines = append(ex.klines, &models.Kline{OpenTime: i * minute, CloseTime: (i+1)*minute - 1})
+	}
+	var mu sync.Mutex
+	var errs []error
+	w := NewWatcher(ex, db).
+		SetSymbols("BTCUSDT", "ETHUSDT").
+		SetInterval("1m").
+		SetChunkSize(2).
+		SetReconnectBackoff(time.Millisecond, 10*time.Millisecond).
+		SetErrorHandler(func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Start(ctx) }()
+
+	<-ex.subscribed
+	<-ex.subscribed
+	ex.send(btc, 0, true)
+	ex.send(eth, 0, true)
+	ex.drop(btc)
+	ex.drop(eth)
+
+	// The failed backfill of one pair neither drops the connection nor holds
+	// back the others.
+	errTooManyRequests := errors.New("too many requests")
+	ex.mu.Lock()
+	ex.klinesErr = errTooManyRequests
+	ex.mu.Unlock()
+	<-ex.subscribed
+	<-ex.subscribed
+	ex.send(eth, 4, false)
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(errs) == 2
+	}, time.Second, time.Millisecond)
+	ex.send(btc, 4, false)
+	require.Eventually(t, func() bool { return len(db.openTimes(btc)) == 4 }, time.Second, time.Millisecond)
+	require.Equal(t, []int64{0, 1, 2, 3}, db.openTimes(btc))
+	require.Equal(t, []int64{0}, db.openTimes(eth))
+
+	// The backfill is retried on the next event of the pair after the backoff.
+	time.Sleep(20 * time.Millisecond)
+	ex.send(eth, 5, false)
+	require.Eventually(t, func() bool { return len(db.openTimes(eth)) == 5 }, time.Second, time.Millisecond)
+	require.Equal(t, []int64{0, 1, 2, 3, 4}, db.openTimes(eth))
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+	require.Empty(t, ex.subscribed)
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, errs, 2)
+	require.ErrorIs(t, errs[0], errStreamClosed)
+	require.ErrorIs(t, errs[1], errTooManyRequests)
+}
+
+func TestWatcher_flush(t *testing.T) {
+	btc := models.WsKlineRequest{Symbol: "BTCUSDT", Interval: "1m"}
+	start := func(flushInterval time.Duration) (*fakeExchange, *fakeStorage, context.CancelFunc, <-chan error) {
+		ex, db := newFakeExchange(), newFakeStorage()
+		w := NewWatcher(ex, db).
+			SetSymbols("BTCUSDT").
+			SetInterval("1m").
+			SetChunkSize(10).
+			SetFlushInterval(flushInterval)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- w.Start(ctx) }()
+		<-ex.subscribed
+		return ex, db, cancel, done
+	}
+
+	t.Run("interval", func(t *testing.T) {
+		ex, db, cancel, done := start(10 * time.Millisecond)
+		defer func() { cancel(); <-done }()
+
+		ex.send(btc, 0, true)
+		require.Eventually(t, func() bool { return len(db.openTimes(btc)) == 1 }, time.Second, time.Millisecond)
+	})
+
+	t.Run("shutdown", func(t *testing.T) {
+		ex, db, cancel, done := start(0)
+
+		ex.send(btc, 0, true)
+		require.Eventually(t, func() bool { return bufferedKlines.Get(metricKey(btc)).String() == "1" }, time.Second, time.Millisecond)
+		cancel()
+		require.ErrorIs(t, <-done, context.Canceled)
+		require.Equal(t, []int64{0}, db.openTimes(btc))
+		require.Equal(t, "0", bufferedKlines.Get(metricKey(btc)).String())
+	})
+}
+
+type fakeCombinedExchange struct {
+	*fakeExchange
+	requests []models.WsCombinedKlineRequest
+}
+
+func (ex *fakeCombinedExchange) WsCombinedKlines(ctx context.Context, r models.WsCombinedKlineRequest) (<-chan *models.WsKlineEvent, <-chan error, error) {
+	ex.requests = append(ex.requests, r)
+	return ex.WsKlines(ctx, r.Pairs[0])
+}
+
+func TestWatcher_subscribeCombined(t *testing.T) {
+	btc := models.WsKlineRequest{Symbol: "BTCUSDT", Interval: "1m"}
+	eth := models.WsKlineRequest{Symbol: "ETHUSDT", Interval: "1m"}
+	ex := &fakeCombinedExchange{fakeExchange: newFakeExchange()}
+	w := NewWatcher(ex, newFakeStorage()).SetSymbols("BTCUSDT", "ETHUSDT").SetInterval("1m")
+
+	events, _, err := w.subscribe(context.Background(), w.Pairs())
+	require.NoError(t, err)
+	require.Equal(t, []models.WsCombinedKlineRequest{{Pairs: []models.WsKlineRequest{btc, eth}}}, ex.requests)
+
+	ex.send(btc, 1, true)
+	require.Equal(t, minute, (<-events).Kline.StartTime)
+}
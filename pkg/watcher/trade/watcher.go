@@ -0,0 +1,408 @@
+package trade
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jpillora/backoff"
+
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/helpers/gapfixer"
+	"crypto_bot/pkg/storage"
+)
+
+const (
+	// backfillChunkSize is the number of trades requested at once while backfilling.
+	backfillChunkSize = 1000
+	// defaultWeightLimit is the request weight backfills spend per minute by
+	// default, half of what Binance allows.
+	defaultWeightLimit = 3000
+)
+
+var errStreamClosed = errors.New("trade stream closed")
+
+// Metrics of every watcher of the process are published with expvar under
+// trade_watcher: trades buffered per symbol, trades written and reconnects.
+var (
+	metrics         = expvar.NewMap("trade_watcher")
+	bufferedTrades  = new(expvar.Map).Init()
+	writtenTrades   = new(expvar.Int)
+	reconnectsTotal = new(expvar.Int)
+)
+
+func init() {
+	metrics.Set("buffered", bufferedTrades)
+	metrics.Set("written", writtenTrades)
+	metrics.Set("reconnects", reconnectsTotal)
+}
+
+type Exchange interface {
+	WsTrades(context.Context, models.WsTradeRequest) (<-chan *models.WsTradeEvent, <-chan error, error)
+	WsAggTrades(context.Context, models.WsTradeRequest) (<-chan *models.WsAggTradeEvent, <-chan error, error)
+	Trades(context.Context, models.TradesRequest) ([]*models.Trade, error)
+	AggTrades(context.Context, models.AggTradesRequest) ([]*models.AggTrade, error)
+}
+
+type Storage interface {
+	WriteTrades(context.Context, storage.WriteTradesRequest) error
+	WriteAggTrades(context.Context, storage.WriteAggTradesRequest) error
+	ReadLastTrade(context.Context, storage.ReadLastTradeRequest) (*models.Trade, error)
+	ReadLastAggTrade(context.Context, storage.ReadLastTradeRequest) (*models.AggTrade, error)
+	ReadTradeGaps(context.Context, storage.ReadTradeGapsRequest) ([]*storage.TradeGap, error)
+}
+
+// Watcher collects trades or aggregate trades of symbols, as the kline watcher
+// collects klines.
+type Watcher struct {
+	db        Storage
+	ex        Exchange
+	symbols   []string
+	agg       bool
+	chunkSize int
+	debug     bool
+
+	flushInterval   time.Duration
+	shutdownTimeout time.Duration
+
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	limiter    gapfixer.Limiter
+
+	buffers   map[string]*buffer
+	backfills map[string]bool
+	received  bool
+
+	errHandler func(err error)
+}
+
+// buffer holds trades of a symbol not written yet, only the ones of the kind
+// the watcher collects are set.
+type buffer struct {
+	trades    []*models.Trade
+	aggTrades []*models.AggTrade
+}
+
+func (b *buffer) len() int {
+	return len(b.trades) + len(b.aggTrades)
+}
+
+// event is a trade or an aggregate trade of a stream.
+type event struct {
+	symbol   string
+	id       int64
+	trade    *models.Trade
+	aggTrade *models.AggTrade
+}
+
+func NewWatcher(ex Exchange, db Storage) *Watcher {
+	return &Watcher{
+		db:        db,
+		ex:        ex,
+		symbols:   []string{"BTCUSDT"},
+		chunkSize: 1000,
+
+		shutdownTimeout: 10 * time.Second,
+
+		minBackoff: time.Second,
+		maxBackoff: time.Minute,
+		limiter:    gapfixer.NewWeightLimiter(defaultWeightLimit),
+
+		buffers:   make(map[string]*buffer),
+		backfills: make(map[string]bool),
+		errHandler: func(err error) {
+			log.Println(err)
+		},
+	}
+}
+
+func (w *Watcher) SetSymbols(symbols ...string) *Watcher {
+	w.symbols = symbols
+	return w
+}
+
+// SetAgg makes the watcher collect aggregate trades instead of trades.
+func (w *Watcher) SetAgg(agg bool) *Watcher {
+	w.agg = agg
+	return w
+}
+
+func (w *Watcher) SetChunkSize(size int) *Watcher {
+	w.chunkSize = size
+	return w
+}
+
+// SetFlushInterval makes the watcher write trades buffered for a symbol at least
+// once an interval even if they do not fill a chunk. Zero disables it.
+func (w *Watcher) SetFlushInterval(interval time.Duration) *Watcher {
+	w.flushInterval = interval
+	return w
+}
+
+// SetShutdownTimeout limits writing buffered trades after the context is done.
+func (w *Watcher) SetShutdownTimeout(timeout time.Duration) *Watcher {
+	w.shutdownTimeout = timeout
+	return w
+}
+
+// SetReconnectBackoff sets the delay before the first reconnect, it doubles with
+// every failed attempt up to max.
+func (w *Watcher) SetReconnectBackoff(min, max time.Duration) *Watcher {
+	w.minBackoff, w.maxBackoff = min, max
+	return w
+}
+
+// SetLimiter sets the limiter backfill requests to the exchange wait for.
+func (w *Watcher) SetLimiter(limiter gapfixer.Limiter) *Watcher {
+	w.limiter = limiter
+	return w
+}
+
+func (w *Watcher) SetErrorHandler(handler func(error)) *Watcher {
+	w.errHandler = handler
+	return w
+}
+
+func (w *Watcher) SetDebug(v bool) *Watcher {
+	w.debug = v
+	return w
+}
+
+// Start watches trades until ctx is done. When the stream drops, it reconnects
+// with exponential backoff and, before resuming, backfills trades missed since
+// the last stored ones by their ids with the REST api.
+func (w *Watcher) Start(ctx context.Context) error {
+	if len(w.symbols) == 0 {
+		return errors.New("no symbols to watch")
+	}
+
+	b := &backoff.Backoff{Min: w.minBackoff, Max: w.maxBackoff, Factor: 2, Jitter: true}
+	for reconnect := false; ; reconnect = true {
+		err := w.watch(ctx, reconnect)
+		if ctx.Err() != nil {
+			return err
+		}
+		if w.received {
+			b.Reset()
+		}
+		d := b.Duration()
+		reconnectsTotal.Add(1)
+		w.errHandler(fmt.Errorf("reconnect in %s: %w", d, err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+		}
+	}
+}
+
+// watch processes trades of one connection until it drops.
+func (w *Watcher) watch(ctx context.Context, backfill bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w.received = false
+	events, errs, err := w.subscribe(ctx)
+	if err != nil {
+		return fmt.Errorf("subscribe: %w", err)
+	}
+
+	go func() {
+		for e := range errs {
+			w.errHandler(e)
+		}
+	}()
+
+	if backfill {
+		for _, s := range w.symbols {
+			w.backfills[strings.ToUpper(s)] = true
+		}
+	}
+	var flush <-chan time.Time
+	if w.flushInterval > 0 {
+		ticker := time.NewTicker(w.flushInterval)
+		defer ticker.Stop()
+		flush = ticker.C
+	}
+	for {
+		if err = w.processChunk(ctx, events, flush); err != nil {
+			return err
+		}
+	}
+}
+
+// subscribe opens the stream of the collected kind and converts its events.
+func (w *Watcher) subscribe(ctx context.Context) (<-chan event, <-chan error, error) {
+	events := make(chan event, 100)
+	send := func(e event) {
+		select {
+		case events <- e:
+		case <-ctx.Done():
+		}
+	}
+	req := models.WsTradeRequest{Symbols: w.symbols}
+	if w.agg {
+		aggEvents, errs, err := w.ex.WsAggTrades(ctx, req)
+		if err != nil {
+			return nil, nil, err
+		}
+		go func() {
+			defer close(events)
+			for e := range aggEvents {
+				send(event{symbol: e.Symbol, id: e.AggTrade.ID, aggTrade: &e.AggTrade})
+			}
+		}()
+		return events, errs, nil
+	}
+	tradeEvents, errs, err := w.ex.WsTrades(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	go func() {
+		defer close(events)
+		for e := range tradeEvents {
+			send(event{symbol: e.Symbol, id: e.Trade.ID, trade: &e.Trade})
+		}
+	}()
+	return events, errs, nil
+}
+
+// processChunk reads events until a symbol collects chunk size trades and writes
+// them. If flush fires, the context is done or the stream is closed, it writes
+// everything collected so far.
+func (w *Watcher) processChunk(ctx context.Context, events <-chan event, flush <-chan time.Time) (err error) {
+	var full []string
+	defer func() {
+		if err != nil {
+			full = w.buffered()
+		}
+		writeCtx := ctx
+		if ctx.Err() != nil {
+			var cancel context.CancelFunc
+			writeCtx, cancel = context.WithTimeout(context.WithoutCancel(ctx), w.shutdownTimeout)
+			defer cancel()
+		}
+		for _, symbol := range full {
+			if writeErr := w.write(writeCtx, symbol); writeErr != nil {
+				err = errors.Join(err, writeErr)
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-flush:
+			full = w.buffered()
+			return nil
+		case e, ok := <-events:
+			if !ok {
+				return errStreamClosed
+			}
+			if w.backfills[e.symbol] {
+				if backfillErr := w.backfill(ctx, e.symbol, e.id); backfillErr != nil {
+					// The symbol keeps its flag, so the backfill is retried after
+					// the reconnect and Start keeps backing off while it fails.
+					w.received = false
+					return fmt.Errorf("backfill %s: %w", e.symbol, backfillErr)
+				}
+				delete(w.backfills, e.symbol)
+			}
+			w.received = true
+			b := w.buffers[e.symbol]
+			if b == nil {
+				b = &buffer{}
+				w.buffers[e.symbol] = b
+			}
+			if e.aggTrade != nil {
+				b.aggTrades = append(b.aggTrades, e.aggTrade)
+			} else {
+				b.trades = append(b.trades, e.trade)
+			}
+			bufferedTrades.Add(e.symbol, 1)
+			if b.len() >= w.chunkSize {
+				full = append(full, e.symbol)
+				return nil
+			}
+		}
+	}
+}
+
+// backfill writes trades with ids after the last stored one and before until.
+// Nothing is written if there are no trades stored yet.
+func (w *Watcher) backfill(ctx context.Context, symbol string, until int64) error {
+	last, err := w.lastID(ctx, symbol)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read last trade: %w", err)
+	}
+	if last+1 >= until {
+		return nil
+	}
+	if w.debug {
+		log.Printf("backfill %s trades from %d to %d", symbol, last+1, until-1)
+	}
+	_, err = gapfixer.FixTradeGaps(ctx, w.ex, w.db, w.limiter, gapfixer.FixTradeGapsRequest{
+		Symbol:    symbol,
+		FromID:    last + 1,
+		ToID:      until - 1,
+		Agg:       w.agg,
+		ChunkSize: backfillChunkSize,
+	})
+	return err
+}
+
+func (w *Watcher) lastID(ctx context.Context, symbol string) (int64, error) {
+	if w.agg {
+		t, err := w.db.ReadLastAggTrade(ctx, storage.ReadLastTradeRequest{Symbol: symbol})
+		if err != nil {
+			return 0, err
+		}
+		return t.ID, nil
+	}
+	t, err := w.db.ReadLastTrade(ctx, storage.ReadLastTradeRequest{Symbol: symbol})
+	if err != nil {
+		return 0, err
+	}
+	return t.ID, nil
+}
+
+func (w *Watcher) write(ctx context.Context, symbol string) error {
+	b := w.buffers[symbol]
+	var err error
+	if w.agg {
+		err = w.db.WriteAggTrades(ctx, storage.WriteAggTradesRequest{Symbol: symbol, AggTrades: b.aggTrades})
+	} else {
+		err = w.db.WriteTrades(ctx, storage.WriteTradesRequest{Symbol: symbol, Trades: b.trades})
+	}
+	if err != nil {
+		return err
+	}
+	n := b.len()
+	delete(w.buffers, symbol)
+	bufferedTrades.Add(symbol, -int64(n))
+	writtenTrades.Add(int64(n))
+	if w.debug {
+		log.Printf("wrote %d trades of %s", n, symbol)
+	}
+	return nil
+}
+
+// buffered returns symbols with buffered trades.
+func (w *Watcher) buffered() []string {
+	var symbols []string
+	for symbol, b := range w.buffers {
+		if b.len() > 0 {
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols
+}
@@ -0,0 +1,356 @@
+package trade
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+
+	"crypto_bot/pkg/exchange/models"
+	"crypto_bot/pkg/storage"
+)
+
+type fakeExchange struct {
+	mu         sync.Mutex
+	trades     chan *models.WsTradeEvent
+	aggTrades  chan *models.WsAggTradeEvent
+	errs       chan error
+	subscribed chan models.WsTradeRequest
+	history    []*models.Trade
+	aggHistory []*models.AggTrade
+	// tradesErr is returned by the next Trades call.
+	tradesErr error
+}
+
+func newFakeExchange() *fakeExchange {
+	return &fakeExchange{subscribed: make(chan models.WsTradeRequest, 100)}
+}
+
+func (ex *fakeExchange) WsTrades(_ context.Context, r models.WsTradeRequest) (<-chan *models.WsTradeEvent, <-chan error, error) {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	ex.trades = make(chan *models.WsTradeEvent, 10)
+	ex.errs = make(chan error)
+	ex.subscribed <- r
+	return ex.trades, ex.errs, nil
+}
+
+func (ex *fakeExchange) WsAggTrades(_ context.Context, r models.WsTradeRequest) (<-chan *models.WsAggTradeEvent, <-chan error, error) {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	ex.aggTrades = make(chan *models.WsAggTradeEvent, 10)
+	ex.errs = make(chan error)
+	ex.subscribed <- r
+	return ex.aggTrades, ex.errs, nil
+}
+
+func (ex *fakeExchange) Trades(_ context.Context, r models.TradesRequest) ([]*models.Trade, error) {
+	ex.mu.Lock()
+	err := ex.tradesErr
+	ex.tradesErr = nil
+	ex.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var res []*models.Trade
+	for _, t := range ex.history {
+		if t.ID >= r.FromID && len(res) < r.Limit {
+			res = append(res, t)
+		}
+	}
+	return res, nil
+}
+
+func (ex *fakeExchange) AggTrades(_ context.Context, r models.AggTradesRequest) ([]*models.AggTrade, error) {
+	var res []*models.AggTrade
+	for _, t := range ex.aggHistory {
+		if t.ID >= r.FromID && len(res) < r.Limit {
+			res = append(res, t)
+		}
+	}
+	return res, nil
+}
+
+func (ex *fakeExchange) send(symbol string, id int64) {
+	ex.mu.Lock()
+	trades := ex.trades
+	ex.mu.Unlock()
+	trades <- &models.WsTradeEvent{Symbol: symbol, Trade: models.Trade{ID: id}}
+}
+
+func (ex *fakeExchange) sendAgg(symbol string, id int64) {
+	ex.mu.Lock()
+	trades := ex.aggTrades
+	ex.mu.Unlock()
+	trades <- &models.WsAggTradeEvent{Symbol: symbol, AggTrade: models.AggTrade{ID: id}}
+}
+
+// drop closes the stream as a dropped connection does.
+func (ex *fakeExchange) drop() {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	close(ex.trades)
+	close(ex.errs)
+}
+
+type fakeStorage struct {
+	mu        sync.Mutex
+	writes    []storage.WriteTradesRequest
+	trades    map[string]map[int64]*models.Trade
+	aggTrades map[string]map[int64]*models.AggTrade
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{
+		trades:    make(map[string]map[int64]*models.Trade),
+		aggTrades: make(map[string]map[int64]*models.AggTrade),
+	}
+}
+
+func (s *fakeStorage) WriteTrades(ctx context.Context, req storage.WriteTradesRequest) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes = append(s.writes, req)
+	if s.trades[req.Symbol] == nil {
+		s.trades[req.Symbol] = make(map[int64]*models.Trade)
+	}
+	for _, t := range req.Trades {
+		s.trades[req.Symbol][t.ID] = t
+	}
+	return nil
+}
+
+func (s *fakeStorage) WriteAggTrades(ctx context.Context, req storage.WriteAggTradesRequest) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.aggTrades[req.Symbol] == nil {
+		s.aggTrades[req.Symbol] = make(map[int64]*models.AggTrade)
+	}
+	for _, t := range req.AggTrades {
+		s.aggTrades[req.Symbol][t.ID] = t
+	}
+	return nil
+}
+
+func (s *fakeStorage) ReadLastTrade(_ context.Context, req storage.ReadLastTradeRequest) (*models.Trade, error) {
+	ids := s.ids(req.Symbol, false)
+	if len(ids) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	return &models.Trade{ID: ids[len(ids)-1]}, nil
+}
+
+func (s *fakeStorage) ReadLastAggTrade(_ context.Context, req storage.ReadLastTradeRequest) (*models.AggTrade, error) {
+	ids := s.ids(req.Symbol, true)
+	if len(ids) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	return &models.AggTrade{ID: ids[len(ids)-1]}, nil
+}
+
+func (s *fakeStorage) ReadTradeGaps(_ context.Context, req storage.ReadTradeGapsRequest) ([]*storage.TradeGap, error) {
+	var res []*storage.TradeGap
+	prev := req.FromID - 1
+	for _, id := range append(s.ids(req.Symbol, req.Agg), req.ToID+1) {
+		if id < req.FromID || id > req.ToID+1 {
+			continue
+		}
+		if id > prev+1 {
+			res = append(res, &storage.TradeGap{Start: prev + 1, End: id - 1})
+		}
+		prev = id
+	}
+	return res, nil
+}
+
+// ids returns the stored ids of symbol in order.
+func (s *fakeStorage) ids(symbol string, agg bool) []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []int64
+	if agg {
+		for id := range s.aggTrades[symbol] {
+			res = append(res, id)
+		}
+	} else {
+		for id := range s.trades[symbol] {
+			res = append(res, id)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res
+}
+
+func TestWatcher_processChunk(t *testing.T) {
+	ex, db := newFakeExchange(), newFakeStorage()
+	w := NewWatcher(ex, db).SetSymbols("BTCUSDT", "ETHUSDT").SetChunkSize(2)
+
+	ctx := context.Background()
+	events, _, err := w.subscribe(ctx)
+	require.NoError(t, err)
+	require.Equal(t, models.WsTradeRequest{Symbols: []string{"BTCUSDT", "ETHUSDT"}}, <-ex.subscribed)
+
+	ex.send("BTCUSDT", 1)
+	ex.send("ETHUSDT", 1)
+	ex.send("BTCUSDT", 2)
+	require.NoError(t, w.processChunk(ctx, events, nil))
+	require.Len(t, db.writes, 1)
+	require.Equal(t, "BTCUSDT", db.writes[0].Symbol)
+	require.Equal(t, []int64{1, 2}, db.ids("BTCUSDT", false))
+
+	ex.send("ETHUSDT", 2)
+	require.NoError(t, w.processChunk(ctx, events, nil))
+	require.Len(t, db.writes, 2)
+	require.Equal(t, []int64{1, 2}, db.ids("ETHUSDT", false))
+	require.Empty(t, w.buffers)
+}
+
+func TestWatcher_processChunkAgg(t *testing.T) {
+	ex, db := newFakeExchange(), newFakeStorage()
+	w := NewWatcher(ex, db).SetSymbols("BTCUSDT").SetAgg(true).SetChunkSize(2)
+
+	ctx := context.Background()
+	events, _, err := w.subscribe(ctx)
+	require.NoError(t, err)
+
+	ex.sendAgg("BTCUSDT", 5)
+	ex.sendAgg("BTCUSDT", 6)
+	require.NoError(t, w.processChunk(ctx, events, nil))
+	require.Equal(t, []int64{5, 6}, db.ids("BTCUSDT", true))
+	require.Empty(t, db.writes)
+}
+
+func TestWatcher_Start(t *testing.T) {
+	ex, db := newFakeExchange(), newFakeStorage()
+	for id := int64(0); id < 10; id++ {
+		ex.history = append(ex.history, &models.Trade{ID: id})
+	}
+	var mu sync.Mutex
+	var errs []error
+	w := NewWatcher(ex, db).
+		SetSymbols("BTCUSDT").
+		SetChunkSize(2).
+		SetReconnectBackoff(time.Millisecond, 10*time.Millisecond).
+		SetErrorHandler(func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Start(ctx) }()
+
+	<-ex.subscribed
+	ex.send("BTCUSDT", 0)
+	ex.send("BTCUSDT", 1)
+	ex.send("BTCUSDT", 2)
+	ex.drop()
+
+	// The buffered trade is written when the stream drops, trades missed
+	// during the reconnect are backfilled by id before the stream resumes.
+	<-ex.subscribed
+	ex.send("BTCUSDT", 6)
+	ex.send("BTCUSDT", 7)
+	require.Eventually(t, func() bool { return len(db.ids("BTCUSDT", false)) == 8 }, time.Second, time.Millisecond)
+	require.Equal(t, []int64{0, 1, 2, 3, 4, 5, 6, 7}, db.ids("BTCUSDT", false))
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, errs, 1)
+	require.ErrorIs(t, errs[0], errStreamClosed)
+}
+
+type fakeLimiter struct {
+	mu      sync.Mutex
+	weights []int
+}
+
+func (l *fakeLimiter) Wait(_ context.Context, weight int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.weights = append(l.weights, weight)
+	return nil
+}
+
+func TestWatcher_StartBackfillRetry(t *testing.T) {
+	ex, db := newFakeExchange(), newFakeStorage()
+	for id := int64(0); id < 10; id++ {
+		ex.history = append(ex.history, &models.Trade{ID: id})
+	}
+	var mu sync.Mutex
+	var errs []error
+	limiter := &fakeLimiter{}
+	w := NewWatcher(ex, db).
+		SetSymbols("BTCUSDT").
+		SetChunkSize(2).
+		SetLimiter(limiter).
+		SetReconnectBackoff(time.Millisecond, 10*time.Millisecond).
+		SetErrorHandler(func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Start(ctx) }()
+
+	<-ex.subscribed
+	ex.send("BTCUSDT", 0)
+	ex.drop()
+
+	// The failed backfill drops the connection, the missed trades are
+	// fetched after the next reconnect.
+	errTooManyRequests := errors.New("too many requests")
+	ex.mu.Lock()
+	ex.tradesErr = errTooManyRequests
+	ex.mu.Unlock()
+	<-ex.subscribed
+	ex.send("BTCUSDT", 4)
+	<-ex.subscribed
+	ex.send("BTCUSDT", 4)
+	require.Eventually(t, func() bool { return len(db.ids("BTCUSDT", false)) == 4 }, time.Second, time.Millisecond)
+	require.Equal(t, []int64{0, 1, 2, 3}, db.ids("BTCUSDT", false))
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, errs, 2)
+	require.ErrorIs(t, errs[0], errStreamClosed)
+	require.ErrorIs(t, errs[1], errTooManyRequests)
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	require.Equal(t, []int{25, 25}, limiter.weights)
+}
+
+func TestWatcher_shutdown(t *testing.T) {
+	ex, db := newFakeExchange(), newFakeStorage()
+	w := NewWatcher(ex, db).SetSymbols("BTCUSDT").SetChunkSize(10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- w.Start(ctx) }()
+	<-ex.subscribed
+
+	ex.send("BTCUSDT", 0)
+	require.Eventually(t, func() bool { return bufferedTrades.Get("BTCUSDT").String() == "1" }, time.Second, time.Millisecond)
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+	require.Equal(t, []int64{0}, db.ids("BTCUSDT", false))
+	require.Equal(t, "0", bufferedTrades.Get("BTCUSDT").String())
+}